@@ -5,9 +5,13 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image/png"
 	"io"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/getlantern/systray"
@@ -34,6 +38,11 @@ type App struct {
 	hueCtrl      *lights.HueController
 	elgatoCtrl   *lights.ElgatoController
 	goveeCtrl    *lights.GoveeController
+	wledCtrl     *lights.WLEDController
+	nanoleafCtrl *lights.NanoleafController
+	yeelightCtrl *lights.YeelightController
+	dmxCtrl      *lights.DMXController
+	virtualCtrl  *lights.VirtualController
 
 	screenSyncEngine      *screensync.Engine
 	screenSyncActiveScene string // sceneID of the running screen sync scene
@@ -66,15 +75,55 @@ func (a *App) startup(ctx context.Context) {
 	a.hueCtrl = lights.NewHueController()
 	a.elgatoCtrl = lights.NewElgatoController()
 	a.goveeCtrl = lights.NewGoveeController()
+	a.wledCtrl = lights.NewWLEDController()
+	a.nanoleafCtrl = lights.NewNanoleafController()
+	a.yeelightCtrl = lights.NewYeelightController()
+	a.dmxCtrl = lights.NewDMXController()
+	a.virtualCtrl = lights.NewVirtualController()
 
 	a.lightManager.RegisterController(a.lifxCtrl)
 	a.lightManager.RegisterController(a.hueCtrl)
 	a.lightManager.RegisterController(a.elgatoCtrl)
 	a.lightManager.RegisterController(a.goveeCtrl)
+	a.lightManager.RegisterController(a.wledCtrl)
+	a.lightManager.RegisterController(a.nanoleafCtrl)
+	a.lightManager.RegisterController(a.yeelightCtrl)
+	a.lightManager.RegisterController(a.dmxCtrl)
+	a.lightManager.RegisterController(a.virtualCtrl)
+
+	// State changes reach the UI without polling. Screen sync frames are
+	// left out: the UI follows those through screensync:colors.
+	a.lightManager.Subscribe(func(ch lights.DeviceStateChange) {
+		if ch.Source != lights.SourceScreenSync {
+			runtime.EventsEmit(a.ctx, "device:state", ch)
+		}
+	})
+	// Lights added or removed by a bridge are persisted straight away.
+	a.lightManager.OnDeviceEvent(func(ev lights.DeviceEvent) {
+		switch ev.Type {
+		case lights.DeviceEventAdded, lights.DeviceEventRemoved:
+			if err := a.store.SetDevices(a.lightManager.GetDevices()); err != nil {
+				runtime.LogWarningf(a.ctx, "Failed to save devices: %v", err)
+			}
+			runtime.EventsEmit(a.ctx, "devices:changed", ev)
+		case lights.DeviceEventOnline, lights.DeviceEventOffline:
+			runtime.EventsEmit(a.ctx, "device:reachability", ev)
+		case lights.DeviceEventRenamed:
+			// Scenes and groups still refer to the old IP-based ID.
+			if err := a.store.MigrateDeviceIDs(map[string]string{ev.PreviousID: ev.DeviceID}); err != nil {
+				runtime.LogWarningf(a.ctx, "Failed to migrate device %s: %v", ev.PreviousID, err)
+			}
+			runtime.EventsEmit(a.ctx, "devices:changed", ev)
+		}
+	})
 
 	bridges := a.store.GetHueBridges()
 	for _, bridge := range bridges {
-		if err := a.hueCtrl.AddBridge(bridge.IP, bridge.Username); err != nil {
+		if err := a.hueCtrl.AddBridge(lights.HueBridge{
+			IP:        bridge.IP,
+			Username:  bridge.Username,
+			ClientKey: bridge.ClientKey,
+		}); err != nil {
 			runtime.LogWarningf(ctx, "Failed to add Hue bridge %s: %v", bridge.IP, err)
 		}
 	}
@@ -87,9 +136,48 @@ func (a *App) startup(ctx context.Context) {
 		hueCancel()
 	}
 
+	// Nanoleaf panel layouts are read at discovery; screen sync needs them
+	// before the first scan.
+	if nanoleafs := a.store.GetNanoleafDevices(); len(nanoleafs) > 0 {
+		for _, d := range nanoleafs {
+			a.nanoleafCtrl.AddDevice(d.IP, d.Token)
+		}
+		nlCtx, nlCancel := context.WithTimeout(ctx, 5*time.Second)
+		if discovered, err := a.nanoleafCtrl.Discover(nlCtx); err == nil && len(discovered) > 0 {
+			runtime.LogInfof(ctx, "Loaded %d Nanoleaf device(s)", len(discovered))
+		}
+		nlCancel()
+	}
+
+	// Elgato lights are identified by serial; register their last known
+	// addresses so they answer before the first scan.
+	for _, d := range a.store.GetDevices() {
+		if d.Brand == lights.BrandElgato {
+			a.elgatoCtrl.AddKnownDevice(d.ID, d.LastIP)
+		}
+	}
+
+	a.dmxCtrl.SetFixtures(a.store.GetDMXFixtures())
+	a.virtualCtrl.SetDevices(a.store.GetVirtualDevices())
+
 	a.lightManager.SetDevices(a.store.GetDevices())
+	a.lightManager.SetGroups(a.store.GetGroups())
+	a.lightManager.SetManualAddresses(a.store.GetManualAddresses())
+	a.lightManager.StartHealthChecks(ctx)
+
+	// Lights added by address are out of reach of broadcast discovery;
+	// probe them directly so they answer before the first scan.
+	if len(a.store.GetManualAddresses()) > 0 {
+		go func() {
+			probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			if found := a.lightManager.ProbeManualAddresses(probeCtx); len(found) > 0 {
+				runtime.LogInfof(ctx, "Found %d device(s) at manual addresses", len(found))
+			}
+		}()
+	}
 
-	a.scanner = discovery.NewScanner(a.lightManager, a.elgatoCtrl)
+	a.scanner = discovery.NewScanner(a.lightManager)
 	a.sceneManager = scenes.NewManager(a.store, a.lightManager)
 	a.sceneManager.OnChange(func(scene store.Scene) {
 		runtime.EventsEmit(a.ctx, "scene:active", scene)
@@ -176,8 +264,27 @@ func (a *App) GetDevices() []lights.Device {
 	return a.lightManager.GetDevices()
 }
 
+// AddDeviceByAddress adds a light discovery can't reach, such as one on
+// another subnet, by probing brand's protocol at address (host or
+// host:port). The address is saved so later scans try it again.
+func (a *App) AddDeviceByAddress(brand lights.Brand, address string) (lights.Device, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 10*time.Second)
+	defer cancel()
+	d, err := a.lightManager.AddDeviceByAddress(ctx, brand, address)
+	if err != nil {
+		return d, err
+	}
+	if err := a.store.SetManualAddresses(a.lightManager.ManualAddresses()); err != nil {
+		return d, err
+	}
+	return d, a.store.SetDevices(a.lightManager.GetDevices())
+}
+
 func (a *App) RemoveDevice(deviceID string) error {
 	a.lightManager.RemoveDevice(deviceID)
+	if err := a.store.SetManualAddresses(a.lightManager.ManualAddresses()); err != nil {
+		return err
+	}
 	return a.store.SetDevices(a.lightManager.GetDevices())
 }
 
@@ -186,6 +293,28 @@ func (a *App) SetDeviceRoom(deviceID, room string) error {
 	return a.store.SetDevices(a.lightManager.GetDevices())
 }
 
+// SetDeviceCalibration saves a device's colour calibration profile; nil
+// removes it.
+func (a *App) SetDeviceCalibration(deviceID string, cal *lights.Calibration) error {
+	if err := a.lightManager.SetCalibration(deviceID, cal); err != nil {
+		return err
+	}
+	return a.store.SetDevices(a.lightManager.GetDevices())
+}
+
+// ShowCalibrationColor sends one step of the reference sequence to a device,
+// with its calibration applied, and returns what was shown.
+func (a *App) ShowCalibrationColor(deviceID string, step int) (lights.ReferenceColor, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	defer cancel()
+	return a.lightManager.ShowReference(ctx, deviceID, step)
+}
+
+// GetDeviceCapabilities returns the capability descriptor for a device.
+func (a *App) GetDeviceCapabilities(deviceID string) (lights.Capabilities, error) {
+	return a.lightManager.Capabilities(deviceID)
+}
+
 // --- Light Control ---
 
 func (a *App) SetLightState(deviceID string, state lights.DeviceState) error {
@@ -212,6 +341,79 @@ func (a *App) TurnOffLight(deviceID string) error {
 	return a.lightManager.TurnOff(ctx, deviceID)
 }
 
+// IdentifyLight makes a light blink so it can be found in the room. Lights
+// without a native identify are flashed and put back as they were.
+func (a *App) IdentifyLight(deviceID string) error {
+	ctx, cancel := context.WithTimeout(a.ctx, 10*time.Second)
+	defer cancel()
+	return a.lightManager.Identify(ctx, deviceID)
+}
+
+// --- Groups ---
+
+func (a *App) GetGroups() []lights.Group {
+	return a.lightManager.Groups()
+}
+
+// SaveGroup adds a group, or replaces the one with the same ID.
+func (a *App) SaveGroup(group lights.Group) (lights.Group, error) {
+	if strings.TrimSpace(group.Name) == "" {
+		return group, fmt.Errorf("group name is required")
+	}
+	if group.ID == "" {
+		group.ID = uuid.New().String()
+	}
+	if err := a.store.UpsertGroup(group); err != nil {
+		return group, err
+	}
+	a.lightManager.SetGroups(a.store.GetGroups())
+	return group, nil
+}
+
+// DeleteGroup removes a group and drops it from every scene.
+func (a *App) DeleteGroup(id string) error {
+	if err := a.store.DeleteGroup(id); err != nil {
+		return err
+	}
+	a.lightManager.SetGroups(a.store.GetGroups())
+	return nil
+}
+
+// SetGroupState applies state to every light in the group. The result maps
+// each light to its error message, empty on success.
+func (a *App) SetGroupState(groupID string, state lights.DeviceState) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	defer cancel()
+	return groupResults(a.lightManager.SetGroupState(ctx, groupID, state))
+}
+
+func (a *App) TurnOnGroup(groupID string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	defer cancel()
+	return groupResults(a.lightManager.TurnOnGroup(ctx, groupID))
+}
+
+func (a *App) TurnOffGroup(groupID string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	defer cancel()
+	return groupResults(a.lightManager.TurnOffGroup(ctx, groupID))
+}
+
+// groupResults converts per-device errors to messages for the frontend.
+func groupResults(results map[string]error, err error) (map[string]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(results))
+	for id, e := range results {
+		out[id] = ""
+		if e != nil {
+			out[id] = e.Error()
+		}
+	}
+	return out, nil
+}
+
 // --- Scenes ---
 
 func (a *App) GetScenes() []store.Scene {
@@ -256,8 +458,9 @@ func (a *App) ActivateScene(id string) error {
 
 	if scene.Trigger == "screen_sync" && scene.ScreenSync != nil {
 		store.NormalizeScreenSyncConfig(scene.ScreenSync)
+		deviceIDs := a.lightManager.ResolveDeviceIDs(scene.ScreenSync.DeviceIDs)
 		// Capture pre-sync device states for later restore.
-		a.preSyncStates = a.captureDeviceStates(scene.ScreenSync.DeviceIDs)
+		a.preSyncStates = a.captureDeviceStates(deviceIDs)
 		// Emit scene:active without applying static device states.
 		if err := a.sceneManager.MarkActive(id); err != nil {
 			return err
@@ -265,7 +468,7 @@ func (a *App) ActivateScene(id string) error {
 		a.screenSyncActiveScene = id
 		// Blackout, start engine immediately. Engine calibrates (runs pipeline
 		// without sending) for 2s, then fades brightness up.
-		a.blackoutDevices(scene.ScreenSync.DeviceIDs)
+		a.blackoutDevices(deviceIDs)
 		return a.screenSyncEngine.Start(*scene.ScreenSync)
 	}
 
@@ -289,16 +492,18 @@ func (a *App) captureDeviceStates(deviceIDs []string) map[string]lights.DeviceSt
 
 // blackoutDevices sets all given devices to brightness 0 (lights on, fully dimmed).
 func (a *App) blackoutDevices(deviceIDs []string) {
-	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(lights.WithSource(a.ctx, lights.SourceScreenSync), 5*time.Second)
 	defer cancel()
 	state := lights.DeviceState{
 		On:         true,
 		Brightness: 0,
 		Color:      &lights.Color{H: 0, S: 0, B: 1.0},
-	}
+	}.WithTransition(0)
+	states := make(map[string]lights.DeviceState, len(deviceIDs))
 	for _, id := range deviceIDs {
-		_ = a.lightManager.SetDeviceState(ctx, id, state)
+		states[id] = state
 	}
+	_ = a.lightManager.SetDeviceStates(ctx, states)
 }
 
 // stopScreenSync stops the engine and restores pre-sync light states.
@@ -310,9 +515,7 @@ func (a *App) stopScreenSync() {
 	}
 	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
 	defer cancel()
-	for id, state := range a.preSyncStates {
-		_ = a.lightManager.SetDeviceState(ctx, id, state)
-	}
+	_ = a.lightManager.SetDeviceStates(ctx, a.preSyncStates)
 	a.preSyncStates = nil
 }
 
@@ -395,14 +598,25 @@ func (a *App) UpdateSettings(settings store.Settings) error {
 // --- Hue Bridge ---
 
 func (a *App) AddHueBridge(ip, username string) error {
-	if err := a.hueCtrl.AddBridge(ip, username); err != nil {
+	return a.addHueBridge(ip, username, "")
+}
+
+// addHueBridge registers and persists a bridge. clientKey is only known when
+// LightSync did the pairing itself and enables Entertainment streaming.
+func (a *App) addHueBridge(ip, username, clientKey string) error {
+	if err := a.hueCtrl.AddBridge(lights.HueBridge{
+		IP:        ip,
+		Username:  username,
+		ClientKey: clientKey,
+	}); err != nil {
 		return err
 	}
 	bridges := a.store.GetHueBridges()
 	bridges = append(bridges, store.HueBridge{
-		ID:       uuid.New().String(),
-		IP:       ip,
-		Username: username,
+		ID:        uuid.New().String(),
+		IP:        ip,
+		Username:  username,
+		ClientKey: clientKey,
 	})
 	return a.store.SetHueBridges(bridges)
 }
@@ -463,7 +677,8 @@ func (a *App) PairHueBridge(ip string) PairResult {
 
 	var results []struct {
 		Success *struct {
-			Username string `json:"username"`
+			Username  string `json:"username"`
+			ClientKey string `json:"clientkey"`
 		} `json:"success,omitempty"`
 		Error *struct {
 			Type        int    `json:"type"`
@@ -487,7 +702,7 @@ func (a *App) PairHueBridge(ip string) PairResult {
 
 	if results[0].Success != nil && results[0].Success.Username != "" {
 		username := results[0].Success.Username
-		if err := a.AddHueBridge(ip, username); err != nil {
+		if err := a.addHueBridge(ip, username, results[0].Success.ClientKey); err != nil {
 			return PairResult{Error: fmt.Sprintf("paired but failed to save: %v", err)}
 		}
 		return PairResult{Success: true, Username: username}
@@ -496,6 +711,191 @@ func (a *App) PairHueBridge(ip string) PairResult {
 	return PairResult{Error: "unexpected response from bridge"}
 }
 
+// --- Nanoleaf ---
+
+func (a *App) GetNanoleafDevices() []store.NanoleafDevice {
+	return a.store.GetNanoleafDevices()
+}
+
+func (a *App) DiscoverNanoleafDevices() []discovery.DiscoveredNanoleaf {
+	ctx, cancel := context.WithTimeout(a.ctx, 10*time.Second)
+	defer cancel()
+	devices := a.scanner.DiscoverNanoleafDevices(ctx)
+	if devices == nil {
+		return []discovery.DiscoveredNanoleaf{}
+	}
+	return devices
+}
+
+// PairNanoleaf requests a token from the device at ip. Like PairHueBridge the
+// frontend polls it; it fails with "pairing mode not enabled" until the
+// power button has been held for 5–7 seconds.
+func (a *App) PairNanoleaf(ip string) PairResult {
+	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	defer cancel()
+	token, err := lights.PairNanoleaf(ctx, net.JoinHostPort(ip, strconv.Itoa(lights.NanoleafAPIPort)))
+	if errors.Is(err, lights.ErrNanoleafNotPairing) {
+		return PairResult{Error: err.Error()}
+	}
+	if err != nil {
+		return PairResult{Error: fmt.Sprintf("cannot reach device: %v", err)}
+	}
+
+	a.nanoleafCtrl.AddDevice(ip, token)
+	devices := a.store.GetNanoleafDevices()
+	devices = append(devices, store.NanoleafDevice{
+		ID:    uuid.New().String(),
+		IP:    ip,
+		Token: token,
+	})
+	if err := a.store.SetNanoleafDevices(devices); err != nil {
+		return PairResult{Error: fmt.Sprintf("paired but failed to save: %v", err)}
+	}
+	return PairResult{Success: true}
+}
+
+func (a *App) RemoveNanoleafDevice(id string) error {
+	devices := a.store.GetNanoleafDevices()
+	filtered := make([]store.NanoleafDevice, 0, len(devices))
+	for _, d := range devices {
+		if d.ID == id {
+			a.nanoleafCtrl.RemoveDevice(d.IP)
+			continue
+		}
+		filtered = append(filtered, d)
+	}
+	return a.store.SetNanoleafDevices(filtered)
+}
+
+// --- DMX ---
+
+func (a *App) GetDMXFixtures() []lights.DMXFixture {
+	return a.store.GetDMXFixtures()
+}
+
+// SaveDMXFixture adds a fixture, or replaces the one with the same ID. The
+// fixture is listed as a light straight away since DMX has no discovery.
+func (a *App) SaveDMXFixture(fixture lights.DMXFixture) (lights.DMXFixture, error) {
+	if fixture.Universe < 0 || fixture.StartChannel < 1 || fixture.StartChannel > 512 {
+		return fixture, fmt.Errorf("invalid universe %d or start channel %d", fixture.Universe, fixture.StartChannel)
+	}
+	if fixture.ID == "" {
+		fixture.ID = uuid.New().String()
+	}
+	fixtures := a.store.GetDMXFixtures()
+	replaced := false
+	for i, f := range fixtures {
+		if f.ID == fixture.ID {
+			fixtures[i] = fixture
+			replaced = true
+		}
+	}
+	if !replaced {
+		fixtures = append(fixtures, fixture)
+	}
+	if err := a.store.SetDMXFixtures(fixtures); err != nil {
+		return fixture, err
+	}
+	a.dmxCtrl.SetFixtures(fixtures)
+	return fixture, a.syncConfiguredDevices(a.dmxCtrl)
+}
+
+func (a *App) RemoveDMXFixture(id string) error {
+	fixtures := a.store.GetDMXFixtures()
+	filtered := make([]lights.DMXFixture, 0, len(fixtures))
+	for _, f := range fixtures {
+		if f.ID != id {
+			filtered = append(filtered, f)
+		}
+	}
+	if err := a.store.SetDMXFixtures(filtered); err != nil {
+		return err
+	}
+	a.dmxCtrl.SetFixtures(filtered)
+	a.lightManager.RemoveDevice("dmx:" + id)
+	return a.syncConfiguredDevices(a.dmxCtrl)
+}
+
+// syncConfiguredDevices refreshes the device list entries of a brand whose
+// devices are defined by the user rather than discovered, keeping rooms.
+func (a *App) syncConfiguredDevices(ctrl lights.Controller) error {
+	devices, _ := ctrl.Discover(a.ctx)
+	rooms := make(map[string]string)
+	for _, d := range a.lightManager.GetDevices() {
+		rooms[d.ID] = d.Room
+	}
+	for i := range devices {
+		devices[i].Room = rooms[devices[i].ID]
+	}
+	a.lightManager.SetDevices(devices)
+	return a.store.SetDevices(a.lightManager.GetDevices())
+}
+
+// --- Virtual lights ---
+
+func (a *App) GetVirtualDevices() []lights.VirtualDevice {
+	return a.store.GetVirtualDevices()
+}
+
+// SaveVirtualDevice adds a simulated light, or replaces the one with the
+// same ID, and lists it straight away.
+func (a *App) SaveVirtualDevice(device lights.VirtualDevice) (lights.VirtualDevice, error) {
+	if device.PacketLoss < 0 || device.PacketLoss > 1 {
+		return device, fmt.Errorf("packet loss must be between 0 and 1")
+	}
+	if device.ID == "" {
+		device.ID = uuid.New().String()
+	}
+	devices := a.store.GetVirtualDevices()
+	replaced := false
+	for i, d := range devices {
+		if d.ID == device.ID {
+			devices[i] = device
+			replaced = true
+		}
+	}
+	if !replaced {
+		devices = append(devices, device)
+	}
+	if err := a.store.SetVirtualDevices(devices); err != nil {
+		return device, err
+	}
+	a.virtualCtrl.SetDevices(devices)
+	return device, a.syncConfiguredDevices(a.virtualCtrl)
+}
+
+func (a *App) RemoveVirtualDevice(id string) error {
+	devices := a.store.GetVirtualDevices()
+	filtered := make([]lights.VirtualDevice, 0, len(devices))
+	for _, d := range devices {
+		if d.ID != id {
+			filtered = append(filtered, d)
+		}
+	}
+	if err := a.store.SetVirtualDevices(filtered); err != nil {
+		return err
+	}
+	a.virtualCtrl.SetDevices(filtered)
+	a.lightManager.RemoveDevice("virtual:" + id)
+	return a.syncConfiguredDevices(a.virtualCtrl)
+}
+
+// GetCommandHistory returns the commands recorded for a virtual light, or
+// for all of them when deviceID is empty, oldest first.
+func (a *App) GetCommandHistory(deviceID string) []lights.CommandRecord {
+	records := a.lightManager.CommandHistory(deviceID)
+	if records == nil {
+		return []lights.CommandRecord{}
+	}
+	return records
+}
+
+// GetCommandMetrics returns, per device, how many commands were sent and how
+// many were coalesced or dropped while waiting for the device's rate budget.
+func (a *App) GetCommandMetrics() map[string]lights.CommandMetrics {
+	return a.lightManager.CommandMetrics()
+}
+
 // --- Screen Sync ---
 
 // ScreenSyncState describes the current engine state returned to the frontend.
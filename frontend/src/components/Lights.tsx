@@ -63,7 +63,7 @@ export function Lights() {
           <h3 className="text-lg font-semibold">No Lights Found</h3>
           <p className="text-sm text-muted-foreground mt-2 max-w-md">
             Go to Settings and scan your network to discover LIFX, Hue, Elgato,
-            and Govee lights.
+            Govee, WLED, Nanoleaf, and Yeelight lights.
           </p>
         </Card>
       )}
@@ -129,6 +129,9 @@ export function Lights() {
             onRemove={() =>
               openRoomDeviceId && lightActions.removeDevice(openRoomDeviceId)
             }
+            onIdentify={() =>
+              openRoomDeviceId && lightActions.identifyLight(openRoomDeviceId)
+            }
             onClose={() => setOpenRoomDeviceId(null)}
           />
         );
@@ -197,6 +197,9 @@ export function LightsPopupPage() {
             onRemove={() =>
               openRoomDeviceId && lightActions.removeDevice(openRoomDeviceId)
             }
+            onIdentify={() =>
+              openRoomDeviceId && lightActions.identifyLight(openRoomDeviceId)
+            }
             onClose={() => setOpenRoomDeviceId(null)}
           />
         );
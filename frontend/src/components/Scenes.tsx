@@ -155,6 +155,16 @@ export function Scenes() {
 
   useEffect(() => { refresh(); }, [refresh]);
 
+  // Discovery can rename devices, rewriting the scenes that reference them.
+  useEffect(() => {
+    const off = EventsOn("devices:changed", () => {
+      GetScenes()
+        .then((s) => setScenes(s || []))
+        .catch(() => {});
+    });
+    return () => { off?.(); };
+  }, []);
+
   // Subscribe to screen sync engine state changes.
   // Use returned unsubscribe so we don't remove the sidebar widget's listener.
   useEffect(() => {
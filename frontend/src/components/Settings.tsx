@@ -6,7 +6,7 @@ import { Toggle } from "@/components/ui/Toggle";
 import { Slider } from "@/components/ui/Slider";
 import type { Settings as SettingsType, Device } from "@/lib/types";
 import { APP_VERSION } from "@/lib/types";
-import { store } from "../../wailsjs/go/models";
+import { lights, store } from "../../wailsjs/go/models";
 import {
   GetSettings,
   UpdateSettings,
@@ -14,6 +14,16 @@ import {
   DiscoverHueBridges,
   PairHueBridge,
   RemoveHueBridge,
+  GetNanoleafDevices,
+  DiscoverNanoleafDevices,
+  PairNanoleaf,
+  RemoveNanoleafDevice,
+  GetDMXFixtures,
+  SaveDMXFixture,
+  RemoveDMXFixture,
+  GetVirtualDevices,
+  SaveVirtualDevice,
+  RemoveVirtualDevice,
 } from "../../wailsjs/go/main/App";
 import { lightActions } from "@/hooks/useLightStore";
 import { getBrandInfo } from "@/lib/brands";
@@ -36,6 +46,62 @@ interface DiscoveredBridge {
   name: string;
 }
 
+interface NanoleafInfo {
+  id: string;
+  ip: string;
+}
+
+const DMX_LAYOUTS: { value: string; label: string }[] = [
+  { value: "rgb", label: "RGB" },
+  { value: "rgbw", label: "RGBW" },
+  { value: "drgb", label: "Dimmer + RGB" },
+  { value: "cct", label: "CCT (warm/cool)" },
+];
+
+const emptyDMXFixture = (): lights.DMXFixture =>
+  lights.DMXFixture.createFrom({
+    id: "",
+    name: "",
+    protocol: "artnet",
+    host: "",
+    universe: 0,
+    startChannel: 1,
+    layout: "rgb",
+    pixels: 1,
+    gamma: 2.2,
+  });
+
+// Capability presets for simulated lights.
+const VIRTUAL_KINDS: { value: string; label: string; caps: Partial<lights.Capabilities> }[] = [
+  { value: "color", label: "Color bulb", caps: { color: true, kelvin: true, minKelvin: 2500, maxKelvin: 9000 } },
+  { value: "white", label: "Tunable white", caps: { color: false, kelvin: true, minKelvin: 2700, maxKelvin: 6500 } },
+  { value: "strip", label: "Color strip (10 segments)", caps: { color: true, kelvin: true, minKelvin: 2500, maxKelvin: 9000, segments: 10 } },
+];
+
+interface VirtualForm {
+  name: string;
+  kind: string;
+  latencyMs: number;
+  packetLossPct: number;
+  maxCommandRate: number;
+}
+
+const emptyVirtualForm = (): VirtualForm => ({
+  name: "",
+  kind: "color",
+  latencyMs: 0,
+  packetLossPct: 0,
+  maxCommandRate: 0,
+});
+
+// Brands whose lights can be added by address when discovery can't reach them.
+const ADDRESS_BRANDS: { value: string; label: string; placeholder: string }[] = [
+  { value: "lifx", label: "LIFX", placeholder: "192.168.20.14" },
+  { value: "govee", label: "Govee", placeholder: "192.168.20.15" },
+  { value: "elgato", label: "Elgato", placeholder: "192.168.20.16:9123" },
+  { value: "hue", label: "Hue Bridge", placeholder: "192.168.20.2" },
+];
+
 type AddBridgeStep = "idle" | "scanning" | "results" | "pairing" | "paired";
 
 export function Settings() {
@@ -97,22 +163,55 @@ export function Settings() {
     return () => { if (scanCleanupRef.current) scanCleanupRef.current(); };
   }, []);
 
+  // Adding a light by address, for networks discovery can't cross.
+  const [addrBrand, setAddrBrand] = useState(ADDRESS_BRANDS[0].value);
+  const [address, setAddress] = useState("");
+  const [addingAddress, setAddingAddress] = useState(false);
+  const [addressResult, setAddressResult] = useState<{ ok: boolean; message: string } | null>(null);
+
   const [step, setStep] = useState<AddBridgeStep>("idle");
   const [discovered, setDiscovered] = useState<DiscoveredBridge[]>([]);
   const [pairingIp, setPairingIp] = useState("");
   const [pairError, setPairError] = useState("");
   const pairIntervalRef = useRef<ReturnType<typeof setInterval> | null>(null);
 
+  // Nanoleaf pairing state (same flow as Hue bridges).
+  const [nanoleafs, setNanoleafs] = useState<NanoleafInfo[]>([]);
+  const [nlStep, setNlStep] = useState<AddBridgeStep>("idle");
+  const [nlDiscovered, setNlDiscovered] = useState<DiscoveredBridge[]>([]);
+  const [nlPairingIp, setNlPairingIp] = useState("");
+  const [nlPairError, setNlPairError] = useState("");
+  const nlPairIntervalRef = useRef<ReturnType<typeof setInterval> | null>(null);
+
+  // DMX fixtures are defined by hand; there is nothing to discover.
+  const [dmxFixtures, setDmxFixtures] = useState<lights.DMXFixture[]>([]);
+  const [dmxForm, setDmxForm] = useState<lights.DMXFixture | null>(null);
+  const [dmxError, setDmxError] = useState("");
+
+  // Simulated lights for demos and development without hardware.
+  const [virtualDevices, setVirtualDevices] = useState<lights.VirtualDevice[]>([]);
+  const [virtualForm, setVirtualForm] = useState<VirtualForm | null>(null);
+
   useEffect(() => {
     GetSettings().then(setSettings).catch(() => {});
     GetHueBridges()
       .then((b) => setBridges(b || []))
       .catch(() => {});
+    GetNanoleafDevices()
+      .then((d) => setNanoleafs(d || []))
+      .catch(() => {});
+    GetDMXFixtures()
+      .then((f) => setDmxFixtures(f || []))
+      .catch(() => {});
+    GetVirtualDevices()
+      .then((d) => setVirtualDevices(d || []))
+      .catch(() => {});
   }, []);
 
   useEffect(() => {
     return () => {
       if (pairIntervalRef.current) clearInterval(pairIntervalRef.current);
+      if (nlPairIntervalRef.current) clearInterval(nlPairIntervalRef.current);
     };
   }, []);
 
@@ -186,6 +285,27 @@ export function Settings() {
     [stopPairing],
   );
 
+  const handleAddByAddress = useCallback(async () => {
+    const addr = address.trim();
+    setAddingAddress(true);
+    setAddressResult(null);
+    try {
+      const d = await lightActions.addDeviceByAddress(addrBrand, addr);
+      setAddressResult({ ok: true, message: `Added ${d.name}` });
+      setAddress("");
+    } catch (e) {
+      const message = String(e);
+      if (addrBrand === "hue" && message.includes("paired first")) {
+        // The bridge answered; pairing it adds its lights.
+        handlePair(addr);
+        setAddressResult({ ok: true, message: "Bridge found. Press its link button to pair it." });
+      } else {
+        setAddressResult({ ok: false, message });
+      }
+    }
+    setAddingAddress(false);
+  }, [address, addrBrand, handlePair]);
+
   const handleCancel = useCallback(() => {
     stopPairing();
     setStep("idle");
@@ -208,6 +328,133 @@ export function Settings() {
     }
   }, []);
 
+  const handleNanoleafScan = async () => {
+    setNlStep("scanning");
+    setNlDiscovered([]);
+    setNlPairError("");
+    try {
+      const found = await DiscoverNanoleafDevices();
+      setNlDiscovered(found || []);
+    } catch (e) {
+      console.error("Failed to scan for Nanoleaf:", e);
+      setNlDiscovered([]);
+    }
+    setNlStep("results");
+  };
+
+  const stopNanoleafPairing = useCallback(() => {
+    if (nlPairIntervalRef.current) {
+      clearInterval(nlPairIntervalRef.current);
+      nlPairIntervalRef.current = null;
+    }
+  }, []);
+
+  const handleNanoleafPair = useCallback(
+    (ip: string) => {
+      stopNanoleafPairing();
+      setNlPairingIp(ip);
+      setNlPairError("");
+      setNlStep("pairing");
+
+      nlPairIntervalRef.current = setInterval(async () => {
+        try {
+          const result = await PairNanoleaf(ip);
+          if (result.success) {
+            stopNanoleafPairing();
+            setNlStep("paired");
+            const updated = await GetNanoleafDevices();
+            setNanoleafs(updated || []);
+            setTimeout(() => setNlStep("idle"), 2000);
+          } else if (
+            result.error &&
+            !result.error.includes("pairing mode not enabled")
+          ) {
+            stopNanoleafPairing();
+            setNlPairError(result.error);
+          }
+        } catch (e) {
+          stopNanoleafPairing();
+          setNlPairError("Failed to communicate with device");
+        }
+      }, 2000);
+    },
+    [stopNanoleafPairing],
+  );
+
+  const handleNanoleafCancel = useCallback(() => {
+    stopNanoleafPairing();
+    setNlStep("idle");
+    setNlDiscovered([]);
+    setNlPairingIp("");
+    setNlPairError("");
+  }, [stopNanoleafPairing]);
+
+  const handleRemoveNanoleaf = useCallback(async (id: string) => {
+    try {
+      await RemoveNanoleafDevice(id);
+      setNanoleafs((prev) => prev.filter((d) => d.id !== id));
+    } catch (e) {
+      console.error("Failed to remove Nanoleaf device:", e);
+    }
+  }, []);
+
+  const handleSaveDMX = useCallback(async () => {
+    if (!dmxForm) return;
+    try {
+      const saved = await SaveDMXFixture(dmxForm);
+      setDmxFixtures((prev) => [...prev.filter((f) => f.id !== saved.id), saved]);
+      setDmxForm(null);
+      setDmxError("");
+      await lightActions.refreshDevices();
+    } catch (e) {
+      setDmxError(String(e));
+    }
+  }, [dmxForm]);
+
+  const handleRemoveDMX = useCallback(async (id: string) => {
+    try {
+      await RemoveDMXFixture(id);
+      setDmxFixtures((prev) => prev.filter((f) => f.id !== id));
+      await lightActions.refreshDevices();
+    } catch (e) {
+      console.error("Failed to remove DMX fixture:", e);
+    }
+  }, []);
+
+  const handleSaveVirtual = useCallback(async () => {
+    if (!virtualForm) return;
+    const kind = VIRTUAL_KINDS.find((k) => k.value === virtualForm.kind) ?? VIRTUAL_KINDS[0];
+    try {
+      const saved = await SaveVirtualDevice(
+        lights.VirtualDevice.createFrom({
+          id: "",
+          name: virtualForm.name.trim(),
+          capabilities: { ...kind.caps, maxCommandRate: virtualForm.maxCommandRate || undefined },
+          latencyMs: virtualForm.latencyMs,
+          packetLoss: virtualForm.packetLossPct / 100,
+        }),
+      );
+      setVirtualDevices((prev) => [...prev, saved]);
+      setVirtualForm(null);
+      await lightActions.refreshDevices();
+    } catch (e) {
+      console.error("Failed to save virtual light:", e);
+    }
+  }, [virtualForm]);
+
+  const handleRemoveVirtual = useCallback(async (id: string) => {
+    try {
+      await RemoveVirtualDevice(id);
+      setVirtualDevices((prev) => prev.filter((d) => d.id !== id));
+      await lightActions.refreshDevices();
+    } catch (e) {
+      console.error("Failed to remove virtual light:", e);
+    }
+  }, []);
+
+  const updateDmxForm = (patch: Partial<lights.DMXFixture>) =>
+    setDmxForm((prev) => (prev ? lights.DMXFixture.createFrom({ ...prev, ...patch }) : prev));
+
   return (
     <div className="space-y-8">
       <div>
@@ -407,6 +654,397 @@ export function Settings() {
         ))}
       </Card>
 
+      <Card className="space-y-6">
+        <div className="flex items-center justify-between">
+          <h3 className="text-lg font-semibold">Nanoleaf</h3>
+          {nlStep === "idle" && (
+            <Button variant="outline" size="sm" onClick={handleNanoleafScan}>
+              <Plus className="h-4 w-4" />
+              Add Nanoleaf
+            </Button>
+          )}
+        </div>
+
+        {nlStep === "scanning" && (
+          <div className="rounded-lg p-6 flex flex-col items-center gap-3">
+            <Loader2 className="h-6 w-6 animate-spin text-primary" />
+            <p className="text-sm font-medium">Scanning network for Nanoleaf devices...</p>
+          </div>
+        )}
+
+        {nlStep === "results" && (
+          <div className="rounded-lg p-4 space-y-3">
+            {nlDiscovered.length === 0 ? (
+              <div className="text-center py-4 space-y-2">
+                <Search className="h-6 w-6 mx-auto text-muted-foreground" />
+                <p className="text-sm font-medium">No Nanoleaf devices found</p>
+                <p className="text-xs text-muted-foreground">
+                  Make sure your panels are powered on and connected to the same network.
+                </p>
+              </div>
+            ) : (
+              <div className="space-y-2">
+                {nlDiscovered.map((d) => (
+                  <div
+                    key={d.ip}
+                    className="flex items-center justify-between rounded-lg p-3"
+                  >
+                    <div className="flex items-center gap-3">
+                      <Wifi className="h-4 w-4 text-primary" />
+                      <div>
+                        <p className="text-sm font-medium">{d.name || "Nanoleaf"}</p>
+                        <p className="text-xs text-muted-foreground">{d.ip}</p>
+                      </div>
+                    </div>
+                    {nanoleafs.some((n) => n.ip === d.ip) ? (
+                      <span className="text-xs text-muted-foreground">Already added</span>
+                    ) : (
+                      <Button size="sm" onClick={() => handleNanoleafPair(d.ip)}>
+                        Pair
+                      </Button>
+                    )}
+                  </div>
+                ))}
+              </div>
+            )}
+            <div className="flex gap-2">
+              <Button size="sm" variant="outline" onClick={handleNanoleafScan}>
+                <Search className="h-3 w-3" />
+                Scan Again
+              </Button>
+              <Button size="sm" variant="outline" onClick={handleNanoleafCancel}>
+                Cancel
+              </Button>
+            </div>
+          </div>
+        )}
+
+        {nlStep === "pairing" && (
+          <div className="rounded-lg p-6 flex flex-col items-center gap-3">
+            <Loader2 className="h-6 w-6 animate-spin text-primary" />
+            <p className="text-sm font-medium">Hold the power button for 5–7 seconds</p>
+            <p className="text-xs text-muted-foreground">
+              Waiting for Nanoleaf at {nlPairingIp} to enter pairing mode...
+            </p>
+            {nlPairError && (
+              <p className="text-xs text-destructive">{nlPairError}</p>
+            )}
+            <Button size="sm" variant="outline" onClick={handleNanoleafCancel}>
+              Cancel
+            </Button>
+          </div>
+        )}
+
+        {nlStep === "paired" && (
+          <div className="rounded-lg bg-green-500/5 p-6 flex flex-col items-center gap-2">
+            <p className="text-sm font-medium text-green-500">
+              Nanoleaf paired! Scan the network to add its panels.
+            </p>
+          </div>
+        )}
+
+        {nanoleafs.length === 0 && nlStep === "idle" && (
+          <p className="text-sm text-muted-foreground">
+            No Nanoleaf devices paired. Add one to control Nanoleaf panels.
+          </p>
+        )}
+
+        {nanoleafs.map((d) => (
+          <div
+            key={d.id || d.ip}
+            className="flex items-center justify-between rounded-lg border border-border p-3 group gap-3"
+          >
+            <div className="flex items-center gap-3 min-w-0">
+              <Wifi className="h-4 w-4 text-muted-foreground shrink-0" />
+              <p className="text-sm font-medium">{d.ip}</p>
+            </div>
+            <button
+              type="button"
+              title="Remove Nanoleaf"
+              onClick={() => handleRemoveNanoleaf(d.id)}
+              className="h-7 w-7 shrink-0 rounded-md flex items-center justify-center opacity-0 group-hover:opacity-100 transition-opacity text-muted-foreground hover:text-destructive hover:bg-destructive/10 focus:outline-none focus:opacity-100"
+            >
+              <Trash2 className="h-3.5 w-3.5" />
+            </button>
+          </div>
+        ))}
+      </Card>
+
+      <Card className="space-y-6">
+        <div className="flex items-center justify-between">
+          <h3 className="text-lg font-semibold">DMX (Art-Net / sACN)</h3>
+          {!dmxForm && (
+            <Button variant="outline" size="sm" onClick={() => setDmxForm(emptyDMXFixture())}>
+              <Plus className="h-4 w-4" />
+              Add Fixture
+            </Button>
+          )}
+        </div>
+
+        {dmxForm && (
+          <div className="rounded-lg p-4 space-y-3">
+            <div className="grid grid-cols-2 gap-2">
+              <div className="col-span-2">
+                <label className="text-xs text-muted-foreground mb-1 block">Name</label>
+                <input
+                  type="text"
+                  value={dmxForm.name}
+                  onChange={(e) => updateDmxForm({ name: e.target.value })}
+                  placeholder="Stage wash"
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Protocol</label>
+                <select
+                  value={dmxForm.protocol}
+                  onChange={(e) => updateDmxForm({ protocol: e.target.value })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                >
+                  <option value="artnet">Art-Net</option>
+                  <option value="sacn">sACN (E1.31)</option>
+                </select>
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Host</label>
+                <input
+                  type="text"
+                  value={dmxForm.host ?? ""}
+                  onChange={(e) => updateDmxForm({ host: e.target.value })}
+                  placeholder={dmxForm.protocol === "sacn" ? "Multicast" : "Broadcast"}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Universe</label>
+                <input
+                  type="number"
+                  min={0}
+                  value={dmxForm.universe}
+                  onChange={(e) => updateDmxForm({ universe: Number(e.target.value) })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Start channel</label>
+                <input
+                  type="number"
+                  min={1}
+                  max={512}
+                  value={dmxForm.startChannel}
+                  onChange={(e) => updateDmxForm({ startChannel: Number(e.target.value) })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Layout</label>
+                <select
+                  value={dmxForm.layout}
+                  onChange={(e) => updateDmxForm({ layout: e.target.value })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                >
+                  {DMX_LAYOUTS.map((l) => (
+                    <option key={l.value} value={l.value}>
+                      {l.label}
+                    </option>
+                  ))}
+                </select>
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Pixels</label>
+                <input
+                  type="number"
+                  min={1}
+                  value={dmxForm.pixels ?? 1}
+                  onChange={(e) => updateDmxForm({ pixels: Number(e.target.value) })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Gamma</label>
+                <input
+                  type="number"
+                  min={1}
+                  step={0.1}
+                  value={dmxForm.gamma ?? 1}
+                  onChange={(e) => updateDmxForm({ gamma: Number(e.target.value) })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+            </div>
+            {dmxError && <p className="text-xs text-destructive">{dmxError}</p>}
+            <div className="flex gap-2">
+              <Button size="sm" onClick={handleSaveDMX} disabled={!dmxForm.name.trim()}>
+                Save
+              </Button>
+              <Button
+                size="sm"
+                variant="outline"
+                onClick={() => {
+                  setDmxForm(null);
+                  setDmxError("");
+                }}
+              >
+                Cancel
+              </Button>
+            </div>
+          </div>
+        )}
+
+        {dmxFixtures.length === 0 && !dmxForm && (
+          <p className="text-sm text-muted-foreground">
+            No DMX fixtures defined. Add one to drive Art-Net or sACN fixtures and pixel controllers.
+          </p>
+        )}
+
+        {dmxFixtures.map((f) => (
+          <div
+            key={f.id}
+            className="flex items-center justify-between rounded-lg border border-border p-3 group gap-3"
+          >
+            <button
+              type="button"
+              onClick={() => setDmxForm(lights.DMXFixture.createFrom(f))}
+              className="flex items-center gap-3 min-w-0 text-left"
+            >
+              <Lightbulb className="h-4 w-4 text-muted-foreground shrink-0" />
+              <div className="min-w-0">
+                <p className="text-sm font-medium truncate">{f.name}</p>
+                <p className="text-xs text-muted-foreground">
+                  {f.protocol === "sacn" ? "sACN" : "Art-Net"} · universe {f.universe} · ch {f.startChannel} ·{" "}
+                  {DMX_LAYOUTS.find((l) => l.value === f.layout)?.label ?? f.layout}
+                </p>
+              </div>
+            </button>
+            <button
+              type="button"
+              title="Remove fixture"
+              onClick={() => handleRemoveDMX(f.id)}
+              className="h-7 w-7 shrink-0 rounded-md flex items-center justify-center opacity-0 group-hover:opacity-100 transition-opacity text-muted-foreground hover:text-destructive hover:bg-destructive/10 focus:outline-none focus:opacity-100"
+            >
+              <Trash2 className="h-3.5 w-3.5" />
+            </button>
+          </div>
+        ))}
+      </Card>
+
+      <Card className="space-y-6">
+        <div className="flex items-center justify-between">
+          <h3 className="text-lg font-semibold">Virtual Lights</h3>
+          {!virtualForm && (
+            <Button variant="outline" size="sm" onClick={() => setVirtualForm(emptyVirtualForm())}>
+              <Plus className="h-4 w-4" />
+              Add Virtual Light
+            </Button>
+          )}
+        </div>
+
+        {virtualForm && (
+          <div className="rounded-lg p-4 space-y-3">
+            <div className="grid grid-cols-2 gap-2">
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Name</label>
+                <input
+                  type="text"
+                  value={virtualForm.name}
+                  onChange={(e) => setVirtualForm({ ...virtualForm, name: e.target.value })}
+                  placeholder="Demo lamp"
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Type</label>
+                <select
+                  value={virtualForm.kind}
+                  onChange={(e) => setVirtualForm({ ...virtualForm, kind: e.target.value })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                >
+                  {VIRTUAL_KINDS.map((k) => (
+                    <option key={k.value} value={k.value}>
+                      {k.label}
+                    </option>
+                  ))}
+                </select>
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Latency (ms)</label>
+                <input
+                  type="number"
+                  min={0}
+                  value={virtualForm.latencyMs}
+                  onChange={(e) => setVirtualForm({ ...virtualForm, latencyMs: Number(e.target.value) })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div>
+                <label className="text-xs text-muted-foreground mb-1 block">Packet loss (%)</label>
+                <input
+                  type="number"
+                  min={0}
+                  max={100}
+                  value={virtualForm.packetLossPct}
+                  onChange={(e) => setVirtualForm({ ...virtualForm, packetLossPct: Number(e.target.value) })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+              <div className="col-span-2">
+                <label className="text-xs text-muted-foreground mb-1 block">
+                  Rate limit (commands/sec, 0 = unlimited)
+                </label>
+                <input
+                  type="number"
+                  min={0}
+                  step={0.5}
+                  value={virtualForm.maxCommandRate}
+                  onChange={(e) => setVirtualForm({ ...virtualForm, maxCommandRate: Number(e.target.value) })}
+                  className="w-full bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+                />
+              </div>
+            </div>
+            <div className="flex gap-2">
+              <Button size="sm" onClick={handleSaveVirtual} disabled={!virtualForm.name.trim()}>
+                Save
+              </Button>
+              <Button size="sm" variant="outline" onClick={() => setVirtualForm(null)}>
+                Cancel
+              </Button>
+            </div>
+          </div>
+        )}
+
+        {virtualDevices.length === 0 && !virtualForm && (
+          <p className="text-sm text-muted-foreground">
+            No virtual lights. Add one to try scenes and Screen Sync without hardware.
+          </p>
+        )}
+
+        {virtualDevices.map((d) => (
+          <div
+            key={d.id}
+            className="flex items-center justify-between rounded-lg border border-border p-3 group gap-3"
+          >
+            <div className="flex items-center gap-3 min-w-0">
+              <Lightbulb className="h-4 w-4 text-muted-foreground shrink-0" />
+              <div className="min-w-0">
+                <p className="text-sm font-medium truncate">{d.name}</p>
+                <p className="text-xs text-muted-foreground">
+                  {d.latencyMs || 0} ms · {Math.round((d.packetLoss || 0) * 100)}% loss
+                  {d.capabilities?.maxCommandRate ? ` · ${d.capabilities.maxCommandRate}/s` : ""}
+                </p>
+              </div>
+            </div>
+            <button
+              type="button"
+              title="Remove virtual light"
+              onClick={() => handleRemoveVirtual(d.id)}
+              className="h-7 w-7 shrink-0 rounded-md flex items-center justify-center opacity-0 group-hover:opacity-100 transition-opacity text-muted-foreground hover:text-destructive hover:bg-destructive/10 focus:outline-none focus:opacity-100"
+            >
+              <Trash2 className="h-3.5 w-3.5" />
+            </button>
+          </div>
+        ))}
+      </Card>
+
       <Card className="space-y-4">
         <div className="flex items-center justify-between">
           <h3 className="text-lg font-semibold">Discover Lights</h3>
@@ -420,9 +1058,45 @@ export function Settings() {
           </Button>
         </div>
         <p className="text-sm text-muted-foreground">
-          Scan your local network to find LIFX, Hue, Elgato, and Govee lights.
+          Scan your local network to find LIFX, Hue, Elgato, Govee, WLED, Nanoleaf, and Yeelight lights.
         </p>
 
+        <div className="space-y-2">
+          <p className="text-xs text-muted-foreground">
+            Lights on another subnet, or on Wi-Fi that blocks discovery, can be added by address.
+          </p>
+          <div className="flex gap-2">
+            <select
+              value={addrBrand}
+              onChange={(e) => setAddrBrand(e.target.value)}
+              className="bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+            >
+              {ADDRESS_BRANDS.map((b) => (
+                <option key={b.value} value={b.value}>
+                  {b.label}
+                </option>
+              ))}
+            </select>
+            <input
+              type="text"
+              value={address}
+              onChange={(e) => setAddress(e.target.value)}
+              onKeyDown={(e) => e.key === "Enter" && address.trim() && !addingAddress && handleAddByAddress()}
+              placeholder={ADDRESS_BRANDS.find((b) => b.value === addrBrand)?.placeholder}
+              className="flex-1 min-w-0 bg-background/50 border border-border rounded-lg px-3 py-1.5 text-sm focus:outline-none focus:ring-1 focus:ring-primary"
+            />
+            <Button variant="outline" size="sm" onClick={handleAddByAddress} disabled={!address.trim() || addingAddress}>
+              {addingAddress ? <Loader2 className="h-4 w-4 animate-spin" /> : <Plus className="h-4 w-4" />}
+              Add
+            </Button>
+          </div>
+          {addressResult && (
+            <p className={`text-xs ${addressResult.ok ? "text-green-500" : "text-destructive"}`}>
+              {addressResult.message}
+            </p>
+          )}
+        </div>
+
         {showScanCard && (
           <div className="rounded-lg bg-background/50 border border-border p-4 space-y-3">
             <div className="flex items-center gap-3">
@@ -480,7 +1154,7 @@ export function Settings() {
           <p>
             Monitors your webcam and automatically controls your smart lights.
           </p>
-          <p>Supports LIFX, Philips Hue, Elgato Key Light, and Govee.</p>
+          <p>Supports LIFX, Philips Hue, Elgato Key Light, Govee, WLED, Nanoleaf, Yeelight, and Art-Net/sACN DMX.</p>
           <p className="pt-2">
             Built with Wails, Go, React, and TypeScript.
           </p>
@@ -45,7 +45,7 @@ function DeviceInfoTooltip({ device }: { device: Device }) {
   const [visible, setVisible] = useState(false);
   const [pos, setPos] = useState({ top: 0, left: 0 });
   const anchorRef = useRef<HTMLDivElement>(null);
-  const minBright = device.brand === "elgato" ? 3 : 0;
+  const minBright = Math.round((device.capabilities?.minBrightness ?? 0) * 100);
   const tempRange =
     device.supportsKelvin && device.minKelvin && device.maxKelvin
       ? `${device.minKelvin.toLocaleString()}K – ${device.maxKelvin.toLocaleString()}K${device.kelvinStep && device.kelvinStep > 1 ? ` (step: ${device.kelvinStep}K)` : ""}`
@@ -80,6 +80,8 @@ function DeviceInfoTooltip({ device }: { device: Device }) {
         >
           <div className="font-medium text-foreground mb-2">Device info</div>
           <DeviceInfoRow label="IP" value={<span className="font-mono">{device.lastIp}</span>} />
+          <DeviceInfoRow label="Status" value={device.offline ? "Offline" : "Online"} />
+          {device.lastSeen && <DeviceInfoRow label="Last seen" value={new Date(device.lastSeen).toLocaleString()} />}
           {device.model && <DeviceInfoRow label="Model" value={device.model} />}
           {device.firmwareVersion && <DeviceInfoRow label="Firmware" value={device.firmwareVersion} />}
           <DeviceInfoRow label="Brightness" value={`${minBright}% – 100%`} />
@@ -113,7 +115,7 @@ export function LightCard({
   useEffect(() => {
     if (!on) setPanelOpen(false);
   }, [on]);
-  const minBrightness = device.brand === "elgato" ? 3 : 0;
+  const minBrightness = Math.round((device.capabilities?.minBrightness ?? 0) * 100);
   const hasColorControl = device.supportsColor || device.supportsKelvin;
   const lightAccent = accentColor(color, kelvin);
   const lightAccentRing = lightAccent.replace(/^rgb\(/, "rgba(").replace(/\)$/, ", 0.5)");
@@ -167,7 +169,9 @@ export function LightCard({
               <p className="font-medium text-sm leading-tight truncate">{device.name}</p>
               <DeviceInfoTooltip device={device} />
             </div>
-            {device.model && (
+            {device.offline ? (
+              <p className="text-xs text-destructive mt-0.5 truncate">Offline</p>
+            ) : device.model && (
               <p className="text-xs text-muted-foreground mt-0.5 truncate">{device.model}</p>
             )}
           </div>
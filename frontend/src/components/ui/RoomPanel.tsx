@@ -1,6 +1,6 @@
 import { useRef, useEffect, useState, type RefObject } from "react";
 import { createPortal } from "react-dom";
-import { Trash2, X } from "lucide-react";
+import { Lightbulb, Trash2, X } from "lucide-react";
 import { ROOM_PRESETS, getRoomIcon } from "@/lib/rooms";
 import { UNASSIGNED_KEY } from "@/lib/brands";
 
@@ -11,6 +11,8 @@ interface RoomPanelProps {
   deviceName: string;
   onRoomChange: (room: string) => void;
   onRemove: () => void;
+  /** Blink the light so it can be found in the room. */
+  onIdentify: () => void;
   onClose: () => void;
 }
 
@@ -21,6 +23,7 @@ export function RoomPanel({
   deviceName,
   onRoomChange,
   onRemove,
+  onIdentify,
   onClose,
 }: RoomPanelProps) {
   const panelRef = useRef<HTMLDivElement>(null);
@@ -146,7 +149,7 @@ export function RoomPanel({
         />
       </div>
 
-      {/* Divider + Remove / Save row */}
+      {/* Divider + Remove / Identify / Save row */}
       <div className="border-t border-border pt-2 flex items-center gap-2">
         <button
           type="button"
@@ -156,6 +159,14 @@ export function RoomPanel({
         >
           <Trash2 className="h-3.5 w-3.5" />
         </button>
+        <button
+          type="button"
+          title="Blink this light to find it"
+          onClick={onIdentify}
+          className="h-8 w-8 shrink-0 flex items-center justify-center rounded-lg text-muted-foreground hover:text-foreground hover:bg-background/50 transition-colors focus:outline-none focus:ring-2 focus:ring-ring"
+        >
+          <Lightbulb className="h-3.5 w-3.5" />
+        </button>
         <button
           type="button"
           onClick={handleSave}
@@ -3,6 +3,7 @@ import { EventsOn } from "../../wailsjs/runtime/runtime";
 import type { Device, DeviceState, Color, Scene } from "@/lib/types";
 import { DEFAULT_KELVIN } from "@/lib/types";
 import {
+  AddDeviceByAddress,
   GetDevices,
   GetLightState,
   GetScene,
@@ -10,6 +11,7 @@ import {
   GetLastSceneID,
   GetScreenSyncState,
   DiscoverLights,
+  IdentifyLight,
   RemoveDevice,
   SetDeviceRoom,
   SetLightState,
@@ -185,6 +187,13 @@ async function discoverLights(): Promise<Device[]> {
   return devs;
 }
 
+/** Add a light discovery can't reach by probing its address; errors are thrown to the caller. */
+async function addDeviceByAddress(brand: string, address: string): Promise<Device> {
+  const device = await AddDeviceByAddress(brand, address);
+  await refreshDevices();
+  return device;
+}
+
 async function toggleLight(deviceId: string, on: boolean) {
   state = { ...state, deviceOn: { ...state.deviceOn, [deviceId]: on } };
   emit();
@@ -455,6 +464,15 @@ async function removeDevice(deviceId: string) {
   }
 }
 
+/** Blink a light so it can be found in the room; its state is restored afterwards. */
+async function identifyLight(deviceId: string) {
+  try {
+    await IdentifyLight(deviceId);
+  } catch (e) {
+    console.error("Failed to identify light:", e);
+  }
+}
+
 function requestEditScene(sceneId: string) {
   state = { ...state, pendingEditSceneId: sceneId };
   emit();
@@ -495,6 +513,7 @@ function clearScreenSyncDevices() {
 export const lightActions = {
   refreshDevices,
   discoverLights,
+  addDeviceByAddress,
   requestEditScene,
   clearPendingEdit,
   applyScreenSyncColors,
@@ -506,6 +525,7 @@ export const lightActions = {
   setColor,
   setDeviceRoom,
   removeDevice,
+  identifyLight,
   applySceneStates,
   setActiveSceneOptimistic,
   clearActiveScene,
@@ -605,9 +625,54 @@ function setupScreenSyncToStoreBridge() {
   });
 }
 
+// device:state fires whenever the backend's last known state of a light
+// changes: commands from the UI, tray or scenes, and changes made outside
+// LightSync (Hue app, switches). The same grace periods as polling apply so an
+// in-flight user or scene change isn't overwritten by an older report.
+// devices:changed fires when a bridge adds or removes a light or discovery
+// moves a light to its hardware ID, and
+// device:reachability when health checks find a light offline or back online.
+function setupDeviceEventListeners() {
+  EventsOn("device:state", (ev: { deviceId?: string; state?: DeviceState; source?: string }) => {
+    const id = ev?.deviceId;
+    const s = ev?.state;
+    if (!id || !s) return;
+    const onOff = { ...state.deviceOn, [id]: s.on };
+    const bright = { ...state.brightness };
+    const temps = { ...state.kelvin };
+    const colors = { ...state.color };
+    if (!recentlySetByUser(id) && !recentlyAppliedByScene(id) && !screenSyncDeviceIds.has(id)) {
+      if (s.brightness != null) bright[id] = Math.round(s.brightness * 100);
+      if (s.color != null) {
+        colors[id] = s.color;
+        delete temps[id];
+      } else if (s.kelvin != null) {
+        temps[id] = s.kelvin;
+        delete colors[id];
+      }
+    }
+    state = { ...state, deviceOn: onOff, brightness: bright, kelvin: temps, color: colors };
+    emit();
+  });
+  EventsOn("devices:changed", () => {
+    refreshDevices();
+  });
+  EventsOn("device:reachability", (ev: { type?: string; deviceId?: string }) => {
+    const id = ev?.deviceId;
+    if (!id) return;
+    const offline = ev.type === "offline";
+    state = {
+      ...state,
+      devices: state.devices.map((d) => (d.id === id ? { ...d, offline } : d)),
+    };
+    emit();
+  });
+}
+
 setupSceneActiveListener();
 setupAppLastSceneListener();
 setupScreenSyncToStoreBridge();
+setupDeviceEventListeners();
 
 
 // camera:state fires when webcam turns on/off. The backend then emits scene:active
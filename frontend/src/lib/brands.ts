@@ -5,6 +5,11 @@ const brandInfo: Record<string, { color: string; label: string }> = {
   hue: { color: "text-blue-400", label: "Philips Hue" },
   elgato: { color: "text-yellow-400", label: "Elgato" },
   govee: { color: "text-purple-400", label: "Govee" },
+  wled: { color: "text-orange-400", label: "WLED" },
+  nanoleaf: { color: "text-teal-400", label: "Nanoleaf" },
+  yeelight: { color: "text-sky-400", label: "Yeelight" },
+  dmx: { color: "text-rose-400", label: "DMX" },
+  virtual: { color: "text-zinc-400", label: "Virtual" },
 };
 
 export function getBrandInfo(brand: string): { color: string; label: string } {
@@ -17,6 +17,48 @@ export interface Device {
   firmwareVersion?: string;
   /** User-assigned room label used for grouping (e.g. "Bedroom", "Office"). */
   room?: string;
+  /** Full capability descriptor; the supports*/kelvin fields above mirror it. */
+  capabilities?: Capabilities;
+  /** Set while background health checks can't reach the device. */
+  offline?: boolean;
+  /** Colour correction applied to everything sent to the device. */
+  calibration?: Calibration;
+}
+
+/** Per-device colour correction. Omitted fields leave that correction off. */
+export interface Calibration {
+  /** Per-channel multipliers; omitted = 1. */
+  redGain?: number;
+  greenGain?: number;
+  blueGain?: number;
+  /** Kelvin the device shows for pure white; colours are corrected to 6500K. */
+  whitePoint?: number;
+  /** Brightness response exponent. */
+  gamma?: number;
+  /** Degrees to rotate hues by. */
+  hueOffset?: number;
+  /** Lowest visible brightness (0–1); non-zero brightness is mapped above it. */
+  minBrightness?: number;
+  /** Scale for full brightness (0–1); omitted = 1. */
+  maxBrightness?: number;
+}
+
+export interface Capabilities {
+  color: boolean;
+  /** Hue colour gamut ("A" | "B" | "C" | "other"); empty when unknown. */
+  gamut?: string;
+  kelvin: boolean;
+  minKelvin?: number;
+  maxKelvin?: number;
+  kelvinStep?: number;
+  /** Lowest brightness (0–1) accepted while on, e.g. 0.03 for Elgato. */
+  minBrightness?: number;
+  nativeTransitions: boolean;
+  segments: number;
+  /** Sustained commands per second; 0/omitted = no declared limit. */
+  maxCommandRate?: number;
+  /** True when the controller can read the real state back from the device. */
+  readBack: boolean;
 }
 
 export interface DeviceState {
@@ -24,6 +66,10 @@ export interface DeviceState {
   brightness: number;
   color?: Color;
   kelvin?: number;
+  /** Fade duration in ms. Omitted = controller default, 0 = instant. */
+  transitionMs?: number;
+  /** One colour per segment (see Capabilities.segments); resampled if the count differs. */
+  zones?: Color[];
 }
 
 export interface Color {
@@ -37,10 +83,21 @@ export type LightMode = "color" | "kelvin";
 export const DEFAULT_KELVIN = 4000;
 export const APP_VERSION = "1.0.0";
 
+/** A room, zone or any other set of lights controlled together. */
+export interface Group {
+  id: string;
+  name: string;
+  /** Every light assigned to this room is a member. */
+  room?: string;
+  /** Members listed explicitly, in addition to the room's lights. */
+  deviceIds?: string[];
+}
+
 export interface Scene {
   id: string;
   name: string;
   trigger: string;
+  /** Keyed by device ID or group reference ("group:<id>"). */
   devices: Record<string, DeviceState>;
   /** Persists the global color override so the editor can restore it on re-edit. */
   globalColor?: Color;
@@ -80,6 +137,7 @@ export interface ScreenSyncConfig {
   region: CaptureRect;
   windowHwnd?: number;
   windowTitle?: string;
+  /** Device IDs or group references ("group:<id>"). */
   deviceIds: string[];
   colorMode: ColorMode;
   extractionMethod: ExtractionMethod;
@@ -152,9 +210,9 @@ export interface ScreenSyncStats {
   sendMs: number;
   /** Device updates (SetState calls) per second. */
   updateRate?: number;
-  /** Frames skipped because previous send was still in flight. */
+  /** Device updates superseded by a newer frame before they were sent. */
   framesDropped?: number;
-  /** Percent of attempted-send frames that were dropped. */
+  /** Dropped updates as a percent of sends plus drops. */
   framesDroppedPct?: number;
   sceneChange: boolean;
   /** Which threshold(s) triggered the scene cut. */
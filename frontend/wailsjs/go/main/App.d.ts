@@ -8,6 +8,8 @@ import {capture} from '../models';
 
 export function ActivateScene(arg1:string):Promise<void>;
 
+export function AddDeviceByAddress(arg1:string,arg2:string):Promise<lights.Device>;
+
 export function AddHueBridge(arg1:string,arg2:string):Promise<void>;
 
 export function CheckCameraNow():Promise<boolean>;
@@ -18,22 +20,36 @@ export function CreateScene(arg1:main.CreateSceneRequest):Promise<store.Scene>;
 
 export function DeactivateScene():Promise<void>;
 
+export function DeleteGroup(arg1:string):Promise<void>;
+
 export function DeleteScene(arg1:string):Promise<void>;
 
 export function DiscoverHueBridges():Promise<Array<discovery.DiscoveredHueBridge>>;
 
 export function DiscoverLights():Promise<main.DiscoverResult>;
 
+export function DiscoverNanoleafDevices():Promise<Array<discovery.DiscoveredNanoleaf>>;
+
 export function GetActiveScene():Promise<string>;
 
 export function GetCameraState():Promise<boolean>;
 
 export function GetCapturePreview():Promise<string>;
 
+export function GetCommandHistory(arg1:string):Promise<Array<lights.CommandRecord>>;
+
+export function GetCommandMetrics():Promise<{[key: string]: lights.CommandMetrics}>;
+
+export function GetDMXFixtures():Promise<Array<lights.DMXFixture>>;
+
 export function GetDefaultScreenSyncConfig():Promise<store.ScreenSyncConfig>;
 
+export function GetDeviceCapabilities(arg1:string):Promise<lights.Capabilities>;
+
 export function GetDevices():Promise<Array<lights.Device>>;
 
+export function GetGroups():Promise<Array<lights.Group>>;
+
 export function GetHueBridges():Promise<Array<store.HueBridge>>;
 
 export function GetLastSceneID():Promise<string>;
@@ -42,6 +58,8 @@ export function GetLightState(arg1:string):Promise<lights.DeviceState>;
 
 export function GetMonitors():Promise<Array<capture.MonitorInfo>>;
 
+export function GetNanoleafDevices():Promise<Array<store.NanoleafDevice>>;
+
 export function GetScene(arg1:string):Promise<store.Scene>;
 
 export function GetScenes():Promise<Array<store.Scene>>;
@@ -50,32 +68,60 @@ export function GetScreenSyncState():Promise<main.ScreenSyncState>;
 
 export function GetSettings():Promise<store.Settings>;
 
+export function GetVirtualDevices():Promise<Array<lights.VirtualDevice>>;
+
 export function GetWindowThumbnail(arg1:number):Promise<string>;
 
 export function GetWindows():Promise<Array<capture.WindowInfo>>;
 
+export function IdentifyLight(arg1:string):Promise<void>;
+
 export function IsMonitoringEnabled():Promise<boolean>;
 
 export function PairHueBridge(arg1:string):Promise<main.PairResult>;
 
+export function PairNanoleaf(arg1:string):Promise<main.PairResult>;
+
 export function QuitApp():Promise<void>;
 
+export function RemoveDMXFixture(arg1:string):Promise<void>;
+
 export function RemoveDevice(arg1:string):Promise<void>;
 
 export function RemoveHueBridge(arg1:string):Promise<void>;
 
+export function RemoveNanoleafDevice(arg1:string):Promise<void>;
+
+export function RemoveVirtualDevice(arg1:string):Promise<void>;
+
+export function SaveDMXFixture(arg1:lights.DMXFixture):Promise<lights.DMXFixture>;
+
+export function SaveGroup(arg1:lights.Group):Promise<lights.Group>;
+
+export function SaveVirtualDevice(arg1:lights.VirtualDevice):Promise<lights.VirtualDevice>;
+
+export function SetDeviceCalibration(arg1:string,arg2:lights.Calibration):Promise<void>;
+
 export function SetDeviceRoom(arg1:string,arg2:string):Promise<void>;
 
+export function SetGroupState(arg1:string,arg2:lights.DeviceState):Promise<{[key: string]: string}>;
+
 export function SetLightState(arg1:string,arg2:lights.DeviceState):Promise<void>;
 
 export function SetMonitoringEnabled(arg1:boolean):Promise<void>;
 
+export function ShowCalibrationColor(arg1:string,arg2:number):Promise<lights.ReferenceColor>;
+
 export function StartRegionSelect():Promise<void>;
 
 export function StopScreenSync():Promise<void>;
 
+export function TurnOffGroup(arg1:string):Promise<{[key: string]: string}>;
+
 export function TurnOffLight(arg1:string):Promise<void>;
 
+export function TurnOnGroup(arg1:string):Promise<{[key: string]: string}>;
+
 export function TurnOnLight(arg1:string):Promise<void>;
 
 export function UpdateScene(arg1:store.Scene):Promise<void>;
@@ -6,6 +6,10 @@ export function ActivateScene(arg1) {
   return window['go']['main']['App']['ActivateScene'](arg1);
 }
 
+export function AddDeviceByAddress(arg1, arg2) {
+  return window['go']['main']['App']['AddDeviceByAddress'](arg1, arg2);
+}
+
 export function AddHueBridge(arg1, arg2) {
   return window['go']['main']['App']['AddHueBridge'](arg1, arg2);
 }
@@ -26,6 +30,10 @@ export function DeactivateScene() {
   return window['go']['main']['App']['DeactivateScene']();
 }
 
+export function DeleteGroup(arg1) {
+  return window['go']['main']['App']['DeleteGroup'](arg1);
+}
+
 export function DeleteScene(arg1) {
   return window['go']['main']['App']['DeleteScene'](arg1);
 }
@@ -38,6 +46,10 @@ export function DiscoverLights() {
   return window['go']['main']['App']['DiscoverLights']();
 }
 
+export function DiscoverNanoleafDevices() {
+  return window['go']['main']['App']['DiscoverNanoleafDevices']();
+}
+
 export function GetActiveScene() {
   return window['go']['main']['App']['GetActiveScene']();
 }
@@ -50,14 +62,34 @@ export function GetCapturePreview() {
   return window['go']['main']['App']['GetCapturePreview']();
 }
 
+export function GetCommandHistory(arg1) {
+  return window['go']['main']['App']['GetCommandHistory'](arg1);
+}
+
+export function GetCommandMetrics() {
+  return window['go']['main']['App']['GetCommandMetrics']();
+}
+
+export function GetDMXFixtures() {
+  return window['go']['main']['App']['GetDMXFixtures']();
+}
+
 export function GetDefaultScreenSyncConfig() {
   return window['go']['main']['App']['GetDefaultScreenSyncConfig']();
 }
 
+export function GetDeviceCapabilities(arg1) {
+  return window['go']['main']['App']['GetDeviceCapabilities'](arg1);
+}
+
 export function GetDevices() {
   return window['go']['main']['App']['GetDevices']();
 }
 
+export function GetGroups() {
+  return window['go']['main']['App']['GetGroups']();
+}
+
 export function GetHueBridges() {
   return window['go']['main']['App']['GetHueBridges']();
 }
@@ -74,6 +106,10 @@ export function GetMonitors() {
   return window['go']['main']['App']['GetMonitors']();
 }
 
+export function GetNanoleafDevices() {
+  return window['go']['main']['App']['GetNanoleafDevices']();
+}
+
 export function GetScene(arg1) {
   return window['go']['main']['App']['GetScene'](arg1);
 }
@@ -90,6 +126,10 @@ export function GetSettings() {
   return window['go']['main']['App']['GetSettings']();
 }
 
+export function GetVirtualDevices() {
+  return window['go']['main']['App']['GetVirtualDevices']();
+}
+
 export function GetWindowThumbnail(arg1) {
   return window['go']['main']['App']['GetWindowThumbnail'](arg1);
 }
@@ -98,6 +138,10 @@ export function GetWindows() {
   return window['go']['main']['App']['GetWindows']();
 }
 
+export function IdentifyLight(arg1) {
+  return window['go']['main']['App']['IdentifyLight'](arg1);
+}
+
 export function IsMonitoringEnabled() {
   return window['go']['main']['App']['IsMonitoringEnabled']();
 }
@@ -106,10 +150,18 @@ export function PairHueBridge(arg1) {
   return window['go']['main']['App']['PairHueBridge'](arg1);
 }
 
+export function PairNanoleaf(arg1) {
+  return window['go']['main']['App']['PairNanoleaf'](arg1);
+}
+
 export function QuitApp() {
   return window['go']['main']['App']['QuitApp']();
 }
 
+export function RemoveDMXFixture(arg1) {
+  return window['go']['main']['App']['RemoveDMXFixture'](arg1);
+}
+
 export function RemoveDevice(arg1) {
   return window['go']['main']['App']['RemoveDevice'](arg1);
 }
@@ -118,10 +170,38 @@ export function RemoveHueBridge(arg1) {
   return window['go']['main']['App']['RemoveHueBridge'](arg1);
 }
 
+export function RemoveNanoleafDevice(arg1) {
+  return window['go']['main']['App']['RemoveNanoleafDevice'](arg1);
+}
+
+export function RemoveVirtualDevice(arg1) {
+  return window['go']['main']['App']['RemoveVirtualDevice'](arg1);
+}
+
+export function SaveDMXFixture(arg1) {
+  return window['go']['main']['App']['SaveDMXFixture'](arg1);
+}
+
+export function SaveGroup(arg1) {
+  return window['go']['main']['App']['SaveGroup'](arg1);
+}
+
+export function SaveVirtualDevice(arg1) {
+  return window['go']['main']['App']['SaveVirtualDevice'](arg1);
+}
+
+export function SetDeviceCalibration(arg1, arg2) {
+  return window['go']['main']['App']['SetDeviceCalibration'](arg1, arg2);
+}
+
 export function SetDeviceRoom(arg1, arg2) {
   return window['go']['main']['App']['SetDeviceRoom'](arg1, arg2);
 }
 
+export function SetGroupState(arg1, arg2) {
+  return window['go']['main']['App']['SetGroupState'](arg1,arg2);
+}
+
 export function SetLightState(arg1, arg2) {
   return window['go']['main']['App']['SetLightState'](arg1, arg2);
 }
@@ -130,6 +210,10 @@ export function SetMonitoringEnabled(arg1) {
   return window['go']['main']['App']['SetMonitoringEnabled'](arg1);
 }
 
+export function ShowCalibrationColor(arg1, arg2) {
+  return window['go']['main']['App']['ShowCalibrationColor'](arg1, arg2);
+}
+
 export function StartRegionSelect() {
   return window['go']['main']['App']['StartRegionSelect']();
 }
@@ -138,10 +222,18 @@ export function StopScreenSync() {
   return window['go']['main']['App']['StopScreenSync']();
 }
 
+export function TurnOffGroup(arg1) {
+  return window['go']['main']['App']['TurnOffGroup'](arg1);
+}
+
 export function TurnOffLight(arg1) {
   return window['go']['main']['App']['TurnOffLight'](arg1);
 }
 
+export function TurnOnGroup(arg1) {
+  return window['go']['main']['App']['TurnOnGroup'](arg1);
+}
+
 export function TurnOnLight(arg1) {
   return window['go']['main']['App']['TurnOnLight'](arg1);
 }
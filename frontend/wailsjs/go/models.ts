@@ -59,11 +59,84 @@ export namespace discovery {
 	        this.name = source["name"];
 	    }
 	}
+	export class DiscoveredNanoleaf {
+	    ip: string;
+	    name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiscoveredNanoleaf(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ip = source["ip"];
+	        this.name = source["name"];
+	    }
+	}
 
 }
 
 export namespace lights {
 	
+	export class Calibration {
+	    redGain?: number;
+	    greenGain?: number;
+	    blueGain?: number;
+	    whitePoint?: number;
+	    gamma?: number;
+	    hueOffset?: number;
+	    minBrightness?: number;
+	    maxBrightness?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Calibration(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.redGain = source["redGain"];
+	        this.greenGain = source["greenGain"];
+	        this.blueGain = source["blueGain"];
+	        this.whitePoint = source["whitePoint"];
+	        this.gamma = source["gamma"];
+	        this.hueOffset = source["hueOffset"];
+	        this.minBrightness = source["minBrightness"];
+	        this.maxBrightness = source["maxBrightness"];
+	    }
+	}
+	export class Capabilities {
+	    color: boolean;
+	    gamut?: string;
+	    kelvin: boolean;
+	    minKelvin?: number;
+	    maxKelvin?: number;
+	    kelvinStep?: number;
+	    minBrightness?: number;
+	    nativeTransitions: boolean;
+	    segments: number;
+	    maxCommandRate?: number;
+	    readBack: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Capabilities(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.color = source["color"];
+	        this.gamut = source["gamut"];
+	        this.kelvin = source["kelvin"];
+	        this.minKelvin = source["minKelvin"];
+	        this.maxKelvin = source["maxKelvin"];
+	        this.kelvinStep = source["kelvinStep"];
+	        this.minBrightness = source["minBrightness"];
+	        this.nativeTransitions = source["nativeTransitions"];
+	        this.segments = source["segments"];
+	        this.maxCommandRate = source["maxCommandRate"];
+	        this.readBack = source["readBack"];
+	    }
+	}
+	
 	export class Color {
 	    h: number;
 	    s: number;
@@ -80,6 +153,95 @@ export namespace lights {
 	        this.b = source["b"];
 	    }
 	}
+	export class CommandMetrics {
+	    sent: number;
+	    coalesced: number;
+	    dropped: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CommandMetrics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sent = source["sent"];
+	        this.coalesced = source["coalesced"];
+	        this.dropped = source["dropped"];
+	    }
+	}
+	export class CommandRecord {
+	    deviceId: string;
+	    state: DeviceState;
+	    // Go type: time
+	    at: any;
+	    dropped?: boolean;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CommandRecord(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.deviceId = source["deviceId"];
+	        this.state = this.convertValues(source["state"], DeviceState);
+	        this.at = this.convertValues(source["at"], null);
+	        this.dropped = source["dropped"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class DMXFixture {
+	    id: string;
+	    name: string;
+	    protocol: string;
+	    host?: string;
+	    universe: number;
+	    startChannel: number;
+	    layout: string;
+	    pixels?: number;
+	    gamma?: number;
+	    minKelvin?: number;
+	    maxKelvin?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DMXFixture(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.protocol = source["protocol"];
+	        this.host = source["host"];
+	        this.universe = source["universe"];
+	        this.startChannel = source["startChannel"];
+	        this.layout = source["layout"];
+	        this.pixels = source["pixels"];
+	        this.gamma = source["gamma"];
+	        this.minKelvin = source["minKelvin"];
+	        this.maxKelvin = source["maxKelvin"];
+	    }
+	}
+	
 	export class Device {
 	    id: string;
 	    brand: string;
@@ -95,6 +257,9 @@ export namespace lights {
 	    kelvinStep?: number;
 	    firmwareVersion?: string;
 	    room?: string;
+	    capabilities?: Capabilities;
+	    offline?: boolean;
+	    calibration?: Calibration;
 	
 	    static createFrom(source: any = {}) {
 	        return new Device(source);
@@ -115,6 +280,9 @@ export namespace lights {
 	        this.kelvinStep = source["kelvinStep"];
 	        this.firmwareVersion = source["firmwareVersion"];
 	        this.room = source["room"];
+	        this.capabilities = this.convertValues(source["capabilities"], Capabilities);
+	        this.offline = source["offline"];
+	        this.calibration = this.convertValues(source["calibration"], Calibration);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -140,6 +308,8 @@ export namespace lights {
 	    brightness: number;
 	    color?: Color;
 	    kelvin?: number;
+	    transitionMs?: number;
+	    zones?: Color[];
 	
 	    static createFrom(source: any = {}) {
 	        return new DeviceState(source);
@@ -151,6 +321,114 @@ export namespace lights {
 	        this.brightness = source["brightness"];
 	        this.color = this.convertValues(source["color"], Color);
 	        this.kelvin = source["kelvin"];
+	        this.transitionMs = source["transitionMs"];
+	        this.zones = this.convertValues(source["zones"], Color);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Group {
+	    id: string;
+	    name: string;
+	    room?: string;
+	    deviceIds?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Group(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.room = source["room"];
+	        this.deviceIds = source["deviceIds"];
+	    }
+	}
+	
+	export class Point {
+	    x: number;
+	    y: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Point(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.x = source["x"];
+	        this.y = source["y"];
+	    }
+	}
+	
+	export class ReferenceColor {
+	    name: string;
+	    state: DeviceState;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReferenceColor(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.state = this.convertValues(source["state"], DeviceState);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class VirtualDevice {
+	    id: string;
+	    name: string;
+	    capabilities: Capabilities;
+	    latencyMs?: number;
+	    packetLoss?: number;
+	    layout?: Point[];
+	
+	    static createFrom(source: any = {}) {
+	        return new VirtualDevice(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.capabilities = this.convertValues(source["capabilities"], Capabilities);
+	        this.latencyMs = source["latencyMs"];
+	        this.packetLoss = source["packetLoss"];
+	        this.layout = this.convertValues(source["layout"], Point);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -171,7 +449,6 @@ export namespace lights {
 		    return a;
 		}
 	}
-
 }
 
 export namespace main {
@@ -305,6 +582,7 @@ export namespace store {
 	    id: string;
 	    ip: string;
 	    username: string;
+	    clientKey?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new HueBridge(source);
@@ -315,6 +593,23 @@ export namespace store {
 	        this.id = source["id"];
 	        this.ip = source["ip"];
 	        this.username = source["username"];
+	        this.clientKey = source["clientKey"];
+	    }
+	}
+	export class NanoleafDevice {
+	    id: string;
+	    ip: string;
+	    token: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NanoleafDevice(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.ip = source["ip"];
+	        this.token = source["token"];
 	    }
 	}
 	export class ScreenSyncConfig {
@@ -10,7 +10,7 @@ require (
 	github.com/kirides/go-d3d v1.0.1
 	github.com/mdlayher/keylight v0.0.0-20221120152827-c7284f814763
 	github.com/openhue/openhue-go v0.4.0
-	github.com/swrm-io/go-vee v0.0.0-20251216170131-8025e642ad03
+	github.com/pion/dtls/v3 v3.0.7
 	github.com/wailsapp/wails/v2 v2.11.0
 	go.yhsif.com/lifxlan v0.3.4
 	golang.org/x/image v0.36.0
@@ -48,6 +48,8 @@ require (
 	github.com/miekg/dns v1.1.65 // indirect
 	github.com/oapi-codegen/runtime v1.1.1 // indirect
 	github.com/oxtoacart/bpool v0.0.0-20190530202638-03653db5a59c // indirect
+	github.com/pion/logging v0.2.4 // indirect
+	github.com/pion/transport/v3 v3.0.7 // indirect
 	github.com/pkg/browser v0.0.0-20240102092130-5ac0b6a4141c // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
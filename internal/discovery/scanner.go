@@ -2,12 +2,10 @@ package discovery
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
-	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -17,15 +15,33 @@ import (
 	"lightsync/internal/lights"
 )
 
+const (
+	// defaultHintTimeout bounds a discovery hint that doesn't set its own.
+	defaultHintTimeout = 10 * time.Second
+	// mdnsListen and ssdpListen are how long a browse or search waits for
+	// answers.
+	mdnsListen = 3 * time.Second
+	ssdpListen = 5 * time.Second
+	// probeConcurrency limits the hosts a subnet probe checks at once.
+	probeConcurrency = 64
+)
+
+// Scanner finds devices on the network. Brands take part by implementing
+// lights.DiscoveryProvider: ScanAll runs every provider's hints
+// concurrently, hands them what they found, then runs the controllers' own
+// discovery.
 type Scanner struct {
 	lightManager *lights.Manager
-	elgatoCtrl   *lights.ElgatoController
+	hintTimeout  time.Duration
+	// subnets returns the /24 prefixes subnet probes cover.
+	subnets func() []string
 }
 
-func NewScanner(lm *lights.Manager, elgato *lights.ElgatoController) *Scanner {
+func NewScanner(lm *lights.Manager) *Scanner {
 	return &Scanner{
 		lightManager: lm,
-		elgatoCtrl:   elgato,
+		hintTimeout:  defaultHintTimeout,
+		subnets:      getLocalSubnets,
 	}
 }
 
@@ -40,29 +56,58 @@ type ScanProgress struct {
 	Devices []lights.Device `json:"devices,omitempty"`
 }
 
+// Found is a host turned up by a discovery hint, with the name it
+// announced, if any.
+type Found struct {
+	Host string
+	Name string
+}
+
+type provider struct {
+	brand lights.Brand
+	lights.DiscoveryProvider
+}
+
+// ScanAll runs every provider's hints concurrently, each phase named after
+// its brand, and then Manager.DiscoverAllWithProgress. A provider's failures
+// are reported in the result without holding up the others.
 func (s *Scanner) ScanAll(ctx context.Context, onProgress func(ScanProgress)) DiscoveryResult {
-	result := DiscoveryResult{}
+	var (
+		result     DiscoveryResult
+		progressMu sync.Mutex
+		errMu      sync.Mutex
+		wg         sync.WaitGroup
+	)
 	progress := func(phase, message string, devices []lights.Device) {
+		progressMu.Lock()
+		defer progressMu.Unlock()
 		log.Printf("[discovery] %s", message)
 		if onProgress != nil {
 			onProgress(ScanProgress{Phase: phase, Message: message, Devices: devices})
 		}
 	}
 
-	progress("elgato", "Searching for Elgato lights...", nil)
-	mdnsFound := s.discoverElgatoViaMDNS(ctx)
-	log.Printf("[discovery] mDNS found %d Elgato device(s)", mdnsFound)
-
-	if mdnsFound == 0 {
-		progress("elgato", "Scanning subnet for Elgato lights...", nil)
-		s.discoverElgatoViaProbe(ctx)
-	}
-
-	progress("hue", "Searching for Hue bridges...", nil)
-	hueBridges := s.DiscoverHueBridges(ctx)
-	if len(hueBridges) > 0 {
-		progress("hue", fmt.Sprintf("Found %d Hue bridge(s), querying lights...", len(hueBridges)), nil)
+	for _, p := range s.providers() {
+		wg.Add(1)
+		go func(p provider) {
+			defer wg.Done()
+			phase := string(p.brand)
+			progress(phase, fmt.Sprintf("Searching for %s devices...", p.brand), nil)
+			found, errs := s.find(ctx, p)
+			for _, f := range found {
+				p.AddDiscovered(f.Host, f.Name)
+			}
+			if len(found) > 0 {
+				progress(phase, fmt.Sprintf("Found %d %s device(s) on the network", len(found), p.brand), nil)
+			}
+			errMu.Lock()
+			for _, err := range errs {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", p.brand, err))
+			}
+			errMu.Unlock()
+		}(p)
 	}
+	wg.Wait()
 
 	progress("lights", "Querying all bridges and devices for lights...", nil)
 	var totalFound int
@@ -79,205 +124,158 @@ func (s *Scanner) ScanAll(ctx context.Context, onProgress func(ScanProgress)) Di
 	return result
 }
 
-func (s *Scanner) discoverElgatoViaMDNS(ctx context.Context) int {
-	entries := make(chan *mdns.ServiceEntry, 10)
-	found := 0
-
-	go func() {
-		params := &mdns.QueryParam{
-			Service:             "_elg._tcp",
-			Domain:              "local",
-			Timeout:             3 * time.Second,
-			Entries:             entries,
-			DisableIPv6:         true,
-			WantUnicastResponse: true,
-		}
-		if err := mdns.Query(params); err != nil {
-			log.Printf("[discovery] mDNS Elgato query error: %v", err)
-		}
-		close(entries)
-	}()
-
-	for entry := range entries {
-		if ctx.Err() != nil {
-			return found
+// Find runs brand's hints and returns what they found without registering
+// it, for pairing flows that list devices to choose from.
+func (s *Scanner) Find(ctx context.Context, brand lights.Brand) ([]Found, error) {
+	for _, p := range s.providers() {
+		if p.brand == brand {
+			found, errs := s.find(ctx, p)
+			return found, errors.Join(errs...)
 		}
-		log.Printf("[discovery] mDNS entry: Name=%s AddrV4=%v Port=%d", entry.Name, entry.AddrV4, entry.Port)
-		addr := entry.AddrV4.String()
-		if addr == "" || addr == "<nil>" {
-			continue
-		}
-		s.elgatoCtrl.AddDevice(addr)
-		found++
 	}
-	return found
+	return nil, fmt.Errorf("no discovery provider for %q", brand)
 }
 
-func (s *Scanner) discoverElgatoViaProbe(ctx context.Context) int {
-	subnets := getLocalSubnets()
-	if len(subnets) == 0 {
-		log.Println("[discovery] Could not determine local subnets for probe scan")
-		return 0
+func (s *Scanner) providers() []provider {
+	var providers []provider
+	for _, c := range s.lightManager.Controllers() {
+		if dp, ok := c.(lights.DiscoveryProvider); ok {
+			providers = append(providers, provider{brand: c.Brand(), DiscoveryProvider: dp})
+		}
 	}
+	return providers
+}
 
-	found := 0
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 50) // limit concurrency
-
-	for _, subnet := range subnets {
-		log.Printf("[discovery] Probing subnet %s for Elgato lights on port 9123", subnet)
-		ips := expandSubnet(subnet)
-		for _, ip := range ips {
-			if ctx.Err() != nil {
-				break
+// find runs p's hints, and its fallback hints when the others found
+// nothing, returning each host once.
+func (s *Scanner) find(ctx context.Context, p provider) ([]Found, []error) {
+	var (
+		mu    sync.Mutex
+		found []Found
+		index = make(map[string]int)
+	)
+	add := func(host, name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if i, ok := index[host]; ok {
+			if found[i].Name == "" {
+				found[i].Name = name
 			}
-			wg.Add(1)
-			sem <- struct{}{}
-			go func(addr string) {
-				defer wg.Done()
-				defer func() { <-sem }()
-
-				if isElgatoKeyLight(ctx, addr) {
-					log.Printf("[discovery] Found Elgato light at %s via probe", addr)
-					s.elgatoCtrl.AddDevice(addr)
-					mu.Lock()
-					found++
-					mu.Unlock()
-				}
-			}(ip)
+			return
 		}
+		index[host] = len(found)
+		found = append(found, Found{Host: host, Name: name})
 	}
-	wg.Wait()
-	return found
-}
 
-func isElgatoKeyLight(ctx context.Context, ip string) bool {
-	client := &http.Client{Timeout: 800 * time.Millisecond}
-	url := fmt.Sprintf("http://%s:9123/elgato/accessory-info", ip)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return false
+	var primary, fallback []lights.DiscoveryHint
+	for _, h := range p.DiscoveryHints() {
+		if h.Fallback {
+			fallback = append(fallback, h)
+		} else {
+			primary = append(primary, h)
+		}
 	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return false
+	errs := s.runHints(ctx, p.brand, primary, add)
+	if len(found) == 0 && len(fallback) > 0 {
+		log.Printf("[discovery] No %s devices found, falling back", p.brand)
+		errs = append(errs, s.runHints(ctx, p.brand, fallback, add)...)
 	}
-	resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
+	return found, errs
 }
 
-func getLocalSubnets() []string {
-	var subnets []string
-	ifaces, err := net.Interfaces()
-	if err != nil {
-		return nil
-	}
-	for _, iface := range ifaces {
-		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-		addrs, err := iface.Addrs()
-		if err != nil {
-			continue
-		}
-		for _, addr := range addrs {
-			ipNet, ok := addr.(*net.IPNet)
-			if !ok {
-				continue
+// runHints runs hints concurrently, each within its timeout.
+func (s *Scanner) runHints(ctx context.Context, brand lights.Brand, hints []lights.DiscoveryHint, add func(host, name string)) []error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for _, h := range hints {
+		wg.Add(1)
+		go func(h lights.DiscoveryHint) {
+			defer wg.Done()
+			timeout := h.Timeout
+			if timeout <= 0 {
+				timeout = s.hintTimeout
 			}
-			ip := ipNet.IP.To4()
-			if ip == nil {
-				continue
-			}
-			ones, bits := ipNet.Mask.Size()
-			if ones == 0 || bits == 0 || ones > 24 {
-				continue
+			hintCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if err := s.runHint(hintCtx, h, add); err != nil {
+				log.Printf("[discovery] %s %s failed: %v", brand, h.Method, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", h.Method, err))
+				mu.Unlock()
 			}
-			subnet := fmt.Sprintf("%d.%d.%d", ip[0], ip[1], ip[2])
-			subnets = append(subnets, subnet)
-		}
+		}(h)
 	}
-	return subnets
+	wg.Wait()
+	return errs
 }
 
-func expandSubnet(prefix string) []string {
-	ips := make([]string, 0, 254)
-	for i := 1; i <= 254; i++ {
-		ips = append(ips, fmt.Sprintf("%s.%d", prefix, i))
+func (s *Scanner) runHint(ctx context.Context, h lights.DiscoveryHint, add func(host, name string)) error {
+	switch h.Method {
+	case lights.DiscoverMDNS:
+		return browseMDNS(ctx, h.Targets, add)
+	case lights.DiscoverSSDP:
+		return searchSSDP(ctx, h.Targets, h.Match, add)
+	case lights.DiscoverProbe:
+		return s.probeSubnets(ctx, h.Probe, add)
+	case lights.DiscoverSearch:
+		return h.Search(ctx, add)
+	default:
+		return fmt.Errorf("unknown discovery method %q", h.Method)
 	}
-	return ips
-}
-
-type DiscoveredHueBridge struct {
-	IP   string `json:"ip"`
-	Name string `json:"name"`
 }
 
-func (s *Scanner) DiscoverHueBridges(ctx context.Context) []DiscoveredHueBridge {
-	var mu sync.Mutex
-	seen := make(map[string]bool)
-	var bridges []DiscoveredHueBridge
+// browseMDNS browses each service type and names hosts after the announced
+// instance.
+func browseMDNS(ctx context.Context, services []string, add func(host, name string)) error {
+	for _, service := range services {
+		entries := make(chan *mdns.ServiceEntry, 10)
+		var queryErr error
+		go func() {
+			params := &mdns.QueryParam{
+				Service:             service,
+				Domain:              "local",
+				Timeout:             mdnsListen,
+				Entries:             entries,
+				DisableIPv6:         true,
+				WantUnicastResponse: true,
+			}
+			queryErr = mdns.QueryContext(ctx, params)
+			close(entries)
+		}()
 
-	addBridge := func(ip, name string) {
-		mu.Lock()
-		defer mu.Unlock()
-		if seen[ip] {
-			return
+		for entry := range entries {
+			log.Printf("[discovery] mDNS entry: Name=%s AddrV4=%v Port=%d", entry.Name, entry.AddrV4, entry.Port)
+			if entry.AddrV4 == nil {
+				continue
+			}
+			name, _, _ := strings.Cut(entry.Name, "."+service)
+			add(entry.AddrV4.String(), strings.ReplaceAll(name, `\ `, " "))
+		}
+		if queryErr != nil && ctx.Err() == nil {
+			return fmt.Errorf("query %s: %w", service, queryErr)
 		}
-		seen[ip] = true
-		bridges = append(bridges, DiscoveredHueBridge{IP: ip, Name: name})
-	}
-
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		discoverHueViaSsdp(ctx, addBridge)
-	}()
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		discoverHueViaCloud(ctx, addBridge)
-	}()
-
-	wg.Wait()
-
-	mu.Lock()
-	found := len(bridges)
-	mu.Unlock()
-
-	if found == 0 {
-		log.Println("[discovery] SSDP and cloud found nothing, falling back to subnet probe on port 443")
-		discoverHueViaProbe(ctx, addBridge)
 	}
-
-	return bridges
+	return nil
 }
 
-func discoverHueViaSsdp(ctx context.Context, addBridge func(ip, name string)) {
+// searchSSDP multicasts an M-SEARCH for each target and adds the hosts
+// whose responses match accepts.
+func searchSSDP(ctx context.Context, targets []string, match func(string) bool, add func(host, name string)) error {
 	conn, err := net.ListenPacket("udp4", ":0")
 	if err != nil {
-		log.Printf("[discovery] SSDP: failed to open UDP socket: %v", err)
-		return
+		return fmt.Errorf("open UDP socket: %w", err)
 	}
 	defer conn.Close()
 
 	ssdpAddr, err := net.ResolveUDPAddr("udp4", "239.255.255.250:1900")
 	if err != nil {
-		log.Printf("[discovery] SSDP: failed to resolve multicast address: %v", err)
-		return
+		return err
 	}
 
-	searchTargets := []string{
-		"ssdp:all",
-		"urn:schemas-upnp-org:device:Basic:1",
-		"upnp:rootdevice",
-	}
-
-	for _, st := range searchTargets {
+	for _, st := range targets {
 		msg := "M-SEARCH * HTTP/1.1\r\n" +
 			"HOST: 239.255.255.250:1900\r\n" +
 			"MAN: \"ssdp:discover\"\r\n" +
@@ -285,24 +283,20 @@ func discoverHueViaSsdp(ctx context.Context, addBridge func(ip, name string)) {
 			"MX: 3\r\n" +
 			"\r\n"
 		if _, err := conn.WriteTo([]byte(msg), ssdpAddr); err != nil {
-			log.Printf("[discovery] SSDP: failed to send M-SEARCH for %s: %v", st, err)
-		} else {
-			log.Printf("[discovery] SSDP: sent M-SEARCH for %s", st)
+			return fmt.Errorf("send M-SEARCH for %s: %w", st, err)
 		}
 	}
 
-	deadline := time.Now().Add(5 * time.Second)
+	deadline := time.Now().Add(ssdpListen)
 	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
-		deadline = ctxDeadline.Add(-500 * time.Millisecond)
+		deadline = ctxDeadline
 	}
 
 	buf := make([]byte, 4096)
-	seen := make(map[string]bool)
 	responseCount := 0
-
 	for time.Now().Before(deadline) {
 		if ctx.Err() != nil {
-			return
+			break
 		}
 		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
 		n, addr, err := conn.ReadFrom(buf)
@@ -319,170 +313,127 @@ func discoverHueViaSsdp(ctx context.Context, addBridge func(ip, name string)) {
 		if !ok {
 			continue
 		}
-		ip := udpAddr.IP.String()
-
-		response := string(buf[:n])
-		responseUpper := strings.ToUpper(response)
-		isHue := strings.Contains(responseUpper, "HUE") ||
-			strings.Contains(responseUpper, "PHILIPS") ||
-			strings.Contains(responseUpper, "IPBRIDGE")
-
-		if !isHue {
-			continue
-		}
-
-		if seen[ip] {
-			continue
-		}
-		seen[ip] = true
-
-		log.Printf("[discovery] SSDP found Hue bridge at %s", ip)
-		addBridge(ip, "Hue Bridge")
-	}
-	log.Printf("[discovery] SSDP: received %d total responses, found %d Hue bridge(s)", responseCount, len(seen))
-}
-
-func discoverHueViaCloud(ctx context.Context, addBridge func(ip, name string)) {
-	nupnpURLs := []string{
-		"https://discovery.meethue.com/",
-		"https://www.meethue.com/api/nupnp",
-		"http://www.meethue.com/api/nupnp",
-	}
-
-	client := &http.Client{Timeout: 5 * time.Second}
-
-	for _, url := range nupnpURLs {
-		if ctx.Err() != nil {
-			return
-		}
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
-			continue
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("[discovery] Hue N-UPnP %s error: %v", url, err)
-			continue
-		}
-
-		if resp.StatusCode == 429 {
-			resp.Body.Close()
-			log.Printf("[discovery] Hue N-UPnP %s rate limited (429), trying next", url)
-			continue
-		}
-		if resp.StatusCode != 200 {
-			resp.Body.Close()
-			log.Printf("[discovery] Hue N-UPnP %s returned %d", url, resp.StatusCode)
-			continue
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			continue
-		}
-
-		var results []struct {
-			ID                string `json:"id"`
-			InternalIPAddress string `json:"internalipaddress"`
-			Port              int    `json:"port"`
-		}
-		if err := json.Unmarshal(body, &results); err != nil {
-			log.Printf("[discovery] Hue N-UPnP %s parse error: %v", url, err)
-			continue
-		}
-
-		found := false
-		for _, r := range results {
-			if r.InternalIPAddress != "" {
-				name := "Hue Bridge"
-				if len(r.ID) >= 6 {
-					name = "Hue Bridge (" + r.ID[len(r.ID)-6:] + ")"
-				}
-				log.Printf("[discovery] N-UPnP found Hue bridge at %s (id: %s)", r.InternalIPAddress, r.ID)
-				addBridge(r.InternalIPAddress, name)
-				found = true
-			}
-		}
-		if found {
-			return
+		if match == nil || match(string(buf[:n])) {
+			add(udpAddr.IP.String(), "")
 		}
 	}
+	log.Printf("[discovery] SSDP: received %d response(s)", responseCount)
+	return nil
 }
 
-func discoverHueViaProbe(ctx context.Context, addBridge func(ip, name string)) {
-	subnets := getLocalSubnets()
+// probeSubnets calls probe for every address on the local subnets.
+func (s *Scanner) probeSubnets(ctx context.Context, probe func(ctx context.Context, host string) bool, add func(host, name string)) error {
+	subnets := s.subnets()
 	if len(subnets) == 0 {
-		log.Println("[discovery] Could not determine local subnets for Hue probe")
-		return
+		log.Println("[discovery] Could not determine local subnets for probe scan")
+		return nil
 	}
 
-	client := lights.NewHueHTTPClient(1 * time.Second)
-
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, 80)
-
+	sem := make(chan struct{}, probeConcurrency)
 	for _, subnet := range subnets {
-		log.Printf("[discovery] Probing subnet %s for Hue bridges on port 443", subnet)
-		ips := expandSubnet(subnet)
-		for _, ip := range ips {
+		log.Printf("[discovery] Probing subnet %s", subnet)
+		for _, ip := range expandSubnet(subnet) {
 			if ctx.Err() != nil {
 				break
 			}
 			wg.Add(1)
 			sem <- struct{}{}
-			go func(addr string) {
+			go func(host string) {
 				defer wg.Done()
 				defer func() { <-sem }()
-				if isHueBridge(ctx, client, addr) {
-					log.Printf("[discovery] Probe found Hue bridge at %s", addr)
-					addBridge(addr, "Hue Bridge")
+				if probe(ctx, host) {
+					log.Printf("[discovery] Probe found %s", host)
+					add(host, "")
 				}
 			}(ip)
 		}
 	}
 	wg.Wait()
+	return nil
 }
 
-var httpPlainClient = &http.Client{Timeout: 1 * time.Second}
-
-func isHueBridge(ctx context.Context, httpsClient *http.Client, ip string) bool {
-	urls := []string{
-		fmt.Sprintf("http://%s/api/config", ip),
-		fmt.Sprintf("https://%s/api/0/config", ip),
+func getLocalSubnets() []string {
+	var subnets []string
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
 	}
-
-	for _, url := range urls {
-		client := httpsClient
-		if strings.HasPrefix(url, "http://") {
-			client = httpPlainClient
-		}
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
 			continue
 		}
-		resp, err := client.Do(req)
+		addrs, err := iface.Addrs()
 		if err != nil {
 			continue
 		}
-
-		body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		resp.Body.Close()
-		if err != nil {
-			continue
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := ipNet.IP.To4()
+			if ip == nil {
+				continue
+			}
+			ones, bits := ipNet.Mask.Size()
+			if ones == 0 || bits == 0 || ones > 24 {
+				continue
+			}
+			subnet := fmt.Sprintf("%d.%d.%d", ip[0], ip[1], ip[2])
+			subnets = append(subnets, subnet)
 		}
+	}
+	return subnets
+}
 
-		var config struct {
-			BridgeID string `json:"bridgeid"`
-		}
-		if err := json.Unmarshal(body, &config); err != nil {
-			continue
-		}
-		if config.BridgeID != "" {
-			return true
+func expandSubnet(prefix string) []string {
+	ips := make([]string, 0, 254)
+	for i := 1; i <= 254; i++ {
+		ips = append(ips, fmt.Sprintf("%s.%d", prefix, i))
+	}
+	return ips
+}
+
+type DiscoveredHueBridge struct {
+	IP   string `json:"ip"`
+	Name string `json:"name"`
+}
+
+// DiscoverHueBridges lists the bridges found by the Hue provider's hints,
+// paired or not.
+func (s *Scanner) DiscoverHueBridges(ctx context.Context) []DiscoveredHueBridge {
+	found, err := s.Find(ctx, lights.BrandHue)
+	if err != nil {
+		log.Printf("[discovery] Hue bridge search: %v", err)
+	}
+	bridges := make([]DiscoveredHueBridge, 0, len(found))
+	for _, f := range found {
+		name := f.Name
+		if name == "" {
+			name = "Hue Bridge"
 		}
+		bridges = append(bridges, DiscoveredHueBridge{IP: f.Host, Name: name})
+	}
+	return bridges
+}
+
+type DiscoveredNanoleaf struct {
+	IP   string `json:"ip"`
+	Name string `json:"name"`
+}
+
+// DiscoverNanoleafDevices lists the Nanoleaf controllers announcing
+// themselves over mDNS. They still need pairing before the controller can
+// reach them.
+func (s *Scanner) DiscoverNanoleafDevices(ctx context.Context) []DiscoveredNanoleaf {
+	found, err := s.Find(ctx, lights.BrandNanoleaf)
+	if err != nil {
+		log.Printf("[discovery] Nanoleaf search: %v", err)
+	}
+	devices := make([]DiscoveredNanoleaf, 0, len(found))
+	for _, f := range found {
+		devices = append(devices, DiscoveredNanoleaf{IP: f.Host, Name: f.Name})
 	}
-	return false
+	return devices
 }
@@ -0,0 +1,135 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lightsync/internal/lights"
+)
+
+// fakeProvider is a virtual controller that finds hosts through the hints
+// it is given.
+type fakeProvider struct {
+	*lights.VirtualController
+	hints []lights.DiscoveryHint
+
+	mu    sync.Mutex
+	added []Found
+}
+
+func (p *fakeProvider) DiscoveryHints() []lights.DiscoveryHint {
+	return p.hints
+}
+
+func (p *fakeProvider) AddDiscovered(host, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.added = append(p.added, Found{Host: host, Name: name})
+}
+
+func search(hosts ...string) lights.DiscoveryHint {
+	return lights.DiscoveryHint{
+		Method: lights.DiscoverSearch,
+		Search: func(ctx context.Context, found func(host, name string)) error {
+			for _, h := range hosts {
+				found(h, "")
+			}
+			return nil
+		},
+	}
+}
+
+func newTestScanner(p *fakeProvider) *Scanner {
+	m := lights.NewManager()
+	m.RegisterController(p)
+	s := NewScanner(m)
+	s.subnets = func() []string { return []string{"10.9.8"} }
+	return s
+}
+
+func TestScanAll_RunsProviderHints(t *testing.T) {
+	probed := false
+	p := &fakeProvider{
+		VirtualController: lights.NewVirtualController(),
+		hints: []lights.DiscoveryHint{
+			search("10.0.0.5", "10.0.0.6"),
+			{
+				Method: lights.DiscoverSearch,
+				Search: func(ctx context.Context, found func(host, name string)) error {
+					found("10.0.0.5", "Desk")
+					return errors.New("cloud unreachable")
+				},
+			},
+			{
+				Method: lights.DiscoverSearch,
+				Search: func(ctx context.Context, found func(host, name string)) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+				Timeout: 50 * time.Millisecond,
+			},
+			{
+				Method:   lights.DiscoverProbe,
+				Probe:    func(context.Context, string) bool { probed = true; return false },
+				Fallback: true,
+			},
+		},
+	}
+	p.SetDevices([]lights.VirtualDevice{{ID: "a"}})
+	s := newTestScanner(p)
+
+	var phases []string
+	result := s.ScanAll(context.Background(), func(sp ScanProgress) {
+		phases = append(phases, sp.Phase)
+	})
+
+	names := make(map[string]string)
+	for _, f := range p.added {
+		names[f.Host] = f.Name
+	}
+	if len(p.added) != 2 || names["10.0.0.5"] != "Desk" || names["10.0.0.6"] != "" {
+		t.Fatalf("expected each host once with its name, got %+v", p.added)
+	}
+	if probed {
+		t.Fatalf("fallback hints must not run when the others found something")
+	}
+	if len(result.Errors) != 2 || !strings.Contains(strings.Join(result.Errors, "\n"), "virtual: search: cloud unreachable") {
+		t.Fatalf("expected the failed and timed-out hints reported, got %v", result.Errors)
+	}
+	if len(result.Devices) != 1 {
+		t.Fatalf("expected the controllers' own discovery to run, got %+v", result.Devices)
+	}
+	if phases[0] != "virtual" || phases[len(phases)-1] != "done" {
+		t.Fatalf("unexpected phases %v", phases)
+	}
+}
+
+func TestFind_FallsBackToSubnetProbe(t *testing.T) {
+	p := &fakeProvider{
+		VirtualController: lights.NewVirtualController(),
+		hints: []lights.DiscoveryHint{
+			search(),
+			{
+				Method:   lights.DiscoverProbe,
+				Probe:    func(_ context.Context, host string) bool { return host == "10.9.8.7" },
+				Fallback: true,
+			},
+		},
+	}
+	s := newTestScanner(p)
+
+	found, err := s.Find(context.Background(), lights.BrandVirtual)
+	if err != nil || len(found) != 1 || found[0].Host != "10.9.8.7" {
+		t.Fatalf("expected the probe to find the host, got %+v %v", found, err)
+	}
+	if len(p.added) != 0 {
+		t.Fatalf("Find must not register what it finds, got %+v", p.added)
+	}
+	if _, err := s.Find(context.Background(), lights.BrandHue); err == nil {
+		t.Fatalf("expected an error for a brand without a provider")
+	}
+}
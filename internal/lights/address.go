@@ -0,0 +1,156 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrNoDeviceAtAddress is returned when nothing of the requested brand
+// answers at an address.
+var ErrNoDeviceAtAddress = errors.New("no device answered at that address")
+
+// ErrAddressNotSupported is returned for brands that can't be added by
+// address.
+var ErrAddressNotSupported = errors.New("brand can't be added by address")
+
+// ErrPairingRequired is returned when the address belongs to a Hue bridge
+// that hasn't been paired yet; pairing it adds its lights.
+var ErrPairingRequired = errors.New("bridge must be paired first")
+
+// ManualAddress is an address a device was added by. Discovery probes it
+// directly, alongside the brand's broadcast and mDNS scans.
+type ManualAddress struct {
+	Brand   Brand  `json:"brand"`
+	Address string `json:"address"`
+}
+
+// SetManualAddresses replaces the remembered manual addresses.
+func (m *Manager) SetManualAddresses(addrs []ManualAddress) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addresses = append([]ManualAddress(nil), addrs...)
+}
+
+// ManualAddresses returns the remembered manual addresses in the order they
+// were added.
+func (m *Manager) ManualAddresses() []ManualAddress {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]ManualAddress(nil), m.addresses...)
+}
+
+// AddDeviceByAddress probes addr (host or host:port) with brand's protocol
+// and adds the device that answers. The address is remembered so later
+// discovery runs try it again.
+func (m *Manager) AddDeviceByAddress(ctx context.Context, brand Brand, addr string) (Device, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return Device{}, fmt.Errorf("address is required")
+	}
+	ctrl, ok := m.GetController(brand)
+	if !ok {
+		return Device{}, fmt.Errorf("no controller for brand %q", brand)
+	}
+	prober, ok := ctrl.(AddressProber)
+	if !ok {
+		return Device{}, fmt.Errorf("%s: %w", brand, ErrAddressNotSupported)
+	}
+
+	log.Printf("[manager] Probing %s at %s", brand, addr)
+	d, err := prober.ProbeAddress(ctx, addr)
+	if err != nil {
+		return Device{}, fmt.Errorf("%s at %s: %w", brand, addr, err)
+	}
+	m.addDevices([]Device{d})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.addresses {
+		if a.Brand == brand && a.Address == addr {
+			return d, nil
+		}
+	}
+	m.addresses = append(m.addresses, ManualAddress{Brand: brand, Address: addr})
+	return d, nil
+}
+
+// ProbeManualAddresses probes every remembered address and adds the devices
+// that answer, so they can be controlled before the first discovery run.
+func (m *Manager) ProbeManualAddresses(ctx context.Context) []Device {
+	m.mu.RLock()
+	controllers := make([]Controller, 0, len(m.controllers))
+	for _, c := range m.controllers {
+		controllers = append(controllers, c)
+	}
+	m.mu.RUnlock()
+
+	var found []Device
+	for _, ctrl := range controllers {
+		found = append(found, m.probeAddresses(ctx, ctrl, nil)...)
+	}
+	m.addDevices(found)
+	return found
+}
+
+// probeAddresses probes ctrl's remembered addresses whose host isn't among
+// found and returns the devices that answered. Failures are only logged; a
+// light that is switched off at the wall isn't an error.
+func (m *Manager) probeAddresses(ctx context.Context, ctrl Controller, found []Device) []Device {
+	prober, ok := ctrl.(AddressProber)
+	if !ok {
+		return nil
+	}
+	seen := make(map[string]bool, len(found))
+	for _, d := range found {
+		seen[d.LastIP] = true
+	}
+
+	var result []Device
+	for _, a := range m.ManualAddresses() {
+		if a.Brand != ctrl.Brand() || seen[addressHost(a.Address)] {
+			continue
+		}
+		d, err := prober.ProbeAddress(ctx, a.Address)
+		if err != nil {
+			log.Printf("[manager] No %s device at %s: %v", a.Brand, a.Address, err)
+			continue
+		}
+		seen[addressHost(a.Address)] = true
+		result = append(result, d)
+	}
+	return result
+}
+
+// forgetAddressesLocked drops the manual addresses pointing at d, so a
+// removed device isn't added back by the next discovery. m.mu must be held.
+func (m *Manager) forgetAddressesLocked(d Device) {
+	kept := m.addresses[:0]
+	for _, a := range m.addresses {
+		if a.Brand == d.Brand && d.LastIP != "" && addressHost(a.Address) == d.LastIP {
+			continue
+		}
+		kept = append(kept, a)
+	}
+	m.addresses = kept
+}
+
+// addressHost returns the host part of addr, which may omit the port.
+func addressHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// withDefaultPort returns addr as host:port, adding port when addr has none.
+func withDefaultPort(addr string, port int) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), strconv.Itoa(port))
+}
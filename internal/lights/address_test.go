@@ -0,0 +1,153 @@
+package lights
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+func TestAddDeviceByAddress_RememberedForDiscovery(t *testing.T) {
+	f := startFakeElgato(t, "Elgato Key Light", 53, []elgatoLight{{On: 1, Brightness: 20, Temperature: 200}})
+	addr := strings.TrimPrefix(f.http.URL, "http://")
+	ctx := context.Background()
+
+	m := NewManager()
+	m.RegisterController(NewElgatoController())
+	m.RegisterController(NewVirtualController())
+	d, err := m.AddDeviceByAddress(ctx, BrandElgato, " "+addr+" ")
+	if err != nil || d.ID != "elgato:bw33j1a01234" || d.LastIP != "127.0.0.1" {
+		t.Fatalf("AddDeviceByAddress: %+v %v", d, err)
+	}
+	if got := m.GetDevices(); len(got) != 1 || got[0].ID != d.ID {
+		t.Fatalf("expected the device to be added, got %+v", got)
+	}
+	if _, err := m.AddDeviceByAddress(ctx, BrandElgato, addr); err != nil {
+		t.Fatalf("adding again: %v", err)
+	}
+	if addrs := m.ManualAddresses(); len(addrs) != 1 || addrs[0] != (ManualAddress{Brand: BrandElgato, Address: addr}) {
+		t.Fatalf("expected the address remembered once, got %+v", addrs)
+	}
+
+	if _, err := m.AddDeviceByAddress(ctx, BrandElgato, "127.0.0.1:1"); !errors.Is(err, ErrNoDeviceAtAddress) {
+		t.Fatalf("expected ErrNoDeviceAtAddress, got %v", err)
+	}
+	if _, err := m.AddDeviceByAddress(ctx, BrandVirtual, addr); !errors.Is(err, ErrAddressNotSupported) {
+		t.Fatalf("expected ErrAddressNotSupported, got %v", err)
+	}
+	if len(m.ManualAddresses()) != 1 {
+		t.Fatalf("failed probes must not be remembered")
+	}
+
+	// After a restart the controller knows nothing; discovery probes the
+	// remembered address.
+	restarted := NewManager()
+	restarted.RegisterController(NewElgatoController())
+	restarted.SetManualAddresses(m.ManualAddresses())
+	found, err := restarted.DiscoverAllWithProgress(ctx, nil)
+	if err != nil || len(found) != 1 || found[0].ID != d.ID {
+		t.Fatalf("expected discovery to find the device at its address, got %+v %v", found, err)
+	}
+	if err := restarted.TurnOff(ctx, d.ID); err != nil {
+		t.Fatalf("TurnOff: %v", err)
+	}
+
+	restarted.RemoveDevice(d.ID)
+	if addrs := restarted.ManualAddresses(); len(addrs) != 0 {
+		t.Fatalf("expected the address forgotten with the device, got %+v", addrs)
+	}
+}
+
+func TestLIFX_ProbeAddress(t *testing.T) {
+	f := startFakeLIFX(t, 1, 2, 80, func(s *mock.Service) {
+		s.Handlers[lifxlan.GetService] = func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+			payload := make([]byte, 5)
+			payload[0] = byte(lifxlan.ServiceUDP)
+			binary.LittleEndian.PutUint32(payload[1:], uint32(conn.LocalAddr().(*net.UDPAddr).Port))
+			s.Reply(conn, addr, orig, lifxlan.StateService, payload)
+		}
+	})
+	conn, err := f.device.Dial()
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	addr := conn.RemoteAddr().String()
+	conn.Close()
+
+	c := NewLIFXController()
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d, err := c.ProbeAddress(ctx, addr)
+	if err != nil {
+		t.Fatalf("ProbeAddress: %v", err)
+	}
+	if d.ID != "lifx:"+mock.Target.String() || d.Model != "LIFX Original 1000" || d.LastIP != "127.0.0.1" {
+		t.Fatalf("unexpected device %+v", d)
+	}
+	if err := c.TurnOn(ctx, d.ID); err != nil {
+		t.Fatalf("TurnOn: %v", err)
+	}
+
+	f.service.Stop()
+	if _, err := c.ProbeAddress(ctx, addr); !errors.Is(err, ErrNoDeviceAtAddress) {
+		t.Fatalf("expected ErrNoDeviceAtAddress from a silent address, got %v", err)
+	}
+}
+
+func TestGovee_ProbeAddress(t *testing.T) {
+	startFakeGovee(t)
+	c := NewGoveeController()
+	t.Cleanup(func() { c.Close() })
+
+	start := time.Now()
+	d, err := c.ProbeAddress(context.Background(), "127.0.0.1:4003")
+	if err != nil {
+		t.Fatalf("ProbeAddress: %v", err)
+	}
+	if d.ID != "govee:aabbccddeeff0011" || d.Model != "H6076" || d.LastIP != "127.0.0.1" {
+		t.Fatalf("unexpected device %+v", d)
+	}
+	// The scan reply wakes ProbeAddress; it doesn't wait for a resend.
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("ProbeAddress took %v after the reply", elapsed)
+	}
+	c.mu.RLock()
+	waiters := len(c.scanWait)
+	c.mu.RUnlock()
+	if waiters != 0 {
+		t.Fatalf("expected the scan waiter to be removed, %d left", waiters)
+	}
+}
+
+func TestHue_ProbeAddressNeedsPairing(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/0/config" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": "Hallway", "bridgeid": "001788FFFE123456"})
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	c := NewHueController()
+	if _, err := c.ProbeAddress(context.Background(), addr); !errors.Is(err, ErrPairingRequired) {
+		t.Fatalf("expected ErrPairingRequired, got %v", err)
+	}
+
+	other := httptest.NewTLSServer(http.NotFoundHandler())
+	defer other.Close()
+	if _, err := c.ProbeAddress(context.Background(), strings.TrimPrefix(other.URL, "https://")); !errors.Is(err, ErrNoDeviceAtAddress) {
+		t.Fatalf("expected ErrNoDeviceAtAddress for something that isn't a bridge, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package lights
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Calibration corrects for how a device renders colour, so the same
+// DeviceState looks alike on every brand. Manager applies it to every
+// command and stream frame; the state cache and subscribers keep seeing the
+// requested state. Zero fields leave their correction off.
+type Calibration struct {
+	// RedGain, GreenGain and BlueGain scale each channel of the colours sent
+	// to the device. Zero means 1.
+	RedGain   float64 `json:"redGain,omitempty"`
+	GreenGain float64 `json:"greenGain,omitempty"`
+	BlueGain  float64 `json:"blueGain,omitempty"`
+	// WhitePoint is the colour temperature in Kelvin the device shows when
+	// sent pure white. Colours are corrected so that white comes out at
+	// 6500K, on top of the channel gains.
+	WhitePoint int `json:"whitePoint,omitempty"`
+	// Gamma shapes the brightness response: brightness b is sent as b^Gamma.
+	Gamma float64 `json:"gamma,omitempty"`
+	// HueOffset rotates colours by this many degrees.
+	HueOffset float64 `json:"hueOffset,omitempty"`
+	// MinBrightness is the lowest brightness (0–1) at which the device is
+	// visibly lit. Brightness above zero is mapped onto
+	// MinBrightness–MaxBrightness.
+	MinBrightness float64 `json:"minBrightness,omitempty"`
+	// MaxBrightness scales full brightness down for a device that is
+	// brighter than the others. Zero means 1.
+	MaxBrightness float64 `json:"maxBrightness,omitempty"`
+}
+
+// calibrationWhite is the white point colours are corrected to.
+const calibrationWhite = 6500
+
+// gains returns the channel multipliers, including the white point
+// correction, normalised so that none exceeds 1 unless set explicitly.
+func (c Calibration) gains() [3]float64 {
+	g := [3]float64{orOne(c.RedGain), orOne(c.GreenGain), orOne(c.BlueGain)}
+	if c.WhitePoint > 0 {
+		wr, wg, wb := KelvinToRGB(c.WhitePoint)
+		tr, tg, tb := KelvinToRGB(calibrationWhite)
+		wp := [3]float64{
+			float64(tr) / math.Max(float64(wr), 1),
+			float64(tg) / math.Max(float64(wg), 1),
+			float64(tb) / math.Max(float64(wb), 1),
+		}
+		peak := math.Max(wp[0], math.Max(wp[1], wp[2]))
+		for i := range g {
+			g[i] *= wp[i] / peak
+		}
+	}
+	return g
+}
+
+// Apply returns state corrected for the device. Kelvin is left alone, as
+// devices with native white render it themselves.
+func (c Calibration) Apply(state DeviceState) DeviceState {
+	state = state.clone()
+	if state.Color != nil {
+		col := c.applyColor(*state.Color)
+		state.Color = &col
+	}
+	for i, z := range state.Zones {
+		state.Zones[i] = c.applyColor(z)
+	}
+	if state.Brightness > 0 {
+		b := state.Brightness
+		if c.Gamma > 0 {
+			b = math.Pow(b, c.Gamma)
+		}
+		lo, hi := c.MinBrightness, orOne(c.MaxBrightness)
+		state.Brightness = math.Max(0, math.Min(1, lo+b*(hi-lo)))
+	}
+	return state
+}
+
+func (c Calibration) applyColor(col Color) Color {
+	h := math.Mod(col.H+c.HueOffset, 360)
+	if h < 0 {
+		h += 360
+	}
+	g := c.gains()
+	if g == [3]float64{1, 1, 1} {
+		return Color{H: h, S: col.S, B: col.B}
+	}
+	r, gr, b := hsbToRGBFloat(h, col.S, 1)
+	r, gr, b = math.Min(r*g[0], 1), math.Min(gr*g[1], 1), math.Min(b*g[2], 1)
+	h, s, v := rgbFloatToHSB(r, gr, b)
+	return Color{H: h, S: s, B: col.B * v}
+}
+
+func orOne(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	return v
+}
+
+// ReferenceColor is one step of the calibration sequence.
+type ReferenceColor struct {
+	Name  string      `json:"name"`
+	State DeviceState `json:"state"`
+}
+
+func referenceState(h, s, b float64) DeviceState {
+	return DeviceState{On: true, Brightness: b, Color: &Color{H: h, S: s, B: 1}}
+}
+
+func referenceWhite(k int, b float64) DeviceState {
+	return DeviceState{On: true, Brightness: b, Kelvin: &k}
+}
+
+// ReferenceColors are shown one at a time while calibrating, so devices can
+// be compared side by side: primaries and secondaries for gains and hue
+// offset, whites for the white point, and dim levels for gamma and minimum
+// brightness.
+var ReferenceColors = []ReferenceColor{
+	{Name: "Red", State: referenceState(0, 1, 1)},
+	{Name: "Orange", State: referenceState(30, 1, 1)},
+	{Name: "Yellow", State: referenceState(60, 1, 1)},
+	{Name: "Green", State: referenceState(120, 1, 1)},
+	{Name: "Cyan", State: referenceState(180, 1, 1)},
+	{Name: "Blue", State: referenceState(240, 1, 1)},
+	{Name: "Magenta", State: referenceState(300, 1, 1)},
+	{Name: "White", State: referenceState(0, 0, 1)},
+	{Name: "Daylight 6500K", State: referenceWhite(6500, 1)},
+	{Name: "Warm white 2700K", State: referenceWhite(2700, 1)},
+	{Name: "White at 50%", State: referenceState(0, 0, 0.5)},
+	{Name: "White at 10%", State: referenceState(0, 0, 0.1)},
+	{Name: "White at 1%", State: referenceState(0, 0, 0.01)},
+}
+
+// ShowReference sends step of ReferenceColors to deviceID with the device's
+// calibration applied, wrapping around past the last step.
+func (m *Manager) ShowReference(ctx context.Context, deviceID string, step int) (ReferenceColor, error) {
+	n := len(ReferenceColors)
+	ref := ReferenceColors[((step%n)+n)%n]
+	if err := m.SetDeviceState(ctx, deviceID, ref.State.clone()); err != nil {
+		return ReferenceColor{}, err
+	}
+	return ref, nil
+}
+
+// SetCalibration stores deviceID's calibration profile; nil removes it. It
+// applies from the next command.
+func (m *Manager) SetCalibration(deviceID string, cal *Calibration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.devices[deviceID]
+	if !ok {
+		return fmt.Errorf("unknown device %q", deviceID)
+	}
+	if cal != nil {
+		c := *cal
+		cal = &c
+	}
+	d.Calibration = cal
+	m.devices[deviceID] = d
+	return nil
+}
+
+// calibrate returns state as it should be sent to deviceID.
+func (m *Manager) calibrate(deviceID string, state DeviceState) DeviceState {
+	m.mu.RLock()
+	cal := m.devices[deviceID].Calibration
+	m.mu.RUnlock()
+	if cal == nil {
+		return state
+	}
+	return cal.Apply(state)
+}
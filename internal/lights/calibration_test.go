@@ -0,0 +1,70 @@
+package lights
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestCalibration_Apply(t *testing.T) {
+	cal := Calibration{HueOffset: 10, Gamma: 2, MinBrightness: 0.1, MaxBrightness: 0.8}
+	st := cal.Apply(DeviceState{On: true, Brightness: 0.5, Color: &Color{H: 355, S: 1, B: 1}})
+	if math.Abs(st.Color.H-5) > 0.01 || st.Color.S != 1 || st.Color.B != 1 {
+		t.Fatalf("expected the hue to wrap round to 5, got %+v", st.Color)
+	}
+	// 0.5^2 = 0.25, mapped onto 0.1–0.8.
+	if math.Abs(st.Brightness-0.275) > 1e-9 {
+		t.Fatalf("expected brightness 0.275, got %v", st.Brightness)
+	}
+	if off := cal.Apply(DeviceState{Brightness: 0}); off.Brightness != 0 {
+		t.Fatalf("zero brightness must stay zero, got %v", off.Brightness)
+	}
+
+	// A device that renders white too blue has its blue channel cut.
+	cool := Calibration{WhitePoint: 9000}
+	white := cool.Apply(DeviceState{On: true, Brightness: 1, Color: &Color{H: 0, S: 0, B: 1}})
+	r, g, b := HSBToRGB(white.Color.H, white.Color.S, white.Color.B)
+	if r != 255 || b >= g || b > 245 {
+		t.Fatalf("expected white shifted warm, got rgb(%d, %d, %d)", r, g, b)
+	}
+	if g := (Calibration{RedGain: 0.5}).gains(); g != [3]float64{0.5, 1, 1} {
+		t.Fatalf("unexpected gains %v", g)
+	}
+}
+
+func TestCalibration_ManagerSendsCorrectedState(t *testing.T) {
+	c := NewVirtualController()
+	c.SetDevices([]VirtualDevice{{ID: "a", Capabilities: Capabilities{Color: true, Segments: 1}}})
+	m := NewManager()
+	m.RegisterController(c)
+	ctx := context.Background()
+	devices, _ := c.Discover(ctx)
+	m.SetDevices(devices)
+	if err := m.SetCalibration("virtual:a", &Calibration{HueOffset: 20, MaxBrightness: 0.5}); err != nil {
+		t.Fatalf("SetCalibration: %v", err)
+	}
+
+	state := DeviceState{On: true, Brightness: 1, Color: &Color{H: 100, S: 1, B: 1}}.WithTransition(0)
+	if err := m.SetDeviceState(ctx, "virtual:a", state); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	if err := m.SetDeviceStates(ctx, map[string]DeviceState{"virtual:a": state}); err != nil {
+		t.Fatalf("SetDeviceStates: %v", err)
+	}
+	for _, rec := range m.CommandHistory("virtual:a") {
+		if rec.State.Color.H != 120 || rec.State.Brightness != 0.5 {
+			t.Fatalf("expected the calibrated state to be sent, got %+v (color %+v)", rec.State, rec.State.Color)
+		}
+	}
+	if st, _ := m.CachedState("virtual:a"); st.Color.H != 100 || st.Brightness != 1 {
+		t.Fatalf("expected the cache to keep the requested state, got %+v", st)
+	}
+
+	ref, err := m.ShowReference(ctx, "virtual:a", len(ReferenceColors)+5)
+	if err != nil || ref.Name != ReferenceColors[5].Name {
+		t.Fatalf("expected step 5 after wrapping, got %q (%v)", ref.Name, err)
+	}
+	if err := m.SetCalibration("virtual:missing", nil); err == nil {
+		t.Fatalf("expected an error for an unknown device")
+	}
+}
@@ -0,0 +1,83 @@
+package lights
+
+import "math"
+
+// Gamut identifies the colour gamut a device can reproduce. Hue lights report
+// one of the lettered Philips gamuts; other brands leave it empty.
+type Gamut string
+
+const (
+	GamutUnknown Gamut = ""
+	GamutA       Gamut = "A"
+	GamutB       Gamut = "B"
+	GamutC       Gamut = "C"
+	GamutOther   Gamut = "other"
+)
+
+// Capabilities describes what a single device can do. Controllers build it
+// from discovery data; Manager, the scene editor and screen sync consult it
+// instead of switching on brand.
+type Capabilities struct {
+	Color bool  `json:"color"`
+	Gamut Gamut `json:"gamut,omitempty"`
+
+	Kelvin bool `json:"kelvin"`
+	// MinKelvin/MaxKelvin bound the colour-temperature range. Zero means unknown.
+	MinKelvin  int `json:"minKelvin,omitempty"`
+	MaxKelvin  int `json:"maxKelvin,omitempty"`
+	KelvinStep int `json:"kelvinStep,omitempty"`
+
+	// MinBrightness is the lowest brightness (0–1) the device accepts while on.
+	MinBrightness float64 `json:"minBrightness,omitempty"`
+
+	// NativeTransitions is true when the device fades on its own given
+	// DeviceState.TransitionMs; otherwise Manager fades in software.
+	NativeTransitions bool `json:"nativeTransitions"`
+
+	// Segments is the number of individually addressable zones (1 for bulbs).
+	Segments int `json:"segments"`
+
+	// MaxCommandRate is the sustained commands per second the device (or its
+	// bridge) accepts. Zero means the controller declares no limit.
+	MaxCommandRate float64 `json:"maxCommandRate,omitempty"`
+
+	// ReadBack is true when GetState reports the device's real state.
+	ReadBack bool `json:"readBack"`
+}
+
+// Clamp returns state adjusted to fit within the device's limits: Kelvin is
+// clamped to the supported range and snapped to KelvinStep, and brightness
+// is raised to MinBrightness.
+func (c Capabilities) Clamp(state DeviceState) DeviceState {
+	if state.Kelvin != nil {
+		k := *state.Kelvin
+		if c.MinKelvin > 0 && k < c.MinKelvin {
+			k = c.MinKelvin
+		}
+		if c.MaxKelvin > 0 && k > c.MaxKelvin {
+			k = c.MaxKelvin
+		}
+		if c.KelvinStep > 1 {
+			k = int(math.Round(float64(k)/float64(c.KelvinStep))) * c.KelvinStep
+		}
+		state.Kelvin = &k
+	}
+	if state.Brightness < c.MinBrightness {
+		state.Brightness = c.MinBrightness
+	}
+	if state.Brightness > 1 {
+		state.Brightness = 1
+	}
+	return state
+}
+
+// applyCapabilities copies the legacy capability fields on d from caps so
+// older consumers of Device keep working.
+func (d *Device) applyCapabilities(caps Capabilities) {
+	d.SupportsColor = caps.Color
+	d.SupportsKelvin = caps.Kelvin
+	d.MinKelvin = caps.MinKelvin
+	d.MaxKelvin = caps.MaxKelvin
+	d.KelvinStep = caps.KelvinStep
+	d.Capabilities = &caps
+}
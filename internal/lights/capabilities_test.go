@@ -0,0 +1,39 @@
+package lights
+
+import "testing"
+
+func TestCapabilities_Clamp(t *testing.T) {
+	caps := Capabilities{MinKelvin: 2900, MaxKelvin: 7000, KelvinStep: 50, MinBrightness: 0.03}
+	tests := []struct {
+		name           string
+		caps           Capabilities
+		kelvin         int
+		brightness     float64
+		wantKelvin     int
+		wantBrightness float64
+	}{
+		{"within range", caps, 4000, 0.5, 4000, 0.5},
+		{"below the warmest", caps, 2000, 0.5, 2900, 0.5},
+		{"above the coolest", caps, 9000, 0.5, 7000, 0.5},
+		{"snapped to the step", caps, 4024, 0.5, 4000, 0.5},
+		{"raised to the minimum brightness", caps, 4000, 0.01, 4000, 0.03},
+		{"brightness capped at one", caps, 4000, 1.5, 4000, 1},
+		{"unknown range left alone", Capabilities{}, 12000, 0, 12000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := tt.kelvin
+			got := tt.caps.Clamp(DeviceState{On: true, Brightness: tt.brightness, Kelvin: &k})
+			if *got.Kelvin != tt.wantKelvin || got.Brightness != tt.wantBrightness {
+				t.Fatalf("expected %dK at %v, got %dK at %v", tt.wantKelvin, tt.wantBrightness, *got.Kelvin, got.Brightness)
+			}
+			if k != tt.kelvin {
+				t.Fatalf("Clamp must not modify the caller's Kelvin")
+			}
+		})
+	}
+
+	if got := caps.Clamp(DeviceState{On: true, Brightness: 0.5}); got.Kelvin != nil {
+		t.Fatalf("Clamp must not add a Kelvin, got %d", *got.Kelvin)
+	}
+}
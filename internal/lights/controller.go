@@ -1,6 +1,9 @@
 package lights
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Controller interface {
 	Brand() Brand
@@ -9,5 +12,143 @@ type Controller interface {
 	GetState(ctx context.Context, deviceID string) (DeviceState, error)
 	TurnOn(ctx context.Context, deviceID string) error
 	TurnOff(ctx context.Context, deviceID string) error
+	// Capabilities describes the device. Unknown devices get the brand's
+	// conservative defaults.
+	Capabilities(deviceID string) Capabilities
 	Close() error
 }
+
+// BatchSetter is implemented by controllers that can apply many device
+// states more cheaply than one SetState call per device. Manager prefers it
+// over per-device fan-out when it is available.
+type BatchSetter interface {
+	SetStates(ctx context.Context, states map[string]DeviceState) error
+}
+
+// Streamer is implemented by controllers with a low-latency transport for
+// continuous per-frame updates, such as Hue Entertainment. Screen sync opens
+// a stream for the devices it drives and sends frames through it instead of
+// the regular command path.
+type Streamer interface {
+	// StartStream opens a stream covering as many of deviceIDs as the
+	// controller can. It returns an error when none of them can be streamed.
+	StartStream(ctx context.Context, deviceIDs []string) (Stream, error)
+}
+
+// Stream is an open streaming session. Send is latest-wins and must not block
+// on the network; the stream transmits at its own cadence.
+type Stream interface {
+	// Devices lists the device IDs this stream addresses.
+	Devices() []string
+	Send(states map[string]DeviceState) error
+	Close() error
+}
+
+// LayoutProvider is implemented by controllers that know where each segment
+// of a device physically sits, such as Nanoleaf panels. Screen sync uses it
+// to sample the matching area of the screen for every segment.
+type LayoutProvider interface {
+	// Layout returns one position per segment in Zones order, or nil when
+	// the device's layout is unknown.
+	Layout(deviceID string) []Point
+}
+
+// CommandRecorder is implemented by controllers that keep a history of the
+// commands they receive, such as the virtual brand. Manager.CommandHistory
+// reads it.
+type CommandRecorder interface {
+	// History returns the commands recorded for deviceID, oldest first, or
+	// every recorded command when deviceID is empty.
+	History(deviceID string) []CommandRecord
+}
+
+// CommandRecord is one SetState call seen by a CommandRecorder.
+type CommandRecord struct {
+	DeviceID string      `json:"deviceId"`
+	State    DeviceState `json:"state"`
+	At       time.Time   `json:"at"`
+	// Dropped is set when the command never took effect: lost in transit or
+	// rejected (Error says why).
+	Dropped bool   `json:"dropped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Prober is implemented by controllers with a cheap way to check that a
+// device answers, used by the Manager's health checks. Probe returns nil
+// when the device responded.
+type Prober interface {
+	Probe(ctx context.Context, deviceID string) error
+}
+
+// RateBudgeter is implemented by controllers whose devices can't take
+// commands as fast as the app produces them. RateBudget returns the budget
+// deviceID draws from: devices returning the same key share it, as lights on
+// one Hue bridge do. A rate of zero means no limit. Manager spaces commands
+// to stay within each budget, keeping only the newest pending command per
+// device.
+type RateBudgeter interface {
+	RateBudget(deviceID string) (key string, perSecond float64)
+}
+
+// Identifier is implemented by controllers with a native way to make a light
+// signal itself, such as Elgato's identify endpoint. Manager.Identify flashes
+// other lights with regular commands instead.
+type Identifier interface {
+	Identify(ctx context.Context, deviceID string) error
+}
+
+// AddressProber is implemented by controllers that can find a device at a
+// known address without broadcast or mDNS discovery, for lights on another
+// subnet or behind Wi-Fi that blocks multicast. Manager.AddDeviceByAddress
+// uses it, and discovery probes the remembered addresses again.
+type AddressProber interface {
+	// ProbeAddress asks the device at addr (host or host:port, with the
+	// brand's default port) to identify itself and registers it with the
+	// controller.
+	ProbeAddress(ctx context.Context, addr string) (Device, error)
+}
+
+// DiscoveryProvider is implemented by controllers whose devices are found by
+// network searches outside Discover, such as mDNS browsing or a subnet probe.
+// The discovery scanner runs every provider's hints concurrently before
+// Manager.DiscoverAllWithProgress and passes each host found to
+// AddDiscovered.
+type DiscoveryProvider interface {
+	DiscoveryHints() []DiscoveryHint
+	// AddDiscovered is told about a host one of the hints found, with the
+	// name it announced, if any.
+	AddDiscovered(host, name string)
+}
+
+// EventSource is implemented by controllers that are told about changes
+// made outside the app, such as the Hue event stream. Manager installs its
+// handler when the controller is registered.
+type EventSource interface {
+	SetEventHandler(func(DeviceEvent))
+}
+
+type DeviceEventType string
+
+const (
+	DeviceEventState   DeviceEventType = "state"
+	DeviceEventAdded   DeviceEventType = "added"
+	DeviceEventRemoved DeviceEventType = "removed"
+	// Online and offline events come from the Manager's health checks.
+	DeviceEventOnline  DeviceEventType = "online"
+	DeviceEventOffline DeviceEventType = "offline"
+	// Renamed events come from discovery when a device known under an
+	// IP-based ID is found under its hardware ID.
+	DeviceEventRenamed DeviceEventType = "renamed"
+)
+
+// DeviceEvent is a change pushed by a device or its bridge.
+type DeviceEvent struct {
+	Type     DeviceEventType `json:"type"`
+	DeviceID string          `json:"deviceId"`
+	// State is the device's full state after a state event.
+	State *DeviceState `json:"state,omitempty"`
+	// Device describes the device for an added event.
+	Device *Device `json:"device,omitempty"`
+	// PreviousID is the device's old ID for a renamed event.
+	PreviousID string `json:"previousId,omitempty"`
+}
@@ -0,0 +1,43 @@
+package lights
+
+import (
+	"context"
+	"time"
+)
+
+// DiscoveryMethod is how a DiscoveryHint searches the network.
+type DiscoveryMethod string
+
+const (
+	// DiscoverMDNS browses for the mDNS service types in Targets, such as
+	// "_elg._tcp". Hosts are named after the announced instance.
+	DiscoverMDNS DiscoveryMethod = "mdns"
+	// DiscoverSSDP multicasts an M-SEARCH for each search target in Targets
+	// and keeps the responses Match accepts.
+	DiscoverSSDP DiscoveryMethod = "ssdp"
+	// DiscoverProbe calls Probe for every address on the local /24 subnets.
+	DiscoverProbe DiscoveryMethod = "probe"
+	// DiscoverSearch runs Search, for lookups the scanner has no runner for,
+	// such as a brand's UDP broadcast or a cloud service.
+	DiscoverSearch DiscoveryMethod = "search"
+)
+
+// DiscoveryHint describes one way to find a brand's devices on the network.
+// Only the fields of its Method are used.
+type DiscoveryHint struct {
+	Method  DiscoveryMethod
+	Targets []string
+	// Match reports whether an SSDP response comes from the brand's device;
+	// nil accepts every response.
+	Match func(response string) bool
+	// Probe reports whether host is the brand's device. It is called for
+	// many hosts at once and should answer within a second.
+	Probe func(ctx context.Context, host string) bool
+	// Search calls found for every host it turns up.
+	Search func(ctx context.Context, found func(host, name string)) error
+	// Fallback hints run only when the provider's other hints found
+	// nothing, since subnet probes are slow and noisy.
+	Fallback bool
+	// Timeout bounds the hint; zero uses the scanner's default.
+	Timeout time.Duration
+}
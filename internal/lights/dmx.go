@@ -0,0 +1,516 @@
+package lights
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DMXProtocol selects how a fixture's universe is sent on the network.
+type DMXProtocol string
+
+const (
+	DMXProtocolArtNet DMXProtocol = "artnet"
+	DMXProtocolSACN   DMXProtocol = "sacn"
+)
+
+// DMXLayout is the channel layout of one fixture (or of each pixel of a
+// pixel controller), starting at its start channel.
+type DMXLayout string
+
+const (
+	DMXLayoutRGB  DMXLayout = "rgb"  // red, green, blue
+	DMXLayoutRGBW DMXLayout = "rgbw" // red, green, blue, white
+	DMXLayoutDRGB DMXLayout = "drgb" // master dimmer, red, green, blue
+	DMXLayoutCCT  DMXLayout = "cct"  // warm white, cool white
+)
+
+// DMXFixture is a user-defined DMX device. It is persisted in the store and
+// shows up as a light with ID "dmx:<ID>".
+type DMXFixture struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name"`
+	Protocol DMXProtocol `json:"protocol"`
+	// Host is the unicast target (IP or IP:port). Empty sends Art-Net as a
+	// broadcast and sACN to the universe's multicast group.
+	Host         string    `json:"host,omitempty"`
+	Universe     int       `json:"universe"`
+	StartChannel int       `json:"startChannel"` // 1–512
+	Layout       DMXLayout `json:"layout"`
+	// Pixels repeats Layout for pixel controllers; each pixel is one zone.
+	// Zero means a single fixture.
+	Pixels int `json:"pixels,omitempty"`
+	// Gamma is applied to every channel value; zero means linear (1.0).
+	Gamma float64 `json:"gamma,omitempty"`
+	// MinKelvin/MaxKelvin are the warm and cool white points of CCT fixtures.
+	MinKelvin int `json:"minKelvin,omitempty"`
+	MaxKelvin int `json:"maxKelvin,omitempty"`
+}
+
+const (
+	dmxUniverseSize = 512
+	artNetPort      = 6454
+	sACNPort        = 5568
+	// dmxKeepalive is how often an unchanged universe is re-sent. Art-Net
+	// nodes and sACN receivers drop their output after a few seconds of
+	// silence (sACN after 2.5 s).
+	dmxKeepalive = time.Second
+	// dmxDefaultMinKelvin/MaxKelvin apply to CCT fixtures without white
+	// points of their own.
+	dmxDefaultMinKelvin = 2700
+	dmxDefaultMaxKelvin = 6500
+)
+
+func (l DMXLayout) channels() int {
+	switch l {
+	case DMXLayoutRGBW, DMXLayoutDRGB:
+		return 4
+	case DMXLayoutCCT:
+		return 2
+	default:
+		return 3
+	}
+}
+
+type DMXController struct {
+	mu        sync.Mutex
+	fixtures  map[string]DMXFixture
+	states    map[string]DeviceState
+	universes map[dmxUniverseKey]*dmxUniverse
+	conn      net.PacketConn
+	cid       [16]byte
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// dmxUniverseKey identifies one output stream: fixtures sharing protocol,
+// target and universe are packed into the same packet.
+type dmxUniverseKey struct {
+	protocol DMXProtocol
+	host     string
+	universe int
+}
+
+type dmxUniverse struct {
+	data   [dmxUniverseSize]byte
+	seq    uint8
+	sentAt time.Time
+}
+
+func NewDMXController() *DMXController {
+	return &DMXController{
+		fixtures:  make(map[string]DMXFixture),
+		states:    make(map[string]DeviceState),
+		universes: make(map[dmxUniverseKey]*dmxUniverse),
+		cid:       uuid.New(),
+	}
+}
+
+func (c *DMXController) Brand() Brand {
+	return BrandDMX
+}
+
+// SetFixtures replaces the configured fixtures. Channels of removed fixtures
+// keep their last value until another fixture overwrites them.
+func (c *DMXController) SetFixtures(fixtures []DMXFixture) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fixtures = make(map[string]DMXFixture, len(fixtures))
+	for _, f := range fixtures {
+		c.fixtures[dmxDeviceID(f.ID)] = f
+	}
+	for id := range c.states {
+		if _, ok := c.fixtures[id]; !ok {
+			delete(c.states, id)
+		}
+	}
+}
+
+func dmxDeviceID(fixtureID string) string {
+	return fmt.Sprintf("dmx:%s", fixtureID)
+}
+
+func (c *DMXController) Capabilities(deviceID string) Capabilities {
+	c.mu.Lock()
+	f, ok := c.fixtures[deviceID]
+	c.mu.Unlock()
+	if !ok {
+		return Capabilities{Color: true, Kelvin: true, Segments: 1}
+	}
+	return f.capabilities()
+}
+
+// capabilities describes a fixture. RGB fixtures render white by
+// approximation, CCT fixtures mix between their two white points.
+func (f DMXFixture) capabilities() Capabilities {
+	caps := Capabilities{
+		Color:      f.Layout != DMXLayoutCCT,
+		Kelvin:     true,
+		MinKelvin:  2000,
+		MaxKelvin:  6500,
+		KelvinStep: 1,
+		Segments:   max(1, f.Pixels),
+	}
+	if f.Layout == DMXLayoutCCT {
+		caps.MinKelvin, caps.MaxKelvin = f.whitePoints()
+	}
+	return caps
+}
+
+func (f DMXFixture) whitePoints() (int, int) {
+	lo, hi := f.MinKelvin, f.MaxKelvin
+	if lo <= 0 {
+		lo = dmxDefaultMinKelvin
+	}
+	if hi <= lo {
+		hi = dmxDefaultMaxKelvin
+	}
+	return lo, hi
+}
+
+// Discover lists the configured fixtures; DMX has no discovery.
+func (c *DMXController) Discover(ctx context.Context) ([]Device, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []Device
+	for id, f := range c.fixtures {
+		d := Device{
+			ID:       id,
+			Brand:    BrandDMX,
+			Name:     f.Name,
+			Model:    fmt.Sprintf("%s universe %d ch %d (%s)", dmxProtocolName(f.Protocol), f.Universe, f.StartChannel, f.Layout),
+			LastIP:   f.Host,
+			LastSeen: time.Now(),
+		}
+		caps := f.capabilities()
+		d.applyCapabilities(caps)
+		result = append(result, d)
+	}
+	log.Printf("[dmx] Discover: %d configured fixture(s)", len(result))
+	return result, nil
+}
+
+func dmxProtocolName(p DMXProtocol) string {
+	if p == DMXProtocolSACN {
+		return "sACN"
+	}
+	return "Art-Net"
+}
+
+func (c *DMXController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+	return c.SetStates(ctx, map[string]DeviceState{deviceID: state})
+}
+
+// SetStates writes every fixture into its universe buffer and then sends
+// each touched universe once, so a screen sync frame costs one packet per
+// universe however many fixtures it holds.
+func (c *DMXController) SetStates(_ context.Context, states map[string]DeviceState) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	touched := make(map[dmxUniverseKey]bool)
+	for id, state := range states {
+		f, ok := c.fixtures[id]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown DMX fixture %s", id))
+			continue
+		}
+		key := dmxUniverseKey{protocol: f.Protocol, host: f.Host, universe: f.Universe}
+		u := c.universes[key]
+		if u == nil {
+			u = &dmxUniverse{}
+			c.universes[key] = u
+		}
+		start := max(1, f.StartChannel) - 1
+		copy(u.data[min(start, dmxUniverseSize):], f.channelValues(state))
+		c.states[id] = state
+		touched[key] = true
+	}
+
+	for key := range touched {
+		if err := c.sendLocked(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// channelValues renders state to the fixture's DMX channels, gamma-corrected.
+func (f DMXFixture) channelValues(state DeviceState) []byte {
+	pixels := max(1, f.Pixels)
+	per := f.Layout.channels()
+	out := make([]byte, 0, pixels*per)
+
+	gamma := f.Gamma
+	if gamma <= 0 {
+		gamma = 1
+	}
+	level := func(v float64) byte {
+		v = math.Max(0, math.Min(1, v))
+		return byte(math.Round(255 * math.Pow(v, gamma)))
+	}
+
+	if !state.On {
+		return make([]byte, pixels*per)
+	}
+
+	if f.Layout == DMXLayoutCCT {
+		lo, hi := f.whitePoints()
+		k := DefaultKelvin
+		if state.Kelvin != nil {
+			k = *state.Kelvin
+		}
+		t := math.Max(0, math.Min(1, float64(k-lo)/float64(hi-lo)))
+		for i := 0; i < pixels; i++ {
+			out = append(out, level(state.Brightness*(1-t)), level(state.Brightness*t))
+		}
+		return out
+	}
+
+	if f.Layout == DMXLayoutDRGB {
+		// The dimmer channel carries brightness; colours stay at full level.
+		full := state
+		full.Brightness = 1
+		for _, px := range statePixels(full, pixels) {
+			out = append(out, level(state.Brightness), level(float64(px[0])/255), level(float64(px[1])/255), level(float64(px[2])/255))
+		}
+		return out
+	}
+
+	for _, px := range statePixels(state, pixels) {
+		r, g, b := float64(px[0])/255, float64(px[1])/255, float64(px[2])/255
+		if f.Layout == DMXLayoutRGBW {
+			w := math.Min(r, math.Min(g, b))
+			out = append(out, level(r-w), level(g-w), level(b-w), level(w))
+			continue
+		}
+		out = append(out, level(r), level(g), level(b))
+	}
+	return out
+}
+
+// sendLocked transmits one universe and starts the keepalive loop on first
+// use. Callers hold c.mu.
+func (c *DMXController) sendLocked(key dmxUniverseKey) error {
+	if c.conn == nil {
+		conn, err := net.ListenPacket("udp4", ":0")
+		if err != nil {
+			return fmt.Errorf("open DMX socket: %w", err)
+		}
+		c.conn = conn
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.keepalive(c.stop, c.done)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp4", dmxTarget(key))
+	if err != nil {
+		return err
+	}
+	u := c.universes[key]
+	u.seq++
+	if u.seq == 0 && key.protocol == DMXProtocolArtNet {
+		u.seq = 1 // 0 disables sequencing in Art-Net
+	}
+	var packet []byte
+	if key.protocol == DMXProtocolSACN {
+		packet = encodeSACN(c.cid, key.universe, u.seq, u.data[:])
+	} else {
+		packet = encodeArtDMX(key.universe, u.seq, u.data[:])
+	}
+	u.sentAt = time.Now()
+	if _, err := c.conn.WriteTo(packet, addr); err != nil {
+		return fmt.Errorf("universe %d: %w", key.universe, err)
+	}
+	return nil
+}
+
+// keepalive re-sends universes that haven't changed for dmxKeepalive.
+func (c *DMXController) keepalive(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(dmxKeepalive / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		c.mu.Lock()
+		select {
+		case <-stop:
+			c.mu.Unlock()
+			return
+		default:
+		}
+		for key, u := range c.universes {
+			if time.Since(u.sentAt) >= dmxKeepalive {
+				if err := c.sendLocked(key); err != nil {
+					log.Printf("[dmx] Keepalive failed: %v", err)
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// dmxTarget returns the UDP destination for a universe. Hosts may carry a
+// port; otherwise the protocol's standard port is used.
+func dmxTarget(key dmxUniverseKey) string {
+	port := artNetPort
+	if key.protocol == DMXProtocolSACN {
+		port = sACNPort
+	}
+	host := key.host
+	if host == "" {
+		if key.protocol == DMXProtocolSACN {
+			host = fmt.Sprintf("239.255.%d.%d", (key.universe>>8)&0xff, key.universe&0xff)
+		} else {
+			host = "255.255.255.255"
+		}
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// encodeArtDMX builds an ArtDmx packet (OpCode 0x5000, protocol 14). The
+// 15-bit port address is split into Net and SubUni.
+func encodeArtDMX(universe int, seq uint8, data []byte) []byte {
+	p := make([]byte, 0, 18+len(data))
+	p = append(p, "Art-Net\x00"...)
+	p = binary.LittleEndian.AppendUint16(p, 0x5000)
+	p = binary.BigEndian.AppendUint16(p, 14)
+	p = append(p, seq, 0, byte(universe), byte(universe>>8)&0x7f)
+	p = binary.BigEndian.AppendUint16(p, uint16(len(data)))
+	return append(p, data...)
+}
+
+// encodeSACN builds an E1.31 data packet: root, framing and DMP layers
+// followed by start code 0 and the slots.
+func encodeSACN(cid [16]byte, universe int, seq uint8, data []byte) []byte {
+	total := 126 + len(data)
+	flagsLength := func(n int) uint16 { return 0x7000 | uint16(n) }
+
+	p := make([]byte, 0, total)
+	// Root layer.
+	p = binary.BigEndian.AppendUint16(p, 0x0010)
+	p = binary.BigEndian.AppendUint16(p, 0x0000)
+	p = append(p, "ASC-E1.17\x00\x00\x00"...)
+	p = binary.BigEndian.AppendUint16(p, flagsLength(total-16))
+	p = binary.BigEndian.AppendUint32(p, 0x00000004)
+	p = append(p, cid[:]...)
+	// Framing layer.
+	p = binary.BigEndian.AppendUint16(p, flagsLength(total-38))
+	p = binary.BigEndian.AppendUint32(p, 0x00000002)
+	var name [64]byte
+	copy(name[:], "LightSync")
+	p = append(p, name[:]...)
+	p = append(p, 100) // priority
+	p = binary.BigEndian.AppendUint16(p, 0)
+	p = append(p, seq, 0)
+	p = binary.BigEndian.AppendUint16(p, uint16(universe))
+	// DMP layer.
+	p = binary.BigEndian.AppendUint16(p, flagsLength(total-115))
+	p = append(p, 0x02, 0xa1)
+	p = binary.BigEndian.AppendUint16(p, 0x0000)
+	p = binary.BigEndian.AppendUint16(p, 0x0001)
+	p = binary.BigEndian.AppendUint16(p, uint16(len(data)+1))
+	p = append(p, 0x00)
+	return append(p, data...)
+}
+
+// GetState returns the last state sent to the fixture; DMX is one-way.
+func (c *DMXController) GetState(_ context.Context, deviceID string) (DeviceState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.fixtures[deviceID]; !ok {
+		return DeviceState{}, fmt.Errorf("unknown DMX fixture %s", deviceID)
+	}
+	if st, ok := c.states[deviceID]; ok {
+		return st, nil
+	}
+	return DeviceState{}, nil
+}
+
+func (c *DMXController) TurnOn(ctx context.Context, deviceID string) error {
+	st, err := c.GetState(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	st.On = true
+	if st.Brightness <= 0 {
+		st.Brightness = 1
+	}
+	return c.SetState(ctx, deviceID, st)
+}
+
+func (c *DMXController) TurnOff(ctx context.Context, deviceID string) error {
+	st, err := c.GetState(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	st.On = false
+	return c.SetState(ctx, deviceID, st)
+}
+
+func (c *DMXController) Close() error {
+	c.mu.Lock()
+	conn, done := c.conn, c.done
+	if conn != nil {
+		close(c.stop)
+	}
+	c.conn = nil
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	<-done
+	return conn.Close()
+}
+
+// StartStream lets screen sync bypass the per-brand send slot. Frames are
+// written straight into the universes; the keepalive loop keeps them alive.
+func (c *DMXController) StartStream(_ context.Context, deviceIDs []string) (Stream, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &dmxStream{ctrl: c}
+	for _, id := range deviceIDs {
+		if _, ok := c.fixtures[id]; ok {
+			s.devices = append(s.devices, id)
+		}
+	}
+	if len(s.devices) == 0 {
+		return nil, errors.New("no DMX fixtures")
+	}
+	return s, nil
+}
+
+type dmxStream struct {
+	ctrl    *DMXController
+	devices []string
+}
+
+func (s *dmxStream) Devices() []string {
+	return append([]string(nil), s.devices...)
+}
+
+func (s *dmxStream) Send(states map[string]DeviceState) error {
+	return s.ctrl.SetStates(context.Background(), states)
+}
+
+func (s *dmxStream) Close() error {
+	return nil
+}
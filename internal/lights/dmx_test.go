@@ -0,0 +1,136 @@
+package lights
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// listenDMX stands in for an Art-Net node or sACN receiver.
+func listenDMX(t *testing.T) (string, chan []byte) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	packets := make(chan []byte, 16)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			packets <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	return conn.LocalAddr().String(), packets
+}
+
+func receiveDMX(t *testing.T, packets chan []byte) []byte {
+	t.Helper()
+	select {
+	case p := <-packets:
+		return p
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no DMX packet received")
+		return nil
+	}
+}
+
+func TestDMX_ArtNetSharedUniverse(t *testing.T) {
+	addr, packets := listenDMX(t)
+	c := NewDMXController()
+	defer c.Close()
+	c.SetFixtures([]DMXFixture{
+		{ID: "par", Protocol: DMXProtocolArtNet, Host: addr, Universe: 0x123, StartChannel: 1, Layout: DMXLayoutRGB},
+		{ID: "wash", Protocol: DMXProtocolArtNet, Host: addr, Universe: 0x123, StartChannel: 10, Layout: DMXLayoutDRGB},
+	})
+
+	err := c.SetStates(context.Background(), map[string]DeviceState{
+		"dmx:par":  {On: true, Brightness: 1, Color: &Color{H: 0, S: 1, B: 1}},
+		"dmx:wash": {On: true, Brightness: 0.5, Color: &Color{H: 240, S: 1, B: 1}},
+	})
+	if err != nil {
+		t.Fatalf("SetStates: %v", err)
+	}
+
+	p := receiveDMX(t, packets)
+	if string(p[:8]) != "Art-Net\x00" || binary.LittleEndian.Uint16(p[8:]) != 0x5000 || p[13] != 0 {
+		t.Fatalf("unexpected ArtDmx header: %v", p[:14])
+	}
+	if p[14] != 0x23 || p[15] != 0x01 || binary.BigEndian.Uint16(p[16:]) != dmxUniverseSize {
+		t.Fatalf("unexpected port address/length: %v", p[14:18])
+	}
+	data := p[18:]
+	if data[0] != 255 || data[1] != 0 || data[2] != 0 {
+		t.Fatalf("expected par red, got %v", data[:3])
+	}
+	if data[9] != 128 || data[10] != 0 || data[11] != 0 || data[12] != 255 {
+		t.Fatalf("expected wash dimmer 50%% blue, got %v", data[9:13])
+	}
+	select {
+	case extra := <-packets:
+		t.Fatalf("expected one packet per universe, got another: %v", extra[:18])
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Unchanged universes are refreshed at the keepalive rate.
+	if q := receiveDMX(t, packets); q[12] == p[12] || q[18] != 255 {
+		t.Fatalf("expected keepalive with next sequence, got seq %d ch1 %d", q[12], q[18])
+	}
+}
+
+func TestDMX_SACNPacket(t *testing.T) {
+	addr, packets := listenDMX(t)
+	c := NewDMXController()
+	defer c.Close()
+	c.SetFixtures([]DMXFixture{
+		{ID: "strip", Protocol: DMXProtocolSACN, Host: addr, Universe: 7, StartChannel: 5, Layout: DMXLayoutRGBW, Pixels: 2, Gamma: 2},
+	})
+
+	err := c.SetState(context.Background(), "dmx:strip", DeviceState{
+		On: true, Brightness: 1, Zones: []Color{{H: 0, S: 0.5, B: 1}, {H: 0, S: 0, B: 0.5}},
+	})
+	if err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+
+	p := receiveDMX(t, packets)
+	if len(p) != 638 || string(p[4:16]) != "ASC-E1.17\x00\x00\x00" {
+		t.Fatalf("unexpected root layer (len %d): %v", len(p), p[:16])
+	}
+	if binary.BigEndian.Uint16(p[16:])&0x0fff != 622 || binary.BigEndian.Uint16(p[38:])&0x0fff != 600 || binary.BigEndian.Uint16(p[115:])&0x0fff != 523 {
+		t.Fatalf("unexpected PDU lengths")
+	}
+	if binary.BigEndian.Uint16(p[113:]) != 7 || p[108] != 100 || p[125] != 0 {
+		t.Fatalf("unexpected universe/priority/start code")
+	}
+	data := p[126:]
+	// Pixel 1 (pink) splits into red and white; pixel 2 (grey) is white only.
+	want := []byte{0, 0, 0, 0, 64, 0, 0, 64, 0, 0, 0, 64}
+	for i, v := range want {
+		if diff := int(data[i]) - int(v); diff < -1 || diff > 1 {
+			t.Fatalf("unexpected channels %v, want about %v", data[:12], want)
+		}
+	}
+
+	if got := dmxTarget(dmxUniverseKey{protocol: DMXProtocolSACN, universe: 300}); got != "239.255.1.44:5568" {
+		t.Fatalf("unexpected sACN multicast target %s", got)
+	}
+}
+
+func TestDMXFixture_CCT(t *testing.T) {
+	f := DMXFixture{Layout: DMXLayoutCCT, MinKelvin: 3000, MaxKelvin: 6000}
+	k := 4500
+	got := f.channelValues(DeviceState{On: true, Brightness: 1, Kelvin: &k})
+	if len(got) != 2 || got[0] != 128 || got[1] != 128 {
+		t.Fatalf("expected an even warm/cool mix, got %v", got)
+	}
+	if caps := f.capabilities(); caps.Color || caps.MinKelvin != 3000 || caps.MaxKelvin != 6000 {
+		t.Fatalf("unexpected CCT capabilities %+v", caps)
+	}
+}
@@ -1,10 +1,14 @@
 package lights
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math"
+	"net"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -12,16 +16,29 @@ import (
 	"github.com/mdlayher/keylight"
 )
 
+// ElgatoController identifies lights by the serial number in their
+// accessory info, so a light keeps its ID when its address changes. Light
+// state goes through /elgato/lights directly rather than the keylight
+// client, whose Light type has no hue or saturation for Light Strips.
 type ElgatoController struct {
 	mu      sync.RWMutex
 	clients map[string]*keylight.Client
 	addrs   map[string]string
+	// caps holds what discovery learned about each accessory: whether it
+	// takes colour, and how many lights it reports.
+	caps map[string]Capabilities
+	// pending holds addresses found by a scan whose serial isn't known yet.
+	pending map[string]bool
+	http    *http.Client
 }
 
 func NewElgatoController() *ElgatoController {
 	return &ElgatoController{
 		clients: make(map[string]*keylight.Client),
 		addrs:   make(map[string]string),
+		caps:    make(map[string]Capabilities),
+		pending: make(map[string]bool),
+		http:    &http.Client{Timeout: 3 * time.Second},
 	}
 }
 
@@ -29,139 +46,314 @@ func (c *ElgatoController) Brand() Brand {
 	return BrandElgato
 }
 
+// elgatoCapabilities describes the Key Light family: white only, 2900–7000K
+// in 50K steps, and the firmware rejects brightness below 3%.
+var elgatoCapabilities = Capabilities{
+	Kelvin:        true,
+	MinKelvin:     2900,
+	MaxKelvin:     7000,
+	KelvinStep:    50,
+	MinBrightness: 0.03,
+	Segments:      1,
+	ReadBack:      true,
+}
+
+// elgatoStripBoardType is the hardwareBoardType Light Strips report in
+// their accessory info.
+const elgatoStripBoardType = 70
+
+func (c *ElgatoController) Capabilities(deviceID string) Capabilities {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if caps, ok := c.caps[deviceID]; ok {
+		return caps
+	}
+	return elgatoCapabilities
+}
+
+// elgatoDeviceCapabilities extends the Key Light defaults for what the
+// accessory reports: Light Strips also take hue and saturation, and an
+// accessory with several lights gets one segment per light.
+func elgatoDeviceCapabilities(info *keylight.Device, lights int) Capabilities {
+	caps := elgatoCapabilities
+	if info.HardwareBoardType == elgatoStripBoardType || strings.Contains(info.ProductName, "Light Strip") {
+		caps.Color = true
+	}
+	if lights > 1 {
+		caps.Segments = lights
+	}
+	return caps
+}
+
 func (c *ElgatoController) Discover(ctx context.Context) ([]Device, error) {
+	type target struct{ id, addr string }
 	c.mu.RLock()
-	existing := make(map[string]string, len(c.addrs))
-	for k, v := range c.addrs {
-		existing[k] = v
+	targets := make([]target, 0, len(c.addrs)+len(c.pending))
+	for id, addr := range c.addrs {
+		targets = append(targets, target{id, addr})
+	}
+	for addr := range c.pending {
+		targets = append(targets, target{"", addr})
 	}
 	c.mu.RUnlock()
 
-	log.Printf("[elgato] Discover: %d known address(es) to probe", len(existing))
+	log.Printf("[elgato] Discover: %d known address(es) to probe", len(targets))
 
 	var result []Device
-	for id, addr := range existing {
-		log.Printf("[elgato] Probing %s at %s", id, addr)
-		client, err := keylight.NewClient(addr, nil)
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		dev, err := c.probe(ctx, t.id, t.addr)
 		if err != nil {
-			log.Printf("[elgato] Failed to create client for %s: %v", id, err)
+			log.Printf("[elgato] Probe of %s failed: %v", t.addr, err)
 			continue
 		}
-		d, err := client.AccessoryInfo(ctx)
-		if err != nil {
-			log.Printf("[elgato] Failed to get accessory info for %s: %v", id, err)
+		if seen[dev.ID] {
 			continue
 		}
-
-		c.mu.Lock()
-		c.clients[id] = client
-		c.mu.Unlock()
-
-		log.Printf("[elgato] Discovered: %s (%s) at %s", d.DisplayName, d.ProductName, addr)
-		result = append(result, Device{
-			ID:              id,
-			Brand:           BrandElgato,
-			Name:            d.DisplayName,
-			Model:           d.ProductName,
-			LastIP:          addr,
-			LastSeen:        time.Now(),
-			SupportsColor:   false,
-			SupportsKelvin:  true,
-			MinKelvin:       2900,
-			MaxKelvin:       7000,
-			KelvinStep:      50,
-			FirmwareVersion: d.FirmwareVersion,
-		})
+		seen[dev.ID] = true
+		result = append(result, dev)
 	}
 
 	return result, nil
 }
 
-func (c *ElgatoController) AddDevice(addr string) {
-	deviceID := fmt.Sprintf("elgato:%s", addr)
-	fullAddr := fmt.Sprintf("http://%s:9123", addr)
-	log.Printf("[elgato] Adding device %s at %s", deviceID, fullAddr)
-	client, err := keylight.NewClient(fullAddr, nil)
+// ProbeAddress implements AddressProber by reading the accessory info at
+// addr, on port 9123 unless another is given.
+func (c *ElgatoController) ProbeAddress(ctx context.Context, addr string) (Device, error) {
+	d, err := c.probe(ctx, "", "http://"+withDefaultPort(addr, elgatoPort))
 	if err != nil {
-		log.Printf("[elgato] Failed to create client for %s: %v", fullAddr, err)
-		return
+		return Device{}, fmt.Errorf("%w: %v", ErrNoDeviceAtAddress, err)
 	}
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.clients[deviceID] = client
-	c.addrs[deviceID] = fullAddr
-	log.Printf("[elgato] Device %s registered successfully", deviceID)
+	return d, nil
 }
 
-func (c *ElgatoController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
-	client, err := c.getClient(deviceID)
+// probe reads the accessory info and lights at addr and registers the
+// accessory under its serial-based ID. knownID is the ID addr was last
+// registered under, if any.
+func (c *ElgatoController) probe(ctx context.Context, knownID, addr string) (Device, error) {
+	log.Printf("[elgato] Probing %s", addr)
+	client, err := keylight.NewClient(addr, nil)
 	if err != nil {
-		return err
+		return Device{}, fmt.Errorf("create client: %w", err)
+	}
+	d, err := client.AccessoryInfo(ctx)
+	if err != nil {
+		return Device{}, fmt.Errorf("get accessory info: %w", err)
+	}
+	var ll elgatoLights
+	if err := c.do(ctx, http.MethodGet, addr, nil, &ll); err != nil {
+		return Device{}, fmt.Errorf("get lights: %w", err)
+	}
+	caps := elgatoDeviceCapabilities(d, len(ll.Lights))
+
+	host := hostOf(addr)
+	id := hardwareDeviceID(BrandElgato, d.SerialNumber, host)
+	c.mu.Lock()
+	delete(c.pending, addr)
+	if knownID != "" && knownID != id {
+		// A legacy IP-based ID, or another light now holds the address.
+		delete(c.clients, knownID)
+		delete(c.addrs, knownID)
+		delete(c.caps, knownID)
+	}
+	if prev, ok := c.addrs[id]; ok && prev != addr {
+		log.Printf("[elgato] %s moved from %s to %s", id, prev, addr)
 	}
+	c.clients[id] = client
+	c.addrs[id] = addr
+	c.caps[id] = caps
+	c.mu.Unlock()
 
-	temp := 4000
-	if state.Kelvin != nil {
-		temp = *state.Kelvin
+	log.Printf("[elgato] Discovered: %s (%s, %d light(s)) at %s", d.DisplayName, d.ProductName, len(ll.Lights), addr)
+	dev := Device{
+		ID:              id,
+		Brand:           BrandElgato,
+		Name:            d.DisplayName,
+		Model:           d.ProductName,
+		LastIP:          host,
+		LastSeen:        time.Now(),
+		FirmwareVersion: d.FirmwareVersion,
 	}
-	if temp < 2900 {
-		temp = 2900
+	dev.applyCapabilities(caps)
+	return dev, nil
+}
+
+// AddDevice queues an address found by a scan. The light's ID is read from
+// its accessory info at the next Discover.
+func (c *ElgatoController) AddDevice(addr string) {
+	fullAddr := elgatoAddr(addr)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, known := range c.addrs {
+		if known == fullAddr {
+			return
+		}
 	}
-	if temp > 7000 {
-		temp = 7000
+	log.Printf("[elgato] Adding address %s", fullAddr)
+	c.pending[fullAddr] = true
+}
+
+// DiscoveryHints implements DiscoveryProvider: accessories announce
+// themselves over mDNS, and a probe of port 9123 across the subnet finds
+// those on networks that filter multicast.
+func (c *ElgatoController) DiscoveryHints() []DiscoveryHint {
+	return []DiscoveryHint{
+		{Method: DiscoverMDNS, Targets: []string{"_elg._tcp"}},
+		{Method: DiscoverProbe, Probe: isElgatoAccessory, Fallback: true},
 	}
+}
 
-	// Library requires brightness in [3, 100]; use Round to avoid float truncation.
-	brightness := int(math.Round(state.Brightness * 100))
-	if brightness < 3 {
-		brightness = 3
+// AddDiscovered implements DiscoveryProvider by queueing host for the next
+// Discover.
+func (c *ElgatoController) AddDiscovered(host, _ string) {
+	c.AddDevice(host)
+}
+
+// isElgatoAccessory reports whether host serves the accessory info.
+func isElgatoAccessory(ctx context.Context, host string) bool {
+	client := &http.Client{Timeout: 800 * time.Millisecond}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, elgatoAddr(host)+"/elgato/accessory-info", nil)
+	if err != nil {
+		return false
 	}
-	if brightness > 100 {
-		brightness = 100
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
 	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
 
-	ll := []*keylight.Light{
-		{
-			On:          state.On,
-			Brightness:  brightness,
-			Temperature: temp,
-		},
+// AddKnownDevice registers a light from the store at its last known
+// address, so it can be controlled before the first scan.
+func (c *ElgatoController) AddKnownDevice(deviceID, lastIP string) {
+	host := hostOf(lastIP)
+	if host == "" && net.ParseIP(ipFromDeviceID(deviceID)) != nil {
+		host = ipFromDeviceID(deviceID) // legacy IP-based ID
 	}
+	if host == "" {
+		return
+	}
+	c.mu.Lock()
+	c.addrs[deviceID] = elgatoAddr(host)
+	c.mu.Unlock()
+}
 
-	if err := client.SetLights(ctx, ll); err != nil {
-		log.Printf("[elgato] SetLights failed for %s, reconnecting: %v", deviceID, err)
-		client, err = c.reconnect(deviceID)
-		if err != nil {
-			return err
+// elgatoPort is the accessories' HTTP API port.
+const elgatoPort = 9123
+
+func elgatoAddr(host string) string {
+	return fmt.Sprintf("http://%s:%d", host, elgatoPort)
+}
+
+// elgatoLight is one light in /elgato/lights. Temperature is in the API's
+// mired-like units; Light Strips showing a colour report hue (0–360) and
+// saturation (0–100) instead.
+type elgatoLight struct {
+	On          int      `json:"on"`
+	Brightness  int      `json:"brightness"`
+	Temperature int      `json:"temperature,omitempty"`
+	Hue         *float64 `json:"hue,omitempty"`
+	Saturation  *float64 `json:"saturation,omitempty"`
+}
+
+type elgatoLights struct {
+	NumberOfLights int           `json:"numberOfLights"`
+	Lights         []elgatoLight `json:"lights"`
+}
+
+// elgatoTemperature converts Kelvin to the API's temperature value, which
+// the firmware accepts from 143 (7000K) to 344 (2900K).
+func elgatoTemperature(kelvin int) int {
+	return min(344, max(143, int(math.Round(1e6/float64(kelvin)))))
+}
+
+// elgatoKelvin converts an API temperature value back to Kelvin, rounded to
+// the 50K steps the lights use.
+func elgatoKelvin(temperature int) int {
+	return int(math.Round(1e6/float64(temperature)/50)) * 50
+}
+
+// elgatoBrightness converts brightness to the firmware's 3–100 range.
+func elgatoBrightness(b float64) int {
+	return min(100, max(3, int(math.Round(b*100))))
+}
+
+// elgatoLightsFor builds the state for each of an accessory's n lights. Zones
+// map to lights in order; otherwise every light gets the same state.
+func elgatoLightsFor(state DeviceState, caps Capabilities, n int) []elgatoLight {
+	zones := resampleZones(state.Zones, n)
+	ll := make([]elgatoLight, n)
+	for i := range ll {
+		l := elgatoLight{Brightness: elgatoBrightness(state.Brightness)}
+		if state.On {
+			l.On = 1
 		}
-		return client.SetLights(ctx, ll)
+		col := state.Color
+		if len(zones) == n {
+			col = &zones[i]
+		}
+		switch {
+		case caps.Color && col != nil:
+			h, s := col.H, col.S*100
+			l.Hue, l.Saturation = &h, &s
+			l.Brightness = elgatoBrightness(state.Brightness * col.B)
+		case state.Kelvin != nil:
+			l.Temperature = elgatoTemperature(*state.Kelvin)
+		}
+		ll[i] = l
 	}
-	log.Printf("[elgato] SetState applied for %s: on=%v brightness=%d temp=%d", deviceID, state.On, int(state.Brightness*100), temp)
-	return nil
+	return ll
 }
 
-func (c *ElgatoController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
-	client, err := c.getClient(deviceID)
+func (c *ElgatoController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+	addr, err := c.addr(deviceID)
 	if err != nil {
-		return DeviceState{}, err
+		return err
+	}
+	caps := c.Capabilities(deviceID)
+	if state.Kelvin == nil && (!caps.Color || (state.Color == nil && len(state.Zones) == 0)) {
+		k := DefaultKelvin
+		state.Kelvin = &k
 	}
+	state = caps.Clamp(state)
 
-	ll, err := client.Lights(ctx)
-	if err != nil {
-		return DeviceState{}, err
+	ll := elgatoLightsFor(state, caps, caps.Segments)
+	if err := c.do(ctx, http.MethodPut, addr, elgatoLights{NumberOfLights: len(ll), Lights: ll}, nil); err != nil {
+		log.Printf("[elgato] SetState failed for %s: %v", deviceID, err)
+		return err
 	}
+	log.Printf("[elgato] SetState applied for %s: on=%v brightness=%d lights=%d", deviceID, state.On, ll[0].Brightness, len(ll))
+	return nil
+}
 
-	if len(ll) == 0 {
-		return DeviceState{}, fmt.Errorf("no lights found on device %s", deviceID)
+func (c *ElgatoController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
+	ll, err := c.lights(ctx, deviceID)
+	if err != nil {
+		return DeviceState{}, err
 	}
 
 	l := ll[0]
-	kelvin := l.Temperature
-	return DeviceState{
-		On:         l.On,
+	state := DeviceState{
+		On:         l.On != 0,
 		Brightness: float64(l.Brightness) / 100.0,
-		Kelvin:     &kelvin,
-	}, nil
+	}
+	switch {
+	case l.Temperature > 0:
+		kelvin := elgatoKelvin(l.Temperature)
+		state.Kelvin = &kelvin
+	case l.Hue != nil && l.Saturation != nil:
+		state.Color = &Color{H: *l.Hue, S: *l.Saturation / 100, B: 1}
+		if len(ll) > 1 {
+			state.Zones = make([]Color, len(ll))
+			for i, l := range ll {
+				if l.Hue != nil && l.Saturation != nil {
+					state.Zones[i] = Color{H: *l.Hue, S: *l.Saturation / 100, B: 1}
+				}
+			}
+		}
+	}
+	return state, nil
 }
 
 func (c *ElgatoController) TurnOn(ctx context.Context, deviceID string) error {
@@ -172,38 +364,112 @@ func (c *ElgatoController) TurnOff(ctx context.Context, deviceID string) error {
 	return c.setPower(ctx, deviceID, false)
 }
 
+// setPower switches every light on the accessory, keeping their other
+// settings.
 func (c *ElgatoController) setPower(ctx context.Context, deviceID string, on bool) error {
-	client, err := c.getClient(deviceID)
+	ll, err := c.lights(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	for i := range ll {
+		ll[i].On = 0
+		if on {
+			ll[i].On = 1
+		}
+	}
+	addr, err := c.addr(deviceID)
 	if err != nil {
 		return err
 	}
+	if err := c.do(ctx, http.MethodPut, addr, elgatoLights{NumberOfLights: len(ll), Lights: ll}, nil); err != nil {
+		log.Printf("[elgato] Setting power failed for %s: %v", deviceID, err)
+		return err
+	}
+	log.Printf("[elgato] Power set to %v for %s", on, deviceID)
+	return nil
+}
 
-	ll, err := client.Lights(ctx)
+// lights reads the state of every light on the accessory.
+func (c *ElgatoController) lights(ctx context.Context, deviceID string) ([]elgatoLight, error) {
+	addr, err := c.addr(deviceID)
 	if err != nil {
-		log.Printf("[elgato] Lights() failed for %s, reconnecting: %v", deviceID, err)
-		client, err = c.reconnect(deviceID)
-		if err != nil {
+		return nil, err
+	}
+	var ll elgatoLights
+	if err := c.do(ctx, http.MethodGet, addr, nil, &ll); err != nil {
+		return nil, err
+	}
+	if len(ll.Lights) == 0 {
+		return nil, fmt.Errorf("no lights found on device %s", deviceID)
+	}
+	return ll.Lights, nil
+}
+
+func (c *ElgatoController) addr(deviceID string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	addr, ok := c.addrs[deviceID]
+	if !ok {
+		return "", fmt.Errorf("device %s not registered", deviceID)
+	}
+	return addr, nil
+}
+
+// do sends a request to the /elgato/lights endpoint of the accessory at
+// addr and decodes the reply into out when it is non-nil.
+func (c *ElgatoController) do(ctx context.Context, method, addr string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
 			return err
 		}
-		ll, err = client.Lights(ctx)
-		if err != nil {
-			return fmt.Errorf("lights %s after reconnect: %w", deviceID, err)
-		}
 	}
-	if len(ll) == 0 {
-		return fmt.Errorf("no lights found on device %s", deviceID)
+	url := addr + "/elgato/lights"
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	ll[0].On = on
-	if err := client.SetLights(ctx, ll); err != nil {
-		log.Printf("[elgato] SetLights failed for %s: %v", deviceID, err)
+	resp, err := c.http.Do(req)
+	if err != nil {
 		return err
 	}
-	log.Printf("[elgato] Power set to %v for %s", on, deviceID)
-	return nil
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: HTTP %d", method, url, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Probe implements Prober by reading /elgato/accessory-info.
+func (c *ElgatoController) Probe(ctx context.Context, deviceID string) error {
+	client, err := c.getClient(deviceID)
+	if err != nil {
+		return err
+	}
+	_, err = client.AccessoryInfo(ctx)
+	return err
 }
 
-// getClient returns an existing client or creates one from the device ID's embedded IP.
+// Identify implements Identifier with the accessory's identify endpoint,
+// which flashes the light a few times.
+func (c *ElgatoController) Identify(ctx context.Context, deviceID string) error {
+	client, err := c.getClient(deviceID)
+	if err != nil {
+		return err
+	}
+	return client.Identify(ctx)
+}
+
+// getClient returns an existing client or creates one for the device's
+// registered address.
 func (c *ElgatoController) getClient(deviceID string) (*keylight.Client, error) {
 	c.mu.RLock()
 	client, ok := c.clients[deviceID]
@@ -212,18 +478,18 @@ func (c *ElgatoController) getClient(deviceID string) (*keylight.Client, error)
 		return client, nil
 	}
 
-	// Extract IP from "elgato:<ip>" and reconnect
 	log.Printf("[elgato] Client for %s not in cache, attempting reconnect", deviceID)
 	return c.reconnect(deviceID)
 }
 
 func (c *ElgatoController) reconnect(deviceID string) (*keylight.Client, error) {
-	ip := ipFromDeviceID(deviceID)
-	if ip == "" {
-		return nil, fmt.Errorf("cannot extract IP from device ID %q", deviceID)
+	c.mu.RLock()
+	fullAddr, ok := c.addrs[deviceID]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("device %s not registered", deviceID)
 	}
 
-	fullAddr := fmt.Sprintf("http://%s:9123", ip)
 	log.Printf("[elgato] Reconnecting %s at %s", deviceID, fullAddr)
 	client, err := keylight.NewClient(fullAddr, nil)
 	if err != nil {
@@ -232,7 +498,6 @@ func (c *ElgatoController) reconnect(deviceID string) (*keylight.Client, error)
 
 	c.mu.Lock()
 	c.clients[deviceID] = client
-	c.addrs[deviceID] = fullAddr
 	c.mu.Unlock()
 
 	log.Printf("[elgato] Reconnected %s successfully", deviceID)
@@ -240,7 +505,7 @@ func (c *ElgatoController) reconnect(deviceID string) (*keylight.Client, error)
 }
 
 func ipFromDeviceID(deviceID string) string {
-	// Format: "elgato:<ip>"
+	// Format: "<brand>:<ip>"
 	parts := strings.SplitN(deviceID, ":", 2)
 	if len(parts) != 2 {
 		return ""
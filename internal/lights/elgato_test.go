@@ -0,0 +1,152 @@
+package lights
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeElgato serves /elgato/accessory-info, /elgato/identify and
+// /elgato/lights for an accessory with the given product and lights.
+type fakeElgato struct {
+	mu         sync.Mutex
+	lights     []elgatoLight
+	puts       []elgatoLights
+	identified int
+
+	http *httptest.Server
+}
+
+func startFakeElgato(t *testing.T, product string, board int, lights []elgatoLight) *fakeElgato {
+	t.Helper()
+	f := &fakeElgato{lights: lights}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/elgato/accessory-info", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"productName":       product,
+			"hardwareBoardType": board,
+			"firmwareVersion":   "1.0.3",
+			"serialNumber":      "BW33J1A01234",
+			"displayName":       "Desk",
+		})
+	})
+	mux.HandleFunc("/elgato/identify", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Method == http.MethodPost {
+			f.identified++
+		}
+	})
+	mux.HandleFunc("/elgato/lights", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Method == http.MethodPut {
+			var body elgatoLights
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			f.puts = append(f.puts, body)
+			f.lights = body.Lights
+		}
+		_ = json.NewEncoder(w).Encode(elgatoLights{NumberOfLights: len(f.lights), Lights: f.lights})
+	})
+	f.http = httptest.NewServer(mux)
+	t.Cleanup(f.http.Close)
+	return f
+}
+
+func (f *fakeElgato) lastPut(t *testing.T) elgatoLights {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.puts) == 0 {
+		t.Fatalf("no lights were set")
+	}
+	return f.puts[len(f.puts)-1]
+}
+
+func discoverFakeElgato(t *testing.T, f *fakeElgato) (*ElgatoController, Device) {
+	t.Helper()
+	c := NewElgatoController()
+	c.pending[f.http.URL] = true
+	devices, err := c.Discover(context.Background())
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Discover: %v %v", devices, err)
+	}
+	return c, devices[0]
+}
+
+func TestElgato_StripColor(t *testing.T) {
+	f := startFakeElgato(t, "Elgato Light Strip", elgatoStripBoardType, []elgatoLight{{On: 1, Brightness: 50, Temperature: 200}})
+	c, dev := discoverFakeElgato(t, f)
+	if dev.ID != "elgato:bw33j1a01234" || !dev.Capabilities.Color || !dev.Capabilities.Kelvin {
+		t.Fatalf("expected a colour strip, got %s %+v", dev.ID, dev.Capabilities)
+	}
+
+	ctx := context.Background()
+	if err := c.SetState(ctx, dev.ID, DeviceState{On: true, Brightness: 0.8, Color: &Color{H: 240, S: 0.5, B: 1}}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	l := f.lastPut(t).Lights[0]
+	if l.Hue == nil || *l.Hue != 240 || *l.Saturation != 50 || l.Brightness != 80 || l.Temperature != 0 {
+		t.Fatalf("expected hue and saturation without temperature, got %+v", l)
+	}
+	st, err := c.GetState(ctx, dev.ID)
+	if err != nil || st.Color == nil || st.Color.H != 240 || st.Color.S != 0.5 || st.Kelvin != nil {
+		t.Fatalf("expected the colour read back, got %+v (%v)", st, err)
+	}
+
+	k := 4000
+	if err := c.SetState(ctx, dev.ID, DeviceState{On: true, Brightness: 1, Kelvin: &k}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	if l := f.lastPut(t).Lights[0]; l.Hue != nil || l.Temperature != 250 {
+		t.Fatalf("expected a temperature command, got %+v", l)
+	}
+	if st, _ := c.GetState(ctx, dev.ID); st.Kelvin == nil || *st.Kelvin != 4000 {
+		t.Fatalf("expected 4000K read back, got %+v", st)
+	}
+}
+
+func TestElgato_MultiLight(t *testing.T) {
+	f := startFakeElgato(t, "Elgato Key Light", 53, []elgatoLight{{On: 1, Brightness: 20, Temperature: 200}, {On: 1, Brightness: 20, Temperature: 200}})
+	c, dev := discoverFakeElgato(t, f)
+	if dev.Capabilities.Color || dev.Capabilities.Segments != 2 {
+		t.Fatalf("expected a white accessory with two lights, got %+v", dev.Capabilities)
+	}
+
+	ctx := context.Background()
+	k := 5000
+	if err := c.SetState(ctx, dev.ID, DeviceState{On: true, Brightness: 0.5, Kelvin: &k}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	put := f.lastPut(t)
+	if put.NumberOfLights != 2 || len(put.Lights) != 2 {
+		t.Fatalf("expected both lights addressed, got %+v", put)
+	}
+	for i, l := range put.Lights {
+		if l.Temperature != 200 || l.Brightness != 50 || l.On != 1 {
+			t.Fatalf("light %d: unexpected state %+v", i, l)
+		}
+	}
+
+	if err := c.TurnOff(ctx, dev.ID); err != nil {
+		t.Fatalf("TurnOff: %v", err)
+	}
+	for i, l := range f.lastPut(t).Lights {
+		if l.On != 0 || l.Brightness != 50 {
+			t.Fatalf("light %d: expected off with its brightness kept, got %+v", i, l)
+		}
+	}
+
+	if err := c.Identify(ctx, dev.ID); err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.identified != 1 {
+		t.Fatalf("expected the identify endpoint to be called once, got %d", f.identified)
+	}
+}
@@ -0,0 +1,164 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+// fadeStepInterval is the spacing between software fade steps. 100ms keeps
+// Govee and Elgato well inside their command budgets while still looking
+// continuous for fades of a few hundred milliseconds or more.
+const fadeStepInterval = 100 * time.Millisecond
+
+// fadeStepTimeout bounds each individual step of a software fade.
+const fadeStepTimeout = 2 * time.Second
+
+// startFade emulates a transition for controllers that can only snap. It
+// starts from the cached state, calibrated like target, and then the first
+// step is sent synchronously so connection errors reach the caller. With
+// nothing cached, the device is read in the background before the first
+// step instead. The steps run until the target is reached or a newer
+// command for the same device cancels the fade.
+func (m *Manager) startFade(ctx context.Context, ctrl Controller, deviceID string, target DeviceState, d time.Duration) error {
+	target.TransitionMs = nil
+	steps := int(math.Ceil(float64(d) / float64(fadeStepInterval)))
+	if steps < 2 {
+		return m.setState(ctx, ctrl, deviceID, target)
+	}
+
+	cached, haveFrom := m.CachedState(deviceID)
+	from := ctrl.Capabilities(deviceID).Clamp(m.calibrate(deviceID, cached))
+	first := 1
+	if haveFrom {
+		if err := m.setState(ctx, ctrl, deviceID, fadeStep(from, target, 1, steps)); err != nil {
+			return err
+		}
+		first = 2
+	}
+
+	// The caller's context usually ends when SetDeviceState returns, so the
+	// background steps only inherit its values, not its cancellation.
+	fadeCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	m.fadeMu.Lock()
+	m.fadeSeq++
+	seq := m.fadeSeq
+	m.fades[deviceID] = fadeEntry{seq: seq, cancel: cancel}
+	m.fadeMu.Unlock()
+
+	go func() {
+		defer m.endFade(deviceID, seq)
+		if !haveFrom {
+			readCtx, readCancel := context.WithTimeout(fadeCtx, fadeStepTimeout)
+			var err error
+			from, err = ctrl.GetState(readCtx, deviceID)
+			readCancel()
+			if err != nil {
+				from = DeviceState{Brightness: 0, Color: target.Color, Kelvin: target.Kelvin}
+			}
+		}
+		ticker := time.NewTicker(fadeStepInterval)
+		defer ticker.Stop()
+		for i := first; i <= steps; i++ {
+			if i > 1 {
+				select {
+				case <-fadeCtx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+			stepCtx, stepCancel := context.WithTimeout(fadeCtx, fadeStepTimeout)
+			err := m.setState(stepCtx, ctrl, deviceID, fadeStep(from, target, i, steps))
+			stepCancel()
+			if err != nil && !errors.Is(err, errSuperseded) && fadeCtx.Err() == nil {
+				log.Printf("[manager] Fade step %d/%d failed for %s: %v", i, steps, deviceID, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// fadeStep returns step i of a fade from from to target in steps steps; the
+// last one is target itself. A light that is off starts from zero.
+func fadeStep(from, target DeviceState, i, steps int) DeviceState {
+	if i >= steps {
+		return target
+	}
+	if !from.On {
+		from.Brightness = 0
+	}
+	return lerpState(from, target, float64(i)/float64(steps))
+}
+
+// cancelFade stops any software fade running for deviceID.
+func (m *Manager) cancelFade(deviceID string) {
+	m.fadeMu.Lock()
+	defer m.fadeMu.Unlock()
+	if f, ok := m.fades[deviceID]; ok {
+		f.cancel()
+		delete(m.fades, deviceID)
+	}
+}
+
+// endFade releases the fade entry for deviceID if it still belongs to seq.
+func (m *Manager) endFade(deviceID string, seq uint64) {
+	m.fadeMu.Lock()
+	defer m.fadeMu.Unlock()
+	if f, ok := m.fades[deviceID]; ok && f.seq == seq {
+		f.cancel()
+		delete(m.fades, deviceID)
+	}
+}
+
+type fadeEntry struct {
+	seq    uint64
+	cancel context.CancelFunc
+}
+
+// lerpState interpolates between two states at t ∈ [0, 1]. The light stays
+// on for the whole fade; turning off is expressed as a fade to zero followed
+// by the final off state.
+func lerpState(from, to DeviceState, t float64) DeviceState {
+	toBrightness := to.Brightness
+	if !to.On {
+		toBrightness = 0
+	}
+	out := DeviceState{
+		On:         true,
+		Brightness: lerp(from.Brightness, toBrightness, t),
+		Color:      to.Color,
+		Kelvin:     to.Kelvin,
+		Zones:      to.Zones,
+	}
+	if from.Color != nil && to.Color != nil {
+		out.Color = &Color{
+			H: lerpHue(from.Color.H, to.Color.H, t),
+			S: lerp(from.Color.S, to.Color.S, t),
+			B: lerp(from.Color.B, to.Color.B, t),
+		}
+	}
+	if from.Kelvin != nil && to.Kelvin != nil && to.Color == nil {
+		k := int(math.Round(lerp(float64(*from.Kelvin), float64(*to.Kelvin), t)))
+		out.Kelvin = &k
+	}
+	if !to.On && out.Color == nil && out.Kelvin == nil {
+		out.Color, out.Kelvin = from.Color, from.Kelvin
+	}
+	return out
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// lerpHue interpolates along the shorter arc of the hue circle.
+func lerpHue(a, b, t float64) float64 {
+	d := math.Mod(b-a+540, 360) - 180
+	h := math.Mod(a+d*t, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
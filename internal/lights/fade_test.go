@@ -0,0 +1,166 @@
+package lights
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLerpState(t *testing.T) {
+	k2700, k6500, k4600 := 2700, 6500, 4600
+	tests := []struct {
+		name     string
+		from, to DeviceState
+		t        float64
+		want     DeviceState
+	}{
+		{
+			name: "brightness",
+			from: DeviceState{On: true, Brightness: 0.2},
+			to:   DeviceState{On: true, Brightness: 1},
+			t:    0.5,
+			want: DeviceState{On: true, Brightness: 0.6},
+		},
+		{
+			name: "hue takes the shorter arc",
+			from: DeviceState{On: true, Brightness: 1, Color: &Color{H: 350, S: 1, B: 1}},
+			to:   DeviceState{On: true, Brightness: 1, Color: &Color{H: 30, S: 0, B: 1}},
+			t:    0.25,
+			want: DeviceState{On: true, Brightness: 1, Color: &Color{H: 0, S: 0.75, B: 1}},
+		},
+		{
+			name: "kelvin",
+			from: DeviceState{On: true, Brightness: 1, Kelvin: &k2700},
+			to:   DeviceState{On: true, Brightness: 1, Kelvin: &k6500},
+			t:    0.5,
+			want: DeviceState{On: true, Brightness: 1, Kelvin: &k4600},
+		},
+		{
+			name: "turning off fades to zero and keeps the colour",
+			from: DeviceState{On: true, Brightness: 0.8, Color: &Color{H: 120, S: 1, B: 1}},
+			to:   DeviceState{On: false, Brightness: 0.8},
+			t:    0.75,
+			want: DeviceState{On: true, Brightness: 0.2, Color: &Color{H: 120, S: 1, B: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lerpState(tt.from, tt.to, tt.t)
+			if got.On != tt.want.On || math.Abs(got.Brightness-tt.want.Brightness) > 1e-9 {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+			if (got.Color == nil) != (tt.want.Color == nil) || (got.Kelvin == nil) != (tt.want.Kelvin == nil) {
+				t.Fatalf("expected color %v kelvin %v, got color %v kelvin %v", tt.want.Color, tt.want.Kelvin, got.Color, got.Kelvin)
+			}
+			if got.Color != nil {
+				if math.Abs(got.Color.H-tt.want.Color.H) > 1e-9 || math.Abs(got.Color.S-tt.want.Color.S) > 1e-9 || got.Color.B != tt.want.Color.B {
+					t.Fatalf("expected color %+v, got %+v", *tt.want.Color, *got.Color)
+				}
+			}
+			if got.Kelvin != nil && *got.Kelvin != *tt.want.Kelvin {
+				t.Fatalf("expected %dK, got %dK", *tt.want.Kelvin, *got.Kelvin)
+			}
+		})
+	}
+}
+
+// waitForHistory polls until deviceID has n recorded commands.
+func waitForHistory(t *testing.T, m *Manager, deviceID string, n int) []CommandRecord {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hist := m.CommandHistory(deviceID)
+		if len(hist) >= n || time.Now().After(deadline) {
+			return hist
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSoftwareFade_StepsToTarget(t *testing.T) {
+	m, _ := newTestVirtualManager(VirtualDevice{ID: "a"})
+	ctx := context.Background()
+	if err := m.SetDeviceState(ctx, "virtual:a", DeviceState{On: false}.WithTransition(0)); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	target := DeviceState{On: true, Brightness: 0.9, Color: &Color{H: 0, S: 1, B: 1}}.WithTransition(300)
+
+	if err := m.SetDeviceState(ctx, "virtual:a", target); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	if hist := m.CommandHistory("virtual:a"); len(hist) != 2 || math.Abs(hist[1].State.Brightness-0.3) > 1e-9 {
+		t.Fatalf("expected the first step to be sent before returning, got %+v", hist)
+	}
+
+	hist := waitForHistory(t, m, "virtual:a", 4)
+	if len(hist) != 4 || math.Abs(hist[2].State.Brightness-0.6) > 1e-9 {
+		t.Fatalf("expected three steps, got %+v", hist)
+	}
+	last := hist[3].State
+	if last.Brightness != 0.9 || last.Color == nil || last.Color.H != 0 || last.TransitionMs != nil {
+		t.Fatalf("expected the last step to be the target without a transition, got %+v", last)
+	}
+}
+
+func TestSoftwareFade_StartsFromCachedState(t *testing.T) {
+	m, c := newTestVirtualManager(VirtualDevice{ID: "a"})
+	ctx := context.Background()
+	if err := m.SetDeviceState(ctx, "virtual:a", DeviceState{On: true, Brightness: 0.5, Color: &Color{H: 0, S: 1, B: 1}}.WithTransition(0)); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	// The device has moved on without the manager knowing; reading it back
+	// would start the fade from 0.1.
+	if err := c.SetState(ctx, "virtual:a", DeviceState{On: true, Brightness: 0.1, Color: &Color{H: 0, S: 1, B: 1}}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+
+	target := DeviceState{On: true, Brightness: 0.8, Color: &Color{H: 0, S: 1, B: 1}}.WithTransition(300)
+	if err := m.SetDeviceState(ctx, "virtual:a", target); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	hist := m.CommandHistory("virtual:a")
+	if len(hist) != 3 || math.Abs(hist[2].State.Brightness-0.6) > 1e-9 {
+		t.Fatalf("expected the first step to start from the cached 0.5, got %+v", hist)
+	}
+}
+
+func TestSoftwareFade_ReadsUncachedStateInBackground(t *testing.T) {
+	m, _ := newTestVirtualManager(VirtualDevice{ID: "a", LatencyMs: 200})
+	target := DeviceState{On: true, Brightness: 0.9, Color: &Color{H: 0, S: 1, B: 1}}.WithTransition(300)
+
+	start := time.Now()
+	if err := m.SetDeviceState(context.Background(), "virtual:a", target); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("SetDeviceState took %v, expected the state read to happen in the background", elapsed)
+	}
+
+	hist := waitForHistory(t, m, "virtual:a", 3)
+	if len(hist) != 3 || math.Abs(hist[0].State.Brightness-0.3) > 1e-9 || hist[2].State.Brightness != 0.9 {
+		t.Fatalf("expected three steps from off, got %+v", hist)
+	}
+}
+
+func TestSoftwareFade_CancelledByNewerCommand(t *testing.T) {
+	m, _ := newTestVirtualManager(VirtualDevice{ID: "a"})
+	ctx := context.Background()
+	if err := m.SetDeviceState(ctx, "virtual:a", DeviceState{On: false}.WithTransition(0)); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+
+	slow := DeviceState{On: true, Brightness: 1, Color: &Color{H: 200, S: 1, B: 1}}.WithTransition(1000)
+	if err := m.SetDeviceState(ctx, "virtual:a", slow); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	if err := m.SetDeviceState(ctx, "virtual:a", DeviceState{On: true, Brightness: 0.2}.WithTransition(0)); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	time.Sleep(3 * fadeStepInterval)
+
+	hist := m.CommandHistory("virtual:a")
+	if len(hist) != 3 || hist[2].State.Brightness != 0.2 {
+		t.Fatalf("expected the fade to stop at the newer command, got %+v", hist)
+	}
+}
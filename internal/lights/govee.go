@@ -2,29 +2,144 @@ package lights
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
-	"os"
+	"log"
+	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
-
-	govee "github.com/swrm-io/go-vee"
 )
 
+// GoveeController speaks the Govee LAN API: scans go to the multicast group
+// on port 4001, commands to each device on 4003, and devices answer on 4002,
+// where one listener owned by the controller records them.
 type GoveeController struct {
-	mu         sync.RWMutex
-	controller *govee.Controller
-	deviceMap  map[string]*govee.Device
-	started    bool
+	mu sync.RWMutex
+	// conn is the listener, also used to send; nil until started.
+	conn *net.UDPConn
+	done chan struct{}
+	wg   sync.WaitGroup
+	// devices holds every device that answered a scan, by ID.
+	devices map[string]*goveeDevice
+
+	// poweredAt records when each device was last switched on by us, so
+	// batched updates can drop the redundant turn-on packet.
+	poweredAt map[string]time.Time
+
+	// status caches the latest devStatus report per device. Commands
+	// invalidate it.
+	status map[string]goveeStatus
+	// statusWait and scanWait are signalled when the listener receives a
+	// devStatus report from a device (by ID) or a scan reply from an IP.
+	statusWait    map[string][]chan DeviceState
+	scanWait      map[string][]chan struct{}
+	statusTimeout time.Duration
 }
 
-func NewGoveeController() *GoveeController {
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
-	ctrl := govee.NewController(logger)
+// goveeDevice is what a device's scan reply told us about it.
+type goveeDevice struct {
+	ip   string
+	sku  string
+	seen time.Time
+}
+
+type goveeStatus struct {
+	state DeviceState
+	at    time.Time
+}
+
+// goveeMessage is the envelope of every LAN API message.
+type goveeMessage struct {
+	Msg struct {
+		Cmd  string          `json:"cmd"`
+		Data json.RawMessage `json:"data"`
+	} `json:"msg"`
+}
+
+type goveeScanReply struct {
+	IP     string `json:"ip"`
+	Device string `json:"device"`
+	SKU    string `json:"sku"`
+}
+
+type goveeRGB struct {
+	R int `json:"r"`
+	G int `json:"g"`
+	B int `json:"b"`
+}
+
+type goveeDevStatus struct {
+	OnOff      int      `json:"onOff"`
+	Brightness int      `json:"brightness"`
+	Color      goveeRGB `json:"color"`
+	Kelvin     int      `json:"colorTemInKelvin"`
+}
+
+// goveeColorCommand is the colorwc payload: the device uses the colour
+// temperature when it's non-zero and the RGB value otherwise.
+type goveeColorCommand struct {
+	Color  goveeRGB `json:"color"`
+	Kelvin int      `json:"colorTemInKelvin"`
+}
+
+type goveeValue struct {
+	Value int `json:"value"`
+}
+
+// errGoveeNoReply is returned when a device doesn't answer a devStatus
+// request in time.
+var errGoveeNoReply = errors.New("no reply")
+
+// goveePowerCoalesceWindow is how long a turn-on is trusted before batches
+// send it again (covers devices switched off from the Govee app).
+const goveePowerCoalesceWindow = 5 * time.Second
 
+// goveeStatusTimeout bounds a devStatus round trip; devices on the LAN
+// normally answer within a few hundred milliseconds.
+const goveeStatusTimeout = 2 * time.Second
+
+// goveeCommandRate is the sustained LAN command rate per device; the
+// firmware silently drops packets sent faster.
+const goveeCommandRate = 10
+
+// goveeScanPort is where devices listen for scan requests; replies go to
+// the controller's multicast listener like those to the multicast scan.
+const goveeScanPort = 4001
+
+// goveeCommandPort is where devices listen for commands.
+const goveeCommandPort = 4003
+
+// goveeListenAddr is the multicast group and port devices answer on.
+const goveeListenAddr = "239.255.255.250:4002"
+
+// goveeScanAddr is the multicast group scans are sent to.
+const goveeScanAddr = "239.255.255.250:4001"
+
+// goveeScanInterval is how often the controller scans while running.
+const goveeScanInterval = time.Minute
+
+// goveeActiveWindow is how long a device counts as present after it last
+// answered; it covers a few missed scans.
+const goveeActiveWindow = 5 * time.Minute
+
+// goveeScanRequest asks a device to announce itself.
+const goveeScanRequest = `{"msg":{"cmd":"scan","data":{"account_topic":"reserve"}}}`
+
+// goveeStatusMaxAge is how long a devStatus report is reused before
+// GetState asks the device again.
+const goveeStatusMaxAge = time.Second
+
+func NewGoveeController() *GoveeController {
 	return &GoveeController{
-		controller: ctrl,
-		deviceMap:  make(map[string]*govee.Device),
+		devices:       make(map[string]*goveeDevice),
+		poweredAt:     make(map[string]time.Time),
+		status:        make(map[string]goveeStatus),
+		statusWait:    make(map[string][]chan DeviceState),
+		scanWait:      make(map[string][]chan struct{}),
+		statusTimeout: goveeStatusTimeout,
 	}
 }
 
@@ -32,63 +147,365 @@ func (c *GoveeController) Brand() Brand {
 	return BrandGovee
 }
 
-func (c *GoveeController) ensureStarted() {
+// goveeCapabilities reflects the LAN API: colorwc accepts RGB and
+// 2000–9000K, and devStatus reports the current state, but there is no
+// transition parameter. It applies to models missing from goveeModels.
+var goveeCapabilities = Capabilities{
+	Color:          true,
+	Kelvin:         true,
+	MinKelvin:      2000,
+	MaxKelvin:      9000,
+	KelvinStep:     1,
+	Segments:       1,
+	MaxCommandRate: goveeCommandRate,
+	ReadBack:       true,
+}
+
+// goveeModel is what a model's white channel can do, where it differs from
+// the range the LAN API accepts.
+type goveeModel struct {
+	minKelvin, maxKelvin int
+}
+
+// goveeModels lists models by SKU whose colour-temperature range is
+// narrower than goveeCapabilities'. The firmware silently caps values
+// outside it, so Clamp must know the real range.
+var goveeModels = map[string]goveeModel{
+	"H6008": {minKelvin: 2700, maxKelvin: 6500}, // bulb
+	"H6022": {minKelvin: 2700, maxKelvin: 6500}, // table lamp
+}
+
+// goveeCapabilitiesFor returns the capabilities of the model sku.
+func goveeCapabilitiesFor(sku string) Capabilities {
+	caps := goveeCapabilities
+	if m, ok := goveeModels[strings.ToUpper(sku)]; ok {
+		caps.MinKelvin, caps.MaxKelvin = m.minKelvin, m.maxKelvin
+	}
+	return caps
+}
+
+// Capabilities returns the capabilities of the device's model, as reported
+// in its scan response.
+func (c *GoveeController) Capabilities(deviceID string) Capabilities {
+	c.mu.RLock()
+	var sku string
+	if dev, ok := c.devices[deviceID]; ok {
+		sku = dev.sku
+	}
+	c.mu.RUnlock()
+	return goveeCapabilitiesFor(sku)
+}
+
+// ensureStarted opens the listener on first use and starts the periodic
+// scan, which also runs once straight away.
+func (c *GoveeController) ensureStarted() (*net.UDPConn, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.started {
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	group, err := net.ResolveUDPAddr("udp4", goveeListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", goveeListenAddr, err)
+	}
+	c.conn = conn
+	c.done = make(chan struct{})
+	c.wg.Add(2)
+	go c.listen(conn)
+	go c.scanLoop(conn, c.done)
+	return conn, nil
+}
+
+// listen handles the replies arriving on conn until it's closed.
+func (c *GoveeController) listen(conn *net.UDPConn) {
+	defer c.wg.Done()
+	buf := make([]byte, 8192)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		c.handle(src.IP.String(), buf[:n])
+	}
+}
+
+// handle records a scan reply or devStatus report from the device at ip and
+// wakes whoever is waiting for it.
+func (c *GoveeController) handle(ip string, data []byte) {
+	var msg goveeMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	switch msg.Msg.Cmd {
+	case "scan":
+		var reply goveeScanReply
+		if err := json.Unmarshal(msg.Msg.Data, &reply); err != nil {
+			return
+		}
+		if reply.IP == "" {
+			reply.IP = ip
+		}
+		deviceID := hardwareDeviceID(BrandGovee, reply.Device, reply.IP)
+		c.mu.Lock()
+		c.devices[deviceID] = &goveeDevice{ip: reply.IP, sku: reply.SKU, seen: time.Now()}
+		for _, ch := range c.scanWait[reply.IP] {
+			close(ch)
+		}
+		delete(c.scanWait, reply.IP)
+		c.mu.Unlock()
+
+	case "devStatus":
+		var status goveeDevStatus
+		if err := json.Unmarshal(msg.Msg.Data, &status); err != nil {
+			return
+		}
+		c.mu.Lock()
+		for deviceID, dev := range c.devices {
+			if dev.ip != ip {
+				continue
+			}
+			dev.seen = time.Now()
+			for _, ch := range c.statusWait[deviceID] {
+				ch <- goveeStatusToState(status)
+			}
+			delete(c.statusWait, deviceID)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// scanLoop multicasts a scan now and every goveeScanInterval until done is
+// closed.
+func (c *GoveeController) scanLoop(conn *net.UDPConn, done <-chan struct{}) {
+	defer c.wg.Done()
+	group, err := net.ResolveUDPAddr("udp4", goveeScanAddr)
+	if err != nil {
 		return
 	}
-	go func() {
-		_ = c.controller.Start()
-	}()
-	c.started = true
+	ticker := time.NewTicker(goveeScanInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := conn.WriteToUDP([]byte(goveeScanRequest), group); err != nil {
+			log.Printf("[govee] Scan failed: %v", err)
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func (c *GoveeController) Discover(ctx context.Context) ([]Device, error) {
-	c.ensureStarted()
+	if _, err := c.ensureStarted(); err != nil {
+		return nil, err
+	}
 
-	time.Sleep(3 * time.Second)
+	// Give the scan sent on start (or the last periodic one) time to be
+	// answered.
+	timer := time.NewTimer(3 * time.Second)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 
-	devices := c.controller.Devices()
-	var result []Device
+	return c.knownDevices(), nil
+}
 
-	c.mu.Lock()
-	for _, d := range devices {
-		ip := d.IP()
-		sku := d.SKU()
-		deviceID := fmt.Sprintf("govee:%s", ip)
-		c.deviceMap[deviceID] = d
-		result = append(result, Device{
-			ID:             deviceID,
-			Brand:          BrandGovee,
-			Name:           fmt.Sprintf("Govee %s (%s)", sku, ip),
-			Model:          sku,
-			LastIP:         ip,
-			LastSeen:       time.Now(),
-			SupportsColor:  true,
-			SupportsKelvin: true,
-		})
+// ProbeAddress implements AddressProber by sending the scan request
+// straight to addr instead of the multicast group, which doesn't cross
+// subnets. The reply reaches the listener like any other, which wakes us.
+func (c *GoveeController) ProbeAddress(ctx context.Context, addr string) (Device, error) {
+	conn, err := c.ensureStarted()
+	if err != nil {
+		return Device{}, err
+	}
+	addr = withDefaultPort(addr, goveeScanPort)
+	host, _, _ := net.SplitHostPort(addr)
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil || len(ips) == 0 {
+		return Device{}, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	ip := ips[0].String()
+	target, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return Device{}, err
 	}
+
+	answered := make(chan struct{})
+	c.mu.Lock()
+	c.scanWait[ip] = append(c.scanWait[ip], answered)
 	c.mu.Unlock()
+	defer c.stopScanWait(ip, answered)
+
+	probeCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	// Scans are resent in case one is lost.
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if _, err := conn.WriteToUDP([]byte(goveeScanRequest), target); err != nil {
+			return Device{}, err
+		}
+		select {
+		case <-probeCtx.Done():
+			return Device{}, ErrNoDeviceAtAddress
+		case <-ticker.C:
+			continue
+		case <-answered:
+		}
+		for _, d := range c.knownDevices() {
+			if d.LastIP == ip {
+				log.Printf("[govee] Found %s at %s", d.ID, addr)
+				return d, nil
+			}
+		}
+		return Device{}, ErrNoDeviceAtAddress
+	}
+}
 
-	return result, nil
+// stopStatusWait removes a GetState waiter the listener didn't answer.
+func (c *GoveeController) stopStatusWait(deviceID string, ch chan DeviceState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waiters := c.statusWait[deviceID]
+	for i, w := range waiters {
+		if w == ch {
+			c.statusWait[deviceID] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(c.statusWait[deviceID]) == 0 {
+		delete(c.statusWait, deviceID)
+	}
 }
 
-func (c *GoveeController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+// stopScanWait removes a ProbeAddress waiter the listener didn't wake.
+func (c *GoveeController) stopScanWait(ip string, ch chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	waiters := c.scanWait[ip]
+	for i, w := range waiters {
+		if w == ch {
+			c.scanWait[ip] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(c.scanWait[ip]) == 0 {
+		delete(c.scanWait, ip)
+	}
+}
+
+// knownDevices returns every device that answered a scan. Devices are
+// identified by the MAC in their scan reply, so one that moved to a new IP
+// keeps its ID and is listed at that IP.
+func (c *GoveeController) knownDevices() []Device {
 	c.mu.RLock()
-	dev, ok := c.deviceMap[deviceID]
+	result := make([]Device, 0, len(c.devices))
+	for deviceID, d := range c.devices {
+		dev := Device{
+			ID:       deviceID,
+			Brand:    BrandGovee,
+			Name:     fmt.Sprintf("Govee %s (%s)", d.sku, d.ip),
+			Model:    d.sku,
+			LastIP:   d.ip,
+			LastSeen: d.seen,
+		}
+		dev.applyCapabilities(goveeCapabilitiesFor(d.sku))
+		result = append(result, dev)
+	}
 	c.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// deviceIP returns the address of a device that answered a scan.
+func (c *GoveeController) deviceIP(deviceID string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	dev, ok := c.devices[deviceID]
 	if !ok {
-		return fmt.Errorf("device %s not connected", deviceID)
+		return "", fmt.Errorf("device %s not connected", deviceID)
 	}
+	return dev.ip, nil
+}
 
-	if !state.On {
-		return dev.TurnOff()
+// command sends one LAN API command to the device at ip.
+func (c *GoveeController) command(ip, cmd string, data any) error {
+	conn, err := c.ensureStarted()
+	if err != nil {
+		return err
 	}
+	var msg goveeMessage
+	msg.Msg.Cmd = cmd
+	if msg.Msg.Data, err = json.Marshal(data); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteToUDP(payload, &net.UDPAddr{IP: net.ParseIP(ip), Port: goveeCommandPort})
+	return err
+}
 
-	if err := dev.TurnOn(); err != nil {
+func (c *GoveeController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+	ip, err := c.deviceIP(deviceID)
+	if err != nil {
 		return err
 	}
+	return c.applyState(deviceID, ip, state, false)
+}
+
+// SetStates coalesces a batch: devices we switched on recently only get the
+// colour packet, halving the UDP traffic of a screen sync frame.
+func (c *GoveeController) SetStates(_ context.Context, states map[string]DeviceState) error {
+	var errs []error
+	for deviceID, state := range states {
+		ip, err := c.deviceIP(deviceID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.mu.RLock()
+		powered := time.Since(c.poweredAt[deviceID]) < goveePowerCoalesceWindow
+		c.mu.RUnlock()
+		if err := c.applyState(deviceID, ip, state, powered); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", deviceID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyState sends state to the device at ip. skipPowerOn omits the turn-on
+// packet when the device is already known to be on.
+func (c *GoveeController) applyState(deviceID, ip string, state DeviceState, skipPowerOn bool) error {
+	c.invalidateStatus(deviceID)
+	if !state.On {
+		c.mu.Lock()
+		delete(c.poweredAt, deviceID)
+		c.mu.Unlock()
+		return c.command(ip, "turn", goveeValue{Value: 0})
+	}
+
+	if !skipPowerOn {
+		if err := c.command(ip, "turn", goveeValue{Value: 1}); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.poweredAt[deviceID] = time.Now()
+		c.mu.Unlock()
+	}
 
 	// For screen sync (and general color updates) we bake brightness directly
 	// into the RGB values so we need only one UDP command instead of three
@@ -100,57 +517,144 @@ func (c *GoveeController) SetState(ctx context.Context, deviceID string, state D
 		if br <= 0 {
 			br = 1.0
 		}
-		return dev.SetColor(govee.Color{
-			R: uint(float64(r) * br),
-			G: uint(float64(g) * br),
-			B: uint(float64(b) * br),
-		})
+		return c.command(ip, "colorwc", goveeColorCommand{Color: goveeRGB{
+			R: int(float64(r) * br),
+			G: int(float64(g) * br),
+			B: int(float64(b) * br),
+		}})
 	}
 
 	if state.Kelvin != nil {
-		return dev.SetColorKelvin(govee.NewColorKelvin(uint(*state.Kelvin)))
+		return c.command(ip, "colorwc", goveeColorCommand{Kelvin: min(max(*state.Kelvin, 2000), 9000)})
 	}
 
 	// Brightness-only update (no color specified).
-	return dev.SetBrightness(govee.NewBrightness(uint(state.Brightness * 100)))
+	return c.command(ip, "brightness", goveeValue{Value: min(int(state.Brightness*100), 100)})
 }
 
-func (c *GoveeController) GetState(_ context.Context, deviceID string) (DeviceState, error) {
+// GetState asks the device for its devStatus report, reusing the cached
+// report when it's recent and no command has been sent since.
+func (c *GoveeController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
+	ip, err := c.deviceIP(deviceID)
+	if err != nil {
+		return DeviceState{}, err
+	}
 	c.mu.RLock()
-	_, ok := c.deviceMap[deviceID]
+	cached, fresh := c.status[deviceID]
 	c.mu.RUnlock()
-	if !ok {
-		return DeviceState{}, fmt.Errorf("device %s not connected", deviceID)
+	if fresh && time.Since(cached.at) < goveeStatusMaxAge {
+		return cached.state.clone(), nil
+	}
+
+	// Reports carry no request ID, only the sender's address, so every
+	// request waiting on a device takes its next report.
+	reply := make(chan DeviceState, 1)
+	c.mu.Lock()
+	c.statusWait[deviceID] = append(c.statusWait[deviceID], reply)
+	c.mu.Unlock()
+	defer c.stopStatusWait(deviceID, reply)
+	if err := c.command(ip, "devStatus", struct{}{}); err != nil {
+		return DeviceState{}, fmt.Errorf("govee status %s: %w", deviceID, err)
+	}
+
+	timer := time.NewTimer(c.statusTimeout)
+	defer timer.Stop()
+	var state DeviceState
+	select {
+	case state = <-reply:
+	case <-timer.C:
+		return DeviceState{}, fmt.Errorf("govee status %s: %w within %v", deviceID, errGoveeNoReply, c.statusTimeout)
+	case <-ctx.Done():
+		return DeviceState{}, ctx.Err()
 	}
-	return DeviceState{On: true, Brightness: 1.0}, nil
+
+	c.mu.Lock()
+	c.status[deviceID] = goveeStatus{state: state, at: time.Now()}
+	c.mu.Unlock()
+	return state.clone(), nil
 }
 
-func (c *GoveeController) TurnOn(_ context.Context, deviceID string) error {
+// Probe implements Prober. The multicast scan, repeated every minute,
+// records when each device last answered; one missing from recent scans
+// fails at once, others confirm with a devStatus round trip.
+func (c *GoveeController) Probe(ctx context.Context, deviceID string) error {
 	c.mu.RLock()
-	dev, ok := c.deviceMap[deviceID]
+	dev, ok := c.devices[deviceID]
+	active := ok && time.Since(dev.seen) < goveeActiveWindow
 	c.mu.RUnlock()
 	if !ok {
 		return fmt.Errorf("device %s not connected", deviceID)
 	}
-	return dev.TurnOn()
+	if !active {
+		return fmt.Errorf("govee %s: no reply to recent scans", deviceID)
+	}
+	_, err := c.GetState(ctx, deviceID)
+	return err
+}
+
+// RateBudget implements RateBudgeter: each device has its own budget.
+func (c *GoveeController) RateBudget(deviceID string) (string, float64) {
+	return deviceID, goveeCommandRate
+}
+
+// goveeStatusToState converts a devStatus report. Colour commands bake the
+// brightness into the RGB values and leave the device's own brightness
+// alone, so in colour mode the RGB level is reported as the brightness and
+// the state can be sent back unchanged.
+func goveeStatusToState(status goveeDevStatus) DeviceState {
+	state := DeviceState{
+		On:         status.OnOff == 1,
+		Brightness: float64(status.Brightness) / 100,
+	}
+	if status.Kelvin > 0 {
+		k := status.Kelvin
+		state.Kelvin = &k
+		return state
+	}
+	if col := status.Color; col.R > 0 || col.G > 0 || col.B > 0 {
+		h, s, v := RGBToHSB(uint8(min(col.R, 255)), uint8(min(col.G, 255)), uint8(min(col.B, 255)))
+		state.Color = &Color{H: h, S: s, B: 1}
+		state.Brightness = v
+	}
+	return state
+}
+
+func (c *GoveeController) invalidateStatus(deviceID string) {
+	c.mu.Lock()
+	delete(c.status, deviceID)
+	c.mu.Unlock()
+}
+
+func (c *GoveeController) TurnOn(_ context.Context, deviceID string) error {
+	return c.setPower(deviceID, 1)
 }
 
 func (c *GoveeController) TurnOff(_ context.Context, deviceID string) error {
-	c.mu.RLock()
-	dev, ok := c.deviceMap[deviceID]
-	c.mu.RUnlock()
-	if !ok {
-		return fmt.Errorf("device %s not connected", deviceID)
+	return c.setPower(deviceID, 0)
+}
+
+func (c *GoveeController) setPower(deviceID string, value int) error {
+	ip, err := c.deviceIP(deviceID)
+	if err != nil {
+		return err
 	}
-	return dev.TurnOff()
+	c.invalidateStatus(deviceID)
+	return c.command(ip, "turn", goveeValue{Value: value})
 }
 
+// Close stops the listener and the periodic scan.
 func (c *GoveeController) Close() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.started {
-		c.controller.Shutdown()
-		c.started = false
+	conn := c.conn
+	if conn != nil {
+		close(c.done)
+		c.conn = nil
+	}
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
 	}
-	return nil
+	err := conn.Close()
+	c.wg.Wait()
+	return err
 }
@@ -0,0 +1,225 @@
+package lights
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGovee stands in for a Govee device on 127.0.0.1: it takes commands on
+// the control port (4003) and sends replies to the controller's listener on
+// 4002, as a real device would.
+type fakeGovee struct {
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	status   string // devStatus data; empty means don't answer
+	commands []string
+}
+
+func startFakeGovee(t *testing.T) *fakeGovee {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4003})
+	if err != nil {
+		t.Skipf("Govee control port unavailable: %v", err)
+	}
+	f := &fakeGovee{conn: conn}
+	t.Cleanup(func() { conn.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeGovee) serve() {
+	buf := make([]byte, 2048)
+	for {
+		n, err := f.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		var req struct {
+			Msg struct {
+				Cmd string `json:"cmd"`
+			} `json:"msg"`
+		}
+		if json.Unmarshal(buf[:n], &req) != nil {
+			continue
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, req.Msg.Cmd)
+		status := f.status
+		f.mu.Unlock()
+		if req.Msg.Cmd == "scan" {
+			f.send(goveeTestScanReply)
+		}
+		if req.Msg.Cmd == "devStatus" && status != "" {
+			f.send(`{"msg":{"cmd":"devStatus","data":` + status + `}}`)
+		}
+	}
+}
+
+// goveeTestScanReply announces the fake device, as a scan response does.
+const goveeTestScanReply = `{"msg":{"cmd":"scan","data":{"ip":"127.0.0.1","device":"AA:BB:CC:DD:EE:FF:00:11","sku":"H6076"}}}`
+
+func (f *fakeGovee) send(msg string) {
+	_, _ = f.conn.WriteToUDP([]byte(msg), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4002})
+}
+
+func (f *fakeGovee) setStatus(status string) {
+	f.mu.Lock()
+	f.status = status
+	f.mu.Unlock()
+}
+
+func (f *fakeGovee) count(cmd string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.commands {
+		if c == cmd {
+			n++
+		}
+	}
+	return n
+}
+
+// newTestGoveeController starts the controller's listener and announces the
+// fake device to it, returning the device ID.
+func newTestGoveeController(t *testing.T, f *fakeGovee) (*GoveeController, string) {
+	t.Helper()
+	c := NewGoveeController()
+	c.statusTimeout = 300 * time.Millisecond
+	if _, err := c.ensureStarted(); err != nil {
+		t.Skipf("Govee listener unavailable: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		f.send(goveeTestScanReply)
+		time.Sleep(50 * time.Millisecond)
+		for _, d := range c.knownDevices() {
+			if d.LastIP == "127.0.0.1" && strings.Contains(d.Name, "H6076") {
+				return c, d.ID
+			}
+		}
+	}
+	t.Fatalf("controller never registered the fake device")
+	return nil, ""
+}
+
+func TestGovee_GetStateReadsDevStatus(t *testing.T) {
+	f := startFakeGovee(t)
+	c, id := newTestGoveeController(t, f)
+	ctx := context.Background()
+	if id != "govee:aabbccddeeff0011" {
+		t.Fatalf("expected an ID from the scan response's MAC, got %q", id)
+	}
+
+	f.setStatus(`{"onOff":1,"brightness":80,"color":{"r":0,"g":0,"b":128},"colorTemInKelvin":0}`)
+	st, err := c.GetState(ctx, id)
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if !st.On || st.Color == nil || st.Color.H != 240 || st.Color.S != 1 || st.Brightness < 0.5 || st.Brightness > 0.51 {
+		t.Fatalf("unexpected colour state %+v (color %+v)", st, st.Color)
+	}
+
+	// The report is cached until a command is sent.
+	if _, err := c.GetState(ctx, id); err != nil {
+		t.Fatalf("cached GetState: %v", err)
+	}
+	if n := f.count("devStatus"); n != 1 {
+		t.Fatalf("expected one devStatus request, got %d", n)
+	}
+
+	f.setStatus(`{"onOff":0,"brightness":40,"color":{"r":255,"g":180,"b":110},"colorTemInKelvin":3000}`)
+	if err := c.TurnOff(ctx, id); err != nil {
+		t.Fatalf("TurnOff: %v", err)
+	}
+	st, err = c.GetState(ctx, id)
+	if err != nil {
+		t.Fatalf("GetState after command: %v", err)
+	}
+	if st.On || st.Kelvin == nil || *st.Kelvin != 3000 || st.Brightness != 0.4 || st.Color != nil {
+		t.Fatalf("unexpected white state %+v", st)
+	}
+}
+
+func TestGovee_GetStateTimesOut(t *testing.T) {
+	f := startFakeGovee(t)
+	c, id := newTestGoveeController(t, f)
+
+	start := time.Now()
+	if _, err := c.GetState(context.Background(), id); !errors.Is(err, errGoveeNoReply) {
+		t.Fatalf("expected errGoveeNoReply from a silent device, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("GetState took %v, expected the %v status timeout", elapsed, c.statusTimeout)
+	}
+}
+
+func TestGovee_GetStateDoesNotWaitOnOtherDevices(t *testing.T) {
+	f := startFakeGovee(t)
+	c, id := newTestGoveeController(t, f)
+	f.setStatus(`{"onOff":1,"brightness":80,"color":{"r":0,"g":0,"b":128},"colorTemInKelvin":0}`)
+	c.mu.Lock()
+	c.devices["govee:silent"] = &goveeDevice{ip: "127.0.0.2", seen: time.Now()}
+	c.mu.Unlock()
+
+	silent := make(chan error, 1)
+	go func() {
+		_, err := c.GetState(context.Background(), "govee:silent")
+		silent <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := c.GetState(context.Background(), id); err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("GetState took %v, expected it not to wait for the silent device", elapsed)
+	}
+	if err := <-silent; !errors.Is(err, errGoveeNoReply) {
+		t.Fatalf("expected errGoveeNoReply from the silent device, got %v", err)
+	}
+}
+
+func TestGovee_DiscoverHonoursContext(t *testing.T) {
+	c := NewGoveeController()
+	if _, err := c.ensureStarted(); err != nil {
+		t.Skipf("Govee listener unavailable: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Discover(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context's error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Discover took %v after its context ended", elapsed)
+	}
+}
+
+func TestGovee_CapabilitiesBySKU(t *testing.T) {
+	if caps := goveeCapabilitiesFor("H6008"); caps.MinKelvin != 2700 || caps.MaxKelvin != 6500 || !caps.Color {
+		t.Fatalf("expected the bulb's own range, got %+v", caps)
+	}
+	if caps := goveeCapabilitiesFor("H9999"); caps != goveeCapabilities {
+		t.Fatalf("expected the LAN defaults for an unknown model, got %+v", caps)
+	}
+
+	c := NewGoveeController()
+	c.devices["govee:aabbccddeeff0011"] = &goveeDevice{ip: "192.168.1.20", sku: "h6008"}
+	k := 9000
+	if got := c.Capabilities("govee:aabbccddeeff0011").Clamp(DeviceState{On: true, Kelvin: &k}); *got.Kelvin != 6500 {
+		t.Fatalf("expected Clamp to use the model's range, got %dK", *got.Kelvin)
+	}
+}
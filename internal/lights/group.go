@@ -0,0 +1,220 @@
+package lights
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// groupRefPrefix marks a group reference where device IDs are expected,
+// such as scene device maps and screen sync device lists.
+const groupRefPrefix = "group:"
+
+// Group is a named set of devices controlled together: a room, a zone or
+// any other selection.
+type Group struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Room makes every device assigned to that room a member, so lights
+	// join the group as soon as they are placed in the room.
+	Room string `json:"room,omitempty"`
+	// DeviceIDs are members listed explicitly, in addition to Room's
+	// devices. Group references here are ignored.
+	DeviceIDs []string `json:"deviceIds,omitempty"`
+}
+
+// GroupRef returns the reference to groupID used in place of a device ID.
+func GroupRef(groupID string) string {
+	return groupRefPrefix + groupID
+}
+
+// ParseGroupRef returns the group ID referenced by id, if it is a group
+// reference.
+func ParseGroupRef(id string) (string, bool) {
+	return strings.CutPrefix(id, groupRefPrefix)
+}
+
+// SetGroups replaces the known groups.
+func (m *Manager) SetGroups(groups []Group) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.groups = make(map[string]Group, len(groups))
+	for _, g := range groups {
+		g.DeviceIDs = append([]string(nil), g.DeviceIDs...)
+		m.groups[g.ID] = g
+	}
+}
+
+// Groups returns the known groups sorted by name.
+func (m *Manager) Groups() []Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	groups := make([]Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		g.DeviceIDs = append([]string(nil), g.DeviceIDs...)
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// GroupMembers returns the device IDs in groupID: its explicit members
+// followed by the devices in its room, sorted by ID.
+func (m *Manager) GroupMembers(groupID string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	g, ok := m.groups[groupID]
+	if !ok {
+		return nil, fmt.Errorf("unknown group %q", groupID)
+	}
+	return m.membersLocked(g), nil
+}
+
+// membersLocked resolves g's members. m.mu must be held.
+func (m *Manager) membersLocked(g Group) []string {
+	seen := make(map[string]bool)
+	var members []string
+	for _, id := range g.DeviceIDs {
+		if _, isGroup := ParseGroupRef(id); isGroup || seen[id] {
+			continue
+		}
+		seen[id] = true
+		members = append(members, id)
+	}
+	if g.Room == "" {
+		return members
+	}
+	var inRoom []string
+	for id, d := range m.devices {
+		if d.Room == g.Room && !seen[id] {
+			inRoom = append(inRoom, id)
+		}
+	}
+	sort.Strings(inRoom)
+	return append(members, inRoom...)
+}
+
+// ResolveDeviceIDs replaces the group references in ids with their members,
+// keeping the order and dropping duplicates. References to unknown groups
+// resolve to nothing.
+func (m *Manager) ResolveDeviceIDs(ids []string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	for _, id := range ids {
+		groupID, isGroup := ParseGroupRef(id)
+		if !isGroup {
+			add(id)
+			continue
+		}
+		if g, ok := m.groups[groupID]; ok {
+			for _, member := range m.membersLocked(g) {
+				add(member)
+			}
+		}
+	}
+	return out
+}
+
+// ExpandGroupStates replaces the group references in states with a copy of
+// the group's state for each member. A device's own entry wins over its
+// groups', and among groups the one whose ID sorts last wins.
+func (m *Manager) ExpandGroupStates(states map[string]DeviceState) map[string]DeviceState {
+	out := make(map[string]DeviceState, len(states))
+	var refs []string
+	for id, state := range states {
+		if _, isGroup := ParseGroupRef(id); isGroup {
+			refs = append(refs, id)
+			continue
+		}
+		out[id] = state
+	}
+	sort.Strings(refs)
+	groupStates := make(map[string]DeviceState)
+	for _, ref := range refs {
+		for _, id := range m.ResolveDeviceIDs([]string{ref}) {
+			groupStates[id] = states[ref].clone()
+		}
+	}
+	for id, state := range groupStates {
+		if _, ok := out[id]; !ok {
+			out[id] = state
+		}
+	}
+	return out
+}
+
+// SetGroupState applies state to every member of groupID through
+// SetDeviceStates and returns each member's result, nil on success.
+func (m *Manager) SetGroupState(ctx context.Context, groupID string, state DeviceState) (map[string]error, error) {
+	members, err := m.GroupMembers(groupID)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]DeviceState, len(members))
+	for _, id := range members {
+		states[id] = state.clone()
+	}
+	return m.applyStates(ctx, states), nil
+}
+
+// TurnOnGroup turns on every member of groupID and returns each member's
+// result.
+func (m *Manager) TurnOnGroup(ctx context.Context, groupID string) (map[string]error, error) {
+	return m.eachMember(ctx, groupID, m.TurnOn)
+}
+
+// TurnOffGroup turns off every member of groupID and returns each member's
+// result.
+func (m *Manager) TurnOffGroup(ctx context.Context, groupID string) (map[string]error, error) {
+	return m.eachMember(ctx, groupID, m.TurnOff)
+}
+
+// eachMember runs fn concurrently for every member of groupID.
+func (m *Manager) eachMember(ctx context.Context, groupID string, fn func(context.Context, string) error) (map[string]error, error) {
+	members, err := m.GroupMembers(groupID)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error, len(members))
+	)
+	for _, id := range members {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := fn(ctx, id)
+			mu.Lock()
+			results[id] = err
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// renameGroupMembers replaces oldID with newID in explicit group members.
+// m.mu must be held.
+func (m *Manager) renameGroupMembers(oldID, newID string) {
+	for gid, g := range m.groups {
+		for i, id := range g.DeviceIDs {
+			if id == oldID {
+				g.DeviceIDs[i] = newID
+				m.groups[gid] = g
+			}
+		}
+	}
+}
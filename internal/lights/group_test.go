@@ -0,0 +1,76 @@
+package lights
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGroups_MembersAndResolve(t *testing.T) {
+	m, c := newTestVirtualManager(VirtualDevice{ID: "a"}, VirtualDevice{ID: "b"}, VirtualDevice{ID: "c"})
+	devices, _ := c.Discover(context.Background())
+	m.SetDevices(devices)
+	m.SetDeviceRoom("virtual:b", "Office")
+	m.SetDeviceRoom("virtual:c", "Office")
+	m.SetGroups([]Group{
+		{ID: "office", Name: "Office", Room: "Office"},
+		{ID: "desk", Name: "Desk", DeviceIDs: []string{"virtual:a", "virtual:b", GroupRef("office")}},
+	})
+
+	members, err := m.GroupMembers("office")
+	if err != nil || len(members) != 2 || members[0] != "virtual:b" || members[1] != "virtual:c" {
+		t.Fatalf("expected the office's devices, got %v (%v)", members, err)
+	}
+	// A light placed in the room joins without editing the group.
+	m.SetDeviceRoom("virtual:a", "Office")
+	if members, _ := m.GroupMembers("office"); len(members) != 3 {
+		t.Fatalf("expected the new light to join, got %v", members)
+	}
+	if members, _ := m.GroupMembers("desk"); len(members) != 2 {
+		t.Fatalf("expected nested group references to be ignored, got %v", members)
+	}
+	if _, err := m.GroupMembers("missing"); err == nil {
+		t.Fatalf("expected an error for an unknown group")
+	}
+
+	ids := m.ResolveDeviceIDs([]string{"virtual:c", GroupRef("desk"), GroupRef("missing")})
+	if len(ids) != 3 || ids[0] != "virtual:c" || ids[1] != "virtual:a" || ids[2] != "virtual:b" {
+		t.Fatalf("unexpected resolved IDs %v", ids)
+	}
+}
+
+func TestGroups_StatesAndFanOut(t *testing.T) {
+	m, c := newTestVirtualManager(VirtualDevice{ID: "a"}, VirtualDevice{ID: "b"})
+	ctx := context.Background()
+	devices, _ := c.Discover(ctx)
+	m.SetDevices(devices)
+	m.SetGroups([]Group{{ID: "all", Name: "All", DeviceIDs: []string{"virtual:a", "virtual:b", "virtual:gone"}}})
+
+	dim := DeviceState{On: true, Brightness: 0.2}.WithTransition(0)
+	bright := DeviceState{On: true, Brightness: 1}.WithTransition(0)
+	states := m.ExpandGroupStates(map[string]DeviceState{GroupRef("all"): dim, "virtual:b": bright})
+	if len(states) != 3 || states["virtual:a"].Brightness != 0.2 || states["virtual:b"].Brightness != 1 {
+		t.Fatalf("expected the device's own state to win over the group's, got %+v", states)
+	}
+
+	results, err := m.SetGroupState(ctx, "all", dim)
+	if err != nil {
+		t.Fatalf("SetGroupState: %v", err)
+	}
+	if len(results) != 3 || results["virtual:a"] != nil || results["virtual:b"] != nil || results["virtual:gone"] == nil {
+		t.Fatalf("expected a result per member with one failure, got %v", results)
+	}
+	if st, _ := m.CachedState("virtual:b"); st.Brightness != 0.2 {
+		t.Fatalf("expected the group state to be applied, got %+v", st)
+	}
+
+	results, err = m.TurnOffGroup(ctx, "all")
+	if err != nil || results["virtual:a"] != nil {
+		t.Fatalf("TurnOffGroup: %v %v", results, err)
+	}
+	if st, _ := m.GetDeviceState(ctx, "virtual:a"); st.On {
+		t.Fatalf("expected the light off, got %+v", st)
+	}
+	if _, err := m.TurnOnGroup(ctx, "missing"); err == nil {
+		t.Fatalf("expected an error for an unknown group")
+	}
+}
@@ -0,0 +1,219 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrDeviceOffline is returned for scene and screen sync commands to devices
+// that health checks have found unreachable, so they fail at once instead
+// of waiting out a network timeout.
+var ErrDeviceOffline = errors.New("device is offline")
+
+// healthTiming is the schedule of the health checks. Devices that answer
+// are checked every interval; after a failure they are retried sooner, and
+// after healthMaxFailures in a row they are offline and retried with a
+// backoff that grows from retry up to maxInterval.
+type healthTiming struct {
+	tick        time.Duration // how often due checks are looked for
+	interval    time.Duration
+	retry       time.Duration
+	maxInterval time.Duration
+	timeout     time.Duration // per probe
+}
+
+var defaultHealthTiming = healthTiming{
+	tick:        time.Second,
+	interval:    30 * time.Second,
+	retry:       5 * time.Second,
+	maxInterval: 2 * time.Minute,
+	timeout:     2 * time.Second,
+}
+
+const healthMaxFailures = 2
+
+type deviceHealth struct {
+	offline  bool
+	failures int
+	lastSeen time.Time
+	next     time.Time
+	probing  bool
+}
+
+// StartHealthChecks probes every device whose controller implements Prober
+// in the background until ctx ends or the Manager is closed. Reachability
+// changes are delivered to OnDeviceEvent handlers as online and offline
+// events, and successful checks refresh Device.LastSeen.
+func (m *Manager) StartHealthChecks(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	m.healthMu.Lock()
+	if m.stopHealth != nil {
+		m.healthMu.Unlock()
+		cancel()
+		return
+	}
+	m.stopHealth = cancel
+	m.healthMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(m.healthTiming.tick)
+		defer ticker.Stop()
+		for {
+			m.runDueChecks(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (m *Manager) runDueChecks(ctx context.Context) {
+	now := time.Now()
+	for _, d := range m.GetDevices() {
+		ctrl, err := m.controllerFor(d.ID)
+		if err != nil {
+			continue
+		}
+		p, ok := ctrl.(Prober)
+		if !ok {
+			continue
+		}
+		m.healthMu.Lock()
+		h := m.healthFor(d.ID)
+		due := !h.probing && !now.Before(h.next)
+		if due {
+			h.probing = true
+		}
+		m.healthMu.Unlock()
+		if due {
+			go m.probe(ctx, p, d.ID)
+		}
+	}
+}
+
+func (m *Manager) probe(ctx context.Context, p Prober, deviceID string) {
+	probeCtx, cancel := context.WithTimeout(ctx, m.healthTiming.timeout)
+	err := p.Probe(probeCtx, deviceID)
+	cancel()
+	if ctx.Err() != nil {
+		m.healthMu.Lock()
+		m.healthFor(deviceID).probing = false
+		m.healthMu.Unlock()
+		return
+	}
+	m.recordHealth(deviceID, err)
+}
+
+// healthFor returns deviceID's entry, creating it due now. m.healthMu must
+// be held.
+func (m *Manager) healthFor(deviceID string) *deviceHealth {
+	h, ok := m.health[deviceID]
+	if !ok {
+		h = &deviceHealth{}
+		m.health[deviceID] = h
+	}
+	return h
+}
+
+// recordHealth applies a probe result and reports reachability changes.
+func (m *Manager) recordHealth(deviceID string, err error) {
+	t := m.healthTiming
+	now := time.Now()
+
+	m.healthMu.Lock()
+	h := m.healthFor(deviceID)
+	h.probing = false
+	wasOffline := h.offline
+	if err == nil {
+		h.failures = 0
+		h.offline = false
+		h.lastSeen = now
+		h.next = now.Add(t.interval)
+	} else {
+		h.failures++
+		if h.failures < healthMaxFailures {
+			h.next = now.Add(t.retry)
+		} else {
+			h.offline = true
+			backoff := t.retry << min(h.failures-healthMaxFailures+1, 16)
+			h.next = now.Add(min(backoff, t.maxInterval))
+		}
+	}
+	offline := h.offline
+	m.healthMu.Unlock()
+
+	switch {
+	case offline && !wasOffline:
+		log.Printf("[manager] %s is offline: %v", deviceID, err)
+		m.notifyEvent(DeviceEvent{Type: DeviceEventOffline, DeviceID: deviceID})
+	case !offline && wasOffline:
+		log.Printf("[manager] %s is back online", deviceID)
+		m.notifyEvent(DeviceEvent{Type: DeviceEventOnline, DeviceID: deviceID})
+	}
+}
+
+// markSeen records that deviceID answered outside a health check: a state
+// read or a push event. Command sends don't count, since UDP brands report
+// success without hearing back.
+func (m *Manager) markSeen(deviceID string) {
+	m.healthMu.Lock()
+	h := m.healthFor(deviceID)
+	if h.offline {
+		m.healthMu.Unlock()
+		m.recordHealth(deviceID, nil)
+		return
+	}
+	h.failures = 0
+	h.lastSeen = time.Now()
+	if !h.probing {
+		h.next = h.lastSeen.Add(m.healthTiming.interval)
+	}
+	m.healthMu.Unlock()
+}
+
+// checkSoon brings deviceID's next health check forward after a command to
+// it failed.
+func (m *Manager) checkSoon(deviceID string) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	if h, ok := m.health[deviceID]; ok && !h.offline {
+		h.next = time.Now()
+	}
+}
+
+// skipOffline returns ErrDeviceOffline when deviceID is known to be
+// unreachable and the command comes from a scene or screen sync. The user's
+// own commands are always attempted.
+func (m *Manager) skipOffline(ctx context.Context, deviceID string) error {
+	if sourceFrom(ctx) == SourceUser {
+		return nil
+	}
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	if h, ok := m.health[deviceID]; ok && h.offline {
+		return ErrDeviceOffline
+	}
+	return nil
+}
+
+// applyHealth copies the health checks' view onto d.
+func (m *Manager) applyHealth(d *Device) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	if h, ok := m.health[d.ID]; ok {
+		d.Offline = h.offline
+		if h.lastSeen.After(d.LastSeen) {
+			d.LastSeen = h.lastSeen
+		}
+	}
+}
+
+func (m *Manager) forgetHealth(deviceID string) {
+	m.healthMu.Lock()
+	delete(m.health, deviceID)
+	m.healthMu.Unlock()
+}
@@ -0,0 +1,76 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// probedVirtual is a virtual controller whose devices can be taken off the
+// network for health checks.
+type probedVirtual struct {
+	*VirtualController
+	mu   sync.Mutex
+	down bool
+}
+
+func (c *probedVirtual) Probe(context.Context, string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return errors.New("no reply")
+	}
+	return nil
+}
+
+func (c *probedVirtual) setDown(down bool) {
+	c.mu.Lock()
+	c.down = down
+	c.mu.Unlock()
+}
+
+func TestHealth_OfflineDevicesAreSkipped(t *testing.T) {
+	c := &probedVirtual{VirtualController: NewVirtualController()}
+	c.SetDevices([]VirtualDevice{{ID: "a"}})
+	m := NewManager()
+	m.RegisterController(c)
+	ctx := context.Background()
+	devices, _ := c.Discover(ctx)
+	m.SetDevices(devices)
+	m.healthTiming = healthTiming{tick: 5 * time.Millisecond, interval: 50 * time.Millisecond, retry: 10 * time.Millisecond, maxInterval: 40 * time.Millisecond, timeout: 100 * time.Millisecond}
+	events := make(chan DeviceEvent, 4)
+	m.OnDeviceEvent(func(ev DeviceEvent) { events <- ev })
+	m.StartHealthChecks(ctx)
+	defer m.Close()
+
+	c.setDown(true)
+	waitDeviceEvent(t, events, DeviceEventOffline)
+	if d, _ := findDevice(m.GetDevices(), "virtual:a"); !d.Offline {
+		t.Fatalf("expected the device to be listed offline")
+	}
+
+	state := DeviceState{On: true, Brightness: 1}.WithTransition(0)
+	err := m.SetDeviceStates(WithSource(ctx, SourceScene), map[string]DeviceState{"virtual:a": state})
+	if !errors.Is(err, ErrDeviceOffline) {
+		t.Fatalf("expected scenes to skip the offline device, got %v", err)
+	}
+	if n := len(m.CommandHistory("virtual:a")); n != 0 {
+		t.Fatalf("expected no command to be sent, got %d", n)
+	}
+	if err := m.SetDeviceState(ctx, "virtual:a", state); err != nil {
+		t.Fatalf("user commands must still be attempted: %v", err)
+	}
+
+	before := time.Now()
+	c.setDown(false)
+	waitDeviceEvent(t, events, DeviceEventOnline)
+	d, _ := findDevice(m.GetDevices(), "virtual:a")
+	if d.Offline || d.LastSeen.Before(before) {
+		t.Fatalf("expected the device back online and just seen, got %+v", d)
+	}
+	if err := m.SetDeviceStates(WithSource(ctx, SourceScene), map[string]DeviceState{"virtual:a": state}); err != nil {
+		t.Fatalf("SetDeviceStates: %v", err)
+	}
+}
@@ -3,10 +3,14 @@ package lights
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,27 +20,46 @@ import (
 type HueBridge struct {
 	IP       string
 	Username string
+	// ClientKey is the hex PSK issued at pairing; it's required for
+	// Entertainment API streaming and empty for bridges paired without one.
+	ClientKey string
 }
 
 type HueController struct {
 	mu      sync.RWMutex
 	bridges map[string]*hueConnection
+
+	// states is each light's last known state, kept current by commands
+	// and the event stream.
+	states  map[string]DeviceState
+	onEvent func(DeviceEvent)
 }
 
 type hueConnection struct {
 	bridge  HueBridge
 	client  *openhue.ClientWithResponses
 	devices map[string]hueDeviceInfo
+
+	baseURL    string // CLIP API root, https://<ip>
+	streamAddr string // Entertainment DTLS endpoint, <ip>:2100
+
+	stopEvents context.CancelFunc // nil until the event stream starts
 }
 
 type hueDeviceInfo struct {
 	lightID string
+	// ownerID is the device resource the light belongs to, which takes the
+	// identify action.
+	ownerID string
 	name    string
+	caps    Capabilities
+	gamut   hueGamut
 }
 
 func NewHueController() *HueController {
 	return &HueController{
 		bridges: make(map[string]*hueConnection),
+		states:  make(map[string]DeviceState),
 	}
 }
 
@@ -61,10 +84,41 @@ func (c *HueController) Brand() Brand {
 	return BrandHue
 }
 
-func (c *HueController) AddBridge(ip, username string) error {
+// hueBridgeCommandRate is the bridge-wide REST budget; every light on a
+// bridge shares it.
+const hueBridgeCommandRate = 10
+
+// hueDefaultCapabilities covers a white-and-colour ambiance bulb; discovery
+// fills in the real gamut and mirek range per light.
+var hueDefaultCapabilities = Capabilities{
+	Color:             true,
+	Gamut:             GamutC,
+	Kelvin:            true,
+	MinKelvin:         2000,
+	MaxKelvin:         6535,
+	KelvinStep:        1,
+	NativeTransitions: true,
+	Segments:          1,
+	MaxCommandRate:    hueBridgeCommandRate,
+	ReadBack:          true,
+}
+
+// Capabilities returns the descriptor built during discovery.
+func (c *HueController) Capabilities(deviceID string) Capabilities {
+	if _, info, ok := c.findDevice(deviceID); ok {
+		return info.caps
+	}
+	return hueDefaultCapabilities
+}
+
+func (c *HueController) AddBridge(bridge HueBridge) error {
+	return c.addBridge(bridge, "https://"+bridge.IP, net.JoinHostPort(bridge.IP, strconv.Itoa(hueStreamPort)))
+}
+
+func (c *HueController) addBridge(bridge HueBridge, apiURL, streamAddr string) error {
+	ip, username := bridge.IP, bridge.Username
 	httpClient := NewHueHTTPClient(0)
 
-	apiURL := fmt.Sprintf("https://%s", ip)
 	client, err := openhue.NewClientWithResponses(
 		apiURL,
 		openhue.WithHTTPClient(httpClient),
@@ -77,14 +131,21 @@ func (c *HueController) AddBridge(ip, username string) error {
 		return fmt.Errorf("failed to create Hue client for %s: %w", ip, err)
 	}
 
+	conn := &hueConnection{
+		bridge:     bridge,
+		client:     client,
+		devices:    make(map[string]hueDeviceInfo),
+		baseURL:    apiURL,
+		streamAddr: streamAddr,
+	}
 	c.mu.Lock()
-	c.bridges[ip] = &hueConnection{
-		bridge:  HueBridge{IP: ip, Username: username},
-		client:  client,
-		devices: make(map[string]hueDeviceInfo),
+	if old, ok := c.bridges[ip]; ok && old.stopEvents != nil {
+		old.stopEvents()
 	}
+	c.bridges[ip] = conn
 	c.mu.Unlock()
 
+	c.startEvents(conn)
 	return nil
 }
 
@@ -115,88 +176,121 @@ func (c *HueController) Discover(ctx context.Context) ([]Device, error) {
 			continue
 		}
 
-		// Fetch Hue Device resources to get model and firmware info (best-effort).
-		// LightGet.Owner.Rid points to the owning DeviceGet, which has ProductData.
-		type hueDeviceMeta struct {
-			modelName       string
-			firmwareVersion string
-		}
-		deviceMeta := make(map[string]hueDeviceMeta) // key: Hue device resource ID
-		if devResp, err := conn.client.GetDevicesWithResponse(ctx); err == nil &&
-			devResp.JSON200 != nil && devResp.JSON200.Data != nil {
-			for _, hd := range *devResp.JSON200.Data {
-				if hd.Id == nil || hd.ProductData == nil {
-					continue
-				}
-				meta := hueDeviceMeta{}
-				if v := hd.ProductData.ProductName; v != nil {
-					meta.modelName = *v
-				} else if v := hd.ProductData.ModelId; v != nil {
-					meta.modelName = *v
-				}
-				if v := hd.ProductData.SoftwareVersion; v != nil {
-					meta.firmwareVersion = *v
-				}
-				deviceMeta[*hd.Id] = meta
-			}
-		}
+		deviceMeta := c.fetchDeviceMeta(ctx, conn)
 
 		bridgeCount := 0
 		for _, l := range *resp.JSON200.Data {
 			if l.Id == nil {
 				continue
 			}
-			deviceID := fmt.Sprintf("hue:%s", *l.Id)
-			name := "Hue Light"
-			if l.Metadata != nil && l.Metadata.Name != nil {
-				name = *l.Metadata.Name
-			}
+			result = append(result, c.registerLight(conn, l, deviceMeta))
+			bridgeCount++
+		}
+		log.Printf("[hue] Bridge %s: found %d light(s)", conn.bridge.IP, bridgeCount)
+	}
+
+	return result, nil
+}
+
+// hueDeviceMeta is the model and firmware of a Hue device resource, which
+// owns one or more lights.
+type hueDeviceMeta struct {
+	modelName       string
+	firmwareVersion string
+}
 
-			c.mu.Lock()
-			conn.devices[deviceID] = hueDeviceInfo{
-				lightID: *l.Id,
-				name:    name,
+// fetchDeviceMeta fetches Hue Device resources to get model and firmware
+// info (best-effort), keyed by device resource ID. LightGet.Owner.Rid points
+// to the owning DeviceGet, which has ProductData.
+func (c *HueController) fetchDeviceMeta(ctx context.Context, conn *hueConnection) map[string]hueDeviceMeta {
+	deviceMeta := make(map[string]hueDeviceMeta)
+	if devResp, err := conn.client.GetDevicesWithResponse(ctx); err == nil &&
+		devResp.JSON200 != nil && devResp.JSON200.Data != nil {
+		for _, hd := range *devResp.JSON200.Data {
+			if hd.Id == nil || hd.ProductData == nil {
+				continue
 			}
-			c.mu.Unlock()
-
-			var minKelvin, maxKelvin int
-			if l.ColorTemperature != nil && l.ColorTemperature.MirekSchema != nil {
-				if v := l.ColorTemperature.MirekSchema.MirekMaximum; v != nil && *v > 0 {
-					minKelvin = 1_000_000 / *v
-				}
-				if v := l.ColorTemperature.MirekSchema.MirekMinimum; v != nil && *v > 0 {
-					maxKelvin = 1_000_000 / *v
-				}
+			meta := hueDeviceMeta{}
+			if v := hd.ProductData.ProductName; v != nil {
+				meta.modelName = *v
+			} else if v := hd.ProductData.ModelId; v != nil {
+				meta.modelName = *v
 			}
-
-			var modelName, firmwareVersion string
-			if l.Owner != nil && l.Owner.Rid != nil {
-				if meta, ok := deviceMeta[*l.Owner.Rid]; ok {
-					modelName = meta.modelName
-					firmwareVersion = meta.firmwareVersion
-				}
+			if v := hd.ProductData.SoftwareVersion; v != nil {
+				meta.firmwareVersion = *v
 			}
+			deviceMeta[*hd.Id] = meta
+		}
+	}
+	return deviceMeta
+}
 
-			result = append(result, Device{
-				ID:              deviceID,
-				Brand:           BrandHue,
-				Name:            name,
-				Model:           modelName,
-				LastIP:          conn.bridge.IP,
-				LastSeen:        time.Now(),
-				SupportsColor:   l.Color != nil,
-				SupportsKelvin:  l.ColorTemperature != nil,
-				MinKelvin:       minKelvin,
-				MaxKelvin:       maxKelvin,
-				KelvinStep:      1,
-				FirmwareVersion: firmwareVersion,
-			})
-			bridgeCount++
+// registerLight records a light's capabilities on its bridge connection and
+// returns it as a Device. l.Id must be set.
+func (c *HueController) registerLight(conn *hueConnection, l openhue.LightGet, deviceMeta map[string]hueDeviceMeta) Device {
+	deviceID := fmt.Sprintf("hue:%s", *l.Id)
+	name := "Hue Light"
+	if l.Metadata != nil && l.Metadata.Name != nil {
+		name = *l.Metadata.Name
+	}
+
+	caps := hueDefaultCapabilities
+	caps.Color = l.Color != nil
+	caps.Gamut = GamutUnknown
+	var gamut hueGamut
+	if l.Color != nil {
+		if l.Color.GamutType != nil {
+			caps.Gamut = Gamut(*l.Color.GamutType)
+		}
+		if g := l.Color.Gamut; g != nil {
+			gamut = hueGamutFromAPI(caps.Gamut, g.Red, g.Green, g.Blue)
+		} else {
+			gamut = hueGamuts[caps.Gamut]
+		}
+	}
+	caps.Kelvin = l.ColorTemperature != nil
+	caps.MinKelvin, caps.MaxKelvin = 0, 0
+	if l.ColorTemperature != nil && l.ColorTemperature.MirekSchema != nil {
+		if v := l.ColorTemperature.MirekSchema.MirekMaximum; v != nil && *v > 0 {
+			caps.MinKelvin = 1_000_000 / *v
+		}
+		if v := l.ColorTemperature.MirekSchema.MirekMinimum; v != nil && *v > 0 {
+			caps.MaxKelvin = 1_000_000 / *v
 		}
-		log.Printf("[hue] Bridge %s: found %d light(s)", conn.bridge.IP, bridgeCount)
 	}
 
-	return result, nil
+	var ownerID string
+	if l.Owner != nil && l.Owner.Rid != nil {
+		ownerID = *l.Owner.Rid
+	}
+
+	c.mu.Lock()
+	conn.devices[deviceID] = hueDeviceInfo{
+		lightID: *l.Id,
+		ownerID: ownerID,
+		name:    name,
+		caps:    caps,
+		gamut:   gamut,
+	}
+	c.mu.Unlock()
+
+	var modelName, firmwareVersion string
+	if meta, ok := deviceMeta[ownerID]; ok {
+		modelName = meta.modelName
+		firmwareVersion = meta.firmwareVersion
+	}
+
+	d := Device{
+		ID:              deviceID,
+		Brand:           BrandHue,
+		Name:            name,
+		Model:           modelName,
+		LastIP:          conn.bridge.IP,
+		LastSeen:        time.Now(),
+		FirmwareVersion: firmwareVersion,
+	}
+	d.applyCapabilities(caps)
+	return d
 }
 
 func (c *HueController) findDevice(deviceID string) (*hueConnection, hueDeviceInfo, bool) {
@@ -210,6 +304,89 @@ func (c *HueController) findDevice(deviceID string) (*hueConnection, hueDeviceIn
 	return nil, hueDeviceInfo{}, false
 }
 
+// RateBudget implements RateBudgeter: every light on a bridge shares the
+// bridge's budget.
+func (c *HueController) RateBudget(deviceID string) (string, float64) {
+	conn, _, ok := c.findDevice(deviceID)
+	if !ok {
+		return deviceID, hueBridgeCommandRate
+	}
+	return "hue-bridge:" + conn.bridge.IP, hueBridgeCommandRate
+}
+
+// Probe implements Prober by reading the bridge resource from the light's
+// bridge; lights are only reachable through it.
+func (c *HueController) Probe(ctx context.Context, deviceID string) error {
+	conn, _, ok := c.findDevice(deviceID)
+	if !ok {
+		return fmt.Errorf("device %s not connected", deviceID)
+	}
+	resp, err := conn.client.GetBridgesWithResponse(ctx)
+	if err != nil {
+		return err
+	}
+	if resp.HTTPResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("bridge returned HTTP %d", resp.HTTPResponse.StatusCode)
+	}
+	return nil
+}
+
+// ProbeAddress implements AddressProber by reading the bridge's public
+// config at addr. Lights are only reachable through a paired bridge, so an
+// unpaired bridge returns ErrPairingRequired and a paired one an error
+// saying so; pairing adds the lights.
+func (c *HueController) ProbeAddress(ctx context.Context, addr string) (Device, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+addr+"/api/0/config", nil)
+	if err != nil {
+		return Device{}, err
+	}
+	resp, err := NewHueHTTPClient(3 * time.Second).Do(req)
+	if err != nil {
+		return Device{}, fmt.Errorf("%w: %v", ErrNoDeviceAtAddress, err)
+	}
+	defer resp.Body.Close()
+	var config struct {
+		Name     string `json:"name"`
+		BridgeID string `json:"bridgeid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil || config.BridgeID == "" {
+		return Device{}, fmt.Errorf("%w: not a Hue bridge", ErrNoDeviceAtAddress)
+	}
+
+	c.mu.RLock()
+	_, paired := c.bridges[addressHost(addr)]
+	c.mu.RUnlock()
+	if paired {
+		return Device{}, fmt.Errorf("bridge %q is already paired; its lights are found by discovery", config.Name)
+	}
+	return Device{}, fmt.Errorf("%w: %q", ErrPairingRequired, config.Name)
+}
+
+// Identify implements Identifier with the identify action on the light's
+// device resource; the light performs a breathe cycle.
+func (c *HueController) Identify(ctx context.Context, deviceID string) error {
+	conn, info, ok := c.findDevice(deviceID)
+	if !ok {
+		return fmt.Errorf("device %s not connected", deviceID)
+	}
+	if info.ownerID == "" {
+		return fmt.Errorf("device %s has no device resource to identify", deviceID)
+	}
+	action := openhue.Identify
+	var body openhue.UpdateDeviceJSONRequestBody
+	body.Identify = &struct {
+		Action *openhue.DevicePutIdentifyAction `json:"action,omitempty"`
+	}{Action: &action}
+	resp, err := conn.client.UpdateDeviceWithResponse(ctx, info.ownerID, body)
+	if err != nil {
+		return err
+	}
+	if resp.HTTPResponse != nil && resp.HTTPResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("bridge returned HTTP %d", resp.HTTPResponse.StatusCode)
+	}
+	return nil
+}
+
 func (c *HueController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
 	conn, info, ok := c.findDevice(deviceID)
 	if !ok {
@@ -224,22 +401,29 @@ func (c *HueController) SetState(ctx context.Context, deviceID string, state Dev
 	brightness := openhue.Brightness(state.Brightness * 100.0)
 	body.Dimming = &openhue.Dimming{Brightness: &brightness}
 
+	// A light is in either colour or colour-temperature mode, so only one is
+	// sent; colour wins, as on the other brands.
 	if state.Color != nil {
-		xy := hsbToXY(state.Color.H, state.Color.S, state.Color.B)
+		xy := hsbToXY(state.Color.H, state.Color.S, state.Color.B, info.gamut)
 		x := float32(xy[0])
 		y := float32(xy[1])
 		body.Color = &openhue.Color{
 			Xy: &openhue.GamutPosition{X: &x, Y: &y},
 		}
-	}
-
-	if state.Kelvin != nil {
+	} else if state.Kelvin != nil {
 		mirek := kelvinToMirek(*state.Kelvin)
 		body.ColorTemperature = &openhue.ColorTemperature{
 			Mirek: &mirek,
 		}
 	}
 
+	// Only send dynamics when the caller asked for a duration so the bridge
+	// keeps its own default otherwise.
+	if state.TransitionMs != nil {
+		duration := int(state.Transition(0) / time.Millisecond)
+		body.Dynamics = &openhue.LightDynamics{Duration: &duration}
+	}
+
 	resp, err := conn.client.UpdateLightWithResponse(ctx, info.lightID, body)
 	if err != nil {
 		log.Printf("[hue] UpdateLight %s error: %v", deviceID, err)
@@ -249,9 +433,54 @@ func (c *HueController) SetState(ctx context.Context, deviceID string, state Dev
 		log.Printf("[hue] UpdateLight %s returned HTTP %d", deviceID, resp.HTTPResponse.StatusCode)
 		return fmt.Errorf("bridge returned HTTP %d", resp.HTTPResponse.StatusCode)
 	}
+	c.rememberSent(deviceID, state)
 	return nil
 }
 
+// rememberSent updates the cached state with what an accepted update set.
+// Without a cached state it is only recorded when it carries a colour or
+// colour temperature, since it would otherwise be incomplete.
+func (c *HueController) rememberSent(deviceID string, sent DeviceState) {
+	c.mu.RLock()
+	state, ok := c.states[deviceID]
+	c.mu.RUnlock()
+	if !ok && sent.Color == nil && sent.Kelvin == nil {
+		return
+	}
+	state.On = sent.On
+	state.Brightness = sent.Brightness
+	if sent.Color != nil {
+		state.Color, state.Kelvin = sent.Color, nil
+	} else if sent.Kelvin != nil {
+		state.Color, state.Kelvin = nil, sent.Kelvin
+	}
+	c.rememberState(deviceID, state)
+}
+
+// SetStates issues every update at once. The shared HTTP/2 transport from
+// NewHueHTTPClient multiplexes them over one connection per bridge, so a
+// whole scene lands in a single burst instead of sequential round trips.
+func (c *HueController) SetStates(ctx context.Context, states map[string]DeviceState) error {
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for deviceID, state := range states {
+		wg.Add(1)
+		go func(id string, st DeviceState) {
+			defer wg.Done()
+			if err := c.SetState(ctx, id, st); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", id, err))
+				mu.Unlock()
+			}
+		}(deviceID, state)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
 func (c *HueController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
 	conn, info, ok := c.findDevice(deviceID)
 	if !ok {
@@ -267,21 +496,36 @@ func (c *HueController) GetState(ctx context.Context, deviceID string) (DeviceSt
 		return DeviceState{}, fmt.Errorf("no data for device %s", deviceID)
 	}
 
-	l := (*resp.JSON200.Data)[0]
-	state := DeviceState{Brightness: 1.0}
+	state := mergeHueLight(DeviceState{Brightness: 1.0}, (*resp.JSON200.Data)[0], info.gamut)
+	c.rememberState(deviceID, state)
+	return state, nil
+}
 
+// mergeHueLight applies the fields present in l to base. Full resources and
+// the partial updates of the event stream decode into the same type.
+func mergeHueLight(base DeviceState, l openhue.LightGet, gamut hueGamut) DeviceState {
+	state := base.clone()
 	if l.On != nil && l.On.On != nil {
 		state.On = *l.On.On
 	}
 	if l.Dimming != nil && l.Dimming.Brightness != nil {
 		state.Brightness = float64(*l.Dimming.Brightness) / 100.0
 	}
-	if l.ColorTemperature != nil && l.ColorTemperature.Mirek != nil {
-		kelvin := mirekToKelvin(*l.ColorTemperature.Mirek)
+	// mirek_valid is the v2 API's colour mode: it is false while the light
+	// shows an xy colour outside the colour-temperature curve.
+	ct := l.ColorTemperature
+	if ct != nil && ct.Mirek != nil && (ct.MirekValid == nil || *ct.MirekValid) {
+		kelvin := mirekToKelvin(*ct.Mirek)
 		state.Kelvin = &kelvin
+		state.Color = nil
+	} else if l.Color != nil {
+		if xy, ok := hueXY(l.Color.Xy); ok {
+			color := xyToHSB(xy, gamut)
+			state.Color = &color
+			state.Kelvin = nil
+		}
 	}
-
-	return state, nil
+	return state
 }
 
 func (c *HueController) TurnOn(ctx context.Context, deviceID string) error {
@@ -302,6 +546,14 @@ func (c *HueController) TurnOff(ctx context.Context, deviceID string) error {
 }
 
 func (c *HueController) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.bridges {
+		if conn.stopEvents != nil {
+			conn.stopEvents()
+			conn.stopEvents = nil
+		}
+	}
 	return nil
 }
 
@@ -322,7 +574,10 @@ func mirekToKelvin(mirek int) int {
 	return 1000000 / mirek
 }
 
-func hsbToXY(h, s, b float64) [2]float64 {
+// hsbToXY converts to CIE xy using the wide-gamut D65 conversion, then
+// clamps into the light's gamut so the bridge doesn't pick its own nearest
+// colour.
+func hsbToXY(h, s, b float64, gamut hueGamut) [2]float64 {
 	r, g, bl := HSBToRGB(h, s, b)
 	rf := gammaCorrect(float64(r) / 255.0)
 	gf := gammaCorrect(float64(g) / 255.0)
@@ -336,7 +591,7 @@ func hsbToXY(h, s, b float64) [2]float64 {
 	if sum == 0 {
 		return [2]float64{0.3127, 0.3290}
 	}
-	return [2]float64{x / sum, y / sum}
+	return gamut.clamp([2]float64{x / sum, y / sum})
 }
 
 func gammaCorrect(v float64) float64 {
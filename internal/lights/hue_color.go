@@ -0,0 +1,119 @@
+package lights
+
+import (
+	"math"
+
+	"github.com/openhue/openhue-go"
+)
+
+// hueGamut is the triangle of CIE xy colours a Hue light can reproduce. The
+// zero value means unknown, and colours are then passed through unclamped.
+type hueGamut struct {
+	red, green, blue [2]float64
+}
+
+// Philips' published gamuts, used when a light reports only its gamut type.
+var hueGamuts = map[Gamut]hueGamut{
+	GamutA: {red: [2]float64{0.704, 0.296}, green: [2]float64{0.2151, 0.7106}, blue: [2]float64{0.138, 0.08}},
+	GamutB: {red: [2]float64{0.675, 0.322}, green: [2]float64{0.409, 0.518}, blue: [2]float64{0.167, 0.04}},
+	GamutC: {red: [2]float64{0.6915, 0.3083}, green: [2]float64{0.17, 0.7}, blue: [2]float64{0.1532, 0.0475}},
+}
+
+// hueGamutFromAPI prefers the triangle the bridge reports for the light and
+// falls back to the table for its gamut type.
+func hueGamutFromAPI(gamutType Gamut, red, green, blue *openhue.GamutPosition) hueGamut {
+	r, okR := hueXY(red)
+	g, okG := hueXY(green)
+	b, okB := hueXY(blue)
+	if okR && okG && okB {
+		return hueGamut{red: r, green: g, blue: b}
+	}
+	return hueGamuts[gamutType]
+}
+
+func hueXY(p *openhue.GamutPosition) ([2]float64, bool) {
+	if p == nil || p.X == nil || p.Y == nil {
+		return [2]float64{}, false
+	}
+	return [2]float64{float64(*p.X), float64(*p.Y)}, true
+}
+
+func (g hueGamut) known() bool {
+	return g != hueGamut{}
+}
+
+// clamp returns p, or the closest point on the gamut's edge when p lies
+// outside it.
+func (g hueGamut) clamp(p [2]float64) [2]float64 {
+	if !g.known() || g.contains(p) {
+		return p
+	}
+	best := closestOnSegment(g.red, g.green, p)
+	for _, q := range [][2]float64{closestOnSegment(g.green, g.blue, p), closestOnSegment(g.blue, g.red, p)} {
+		if xyDistance(q, p) < xyDistance(best, p) {
+			best = q
+		}
+	}
+	return best
+}
+
+func (g hueGamut) contains(p [2]float64) bool {
+	d1 := xyCross(g.red, g.green, p)
+	d2 := xyCross(g.green, g.blue, p)
+	d3 := xyCross(g.blue, g.red, p)
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNeg && hasPos)
+}
+
+func xyCross(a, b, p [2]float64) float64 {
+	return (b[0]-a[0])*(p[1]-a[1]) - (b[1]-a[1])*(p[0]-a[0])
+}
+
+func closestOnSegment(a, b, p [2]float64) [2]float64 {
+	ab := [2]float64{b[0] - a[0], b[1] - a[1]}
+	t := ((p[0]-a[0])*ab[0] + (p[1]-a[1])*ab[1]) / (ab[0]*ab[0] + ab[1]*ab[1])
+	t = math.Max(0, math.Min(1, t))
+	return [2]float64{a[0] + t*ab[0], a[1] + t*ab[1]}
+}
+
+func xyDistance(a, b [2]float64) float64 {
+	return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+// xyToHSB converts a CIE xy colour to hue and saturation at full value,
+// the inverse of hsbToXY. Brightness comes from the light's dimming level.
+func xyToHSB(xy [2]float64, gamut hueGamut) Color {
+	xy = gamut.clamp(xy)
+	if xy[1] <= 0 {
+		return Color{H: 0, S: 0, B: 1}
+	}
+	y := 1.0
+	x := y / xy[1] * xy[0]
+	z := y / xy[1] * (1 - xy[0] - xy[1])
+
+	rgb := [3]float64{
+		x*1.656492 - y*0.354851 - z*0.255038,
+		-x*0.707196 + y*1.655397 + z*0.036152,
+		x*0.051713 - y*0.121364 + z*1.011530,
+	}
+	peak := math.Max(rgb[0], math.Max(rgb[1], rgb[2]))
+	var out [3]uint8
+	for i, v := range rgb {
+		v = math.Max(v, 0)
+		if peak > 0 {
+			v /= peak
+		}
+		out[i] = uint8(math.Round(gammaEncode(v) * 255))
+	}
+	h, s, _ := RGBToHSB(out[0], out[1], out[2])
+	return Color{H: h, S: s, B: 1}
+}
+
+// gammaEncode is the inverse of gammaCorrect.
+func gammaEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
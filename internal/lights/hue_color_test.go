@@ -0,0 +1,124 @@
+package lights
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHueColor_RoundTrip(t *testing.T) {
+	for _, s := range []float64{1, 0.5} {
+		for h := 0.0; h < 360; h += 30 {
+			xy := hsbToXY(h, s, 1, hueGamut{})
+			got := xyToHSB(xy, hueGamut{})
+			dh := math.Abs(got.H - h)
+			if dh > 180 {
+				dh = 360 - dh
+			}
+			if dh > 2 || math.Abs(got.S-s) > 0.02 {
+				t.Fatalf("H=%v S=%v came back as H=%.1f S=%.3f (xy %v)", h, s, got.H, got.S, xy)
+			}
+		}
+	}
+}
+
+func TestHueColor_GamutClamp(t *testing.T) {
+	gamut := hueGamuts[GamutB]
+	raw := hsbToXY(120, 1, 1, hueGamut{})
+	if gamut.contains(raw) {
+		t.Fatalf("expected pure green %v to be outside gamut B", raw)
+	}
+	clamped := hsbToXY(120, 1, 1, gamut)
+	if !gamut.contains(clamped) || xyDistance(clamped, gamut.green) > 0.05 {
+		t.Fatalf("expected green to clamp near gamut B's green corner, got %v", clamped)
+	}
+	if inside := [2]float64{0.4, 0.4}; gamut.clamp(inside) != inside {
+		t.Fatalf("in-gamut colours must pass through unchanged")
+	}
+	if got := xyToHSB([2]float64{0.2, 0.75}, gamut); math.Abs(got.H-80) > 15 {
+		t.Fatalf("expected an out-of-gamut read to clamp to yellow-green, got H=%.1f", got.H)
+	}
+}
+
+// hueLightBridge serves GET and PUT for a single light resource.
+type hueLightBridge struct {
+	mu    sync.Mutex
+	light map[string]interface{}
+	puts  []map[string]interface{}
+}
+
+func (b *hueLightBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.URL.Path != "/clip/v2/resource/light/light-1" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodPut {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		b.puts = append(b.puts, body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []interface{}{}, "data": []interface{}{b.light}})
+}
+
+func (b *hueLightBridge) set(light map[string]interface{}) {
+	b.mu.Lock()
+	b.light = light
+	b.mu.Unlock()
+}
+
+func TestHueGetState_ColorModes(t *testing.T) {
+	fake := &hueLightBridge{}
+	api := httptest.NewTLSServer(fake)
+	defer api.Close()
+	c := newTestHueController(t, api.URL, "")
+	c.bridges["bridge"].devices["hue:light-1"] = hueDeviceInfo{lightID: "light-1", gamut: hueGamuts[GamutC]}
+	ctx := context.Background()
+
+	// xy mode: the stale mirek must not win over the colour.
+	fake.set(map[string]interface{}{
+		"id":                "light-1",
+		"on":                map[string]bool{"on": true},
+		"dimming":           map[string]float64{"brightness": 60},
+		"color":             map[string]interface{}{"xy": map[string]float64{"x": 0.1532, "y": 0.0475}},
+		"color_temperature": map[string]interface{}{"mirek": 366, "mirek_valid": false},
+	})
+	st, err := c.GetState(ctx, "hue:light-1")
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if !st.On || st.Brightness != 0.6 || st.Kelvin != nil || st.Color == nil || math.Abs(st.Color.H-245) > 15 {
+		t.Fatalf("expected a blue colour state, got %+v (color %+v)", st, st.Color)
+	}
+
+	// Writing it back sends xy only.
+	if err := c.SetState(ctx, "hue:light-1", st); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	put := fake.puts[len(fake.puts)-1]
+	if _, ok := put["color"]; !ok || put["color_temperature"] != nil {
+		t.Fatalf("expected only xy in the update, got %v", put)
+	}
+
+	// CT mode restores as Kelvin.
+	fake.set(map[string]interface{}{
+		"id":                "light-1",
+		"on":                map[string]bool{"on": true},
+		"dimming":           map[string]float64{"brightness": 100},
+		"color":             map[string]interface{}{"xy": map[string]float64{"x": 0.4573, "y": 0.41}},
+		"color_temperature": map[string]interface{}{"mirek": 366, "mirek_valid": true},
+	})
+	st, err = c.GetState(ctx, "hue:light-1")
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if st.Color != nil || st.Kelvin == nil || *st.Kelvin != 2732 {
+		t.Fatalf("expected a 2732K state, got %+v", st)
+	}
+}
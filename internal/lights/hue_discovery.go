@@ -0,0 +1,167 @@
+package lights
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hueNUPnPURLs are Philips' cloud lookups of the bridges behind the
+// caller's public IP, tried in order.
+var hueNUPnPURLs = []string{
+	"https://discovery.meethue.com/",
+	"https://www.meethue.com/api/nupnp",
+	"http://www.meethue.com/api/nupnp",
+}
+
+// DiscoveryHints implements DiscoveryProvider: bridges answer SSDP and are
+// listed by the cloud lookup, and a probe of the subnet finds them when
+// neither works.
+func (c *HueController) DiscoveryHints() []DiscoveryHint {
+	return []DiscoveryHint{
+		{
+			Method:  DiscoverSSDP,
+			Targets: []string{"ssdp:all", "urn:schemas-upnp-org:device:Basic:1", "upnp:rootdevice"},
+			Match:   isHueSSDPResponse,
+		},
+		{Method: DiscoverSearch, Search: searchHueCloud},
+		{Method: DiscoverProbe, Probe: isHueBridge, Fallback: true},
+	}
+}
+
+// AddDiscovered implements DiscoveryProvider. A bridge needs pairing before
+// its lights can be reached, so one found by a scan is only logged; the
+// pairing flow lists them.
+func (c *HueController) AddDiscovered(host, _ string) {
+	c.mu.RLock()
+	_, paired := c.bridges[host]
+	c.mu.RUnlock()
+	if !paired {
+		log.Printf("[hue] Found bridge at %s; pair it to add its lights", host)
+	}
+}
+
+func isHueSSDPResponse(response string) bool {
+	upper := strings.ToUpper(response)
+	return strings.Contains(upper, "HUE") ||
+		strings.Contains(upper, "PHILIPS") ||
+		strings.Contains(upper, "IPBRIDGE")
+}
+
+// searchHueCloud asks the N-UPnP service for the bridges on this network.
+func searchHueCloud(ctx context.Context, found func(host, name string)) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	var (
+		lastErr  error
+		answered bool
+	)
+	for _, url := range hueNUPnPURLs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s returned HTTP %d", url, resp.StatusCode)
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var results []struct {
+			ID                string `json:"id"`
+			InternalIPAddress string `json:"internalipaddress"`
+		}
+		if err := json.Unmarshal(body, &results); err != nil {
+			lastErr = fmt.Errorf("parse %s: %w", url, err)
+			continue
+		}
+		answered = true
+		listed := false
+		for _, r := range results {
+			if r.InternalIPAddress == "" {
+				continue
+			}
+			name := "Hue Bridge"
+			if len(r.ID) >= 6 {
+				name = "Hue Bridge (" + r.ID[len(r.ID)-6:] + ")"
+			}
+			found(r.InternalIPAddress, name)
+			listed = true
+		}
+		if listed {
+			return nil
+		}
+	}
+	// An empty list is a valid answer: no bridges are registered here.
+	if answered {
+		return nil
+	}
+	return lastErr
+}
+
+var hueProbeHTTPClient = &http.Client{Timeout: 1 * time.Second}
+
+// hueProbeHTTPSClient accepts the bridge's self-signed certificate.
+var hueProbeHTTPSClient = NewHueHTTPClient(1 * time.Second)
+
+// isHueBridge reports whether host serves a bridge config, over plain HTTP
+// as older firmware does or HTTPS.
+func isHueBridge(ctx context.Context, host string) bool {
+	urls := []string{
+		fmt.Sprintf("http://%s/api/config", host),
+		fmt.Sprintf("https://%s/api/0/config", host),
+	}
+
+	for _, url := range urls {
+		client := hueProbeHTTPSClient
+		if strings.HasPrefix(url, "http://") {
+			client = hueProbeHTTPClient
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		var config struct {
+			BridgeID string `json:"bridgeid"`
+		}
+		if err := json.Unmarshal(body, &config); err != nil {
+			continue
+		}
+		if config.BridgeID != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,583 @@
+package lights
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v3"
+)
+
+const (
+	hueStreamPort = 2100
+	// hueStreamInterval paces outgoing frames. The bridge renders at 25 Hz
+	// and recommends sending faster so a lost UDP packet doesn't show.
+	hueStreamInterval = 20 * time.Millisecond
+	// hueStreamMaxChannels is the protocol limit per entertainment area.
+	hueStreamMaxChannels = 20
+	// hueEntertainmentName names the area LightSync creates and reuses.
+	hueEntertainmentName = "LightSync"
+	hueHandshakeTimeout  = 5 * time.Second
+)
+
+// hueEntertainmentConfig is the subset of an entertainment_configuration
+// resource needed to stream to it.
+type hueEntertainmentConfig struct {
+	ID     string
+	Name   string
+	Status string
+	// Channels maps Hue light resource IDs to the channels rendering them.
+	Channels map[string][]uint8
+}
+
+// covers reports whether every light in lightIDs has a channel.
+func (c hueEntertainmentConfig) covers(lightIDs []string) bool {
+	for _, id := range lightIDs {
+		if len(c.Channels[id]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hueEntertainmentAPI wraps the CLIP v2 entertainment endpoints of one
+// bridge. It uses raw JSON because openhue-go doesn't model them.
+type hueEntertainmentAPI struct {
+	baseURL  string
+	username string
+	client   *http.Client
+}
+
+type hueResourceRef struct {
+	Rid   string `json:"rid"`
+	Rtype string `json:"rtype"`
+}
+
+func (a *hueEntertainmentAPI) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+"/clip/v2/resource/"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("hue-application-key", a.username)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Errors []struct {
+				Description string `json:"description"`
+			} `json:"errors"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && len(apiErr.Errors) > 0 {
+			return fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, apiErr.Errors[0].Description)
+		}
+		return fmt.Errorf("%s %s: HTTP %d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// entertainmentServices maps entertainment service IDs to the light each one
+// renders. Newer bridges report renderer_reference directly; older ones are
+// matched through the owning device.
+func (a *hueEntertainmentAPI) entertainmentServices(ctx context.Context) (map[string]string, error) {
+	var ents struct {
+		Data []struct {
+			ID                string          `json:"id"`
+			Owner             hueResourceRef  `json:"owner"`
+			Renderer          bool            `json:"renderer"`
+			RendererReference *hueResourceRef `json:"renderer_reference"`
+		} `json:"data"`
+	}
+	if err := a.do(ctx, http.MethodGet, "entertainment", nil, &ents); err != nil {
+		return nil, err
+	}
+
+	var lightsResp struct {
+		Data []struct {
+			ID    string         `json:"id"`
+			Owner hueResourceRef `json:"owner"`
+		} `json:"data"`
+	}
+	if err := a.do(ctx, http.MethodGet, "light", nil, &lightsResp); err != nil {
+		return nil, err
+	}
+	lightByOwner := make(map[string]string, len(lightsResp.Data))
+	for _, l := range lightsResp.Data {
+		lightByOwner[l.Owner.Rid] = l.ID
+	}
+
+	out := make(map[string]string)
+	for _, e := range ents.Data {
+		if !e.Renderer {
+			continue
+		}
+		if e.RendererReference != nil && e.RendererReference.Rid != "" {
+			out[e.ID] = e.RendererReference.Rid
+		} else if lightID, ok := lightByOwner[e.Owner.Rid]; ok {
+			out[e.ID] = lightID
+		}
+	}
+	return out, nil
+}
+
+// configurations lists the bridge's entertainment areas with their channels
+// resolved to light IDs.
+func (a *hueEntertainmentAPI) configurations(ctx context.Context, path string) ([]hueEntertainmentConfig, error) {
+	services, err := a.entertainmentServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data []struct {
+			ID       string `json:"id"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Status   string `json:"status"`
+			Channels []struct {
+				ChannelID uint8 `json:"channel_id"`
+				Members   []struct {
+					Service hueResourceRef `json:"service"`
+				} `json:"members"`
+			} `json:"channels"`
+		} `json:"data"`
+	}
+	if err := a.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	configs := make([]hueEntertainmentConfig, 0, len(resp.Data))
+	for _, d := range resp.Data {
+		cfg := hueEntertainmentConfig{
+			ID:       d.ID,
+			Name:     d.Metadata.Name,
+			Status:   d.Status,
+			Channels: make(map[string][]uint8),
+		}
+		for _, ch := range d.Channels {
+			for _, m := range ch.Members {
+				if lightID, ok := services[m.Service.Rid]; ok {
+					cfg.Channels[lightID] = append(cfg.Channels[lightID], ch.ChannelID)
+				}
+			}
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// selectConfiguration returns an entertainment area covering lightIDs. An
+// existing area is reused when it covers every light; otherwise LightSync's
+// own area is created or updated to hold exactly these lights.
+func (a *hueEntertainmentAPI) selectConfiguration(ctx context.Context, lightIDs []string) (hueEntertainmentConfig, error) {
+	configs, err := a.configurations(ctx, "entertainment_configuration")
+	if err != nil {
+		return hueEntertainmentConfig{}, err
+	}
+
+	var own *hueEntertainmentConfig
+	for i, cfg := range configs {
+		if cfg.Name == hueEntertainmentName {
+			if cfg.covers(lightIDs) {
+				return cfg, nil
+			}
+			own = &configs[i]
+		}
+	}
+	for _, cfg := range configs {
+		if cfg.covers(lightIDs) {
+			return cfg, nil
+		}
+	}
+
+	services, err := a.entertainmentServices(ctx)
+	if err != nil {
+		return hueEntertainmentConfig{}, err
+	}
+	serviceByLight := make(map[string]string, len(services))
+	for svc, light := range services {
+		serviceByLight[light] = svc
+	}
+
+	// Lay lights out left to right across the front of the screen; screen
+	// sync assigns colours itself, so positions only need to be distinct.
+	type position struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+		Z float64 `json:"z"`
+	}
+	type serviceLocation struct {
+		Service   hueResourceRef `json:"service"`
+		Positions []position     `json:"positions"`
+	}
+	var locations []serviceLocation
+	for _, id := range lightIDs {
+		svc, ok := serviceByLight[id]
+		if !ok {
+			continue
+		}
+		locations = append(locations, serviceLocation{
+			Service: hueResourceRef{Rid: svc, Rtype: "entertainment"},
+		})
+	}
+	if len(locations) == 0 {
+		return hueEntertainmentConfig{}, errors.New("none of the lights support entertainment streaming")
+	}
+	if len(locations) > hueStreamMaxChannels {
+		locations = locations[:hueStreamMaxChannels]
+	}
+	for i := range locations {
+		x := -1.0
+		if len(locations) > 1 {
+			x = -1 + 2*float64(i)/float64(len(locations)-1)
+		}
+		locations[i].Positions = []position{{X: x, Y: 0.8, Z: 0}}
+	}
+
+	body := map[string]interface{}{
+		"metadata":           map[string]string{"name": hueEntertainmentName},
+		"configuration_type": "screen",
+		"locations":          map[string]interface{}{"service_locations": locations},
+	}
+
+	id := ""
+	if own != nil {
+		id = own.ID
+		if err := a.do(ctx, http.MethodPut, "entertainment_configuration/"+id, body, nil); err != nil {
+			return hueEntertainmentConfig{}, fmt.Errorf("update entertainment area: %w", err)
+		}
+	} else {
+		body["type"] = "entertainment_configuration"
+		var created struct {
+			Data []hueResourceRef `json:"data"`
+		}
+		if err := a.do(ctx, http.MethodPost, "entertainment_configuration", body, &created); err != nil {
+			return hueEntertainmentConfig{}, fmt.Errorf("create entertainment area: %w", err)
+		}
+		if len(created.Data) == 0 {
+			return hueEntertainmentConfig{}, errors.New("create entertainment area: empty response")
+		}
+		id = created.Data[0].Rid
+	}
+
+	fresh, err := a.configurations(ctx, "entertainment_configuration/"+id)
+	if err != nil {
+		return hueEntertainmentConfig{}, err
+	}
+	if len(fresh) == 0 {
+		return hueEntertainmentConfig{}, fmt.Errorf("entertainment area %s not found", id)
+	}
+	return fresh[0], nil
+}
+
+// setStreaming starts or stops streaming mode for an entertainment area.
+func (a *hueEntertainmentAPI) setStreaming(ctx context.Context, configID string, active bool) error {
+	action := "stop"
+	if active {
+		action = "start"
+	}
+	return a.do(ctx, http.MethodPut, "entertainment_configuration/"+configID, map[string]string{"action": action}, nil)
+}
+
+// hueStream is an open Entertainment API session on one bridge. Send stores
+// the latest frame; a background loop transmits it over DTLS at a fixed
+// cadence, which also keeps the bridge from timing the stream out.
+type hueStream struct {
+	api      *hueEntertainmentAPI
+	configID string
+	conn     net.Conn
+	channels map[string][]uint8 // device ID → channels
+	devices  []string
+
+	mu    sync.Mutex
+	frame map[uint8][3]uint16
+	seq   uint8
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// openHueStream selects an entertainment area for devices (device ID →
+// light ID), enables streaming and completes the DTLS-PSK handshake.
+func openHueStream(ctx context.Context, api *hueEntertainmentAPI, clientKey, streamAddr string, devices map[string]string) (*hueStream, error) {
+	psk, err := hex.DecodeString(clientKey)
+	if err != nil || len(psk) == 0 {
+		return nil, fmt.Errorf("invalid client key: %v", err)
+	}
+
+	lightIDs := make([]string, 0, len(devices))
+	for _, lightID := range devices {
+		lightIDs = append(lightIDs, lightID)
+	}
+	sort.Strings(lightIDs)
+
+	cfg, err := api.selectConfiguration(ctx, lightIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := api.setStreaming(ctx, cfg.ID, true); err != nil {
+		return nil, fmt.Errorf("start streaming: %w", err)
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", streamAddr)
+	if err != nil {
+		_ = api.setStreaming(context.WithoutCancel(ctx), cfg.ID, false)
+		return nil, err
+	}
+	conn, err := dtls.Dial("udp", raddr, &dtls.Config{
+		PSK: func([]byte) ([]byte, error) {
+			return psk, nil
+		},
+		PSKIdentityHint: []byte(api.username),
+		CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		_ = api.setStreaming(context.WithoutCancel(ctx), cfg.ID, false)
+		return nil, fmt.Errorf("dtls dial: %w", err)
+	}
+	hsCtx, cancel := context.WithTimeout(ctx, hueHandshakeTimeout)
+	err = conn.HandshakeContext(hsCtx)
+	cancel()
+	if err != nil {
+		conn.Close()
+		_ = api.setStreaming(context.WithoutCancel(ctx), cfg.ID, false)
+		return nil, fmt.Errorf("dtls handshake: %w", err)
+	}
+
+	s := &hueStream{
+		api:      api,
+		configID: cfg.ID,
+		conn:     conn,
+		channels: make(map[string][]uint8),
+		frame:    make(map[uint8][3]uint16),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for deviceID, lightID := range devices {
+		if ch := cfg.Channels[lightID]; len(ch) > 0 {
+			s.channels[deviceID] = ch
+			s.devices = append(s.devices, deviceID)
+		}
+	}
+	sort.Strings(s.devices)
+
+	go s.loop()
+	return s, nil
+}
+
+func (s *hueStream) Devices() []string {
+	return append([]string(nil), s.devices...)
+}
+
+func (s *hueStream) Send(states map[string]DeviceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, st := range states {
+		rgb := hueStreamRGB(st)
+		for _, ch := range s.channels[id] {
+			s.frame[ch] = rgb
+		}
+	}
+	return nil
+}
+
+func (s *hueStream) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(hueStreamInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		if len(s.frame) == 0 {
+			s.mu.Unlock()
+			continue
+		}
+		packet := encodeHueStreamFrame(s.configID, s.seq, s.frame)
+		s.seq++
+		s.mu.Unlock()
+		if _, err := s.conn.Write(packet); err != nil {
+			log.Printf("[hue] Entertainment stream write failed: %v", err)
+		}
+	}
+}
+
+// Close stops the send loop, closes the DTLS session and takes the area out
+// of streaming mode so the lights accept regular commands again.
+func (s *hueStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		connErr := s.conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		s.closeErr = errors.Join(connErr, s.api.setStreaming(ctx, s.configID, false))
+	})
+	return s.closeErr
+}
+
+// encodeHueStreamFrame builds a HueStream v2 packet in the RGB colour space:
+// a 52-byte header (protocol name, version, sequence, colour space, area ID)
+// followed by 7 bytes per channel (ID + 16-bit R, G, B).
+func encodeHueStreamFrame(configID string, seq uint8, frame map[uint8][3]uint16) []byte {
+	ids := make([]int, 0, len(frame))
+	for ch := range frame {
+		ids = append(ids, int(ch))
+	}
+	sort.Ints(ids)
+	if len(ids) > hueStreamMaxChannels {
+		ids = ids[:hueStreamMaxChannels]
+	}
+
+	buf := make([]byte, 0, 52+7*len(ids))
+	buf = append(buf, "HueStream"...)
+	buf = append(buf, 0x02, 0x00) // API version 2.0
+	buf = append(buf, seq)
+	buf = append(buf, 0x00, 0x00) // reserved
+	buf = append(buf, 0x00)       // colour space: RGB
+	buf = append(buf, 0x00)       // reserved
+	buf = append(buf, configID...)
+	for _, id := range ids {
+		rgb := frame[uint8(id)]
+		buf = append(buf, uint8(id))
+		buf = binary.BigEndian.AppendUint16(buf, rgb[0])
+		buf = binary.BigEndian.AppendUint16(buf, rgb[1])
+		buf = binary.BigEndian.AppendUint16(buf, rgb[2])
+	}
+	return buf
+}
+
+// hueStreamRGB converts a device state to 16-bit RGB with brightness baked
+// in. Kelvin-only states are streamed as neutral white.
+func hueStreamRGB(st DeviceState) [3]uint16 {
+	if !st.On {
+		return [3]uint16{}
+	}
+	h, s, b := 0.0, 0.0, st.Brightness
+	if st.Color != nil {
+		h, s, b = st.Color.H, st.Color.S, st.Color.B*st.Brightness
+	}
+	r, g, bl := HSBToRGB(h, s, b)
+	return [3]uint16{uint16(r) * 257, uint16(g) * 257, uint16(bl) * 257}
+}
+
+// hueStreamGroup fans a Stream out over several bridges.
+type hueStreamGroup []*hueStream
+
+func (g hueStreamGroup) Devices() []string {
+	var out []string
+	for _, s := range g {
+		out = append(out, s.Devices()...)
+	}
+	return out
+}
+
+func (g hueStreamGroup) Send(states map[string]DeviceState) error {
+	for _, s := range g {
+		_ = s.Send(states)
+	}
+	return nil
+}
+
+func (g hueStreamGroup) Close() error {
+	var errs []error
+	for _, s := range g {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// StartStream opens an Entertainment API stream on every bridge hosting one
+// of deviceIDs. Bridges paired before LightSync stored the client key can't
+// stream and are skipped.
+func (c *HueController) StartStream(ctx context.Context, deviceIDs []string) (Stream, error) {
+	perBridge := make(map[*hueConnection]map[string]string)
+	for _, id := range deviceIDs {
+		conn, info, ok := c.findDevice(id)
+		if !ok {
+			continue
+		}
+		if perBridge[conn] == nil {
+			perBridge[conn] = make(map[string]string)
+		}
+		perBridge[conn][id] = info.lightID
+	}
+
+	var (
+		group hueStreamGroup
+		errs  []error
+	)
+	for conn, devices := range perBridge {
+		if conn.bridge.ClientKey == "" {
+			errs = append(errs, fmt.Errorf("bridge %s has no client key; re-pair it to enable streaming", conn.bridge.IP))
+			continue
+		}
+		api := &hueEntertainmentAPI{
+			baseURL:  conn.baseURL,
+			username: conn.bridge.Username,
+			client:   NewHueHTTPClient(5 * time.Second),
+		}
+		s, err := openHueStream(ctx, api, conn.bridge.ClientKey, conn.streamAddr, devices)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bridge %s: %w", conn.bridge.IP, err))
+			continue
+		}
+		log.Printf("[hue] Entertainment stream open on %s (%s)", conn.bridge.IP, strings.Join(s.Devices(), ", "))
+		group = append(group, s)
+	}
+
+	if len(group) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no streamable Hue lights")
+		}
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		log.Printf("[hue] %v", err)
+	}
+	if len(group) == 1 {
+		return group[0], nil
+	}
+	return group, nil
+}
@@ -0,0 +1,323 @@
+package lights
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v3"
+)
+
+const (
+	testHueUsername  = "test-app-key"
+	testHueClientKey = "00112233445566778899aabbccddeeff"
+)
+
+// fakeHueBridge serves the CLIP v2 entertainment endpoints for two lights.
+type fakeHueBridge struct {
+	mu        sync.Mutex
+	configs   map[string]map[string]interface{}
+	actions   []string
+	createdID string
+}
+
+func newFakeHueBridge() *fakeHueBridge {
+	return &fakeHueBridge{configs: make(map[string]map[string]interface{})}
+}
+
+func (b *fakeHueBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("hue-application-key") != testHueUsername {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/clip/v2/resource/")
+	reply := func(v interface{}) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []interface{}{}, "data": v})
+	}
+
+	switch {
+	case path == "entertainment" && r.Method == http.MethodGet:
+		reply([]map[string]interface{}{
+			{"id": "ent-1", "renderer": true, "owner": map[string]string{"rid": "dev-1"},
+				"renderer_reference": map[string]string{"rid": "light-1", "rtype": "light"}},
+			// Older firmware: no renderer_reference, matched via the owner device.
+			{"id": "ent-2", "renderer": true, "owner": map[string]string{"rid": "dev-2"}},
+			{"id": "ent-3", "renderer": false, "owner": map[string]string{"rid": "dev-3"}},
+		})
+	case path == "light" && r.Method == http.MethodGet:
+		reply([]map[string]interface{}{
+			{"id": "light-1", "owner": map[string]string{"rid": "dev-1"}},
+			{"id": "light-2", "owner": map[string]string{"rid": "dev-2"}},
+		})
+	case path == "entertainment_configuration" && r.Method == http.MethodGet:
+		var out []map[string]interface{}
+		for _, cfg := range b.configs {
+			out = append(out, cfg)
+		}
+		reply(out)
+	case path == "entertainment_configuration" && r.Method == http.MethodPost:
+		var body struct {
+			Metadata  map[string]string `json:"metadata"`
+			Locations struct {
+				ServiceLocations []struct {
+					Service hueResourceRef `json:"service"`
+				} `json:"service_locations"`
+			} `json:"locations"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		id := "00000000-0000-0000-0000-000000000001"
+		var channels []map[string]interface{}
+		for i, loc := range body.Locations.ServiceLocations {
+			channels = append(channels, map[string]interface{}{
+				"channel_id": i,
+				"members":    []map[string]interface{}{{"service": loc.Service, "index": 0}},
+			})
+		}
+		b.configs[id] = map[string]interface{}{
+			"id":       id,
+			"metadata": body.Metadata,
+			"status":   "inactive",
+			"channels": channels,
+		}
+		b.createdID = id
+		reply([]hueResourceRef{{Rid: id, Rtype: "entertainment_configuration"}})
+	case strings.HasPrefix(path, "entertainment_configuration/"):
+		id := strings.TrimPrefix(path, "entertainment_configuration/")
+		cfg, ok := b.configs[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			reply([]map[string]interface{}{cfg})
+			return
+		}
+		var body struct {
+			Action string `json:"action"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		b.actions = append(b.actions, body.Action)
+		if body.Action == "start" {
+			cfg["status"] = "active"
+		} else if body.Action == "stop" {
+			cfg["status"] = "inactive"
+		}
+		reply([]hueResourceRef{{Rid: id, Rtype: "entertainment_configuration"}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (b *fakeHueBridge) Actions() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.actions...)
+}
+
+// startFakeHueStreamServer accepts one DTLS-PSK session and forwards every
+// datagram it receives on the returned channel.
+func startFakeHueStreamServer(t *testing.T) (string, <-chan []byte) {
+	t.Helper()
+	psk, _ := hex.DecodeString(testHueClientKey)
+	ln, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &dtls.Config{
+		PSK: func(identity []byte) ([]byte, error) {
+			if string(identity) != testHueUsername {
+				return nil, errors.New("unknown identity")
+			}
+			return psk, nil
+		},
+		CipherSuites: []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		t.Fatalf("dtls listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	packets := make(chan []byte, 256)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			select {
+			case packets <- append([]byte(nil), buf[:n]...):
+			default:
+			}
+		}
+	}()
+	return ln.Addr().String(), packets
+}
+
+func newTestHueController(t *testing.T, apiURL, streamAddr string) *HueController {
+	t.Helper()
+	c := NewHueController()
+	bridge := HueBridge{IP: "bridge", Username: testHueUsername, ClientKey: testHueClientKey}
+	if err := c.addBridge(bridge, apiURL, streamAddr); err != nil {
+		t.Fatalf("addBridge: %v", err)
+	}
+	c.bridges["bridge"].devices["hue:light-1"] = hueDeviceInfo{lightID: "light-1"}
+	c.bridges["bridge"].devices["hue:light-2"] = hueDeviceInfo{lightID: "light-2"}
+	return c
+}
+
+func TestHueStream_CreatesAreaAndStreamsFrames(t *testing.T) {
+	fake := newFakeHueBridge()
+	api := httptest.NewTLSServer(fake)
+	defer api.Close()
+	streamAddr, packets := startFakeHueStreamServer(t)
+
+	c := newTestHueController(t, api.URL, streamAddr)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := c.StartStream(ctx, []string{"hue:light-1", "hue:light-2"})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	if got := stream.Devices(); len(got) != 2 {
+		t.Fatalf("expected 2 streamed devices, got %v", got)
+	}
+	if fake.createdID == "" {
+		t.Fatalf("expected a LightSync entertainment area to be created")
+	}
+
+	_ = stream.Send(map[string]DeviceState{
+		"hue:light-1": {On: true, Brightness: 1, Color: &Color{H: 0, S: 1, B: 1}},
+		"hue:light-2": {On: false},
+	})
+
+	var packet []byte
+	deadline := time.After(3 * time.Second)
+	for packet == nil {
+		select {
+		case p := <-packets:
+			if len(p) == 52+2*7 {
+				packet = p
+			}
+		case <-deadline:
+			t.Fatalf("no frame received over DTLS")
+		}
+	}
+
+	if string(packet[:9]) != "HueStream" || packet[9] != 0x02 {
+		t.Fatalf("bad header: %q", packet[:11])
+	}
+	if got := string(packet[16:52]); got != fake.createdID {
+		t.Fatalf("expected area ID %s, got %s", fake.createdID, got)
+	}
+	channels := make(map[uint8][3]uint16)
+	for off := 52; off < len(packet); off += 7 {
+		channels[packet[off]] = [3]uint16{
+			binary.BigEndian.Uint16(packet[off+1:]),
+			binary.BigEndian.Uint16(packet[off+3:]),
+			binary.BigEndian.Uint16(packet[off+5:]),
+		}
+	}
+	red, off := [3]uint16{0xffff, 0, 0}, [3]uint16{}
+	if channels[0] != red && channels[1] != red {
+		t.Fatalf("expected one channel full red, got %v", channels)
+	}
+	if channels[0] != off && channels[1] != off {
+		t.Fatalf("expected one channel off, got %v", channels)
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := fmt.Sprint(fake.Actions()); got != "[start stop]" {
+		t.Fatalf("expected start then stop, got %s", got)
+	}
+}
+
+func TestHueStream_TwoBridgesThroughStreamSet(t *testing.T) {
+	api1 := httptest.NewTLSServer(newFakeHueBridge())
+	defer api1.Close()
+	api2 := httptest.NewTLSServer(newFakeHueBridge())
+	defer api2.Close()
+	streamAddr1, packets1 := startFakeHueStreamServer(t)
+	streamAddr2, packets2 := startFakeHueStreamServer(t)
+
+	c := newTestHueController(t, api1.URL, streamAddr1)
+	second := HueBridge{IP: "bridge2", Username: testHueUsername, ClientKey: testHueClientKey}
+	if err := c.addBridge(second, api2.URL, streamAddr2); err != nil {
+		t.Fatalf("addBridge: %v", err)
+	}
+	c.bridges["bridge2"].devices["hue:light-3"] = hueDeviceInfo{lightID: "light-1"}
+
+	m := NewManager()
+	m.RegisterController(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	set := m.StartStreams(ctx, []string{"hue:light-1", "hue:light-3"})
+	defer set.Close()
+	if !set.Covers("hue:light-1") || !set.Covers("hue:light-3") {
+		t.Fatalf("expected both bridges' lights to be streamed")
+	}
+
+	red := DeviceState{On: true, Brightness: 1, Color: &Color{H: 0, S: 1, B: 1}}
+	set.Send(map[string]DeviceState{"hue:light-1": red, "hue:light-3": red})
+
+	for i, packets := range []<-chan []byte{packets1, packets2} {
+		select {
+		case <-packets:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("bridge %d received no frame", i+1)
+		}
+	}
+	if st, ok := m.CachedState("hue:light-3"); !ok || st.Color == nil {
+		t.Fatalf("expected the sent frame recorded, got %+v", st)
+	}
+}
+
+func TestHueStream_RequiresClientKey(t *testing.T) {
+	c := NewHueController()
+	if err := c.AddBridge(HueBridge{IP: "192.0.2.1", Username: testHueUsername}); err != nil {
+		t.Fatalf("AddBridge: %v", err)
+	}
+	c.bridges["192.0.2.1"].devices["hue:light-1"] = hueDeviceInfo{lightID: "light-1"}
+
+	if _, err := c.StartStream(context.Background(), []string{"hue:light-1"}); err == nil {
+		t.Fatalf("expected an error for a bridge without a client key")
+	}
+}
+
+func TestEncodeHueStreamFrame_Layout(t *testing.T) {
+	id := "11111111-2222-3333-4444-555555555555"
+	frame := map[uint8][3]uint16{
+		3: {1, 2, 3},
+		1: {0xffff, 0x8000, 0},
+	}
+	p := encodeHueStreamFrame(id, 7, frame)
+	if len(p) != 52+14 {
+		t.Fatalf("expected 66 bytes, got %d", len(p))
+	}
+	if p[11] != 7 || p[14] != 0x00 {
+		t.Fatalf("expected seq 7 and RGB colour space, got seq=%d space=%d", p[11], p[14])
+	}
+	if p[52] != 1 || p[59] != 3 {
+		t.Fatalf("expected channels sorted 1,3, got %d,%d", p[52], p[59])
+	}
+	if binary.BigEndian.Uint16(p[55:]) != 0x8000 {
+		t.Fatalf("expected 16-bit big-endian green, got %x", p[55:57])
+	}
+}
@@ -0,0 +1,231 @@
+package lights
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openhue/openhue-go"
+)
+
+// The CLIP v2 event stream pushes every change made on a bridge as
+// server-sent events, whichever app, switch or sensor made it. One stream
+// is held open per bridge and reconnected with exponential backoff.
+const (
+	hueEventMinBackoff = time.Second
+	hueEventMaxBackoff = 30 * time.Second
+)
+
+// hueEvent is one entry of an event stream message. Data holds the changed
+// resources; updates carry only the fields that changed.
+type hueEvent struct {
+	Type string            `json:"type"` // add, update, delete or error
+	Data []json.RawMessage `json:"data"`
+}
+
+type hueEventResource struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// SetEventHandler implements EventSource and starts the event stream of
+// every registered bridge.
+func (c *HueController) SetEventHandler(fn func(DeviceEvent)) {
+	c.mu.Lock()
+	c.onEvent = fn
+	conns := make([]*hueConnection, 0, len(c.bridges))
+	for _, conn := range c.bridges {
+		conns = append(conns, conn)
+	}
+	c.mu.Unlock()
+
+	for _, conn := range conns {
+		c.startEvents(conn)
+	}
+}
+
+// startEvents opens conn's event stream unless no handler is installed yet
+// or it is already running.
+func (c *HueController) startEvents(conn *hueConnection) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	if c.onEvent == nil || conn.stopEvents != nil {
+		c.mu.Unlock()
+		cancel()
+		return
+	}
+	conn.stopEvents = cancel
+	c.mu.Unlock()
+	go c.runEvents(ctx, conn)
+}
+
+func (c *HueController) runEvents(ctx context.Context, conn *hueConnection) {
+	client := NewHueHTTPClient(0)
+	backoff := hueEventMinBackoff
+	for {
+		connected, err := c.readEvents(ctx, client, conn)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = hueEventMinBackoff
+		}
+		log.Printf("[hue] Event stream from %s ended (%v), reconnecting in %v", conn.bridge.IP, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, hueEventMaxBackoff)
+	}
+}
+
+// readEvents holds one event stream connection open until it fails.
+// connected reports whether the bridge accepted the connection.
+func (c *HueController) readEvents(ctx context.Context, client *http.Client, conn *hueConnection) (connected bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, conn.baseURL+"/eventstream/clip/v2", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("hue-application-key", conn.bridge.Username)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("bridge returned HTTP %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				c.handleEvents(ctx, conn, []byte(data.String()))
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, err
+	}
+	return true, io.EOF
+}
+
+func (c *HueController) handleEvents(ctx context.Context, conn *hueConnection, payload []byte) {
+	var events []hueEvent
+	if err := json.Unmarshal(payload, &events); err != nil {
+		log.Printf("[hue] Unreadable event from %s: %v", conn.bridge.IP, err)
+		return
+	}
+	for _, ev := range events {
+		for _, raw := range ev.Data {
+			var res hueEventResource
+			if err := json.Unmarshal(raw, &res); err != nil || res.Type != "light" || res.ID == "" {
+				continue
+			}
+			deviceID := fmt.Sprintf("hue:%s", res.ID)
+			switch ev.Type {
+			case "update":
+				c.lightUpdated(ctx, conn, deviceID, raw)
+			case "add":
+				c.lightAdded(ctx, conn, res.ID)
+			case "delete":
+				c.lightRemoved(conn, deviceID)
+			}
+		}
+	}
+}
+
+func (c *HueController) lightUpdated(ctx context.Context, conn *hueConnection, deviceID string, raw json.RawMessage) {
+	var l openhue.LightGet
+	if err := json.Unmarshal(raw, &l); err != nil {
+		return
+	}
+	c.mu.RLock()
+	info, known := conn.devices[deviceID]
+	base, cached := c.states[deviceID]
+	c.mu.RUnlock()
+	if !known {
+		return
+	}
+
+	var state DeviceState
+	if cached {
+		state = mergeHueLight(base, l, info.gamut)
+		c.rememberState(deviceID, state)
+	} else {
+		// Nothing to merge the partial update onto; read the whole light.
+		reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		var err error
+		state, err = c.GetState(reqCtx, deviceID)
+		cancel()
+		if err != nil {
+			log.Printf("[hue] Reading %s after an update event failed: %v", deviceID, err)
+			return
+		}
+	}
+	c.emit(DeviceEvent{Type: DeviceEventState, DeviceID: deviceID, State: &state})
+}
+
+func (c *HueController) lightAdded(ctx context.Context, conn *hueConnection, lightID string) {
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := conn.client.GetLightWithResponse(reqCtx, lightID)
+	if err != nil || resp.JSON200 == nil || resp.JSON200.Data == nil || len(*resp.JSON200.Data) == 0 {
+		log.Printf("[hue] Reading new light %s failed: %v", lightID, err)
+		return
+	}
+	l := (*resp.JSON200.Data)[0]
+	l.Id = &lightID
+	d := c.registerLight(conn, l, c.fetchDeviceMeta(reqCtx, conn))
+	log.Printf("[hue] Light %s added on bridge %s", d.ID, conn.bridge.IP)
+	c.emit(DeviceEvent{Type: DeviceEventAdded, DeviceID: d.ID, Device: &d})
+}
+
+func (c *HueController) lightRemoved(conn *hueConnection, deviceID string) {
+	c.mu.Lock()
+	_, known := conn.devices[deviceID]
+	delete(conn.devices, deviceID)
+	delete(c.states, deviceID)
+	c.mu.Unlock()
+	if known {
+		log.Printf("[hue] Light %s removed from bridge %s", deviceID, conn.bridge.IP)
+		c.emit(DeviceEvent{Type: DeviceEventRemoved, DeviceID: deviceID})
+	}
+}
+
+func (c *HueController) emit(ev DeviceEvent) {
+	c.mu.RLock()
+	fn := c.onEvent
+	c.mu.RUnlock()
+	if fn != nil {
+		fn(ev)
+	}
+}
+
+// rememberState records the light's last known state, the base that
+// partial updates are merged onto.
+func (c *HueController) rememberState(deviceID string, state DeviceState) {
+	state = state.clone()
+	state.TransitionMs = nil
+	c.mu.Lock()
+	c.states[deviceID] = state
+	c.mu.Unlock()
+}
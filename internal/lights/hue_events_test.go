@@ -0,0 +1,196 @@
+package lights
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hueEventBridge serves a bridge's event stream, pushing whatever is sent on
+// events, plus GET for the lights it knows about. An empty message ends the
+// current stream.
+type hueEventBridge struct {
+	events    chan string
+	connected chan struct{}
+
+	mu     sync.Mutex
+	lights map[string]map[string]interface{}
+}
+
+func newHueEventBridge() *hueEventBridge {
+	return &hueEventBridge{
+		events:    make(chan string),
+		connected: make(chan struct{}, 4),
+		lights:    make(map[string]map[string]interface{}),
+	}
+}
+
+func (b *hueEventBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/eventstream/clip/v2" {
+		if r.Header.Get("hue-application-key") != testHueUsername {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		b.connected <- struct{}{}
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case msg := <-b.events:
+				if msg == "" {
+					return
+				}
+				fmt.Fprintf(w, "id: 1:0\ndata: %s\n\n", msg)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/clip/v2/resource/light/")
+	b.mu.Lock()
+	light, ok := b.lights[id]
+	b.mu.Unlock()
+	if r.Method != http.MethodGet || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []interface{}{}, "data": []interface{}{light}})
+}
+
+func (b *hueEventBridge) push(t *testing.T, eventType string, data string) {
+	t.Helper()
+	select {
+	case b.events <- fmt.Sprintf(`[{"creationtime":"2024-01-01T00:00:00Z","id":"e1","type":%q,"data":[%s]}]`, eventType, data):
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event stream not connected")
+	}
+}
+
+func (b *hueEventBridge) waitConnected(t *testing.T) {
+	t.Helper()
+	select {
+	case <-b.connected:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("controller never opened the event stream")
+	}
+}
+
+func waitDeviceEvent(t *testing.T, events <-chan DeviceEvent, want DeviceEventType) DeviceEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		if ev.Type != want {
+			t.Fatalf("expected a %s event, got %+v", want, ev)
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s event", want)
+	}
+	return DeviceEvent{}
+}
+
+func findDevice(devices []Device, id string) (Device, bool) {
+	for _, d := range devices {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+func TestHueEvents_UpdatesManager(t *testing.T) {
+	fake := newHueEventBridge()
+	fake.lights["light-1"] = map[string]interface{}{
+		"id":      "light-1",
+		"on":      map[string]bool{"on": true},
+		"dimming": map[string]float64{"brightness": 60},
+		"color":   map[string]interface{}{"xy": map[string]float64{"x": 0.1532, "y": 0.0475}},
+	}
+	fake.lights["light-3"] = map[string]interface{}{
+		"id":       "light-3",
+		"metadata": map[string]string{"name": "Porch"},
+		"on":       map[string]bool{"on": false},
+		"dimming":  map[string]float64{"brightness": 100},
+	}
+	api := httptest.NewTLSServer(fake)
+	defer api.Close()
+
+	c := newTestHueController(t, api.URL, "")
+	defer c.Close()
+	m := NewManager()
+	m.devices["hue:light-1"] = Device{ID: "hue:light-1", Brand: BrandHue, Name: "Desk"}
+	events := make(chan DeviceEvent, 8)
+	m.OnDeviceEvent(func(ev DeviceEvent) { events <- ev })
+	m.RegisterController(c)
+	fake.waitConnected(t)
+
+	// With nothing cached yet, the partial update is read back in full.
+	fake.push(t, "update", `{"id":"light-1","type":"light","dimming":{"brightness":60}}`)
+	ev := waitDeviceEvent(t, events, DeviceEventState)
+	if ev.DeviceID != "hue:light-1" || ev.State == nil || ev.State.Color == nil || ev.State.Brightness != 0.6 {
+		t.Fatalf("unexpected state event %+v", ev)
+	}
+
+	// Later updates are merged onto the cached state.
+	fake.push(t, "update", `{"id":"light-1","type":"light","dimming":{"brightness":25}}`)
+	waitDeviceEvent(t, events, DeviceEventState)
+	st, ok := m.CachedState("hue:light-1")
+	if !ok || !st.On || st.Brightness != 0.25 || st.Color == nil {
+		t.Fatalf("expected a dimmed colour state, got %+v", st)
+	}
+	fake.push(t, "update", `{"id":"light-1","type":"light","color_temperature":{"mirek":250,"mirek_valid":true}}`)
+	waitDeviceEvent(t, events, DeviceEventState)
+	st, _ = m.CachedState("hue:light-1")
+	if st.Color != nil || st.Kelvin == nil || *st.Kelvin != 4000 || st.Brightness != 0.25 {
+		t.Fatalf("expected a 4000K state, got %+v", st)
+	}
+
+	// Lights added and removed on the bridge show up in the device list.
+	fake.push(t, "add", `{"id":"light-3","type":"light"}`)
+	ev = waitDeviceEvent(t, events, DeviceEventAdded)
+	if ev.Device == nil || ev.Device.Name != "Porch" {
+		t.Fatalf("unexpected add event %+v", ev)
+	}
+	if _, ok := findDevice(m.GetDevices(), "hue:light-3"); !ok {
+		t.Fatalf("expected the new light in the device list")
+	}
+	fake.push(t, "delete", `{"id":"light-3","type":"light"}`)
+	waitDeviceEvent(t, events, DeviceEventRemoved)
+	if _, ok := findDevice(m.GetDevices(), "hue:light-3"); ok {
+		t.Fatalf("expected the removed light to leave the device list")
+	}
+	if d, _ := findDevice(m.GetDevices(), "hue:light-1"); d.Name != "Desk" {
+		t.Fatalf("other devices must be untouched, got %+v", d)
+	}
+}
+
+func TestHueEvents_Reconnects(t *testing.T) {
+	fake := newHueEventBridge()
+	api := httptest.NewTLSServer(fake)
+	defer api.Close()
+
+	c := newTestHueController(t, api.URL, "")
+	defer c.Close()
+	k := 2700
+	c.rememberState("hue:light-1", DeviceState{On: true, Brightness: 1, Kelvin: &k})
+	events := make(chan DeviceEvent, 8)
+	c.SetEventHandler(func(ev DeviceEvent) { events <- ev })
+	fake.waitConnected(t)
+
+	fake.events <- ""
+	fake.waitConnected(t)
+	fake.push(t, "update", `{"id":"light-1","type":"light","on":{"on":false}}`)
+	ev := waitDeviceEvent(t, events, DeviceEventState)
+	if ev.State.On || ev.State.Kelvin == nil || *ev.State.Kelvin != 2700 {
+		t.Fatalf("unexpected state after reconnecting %+v", ev.State)
+	}
+}
@@ -0,0 +1,76 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// identifyFlashes is how many times a light without native identify is
+	// flashed, and identifyFlashPeriod how long it stays on and off each time.
+	identifyFlashes     = 3
+	identifyFlashPeriod = 400 * time.Millisecond
+)
+
+// Identify makes deviceID signal itself so it can be told apart from lights
+// with similar names. Controllers implementing Identifier use the device's
+// own sequence; other lights are flashed and then put back in the state
+// they were in.
+func (m *Manager) Identify(ctx context.Context, deviceID string) error {
+	ctrl, err := m.controllerFor(deviceID)
+	if err != nil {
+		return err
+	}
+	log.Printf("[manager] Identify %s", deviceID)
+	m.cancelFade(deviceID)
+	if id, ok := ctrl.(Identifier); ok {
+		err = m.send(ctx, ctrl, deviceID, func(ctx context.Context) error {
+			return id.Identify(ctx, deviceID)
+		})
+	} else {
+		err = m.flash(ctx, ctrl, deviceID)
+	}
+	if errors.Is(err, errSuperseded) {
+		return nil
+	}
+	if err != nil {
+		m.checkSoon(deviceID)
+	}
+	return err
+}
+
+// flash alternates deviceID between full brightness and off, then restores
+// its previous state. The state cache is left alone, so subscribers don't see
+// the sequence. A newer command for the device ends the sequence early and
+// is left in place.
+func (m *Manager) flash(ctx context.Context, ctrl Controller, deviceID string) error {
+	prev, ok := m.CachedState(deviceID)
+	if !ok {
+		var err error
+		if prev, err = m.GetDeviceState(ctx, deviceID); err != nil {
+			return fmt.Errorf("read state to restore: %w", err)
+		}
+	}
+
+	caps := ctrl.Capabilities(deviceID)
+	bright := caps.Clamp(caps.Translate(DeviceState{On: true, Brightness: 1}.WithTransition(0)))
+	dark := DeviceState{On: false}.WithTransition(0)
+	for i := 0; i < identifyFlashes; i++ {
+		for _, state := range []DeviceState{bright, dark} {
+			if err := m.setState(ctx, ctrl, deviceID, state); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(m.identifyPeriod):
+			}
+		}
+	}
+
+	prev = caps.Clamp(caps.Translate(prev.clone()))
+	return m.setState(ctx, ctrl, deviceID, caps.Clamp(m.calibrate(deviceID, prev.WithTransition(0))))
+}
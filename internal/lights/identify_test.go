@@ -0,0 +1,66 @@
+package lights
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdentify_FlashRestoresState(t *testing.T) {
+	m, _ := newTestVirtualManager(VirtualDevice{ID: "a", Capabilities: Capabilities{Color: true, Segments: 1}})
+	m.identifyPeriod = time.Millisecond
+	ctx := context.Background()
+
+	blue := DeviceState{On: true, Brightness: 0.3, Color: &Color{H: 240, S: 1, B: 1}}.WithTransition(0)
+	if err := m.SetDeviceState(ctx, "virtual:a", blue); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	var changes atomic.Int32
+	defer m.Subscribe(func(DeviceStateChange) { changes.Add(1) })()
+
+	if err := m.Identify(ctx, "virtual:a"); err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	hist := m.CommandHistory("virtual:a")
+	if len(hist) != 2+2*identifyFlashes {
+		t.Fatalf("expected %d flashes and a restore, got %d commands", identifyFlashes, len(hist)-1)
+	}
+	if !hist[1].State.On || hist[1].State.Brightness != 1 || hist[2].State.On {
+		t.Fatalf("expected the light to flash on and off, got %+v then %+v", hist[1].State, hist[2].State)
+	}
+	if st, _ := m.GetDeviceState(ctx, "virtual:a"); !st.On || st.Brightness != 0.3 || st.Color == nil || st.Color.H != 240 {
+		t.Fatalf("expected the previous state restored, got %+v", st)
+	}
+	if n := changes.Load(); n != 0 {
+		t.Fatalf("expected the flashes to stay out of the state cache, got %d changes", n)
+	}
+}
+
+// identifyingVirtual is a virtual controller with a native identify.
+type identifyingVirtual struct {
+	*VirtualController
+	identified atomic.Int32
+}
+
+func (c *identifyingVirtual) Identify(context.Context, string) error {
+	c.identified.Add(1)
+	return nil
+}
+
+func TestIdentify_Native(t *testing.T) {
+	c := &identifyingVirtual{VirtualController: NewVirtualController()}
+	c.SetDevices([]VirtualDevice{{ID: "a"}})
+	m := NewManager()
+	m.RegisterController(c)
+
+	if err := m.Identify(context.Background(), "virtual:a"); err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	if c.identified.Load() != 1 || len(m.CommandHistory("virtual:a")) != 0 {
+		t.Fatalf("expected the native identify and no flashes")
+	}
+	if err := m.Identify(context.Background(), "lifx:missing"); err == nil {
+		t.Fatalf("expected an error for a brand without a controller")
+	}
+}
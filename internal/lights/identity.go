@@ -0,0 +1,73 @@
+package lights
+
+import (
+	"log"
+	"net/url"
+	"strings"
+)
+
+// hardwareDeviceID builds a device ID from a hardware identifier such as a
+// MAC address or serial number, so the ID survives DHCP lease changes.
+// Separators are dropped and letters lowercased; fallback is used when the
+// device didn't report one.
+func hardwareDeviceID(brand Brand, hw, fallback string) string {
+	hw = strings.ToLower(strings.NewReplacer(":", "", "-", "", " ", "").Replace(hw))
+	if hw == "" {
+		return string(brand) + ":" + fallback
+	}
+	return string(brand) + ":" + hw
+}
+
+// hostOf returns the host part of a LastIP, which may be a bare IP or a URL.
+func hostOf(addr string) string {
+	if u, err := url.Parse(addr); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	return addr
+}
+
+// migrateLegacyIDs moves devices known under an IP-based ID ("govee:<ip>",
+// "elgato:<ip>") to the hardware ID discovery found at the same address,
+// keeping their settings, cached state, health and group memberships. m.mu
+// must be held. The returned map is old ID to new ID.
+func (m *Manager) migrateLegacyIDs(discovered []Device) map[string]string {
+	renamed := make(map[string]string)
+	for _, d := range discovered {
+		if _, ok := m.devices[d.ID]; ok || d.LastIP == "" {
+			continue
+		}
+		legacyID := string(d.Brand) + ":" + hostOf(d.LastIP)
+		old, ok := m.devices[legacyID]
+		if !ok || legacyID == d.ID {
+			continue
+		}
+		log.Printf("[manager] %s is now %s", legacyID, d.ID)
+		d.keepSettings(old)
+		m.devices[d.ID] = d
+		delete(m.devices, legacyID)
+		m.cancelFade(legacyID)
+		m.renameState(legacyID, d.ID)
+		m.renameHealth(legacyID, d.ID)
+		m.renameGroupMembers(legacyID, d.ID)
+		renamed[legacyID] = d.ID
+	}
+	return renamed
+}
+
+func (m *Manager) renameState(oldID, newID string) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if cs, ok := m.states[oldID]; ok {
+		m.states[newID] = cs
+		delete(m.states, oldID)
+	}
+}
+
+func (m *Manager) renameHealth(oldID, newID string) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	if h, ok := m.health[oldID]; ok {
+		m.health[newID] = h
+		delete(m.health, oldID)
+	}
+}
@@ -0,0 +1,74 @@
+package lights
+
+import (
+	"context"
+	"testing"
+)
+
+// rediscovered is a Govee controller stand-in whose scan finds a fixed set
+// of devices.
+type rediscovered struct {
+	*VirtualController
+	found []Device
+}
+
+func (c *rediscovered) Brand() Brand { return BrandGovee }
+
+func (c *rediscovered) Discover(context.Context) ([]Device, error) {
+	return c.found, nil
+}
+
+func TestHardwareDeviceID(t *testing.T) {
+	if got := hardwareDeviceID(BrandGovee, "AA:BB:CC:DD:EE:FF:00:11", "10.0.0.5"); got != "govee:aabbccddeeff0011" {
+		t.Fatalf("unexpected MAC-based ID %q", got)
+	}
+	if got := hardwareDeviceID(BrandElgato, "", "10.0.0.5"); got != "elgato:10.0.0.5" {
+		t.Fatalf("expected the address fallback, got %q", got)
+	}
+	if got := hostOf("http://10.0.0.5:9123"); got != "10.0.0.5" {
+		t.Fatalf("unexpected host %q", got)
+	}
+}
+
+func TestDiscover_MigratesLegacyIDs(t *testing.T) {
+	const newID = "govee:aabbccddeeff0011"
+	c := &rediscovered{VirtualController: NewVirtualController()}
+	c.found = []Device{{ID: newID, Brand: BrandGovee, LastIP: "10.0.0.5"}}
+	m := NewManager()
+	m.RegisterController(c)
+	m.SetDevices([]Device{{ID: "govee:10.0.0.5", Brand: BrandGovee, LastIP: "10.0.0.5", Room: "Office"}})
+	m.recordState("govee:10.0.0.5", DeviceState{On: true, Brightness: 0.5}, SourceUser)
+	events := make(chan DeviceEvent, 4)
+	m.OnDeviceEvent(func(ev DeviceEvent) { events <- ev })
+	ctx := context.Background()
+
+	if _, err := m.DiscoverAllWithProgress(ctx, nil); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	ev := waitDeviceEvent(t, events, DeviceEventRenamed)
+	if ev.DeviceID != newID || ev.PreviousID != "govee:10.0.0.5" {
+		t.Fatalf("unexpected rename event %+v", ev)
+	}
+	devices := m.GetDevices()
+	if len(devices) != 1 || devices[0].ID != newID || devices[0].Room != "Office" {
+		t.Fatalf("expected the device under its hardware ID with its room, got %+v", devices)
+	}
+	if st, ok := m.CachedState(newID); !ok || st.Brightness != 0.5 {
+		t.Fatalf("expected the cached state to follow the device, got %+v", st)
+	}
+
+	// Later at a new address the ID holds and only LastIP changes.
+	c.found = []Device{{ID: newID, Brand: BrandGovee, LastIP: "10.0.0.9"}}
+	if _, err := m.DiscoverAllWithProgress(ctx, nil); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	devices = m.GetDevices()
+	if len(devices) != 1 || devices[0].LastIP != "10.0.0.9" || devices[0].Room != "Office" {
+		t.Fatalf("expected one device at the new address, got %+v", devices)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %+v", ev)
+	default:
+	}
+}
@@ -1,11 +1,15 @@
 package lights
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -17,13 +21,58 @@ type LIFXController struct {
 	mu      sync.RWMutex
 	devices map[string]lifxlan.Device
 	lights  map[string]light.Device
+	caps    map[string]Capabilities
+	zones   map[string]lifxZones
+
+	// pool holds one open connection per bulb, reused for every command.
+	pool map[string]*lifxConn
+	// poweredOn records when each bulb was last switched or seen on.
+	poweredOn map[string]time.Time
+	// rediscovering marks devices with a background re-discovery running.
+	rediscovering map[string]bool
+	ackInterval   time.Duration
+	ackTimeout    time.Duration
+	// rediscover finds a device again after its connection went dead.
+	rediscover func(ctx context.Context, deviceID string) (light.Device, error)
 }
 
 func NewLIFXController() *LIFXController {
-	return &LIFXController{
-		devices: make(map[string]lifxlan.Device),
-		lights:  make(map[string]light.Device),
+	c := &LIFXController{
+		devices:       make(map[string]lifxlan.Device),
+		lights:        make(map[string]light.Device),
+		caps:          make(map[string]Capabilities),
+		zones:         make(map[string]lifxZones),
+		pool:          make(map[string]*lifxConn),
+		poweredOn:     make(map[string]time.Time),
+		rediscovering: make(map[string]bool),
+		ackInterval:   lifxAckInterval,
+		ackTimeout:    lifxAckTimeout,
 	}
+	c.rediscover = c.rediscoverDevice
+	return c
+}
+
+// lifxDefaultCapabilities covers a colour bulb; discovery narrows it down
+// from the product's feature table.
+var lifxDefaultCapabilities = Capabilities{
+	Color:             true,
+	Kelvin:            true,
+	MinKelvin:         2500,
+	MaxKelvin:         9000,
+	KelvinStep:        1,
+	NativeTransitions: true,
+	Segments:          1,
+	ReadBack:          true,
+}
+
+// Capabilities returns the descriptor built during discovery.
+func (c *LIFXController) Capabilities(deviceID string) Capabilities {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if caps, ok := c.caps[deviceID]; ok {
+		return caps
+	}
+	return lifxDefaultCapabilities
 }
 
 func (c *LIFXController) Brand() Brand {
@@ -49,96 +98,234 @@ func (c *LIFXController) Discover(ctx context.Context) ([]Device, error) {
 		}
 		seen[target] = true
 
-		labelCtx, labelCancel := context.WithTimeout(ctx, 2*time.Second)
-		ld, err := light.Wrap(labelCtx, raw, false)
-		labelCancel()
+		d, err := c.register(ctx, raw)
 		if err != nil {
 			continue
 		}
+		result = append(result, d)
+	}
+
+	return result, nil
+}
+
+// ProbeAddress implements AddressProber with a GetService sent straight to
+// addr, which reaches bulbs that broadcast discovery can't, and registers
+// the bulb that answers.
+func (c *LIFXController) ProbeAddress(ctx context.Context, addr string) (Device, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	raw, err := lifxServiceAt(probeCtx, withDefaultPort(addr, lifxPort))
+	cancel()
+	if err != nil {
+		return Device{}, fmt.Errorf("%w: %v", ErrNoDeviceAtAddress, err)
+	}
+	return c.register(ctx, raw)
+}
+
+// lifxPort is the LAN protocol's UDP port.
+const lifxPort = 56700
+
+// lifxServiceAt sends a tagged GetService to addr and returns the bulb that
+// answers, addressed by the service port it reports.
+func lifxServiceAt(ctx context.Context, addr string) (lifxlan.Device, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
 
-		// Fetch hardware version and firmware (best-effort; errors are non-fatal).
-		versionCtx, versionCancel := context.WithTimeout(ctx, 2*time.Second)
-		_ = raw.GetHardwareVersion(versionCtx, nil)
-		versionCancel()
-
-		firmwareCtx, firmwareCancel := context.WithTimeout(ctx, 2*time.Second)
-		_ = raw.GetFirmware(firmwareCtx, nil)
-		firmwareCancel()
-
-		supportsColor := true
-		supportsKelvin := true
-		var minKelvin, maxKelvin int
-		var productName string
-		if product := raw.HardwareVersion().Parse(); product != nil {
-			supportsColor = product.Features.Color.Get()
-			productName = product.ProductName
-			tr := product.Features.TemperatureRange
-			if tr.Valid() {
-				minKelvin = int(tr.Min())
-				maxKelvin = int(tr.Max())
-			}
+	source := lifxlan.RandomSource()
+	msg, err := lifxlan.GenerateMessage(lifxlan.Tagged, source, lifxlan.AllDevices, 0, 0, lifxlan.GetService, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	for {
+		resp, err := lifxlan.ReadNextResponse(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Source != source || resp.Message != lifxlan.StateService {
+			continue
+		}
+		var svc lifxlan.RawStateServicePayload
+		if err := binary.Read(bytes.NewReader(resp.Payload), binary.LittleEndian, &svc); err != nil {
+			return nil, err
+		}
+		if svc.Service != lifxlan.ServiceUDP {
+			continue
 		}
+		host, _, _ := net.SplitHostPort(addr)
+		return lifxlan.NewDevice(net.JoinHostPort(host, strconv.Itoa(int(svc.Port))), svc.Service, resp.Target), nil
+	}
+}
+
+// register queries a discovered device's label, product and zone layout and
+// caches it for later commands.
+func (c *LIFXController) register(ctx context.Context, raw lifxlan.Device) (Device, error) {
+	target := raw.Target().String()
 
-		var firmwareVersion string
-		if fw := raw.Firmware(); fw.String() != lifxlan.EmptyFirmware {
-			firmwareVersion = fmt.Sprintf("%d.%d", fw.Major, fw.Minor)
+	labelCtx, labelCancel := context.WithTimeout(ctx, 2*time.Second)
+	ld, err := light.Wrap(labelCtx, raw, false)
+	labelCancel()
+	if err != nil {
+		return Device{}, err
+	}
+
+	// Fetch hardware version and firmware (best-effort; errors are non-fatal).
+	versionCtx, versionCancel := context.WithTimeout(ctx, 2*time.Second)
+	_ = raw.GetHardwareVersion(versionCtx, nil)
+	versionCancel()
+
+	firmwareCtx, firmwareCancel := context.WithTimeout(ctx, 2*time.Second)
+	_ = raw.GetFirmware(firmwareCtx, nil)
+	firmwareCancel()
+
+	deviceID := fmt.Sprintf("lifx:%s", target)
+
+	caps := lifxDefaultCapabilities
+	zones := lifxZones{count: 1}
+	var productName string
+	if product := raw.HardwareVersion().Parse(); product != nil {
+		features := product.FeaturesAt(*raw.Firmware())
+		caps.Color = features.Color.Get()
+		productName = product.ProductName
+		tr := features.TemperatureRange
+		if tr.Valid() {
+			caps.MinKelvin = int(tr.Min())
+			caps.MaxKelvin = int(tr.Max())
 		}
 
-		deviceID := fmt.Sprintf("lifx:%s", target)
-		var host string
-		if conn, dialErr := raw.Dial(); dialErr == nil {
-			host, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
-			conn.Close()
+		zonesCtx, zonesCancel := context.WithTimeout(ctx, 2*time.Second)
+		zl, err := probeLIFXZones(zonesCtx, ld, features)
+		zonesCancel()
+		if err != nil {
+			log.Printf("[lifx] Zone probe failed for %s: %v", deviceID, err)
+		} else if zl.count > 0 {
+			zones = zl
 		}
+	}
+	caps.Segments = zones.count
 
-		c.mu.Lock()
-		c.devices[deviceID] = raw
-		c.lights[deviceID] = ld
-		c.mu.Unlock()
+	var firmwareVersion string
+	if fw := raw.Firmware(); fw.String() != lifxlan.EmptyFirmware {
+		firmwareVersion = fmt.Sprintf("%d.%d", fw.Major, fw.Minor)
+	}
 
-		name := ld.Label().String()
-		if name == lifxlan.EmptyLabel {
-			name = fmt.Sprintf("LIFX %s", target)
-		}
+	var host string
+	if conn, dialErr := raw.Dial(); dialErr == nil {
+		host, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
+		conn.Close()
+	}
 
-		result = append(result, Device{
-			ID:              deviceID,
-			Brand:           BrandLIFX,
-			Name:            name,
-			Model:           productName,
-			LastIP:          host,
-			LastSeen:        time.Now(),
-			SupportsColor:   supportsColor,
-			SupportsKelvin:  supportsKelvin,
-			MinKelvin:       minKelvin,
-			MaxKelvin:       maxKelvin,
-			KelvinStep:      1,
-			FirmwareVersion: firmwareVersion,
-		})
+	c.mu.Lock()
+	c.devices[deviceID] = raw
+	c.lights[deviceID] = ld
+	c.caps[deviceID] = caps
+	c.zones[deviceID] = zones
+	// The pooled connection belongs to the previous registration, which may
+	// point at an old address; the next command dials afresh.
+	c.dropConnLocked(deviceID)
+	c.mu.Unlock()
+
+	name := ld.Label().String()
+	if name == lifxlan.EmptyLabel {
+		name = fmt.Sprintf("LIFX %s", target)
 	}
 
-	return result, nil
+	d := Device{
+		ID:              deviceID,
+		Brand:           BrandLIFX,
+		Name:            name,
+		Model:           productName,
+		LastIP:          host,
+		LastSeen:        time.Now(),
+		FirmwareVersion: firmwareVersion,
+	}
+	d.applyCapabilities(caps)
+	return d, nil
 }
 
+// lifxTransition is the fade used when a DeviceState doesn't specify one.
 const lifxTransition = 200 * time.Millisecond
 
+// SetState sends the state over the device's pooled connection without
+// waiting for a reply. The power packet is left out while the bulb is known
+// to be on.
 func (c *LIFXController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
-	ld, conn, err := c.dialWithRetry(ctx, deviceID)
+	ld, conn, err := c.conn(ctx, deviceID)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
+	sendPower := !state.On || !c.knownOn(deviceID)
+	if err := sendLIFXState(ctx, ld, conn, c.zoneLayout(deviceID), state, sendPower, conn.ackFlag(c.ackInterval)); err != nil {
+		c.connFailed(deviceID, conn)
+		return err
+	}
+	c.notePower(deviceID, state.On)
+	return nil
+}
 
-	if !state.On {
-		return ld.SetLightPower(ctx, conn, lifxlan.PowerOff, lifxTransition, false)
+// SetStates writes every device's packets over its pooled connection, back
+// to back and without waiting for acks.
+func (c *LIFXController) SetStates(ctx context.Context, states map[string]DeviceState) error {
+	var errs []error
+	for deviceID, state := range states {
+		if err := c.SetState(ctx, deviceID, state); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", deviceID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// zoneLayout returns the cached zone layout, or a single segment if unknown.
+func (c *LIFXController) zoneLayout(deviceID string) lifxZones {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if zl, ok := c.zones[deviceID]; ok {
+		return zl
 	}
+	return lifxZones{count: 1}
+}
+
+// Layout implements LayoutProvider for multizone strips and matrix tiles.
+// The zone count and tile sizes are all the device reports, so strips lie
+// left to right across the screen and tiles sit side by side.
+func (c *LIFXController) Layout(deviceID string) []Point {
+	return c.zoneLayout(deviceID).points()
+}
 
-	if err := ld.SetLightPower(ctx, conn, lifxlan.PowerOn, lifxTransition, false); err != nil {
+// sendLIFXState sends the power packet (unless sendPower is false) followed
+// by the colour packet, or the zone packets when the state carries per-zone
+// colours for a multizone or matrix device. ack is set on the last packet.
+func sendLIFXState(ctx context.Context, ld light.Device, conn net.Conn, zl lifxZones, state DeviceState, sendPower bool, ack lifxlan.AckResFlag) error {
+	transition := state.Transition(lifxTransition)
+
+	if !state.On {
+		_, err := ld.Send(ctx, conn, ack, light.SetLightPower, &light.RawSetLightPowerPayload{
+			Level:    lifxlan.PowerOff,
+			Duration: lifxlan.ConvertDuration(transition),
+		})
 		return err
 	}
 
+	if sendPower {
+		if err := ld.SetLightPower(ctx, conn, lifxlan.PowerOn, transition, false); err != nil {
+			return err
+		}
+	}
+
+	if len(state.Zones) > 0 && zl.kind != lifxZonesNone {
+		return sendLIFXZones(ctx, ld, conn, zl, state, transition, ack)
+	}
+
 	color := stateToLIFXColor(state)
-	return ld.SetColor(ctx, conn, &color, lifxTransition, false)
+	_, err := ld.Send(ctx, conn, ack, light.SetColor, &light.RawSetColorPayload{
+		Color:    ld.SanitizeColor(color),
+		Duration: lifxlan.ConvertDuration(transition),
+	})
+	return err
 }
 
 func (c *LIFXController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
@@ -163,6 +350,7 @@ func (c *LIFXController) GetState(ctx context.Context, deviceID string) (DeviceS
 		return DeviceState{}, err
 	}
 
+	c.notePower(deviceID, power.On())
 	return lifxColorToState(power, color), nil
 }
 
@@ -175,35 +363,160 @@ func (c *LIFXController) TurnOff(ctx context.Context, deviceID string) error {
 }
 
 func (c *LIFXController) setPower(ctx context.Context, deviceID string, power lifxlan.Power) error {
-	ld, conn, err := c.dialWithRetry(ctx, deviceID)
+	ld, conn, err := c.conn(ctx, deviceID)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-	return ld.SetLightPower(ctx, conn, power, lifxTransition, false)
+	_, err = ld.Send(ctx, conn, conn.ackFlag(c.ackInterval), light.SetLightPower, &light.RawSetLightPowerPayload{
+		Level:    power,
+		Duration: lifxlan.ConvertDuration(lifxTransition),
+	})
+	if err != nil {
+		c.connFailed(deviceID, conn)
+		return err
+	}
+	c.notePower(deviceID, power.On())
+	return nil
 }
 
-func (c *LIFXController) dialWithRetry(ctx context.Context, deviceID string) (light.Device, net.Conn, error) {
+const (
+	// lifxIdentifyCycles pulses of lifxIdentifyPeriod each make up Identify.
+	lifxIdentifyCycles = 3
+	lifxIdentifyPeriod = 600 * time.Millisecond
+)
+
+// Identify implements Identifier with a transient pulse waveform: the
+// brightness dips to zero and comes back, and the bulb returns to its colour
+// afterwards. A bulb that is off is switched on for the pulses.
+func (c *LIFXController) Identify(ctx context.Context, deviceID string) error {
+	state, err := c.GetState(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	ld, conn, err := c.conn(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if !state.On {
+		if err := ld.SetLightPower(ctx, conn, lifxlan.PowerOn, 0, false); err != nil {
+			c.connFailed(deviceID, conn)
+			return err
+		}
+	}
+	err = ld.SetWaveform(ctx, conn, &light.SetWaveformArgs{
+		Transient:      true,
+		Color:          &lifxlan.Color{},
+		Period:         lifxIdentifyPeriod,
+		Cycles:         lifxIdentifyCycles,
+		Waveform:       light.WaveformPulse,
+		SkewRatio:      0.5,
+		KeepHue:        true,
+		KeepSaturation: true,
+		KeepKelvin:     true,
+	}, false)
+	if err != nil {
+		c.connFailed(deviceID, conn)
+		return err
+	}
+	if state.On {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(lifxIdentifyCycles * lifxIdentifyPeriod):
+	}
+	return c.setPower(ctx, deviceID, lifxlan.PowerOff)
+}
+
+// conn returns the device's pooled connection, dialing one if needed. A
+// connection whose acks stopped arriving is replaced, and the device is
+// re-discovered in the background in case it moved to a new IP.
+func (c *LIFXController) conn(ctx context.Context, deviceID string) (light.Device, *lifxConn, error) {
 	ld, err := c.getLight(ctx, deviceID)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	conn, err := ld.Dial()
-	if err != nil {
-		log.Printf("[lifx] Dial failed for %s, re-discovering: %v", deviceID, err)
-		ld, err = c.rediscoverDevice(ctx, deviceID)
-		if err != nil {
-			return nil, nil, err
-		}
-		conn, err = ld.Dial()
-		if err != nil {
-			return nil, nil, fmt.Errorf("dial %s after re-discovery: %w", deviceID, err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok := c.pool[deviceID]; ok {
+		if conn.alive(c.ackTimeout) {
+			return ld, conn, nil
 		}
+		log.Printf("[lifx] %s stopped acknowledging, reconnecting", deviceID)
+		c.dropConnLocked(deviceID)
+		c.rediscoverInBackgroundLocked(deviceID)
 	}
+
+	raw, err := ld.Dial()
+	if err != nil {
+		c.rediscoverInBackgroundLocked(deviceID)
+		return nil, nil, fmt.Errorf("dial %s: %w", deviceID, err)
+	}
+	conn := newLIFXConn(raw, ld.Source())
+	c.pool[deviceID] = conn
 	return ld, conn, nil
 }
 
+// connFailed drops conn after a failed write, if it is still the device's
+// pooled connection, and looks for the device in case it moved.
+func (c *LIFXController) connFailed(deviceID string, conn *lifxConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pool[deviceID] == conn {
+		c.dropConnLocked(deviceID)
+		c.rediscoverInBackgroundLocked(deviceID)
+	}
+}
+
+// dropConnLocked closes and forgets the pooled connection. Callers hold c.mu.
+func (c *LIFXController) dropConnLocked(deviceID string) {
+	if conn, ok := c.pool[deviceID]; ok {
+		conn.Close()
+		delete(c.pool, deviceID)
+	}
+	delete(c.poweredOn, deviceID)
+}
+
+// rediscoverInBackgroundLocked starts a re-discovery of deviceID unless one
+// is already running. Callers hold c.mu.
+func (c *LIFXController) rediscoverInBackgroundLocked(deviceID string) {
+	if c.rediscovering[deviceID] {
+		return
+	}
+	c.rediscovering[deviceID] = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := c.rediscover(ctx, deviceID); err != nil {
+			log.Printf("[lifx] Background re-discovery of %s failed: %v", deviceID, err)
+		}
+		c.mu.Lock()
+		delete(c.rediscovering, deviceID)
+		c.mu.Unlock()
+	}()
+}
+
+// knownOn reports whether the bulb was switched or seen on recently enough
+// to skip the power packet.
+func (c *LIFXController) knownOn(deviceID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	at, ok := c.poweredOn[deviceID]
+	return ok && time.Since(at) < lifxPowerTTL
+}
+
+func (c *LIFXController) notePower(deviceID string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if on {
+		c.poweredOn[deviceID] = time.Now()
+	} else {
+		delete(c.poweredOn, deviceID)
+	}
+}
+
 // getLight retrieves a known light, or re-discovers if missing.
 func (c *LIFXController) getLight(ctx context.Context, deviceID string) (light.Device, error) {
 	c.mu.RLock()
@@ -216,6 +529,49 @@ func (c *LIFXController) getLight(ctx context.Context, deviceID string) (light.D
 	return c.rediscoverDevice(ctx, deviceID)
 }
 
+// Probe implements Prober with a unicast GetService, which every LIFX device
+// answers. A bulb that stays silent may have moved, so a failed probe also
+// starts a background re-discovery.
+func (c *LIFXController) Probe(ctx context.Context, deviceID string) error {
+	c.mu.RLock()
+	ld, ok := c.lights[deviceID]
+	c.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("device %s not discovered", deviceID)
+	}
+
+	err := lifxGetService(ctx, ld)
+	if err != nil {
+		c.mu.Lock()
+		c.rediscoverInBackgroundLocked(deviceID)
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// lifxGetService sends GetService on its own connection, so the reply can't
+// be confused with the pooled connection's acks.
+func lifxGetService(ctx context.Context, ld light.Device) error {
+	conn, err := ld.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	seq, err := ld.Send(ctx, conn, 0, lifxlan.GetService, nil)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := lifxlan.ReadNextResponse(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if resp.Sequence == seq && resp.Source == ld.Source() && resp.Message == lifxlan.StateService {
+			return nil
+		}
+	}
+}
+
 func (c *LIFXController) rediscoverDevice(ctx context.Context, deviceID string) (light.Device, error) {
 	discoverCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -233,6 +589,11 @@ func (c *LIFXController) rediscoverDevice(ctx context.Context, deviceID string)
 }
 
 func (c *LIFXController) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for deviceID := range c.pool {
+		c.dropConnLocked(deviceID)
+	}
 	return nil
 }
 
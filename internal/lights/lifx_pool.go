@@ -0,0 +1,109 @@
+package lights
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+)
+
+// Pooled connections never wait for acks. Instead, at most one packet per
+// lifxAckInterval asks for one, and a connection whose acks stop arriving is
+// treated as dead (the bulb rebooted, or moved to a new IP).
+const (
+	lifxAckInterval   = time.Second
+	lifxAckTimeout    = 2 * time.Second
+	lifxMaxMissedAcks = 2
+)
+
+// lifxPowerTTL is how long a bulb we switched on is assumed to still be on,
+// so the power packet can be left out. It's short because the bulb may be
+// switched off from the LIFX app or a wall switch.
+const lifxPowerTTL = 10 * time.Second
+
+// lifxConn is a long-lived connection to one bulb. It tracks the sequence
+// numbers of packets sent with an ack request and drains replies in the
+// background, so callers must not read from it.
+type lifxConn struct {
+	net.Conn
+	source uint32
+
+	mu        sync.Mutex
+	pending   map[uint8]time.Time
+	lastAsked time.Time
+	missed    int
+}
+
+func newLIFXConn(conn net.Conn, source uint32) *lifxConn {
+	lc := &lifxConn{
+		Conn:    conn,
+		source:  source,
+		pending: make(map[uint8]time.Time),
+	}
+	go lc.readAcks()
+	return lc
+}
+
+// Write sends one packet, remembering its sequence number when it asks for
+// an ack. The flags and sequence sit at bytes 22 and 23 of the LIFX header.
+func (lc *lifxConn) Write(b []byte) (int, error) {
+	if len(b) >= lifxlan.HeaderLength && lifxlan.AckResFlag(b[22])&lifxlan.FlagAckRequired != 0 {
+		lc.mu.Lock()
+		lc.pending[b[23]] = time.Now()
+		lc.mu.Unlock()
+	}
+	return lc.Conn.Write(b)
+}
+
+func (lc *lifxConn) readAcks() {
+	buf := make([]byte, lifxlan.ResponseReadBufferSize)
+	for {
+		n, err := lc.Conn.Read(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			// e.g. ICMP port unreachable while the bulb is rebooting.
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+		resp, err := lifxlan.ParseResponse(buf[:n])
+		if err != nil || resp.Message != lifxlan.Acknowledgement || resp.Source != lc.source {
+			continue
+		}
+		lc.mu.Lock()
+		if _, ok := lc.pending[resp.Sequence]; ok {
+			delete(lc.pending, resp.Sequence)
+			lc.missed = 0
+		}
+		lc.mu.Unlock()
+	}
+}
+
+// ackFlag returns FlagAckRequired when no ack has been asked for within
+// interval, and 0 otherwise.
+func (lc *lifxConn) ackFlag(interval time.Duration) lifxlan.AckResFlag {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if time.Since(lc.lastAsked) < interval {
+		return 0
+	}
+	lc.lastAsked = time.Now()
+	return lifxlan.FlagAckRequired
+}
+
+// alive counts acks older than timeout as missed and reports whether the bulb
+// is still answering.
+func (lc *lifxConn) alive(timeout time.Duration) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	for seq, at := range lc.pending {
+		if time.Since(at) > timeout {
+			delete(lc.pending, seq)
+			lc.missed++
+		}
+	}
+	return lc.missed < lifxMaxMissedAcks
+}
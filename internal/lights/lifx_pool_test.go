@@ -0,0 +1,179 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/light"
+	"go.yhsif.com/lifxlan/mock"
+)
+
+// startFakeLIFXBulb starts a responder for an original LIFX colour bulb.
+func startFakeLIFXBulb(t testing.TB) *fakeLIFX {
+	t.Helper()
+	return startFakeLIFX(t, 1, 2, 80, nil)
+}
+
+func (f *fakeLIFX) count(msg lifxlan.MessageType) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.received[msg])
+}
+
+func TestLIFXPool_ReusesConnectionAndSkipsPower(t *testing.T) {
+	f := startFakeLIFXBulb(t)
+	c := NewLIFXController()
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d, err := c.register(ctx, f.device)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	// Paced at 1 kHz, well above screen sync rates, so the responder's socket
+	// buffer doesn't overflow; BenchmarkLIFXSetState measures raw throughput.
+	const frames = 300
+	start := time.Now()
+	for i := 0; i < frames; i++ {
+		err := c.SetStates(ctx, map[string]DeviceState{
+			d.ID: DeviceState{On: true, Brightness: 1, Color: &Color{H: float64(i % 360), S: 1, B: 1}}.WithTransition(0),
+		})
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	f.waitFor(t, light.SetColor, frames)
+	t.Logf("%d frames delivered in %v", frames, time.Since(start))
+
+	if n := f.count(light.SetLightPower); n != 1 {
+		t.Fatalf("expected a single power packet, got %d", n)
+	}
+	c.mu.RLock()
+	conn, pooled := c.pool[d.ID]
+	c.mu.RUnlock()
+	if !pooled || len(c.pool) != 1 {
+		t.Fatalf("expected one pooled connection")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !conn.alive(c.ackTimeout) || len(conn.pending) != 0 {
+		t.Fatalf("expected the requested ack to arrive, pending %v", conn.pending)
+	}
+
+	// Switching off always sends power, and the next colour needs it again.
+	if err := c.SetState(ctx, d.ID, DeviceState{On: false}); err != nil {
+		t.Fatalf("SetState off: %v", err)
+	}
+	if err := c.SetState(ctx, d.ID, DeviceState{On: true, Brightness: 1}); err != nil {
+		t.Fatalf("SetState on: %v", err)
+	}
+	f.waitFor(t, light.SetLightPower, 3)
+}
+
+func TestLIFXPool_ReconnectsAfterIPChange(t *testing.T) {
+	old := startFakeLIFXBulb(t)
+	c := NewLIFXController()
+	defer c.Close()
+	c.ackInterval = 10 * time.Millisecond
+	c.ackTimeout = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d, err := c.register(ctx, old.device)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	state := DeviceState{On: true, Brightness: 1}
+	if err := c.SetState(ctx, d.ID, state); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	old.waitFor(t, light.SetColor, 1)
+
+	// The bulb goes away and comes back on another port, which discovery
+	// would find.
+	old.service.Stop()
+	moved := startFakeLIFXBulb(t)
+	rediscovered := make(chan struct{}, 1)
+	c.rediscover = func(ctx context.Context, deviceID string) (light.Device, error) {
+		if _, err := c.register(ctx, moved.device); err != nil {
+			return nil, err
+		}
+		rediscovered <- struct{}{}
+		return c.getLight(ctx, deviceID)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for moved.count(light.SetColor) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("commands never reached the bulb at its new address")
+		}
+		_ = c.SetState(ctx, d.ID, state)
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case <-rediscovered:
+	default:
+		t.Fatalf("expected a background re-discovery")
+	}
+}
+
+func TestLIFXProbe_GetService(t *testing.T) {
+	f := startFakeLIFX(t, 1, 2, 80, func(s *mock.Service) {
+		s.Handlers[lifxlan.GetService] = func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+			s.Reply(conn, addr, orig, lifxlan.StateService, []byte{byte(lifxlan.ServiceUDP), 0x7c, 0xdd, 0, 0})
+		}
+	})
+	c := NewLIFXController()
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d, err := c.register(ctx, f.device)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := c.Probe(ctx, d.ID); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+
+	rediscovered := make(chan struct{}, 1)
+	c.rediscover = func(ctx context.Context, deviceID string) (light.Device, error) {
+		rediscovered <- struct{}{}
+		return nil, errors.New("not found")
+	}
+	f.service.Stop()
+	probeCtx, probeCancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer probeCancel()
+	if err := c.Probe(probeCtx, d.ID); err == nil {
+		t.Fatalf("expected a silent bulb to fail the probe")
+	}
+	select {
+	case <-rediscovered:
+	case <-time.After(time.Second):
+		t.Fatalf("expected a failed probe to start re-discovery")
+	}
+}
+
+// BenchmarkLIFXSetState measures single-bulb frame throughput over the
+// pooled connection.
+func BenchmarkLIFXSetState(b *testing.B) {
+	f := startFakeLIFXBulb(b)
+	c := NewLIFXController()
+	defer c.Close()
+	ctx := context.Background()
+	d, err := c.register(ctx, f.device)
+	if err != nil {
+		b.Fatalf("register: %v", err)
+	}
+	state := DeviceState{On: true, Brightness: 1, Color: &Color{H: 120, S: 1, B: 1}}.WithTransition(0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.SetState(ctx, d.ID, state); err != nil {
+			b.Fatalf("SetState: %v", err)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package lights
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/tile"
+)
+
+// Multizone messages. lifxlan has no multizone package, so the payloads are
+// encoded here following https://lan.developer.lifx.com/docs/changing-a-device.
+const (
+	lifxSetColorZones           lifxlan.MessageType = 501
+	lifxGetColorZones           lifxlan.MessageType = 502
+	lifxStateZone               lifxlan.MessageType = 503
+	lifxStateMultiZone          lifxlan.MessageType = 506
+	lifxSetExtendedColorZones   lifxlan.MessageType = 510
+	lifxGetExtendedColorZones   lifxlan.MessageType = 511
+	lifxStateExtendedColorZones lifxlan.MessageType = 512
+	lifxExtendedZonesPerMessage                     = 82
+	lifxMatrixPixelsPerTile                         = 64
+	lifxApplyNo                 uint8               = 0
+	lifxApplyNow                uint8               = 1
+)
+
+type lifxRawSetColorZonesPayload struct {
+	StartIndex uint8
+	EndIndex   uint8
+	Color      lifxlan.Color
+	Duration   lifxlan.TransitionTime
+	Apply      uint8
+}
+
+type lifxRawGetColorZonesPayload struct {
+	StartIndex uint8
+	EndIndex   uint8
+}
+
+// lifxRawStateZoneHeader is the common prefix of StateZone and StateMultiZone.
+type lifxRawStateZoneHeader struct {
+	ZonesCount uint8
+	ZoneIndex  uint8
+}
+
+type lifxRawSetExtendedColorZonesPayload struct {
+	Duration    lifxlan.TransitionTime
+	Apply       uint8
+	ZoneIndex   uint16
+	ColorsCount uint8
+	Colors      [lifxExtendedZonesPerMessage]lifxlan.Color
+}
+
+type lifxRawStateExtendedColorZonesPayload struct {
+	ZonesCount  uint16
+	ZoneIndex   uint16
+	ColorsCount uint8
+	Colors      [lifxExtendedZonesPerMessage]lifxlan.Color
+}
+
+type lifxZoneKind int
+
+const (
+	lifxZonesNone lifxZoneKind = iota
+	lifxZonesLegacy
+	lifxZonesExtended
+	lifxZonesMatrix
+)
+
+// lifxZones describes how a device's segments are addressed. Matrix zones
+// are numbered tile by tile, row-major within each tile.
+type lifxZones struct {
+	kind  lifxZoneKind
+	count int
+	tiles []lifxTileSize
+}
+
+type lifxTileSize struct {
+	width, height int
+}
+
+// points returns one screen position per zone in zone order, or nil for a
+// single-segment device. Strip zones are spread along one row; matrix tiles
+// are placed left to right, each pixel at its spot within its tile.
+func (zl lifxZones) points() []Point {
+	switch zl.kind {
+	case lifxZonesLegacy, lifxZonesExtended:
+		if zl.count < 2 {
+			return nil
+		}
+		points := make([]Point, zl.count)
+		for i := range points {
+			points[i] = Point{X: (float64(i) + 0.5) / float64(zl.count), Y: 0.5}
+		}
+		return points
+
+	case lifxZonesMatrix:
+		width, height := 0, 0
+		for _, t := range zl.tiles {
+			width += t.width
+			height = max(height, t.height)
+		}
+		if zl.count < 2 || width == 0 || height == 0 {
+			return nil
+		}
+		points := make([]Point, 0, zl.count)
+		left := 0
+		for _, t := range zl.tiles {
+			for y := 0; y < t.height; y++ {
+				for x := 0; x < t.width; x++ {
+					points = append(points, Point{
+						X: (float64(left+x) + 0.5) / float64(width),
+						Y: (float64(y) + 0.5) / float64(height),
+					})
+				}
+			}
+			left += t.width
+		}
+		return points
+	}
+	return nil
+}
+
+// probeLIFXZones determines the zone layout of a multizone or matrix device.
+// Plain bulbs and failed probes report a single segment.
+func probeLIFXZones(ctx context.Context, dev lifxlan.Device, features lifxlan.Features) (lifxZones, error) {
+	switch {
+	case features.Matrix.Get():
+		td, err := tile.Wrap(ctx, dev, false)
+		if err != nil {
+			return lifxZones{}, fmt.Errorf("device chain: %w", err)
+		}
+		zl := lifxZones{kind: lifxZonesMatrix}
+		for _, t := range td.Tiles() {
+			zl.tiles = append(zl.tiles, lifxTileSize{width: int(t.Width), height: int(t.Height)})
+			zl.count += int(t.Width) * int(t.Height)
+		}
+		return zl, nil
+
+	case features.ExtendedMultizone.Get():
+		resp, err := lifxRequest(ctx, dev, lifxGetExtendedColorZones, nil, lifxStateExtendedColorZones)
+		if err != nil {
+			return lifxZones{}, err
+		}
+		var raw lifxRawStateExtendedColorZonesPayload
+		if err := binary.Read(bytes.NewReader(resp.Payload), binary.LittleEndian, &raw); err != nil {
+			return lifxZones{}, err
+		}
+		return lifxZones{kind: lifxZonesExtended, count: int(raw.ZonesCount)}, nil
+
+	case features.Multizone.Get():
+		resp, err := lifxRequest(ctx, dev, lifxGetColorZones,
+			&lifxRawGetColorZonesPayload{StartIndex: 0, EndIndex: 255},
+			lifxStateZone, lifxStateMultiZone)
+		if err != nil {
+			return lifxZones{}, err
+		}
+		var raw lifxRawStateZoneHeader
+		if err := binary.Read(bytes.NewReader(resp.Payload), binary.LittleEndian, &raw); err != nil {
+			return lifxZones{}, err
+		}
+		return lifxZones{kind: lifxZonesLegacy, count: int(raw.ZonesCount)}, nil
+	}
+	return lifxZones{count: 1}, nil
+}
+
+// lifxRequest sends msg on a fresh connection and returns the first reply of
+// one of the wanted types.
+func lifxRequest(ctx context.Context, dev lifxlan.Device, msg lifxlan.MessageType, payload interface{}, want ...lifxlan.MessageType) (*lifxlan.Response, error) {
+	conn, err := dev.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	seq, err := dev.Send(ctx, conn, 0, msg, payload)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		resp, err := lifxlan.ReadNextResponse(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Sequence != seq || resp.Source != dev.Source() {
+			continue
+		}
+		for _, w := range want {
+			if resp.Message == w {
+				return resp, nil
+			}
+		}
+	}
+}
+
+// sendLIFXZones writes state.Zones to every segment of the device. Zones are
+// resampled when their count doesn't match the device. ack is set on the
+// last packet only.
+func sendLIFXZones(ctx context.Context, dev lifxlan.Device, conn net.Conn, zl lifxZones, state DeviceState, transition time.Duration, ack lifxlan.AckResFlag) error {
+	colors := make([]lifxlan.Color, zl.count)
+	for i, z := range resampleZones(state.Zones, zl.count) {
+		colors[i] = stateToLIFXColor(DeviceState{
+			Brightness: z.B * state.Brightness,
+			Color:      &Color{H: z.H, S: z.S, B: 1},
+			Kelvin:     state.Kelvin,
+		})
+	}
+	duration := lifxlan.ConvertDuration(transition)
+
+	switch zl.kind {
+	case lifxZonesExtended:
+		for start := 0; start < len(colors); start += lifxExtendedZonesPerMessage {
+			end := min(start+lifxExtendedZonesPerMessage, len(colors))
+			payload := &lifxRawSetExtendedColorZonesPayload{
+				Duration:    duration,
+				Apply:       lifxApplyNo,
+				ZoneIndex:   uint16(start),
+				ColorsCount: uint8(end - start),
+			}
+			if end == len(colors) {
+				payload.Apply = lifxApplyNow
+			}
+			flags := lifxlan.AckResFlag(0)
+			if end == len(colors) {
+				flags = ack
+			}
+			copy(payload.Colors[:], colors[start:end])
+			if _, err := dev.Send(ctx, conn, flags, lifxSetExtendedColorZones, payload); err != nil {
+				return err
+			}
+		}
+
+	case lifxZonesLegacy:
+		// One message per run of identical zones; only the last one applies,
+		// so the strip updates in a single step.
+		for start := 0; start < len(colors); {
+			end := start
+			for end+1 < len(colors) && colors[end+1] == colors[start] {
+				end++
+			}
+			payload := &lifxRawSetColorZonesPayload{
+				StartIndex: uint8(start),
+				EndIndex:   uint8(end),
+				Color:      colors[start],
+				Duration:   duration,
+				Apply:      lifxApplyNo,
+			}
+			flags := lifxlan.AckResFlag(0)
+			if end == len(colors)-1 {
+				payload.Apply = lifxApplyNow
+				flags = ack
+			}
+			if _, err := dev.Send(ctx, conn, flags, lifxSetColorZones, payload); err != nil {
+				return err
+			}
+			start = end + 1
+		}
+
+	case lifxZonesMatrix:
+		offset := 0
+		for i, t := range zl.tiles {
+			payload := &tile.RawSetTileState64Payload{
+				TileIndex: uint8(i),
+				Length:    1,
+				Width:     uint8(t.width),
+				Duration:  duration,
+			}
+			n := min(t.width*t.height, lifxMatrixPixelsPerTile)
+			copy(payload.Colors[:n], colors[offset:offset+n])
+			offset += t.width * t.height
+			flags := lifxlan.AckResFlag(0)
+			if i == len(zl.tiles)-1 {
+				flags = ack
+			}
+			if _, err := dev.Send(ctx, conn, flags, tile.SetTileState64, payload); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("device has no addressable zones")
+	}
+	return nil
+}
+
+// resampleZones stretches or squeezes zones to n entries by nearest
+// neighbour, so a gradient keeps its shape on strips of any length.
+func resampleZones(zones []Color, n int) []Color {
+	if len(zones) == n || len(zones) == 0 {
+		return zones
+	}
+	out := make([]Color, n)
+	for i := range out {
+		out[i] = zones[i*len(zones)/n]
+	}
+	return out
+}
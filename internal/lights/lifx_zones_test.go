@@ -0,0 +1,284 @@
+package lights
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/lifxlan"
+	"go.yhsif.com/lifxlan/light"
+	"go.yhsif.com/lifxlan/mock"
+	"go.yhsif.com/lifxlan/tile"
+)
+
+// fakeLIFX is a LIFX UDP responder built on lifxlan/mock that also answers
+// the multizone queries and records every set packet.
+type fakeLIFX struct {
+	service *mock.Service
+	device  lifxlan.Device
+
+	mu       sync.Mutex
+	received map[lifxlan.MessageType][][]byte
+}
+
+// startFakeLIFX starts a responder for the given product and firmware.
+// configure may add handlers and payloads before the service starts.
+func startFakeLIFX(t testing.TB, productID uint32, fwMajor, fwMinor uint16, configure func(*mock.Service)) *fakeLIFX {
+	t.Helper()
+	f := &fakeLIFX{received: make(map[lifxlan.MessageType][][]byte)}
+	service := &mock.Service{
+		TB:         t,
+		Handlers:   make(map[lifxlan.MessageType]mock.HandlerFunc),
+		HandleAcks: true,
+	}
+	f.service = service
+
+	var label lifxlan.Label
+	copy(label[:], "Test Light")
+	service.RawStateLabelPayload = &lifxlan.RawStateLabelPayload{Label: label}
+	service.RawStateVersionPayload = &lifxlan.RawStateVersionPayload{
+		Version: lifxlan.HardwareVersion{VendorID: 1, ProductID: productID},
+	}
+	service.RawStatePayload = &light.RawStatePayload{}
+
+	reply := func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response, msg lifxlan.MessageType, payload interface{}) {
+		buf := new(bytes.Buffer)
+		if err := binary.Write(buf, binary.LittleEndian, payload); err != nil {
+			s.TB.Log(err)
+			return
+		}
+		s.Reply(conn, addr, orig, msg, buf.Bytes())
+	}
+	service.Handlers[lifxlan.GetHostFirmware] = func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+		reply(s, conn, addr, orig, lifxlan.StateHostFirmware, &lifxlan.RawStateHostFirmwarePayload{
+			VersionMajor: fwMajor,
+			VersionMinor: fwMinor,
+		})
+	}
+	record := func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+		f.mu.Lock()
+		f.received[orig.Message] = append(f.received[orig.Message], append([]byte(nil), orig.Payload...))
+		f.mu.Unlock()
+	}
+	service.Handlers[lifxSetExtendedColorZones] = record
+	service.Handlers[lifxSetColorZones] = record
+	service.Handlers[tile.SetTileState64] = record
+	service.Handlers[light.SetColor] = record
+	service.Handlers[light.SetLightPower] = record
+	if configure != nil {
+		configure(service)
+	}
+	f.device = service.Start()
+	return f
+}
+
+// waitFor returns the payloads received for msg once there are at least n.
+func (f *fakeLIFX) waitFor(t testing.TB, msg lifxlan.MessageType, n int) [][]byte {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		got := append([][]byte(nil), f.received[msg]...)
+		f.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d message(s) of type %v", n, msg)
+	return nil
+}
+
+func TestLIFXZones_ExtendedMultizone(t *testing.T) {
+	// LIFX Z on firmware 2.80 supports extended multizone.
+	f := startFakeLIFX(t, 32, 2, 80, func(service *mock.Service) {
+		service.Handlers[lifxGetExtendedColorZones] = func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+			buf := new(bytes.Buffer)
+			_ = binary.Write(buf, binary.LittleEndian, &lifxRawStateExtendedColorZonesPayload{ZonesCount: 16, ColorsCount: 16})
+			s.Reply(conn, addr, orig, lifxStateExtendedColorZones, buf.Bytes())
+		}
+	})
+
+	c := NewLIFXController()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d, err := c.register(ctx, f.device)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if d.Capabilities == nil || d.Capabilities.Segments != 16 {
+		t.Fatalf("expected 16 segments, got %+v", d.Capabilities)
+	}
+
+	err = c.SetState(ctx, d.ID, DeviceState{
+		On:         true,
+		Brightness: 1,
+		Zones:      []Color{{H: 0, S: 1, B: 1}, {H: 240, S: 1, B: 0.5}},
+	})
+	if err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+
+	raw := f.waitFor(t, lifxSetExtendedColorZones, 1)[0]
+	var p lifxRawSetExtendedColorZonesPayload
+	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, &p); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if p.ColorsCount != 16 || p.ZoneIndex != 0 || p.Apply != lifxApplyNow {
+		t.Fatalf("unexpected header: count=%d index=%d apply=%d", p.ColorsCount, p.ZoneIndex, p.Apply)
+	}
+	if p.Colors[0].Hue != 0 || p.Colors[7].Hue != 0 {
+		t.Fatalf("expected first half red, got hues %d,%d", p.Colors[0].Hue, p.Colors[7].Hue)
+	}
+	if p.Colors[8].Hue < 43000 || p.Colors[15].Brightness > 0x8000 {
+		t.Fatalf("expected second half dim blue, got hue=%d bri=%d", p.Colors[8].Hue, p.Colors[15].Brightness)
+	}
+}
+
+func TestLIFXZones_LegacyMultizoneMergesRuns(t *testing.T) {
+	// Original LIFX Z without extended multizone.
+	f := startFakeLIFX(t, 31, 1, 0, func(service *mock.Service) {
+		service.Handlers[lifxGetColorZones] = func(s *mock.Service, conn net.PacketConn, addr net.Addr, orig *lifxlan.Response) {
+			buf := new(bytes.Buffer)
+			_ = binary.Write(buf, binary.LittleEndian, &lifxRawStateZoneHeader{ZonesCount: 8})
+			_ = binary.Write(buf, binary.LittleEndian, [8]lifxlan.Color{})
+			s.Reply(conn, addr, orig, lifxStateMultiZone, buf.Bytes())
+		}
+	})
+
+	c := NewLIFXController()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d, err := c.register(ctx, f.device)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if d.Capabilities.Segments != 8 {
+		t.Fatalf("expected 8 segments, got %d", d.Capabilities.Segments)
+	}
+
+	red, green := Color{H: 0, S: 1, B: 1}, Color{H: 120, S: 1, B: 1}
+	err = c.SetState(ctx, d.ID, DeviceState{
+		On:         true,
+		Brightness: 1,
+		Zones:      []Color{red, red, red, green, green, green, green, green},
+	})
+	if err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+
+	msgs := f.waitFor(t, lifxSetColorZones, 2)
+	var first, second lifxRawSetColorZonesPayload
+	_ = binary.Read(bytes.NewReader(msgs[0]), binary.LittleEndian, &first)
+	_ = binary.Read(bytes.NewReader(msgs[1]), binary.LittleEndian, &second)
+	if first.StartIndex != 0 || first.EndIndex != 2 || first.Apply != lifxApplyNo {
+		t.Fatalf("unexpected first run: %+v", first)
+	}
+	if second.StartIndex != 3 || second.EndIndex != 7 || second.Apply != lifxApplyNow {
+		t.Fatalf("unexpected second run: %+v", second)
+	}
+}
+
+func TestLIFXZones_MatrixSet64(t *testing.T) {
+	f := startFakeLIFX(t, 55, 3, 70, func(service *mock.Service) {
+		chain := &tile.RawStateDeviceChainPayload{TotalCount: 2}
+		for i := 0; i < 2; i++ {
+			chain.TileDevices[i].Width = 8
+			chain.TileDevices[i].Height = 8
+			chain.TileDevices[i].HardwareVersion = lifxlan.HardwareVersion{VendorID: 1, ProductID: 55}
+		}
+		service.RawStateDeviceChainPayload = chain
+	})
+
+	c := NewLIFXController()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d, err := c.register(ctx, f.device)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if d.Capabilities.Segments != 128 {
+		t.Fatalf("expected 128 segments, got %d", d.Capabilities.Segments)
+	}
+	// The second tile sits to the right of the first.
+	layout := c.Layout(d.ID)
+	if len(layout) != 128 || layout[0] != (Point{X: 0.5 / 16, Y: 0.5 / 8}) || layout[64] != (Point{X: 8.5 / 16, Y: 0.5 / 8}) {
+		t.Fatalf("unexpected tile layout %v", layout)
+	}
+
+	zones := make([]Color, 128)
+	for i := 64; i < 128; i++ {
+		zones[i] = Color{H: 120, S: 1, B: 1}
+	}
+	if err := c.SetState(ctx, d.ID, DeviceState{On: true, Brightness: 1, Zones: zones}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+
+	msgs := f.waitFor(t, tile.SetTileState64, 2)
+	seen := make(map[uint8]tile.RawSetTileState64Payload)
+	for _, raw := range msgs {
+		var p tile.RawSetTileState64Payload
+		if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, &p); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		seen[p.TileIndex] = p
+	}
+	if seen[0].Width != 8 || seen[0].Colors[63].Brightness != 0 {
+		t.Fatalf("expected tile 0 dark, got %+v", seen[0].Colors[63])
+	}
+	if seen[1].Colors[0].Brightness != 0xffff {
+		t.Fatalf("expected tile 1 lit, got %+v", seen[1].Colors[0])
+	}
+}
+
+func TestLIFXZones_Points(t *testing.T) {
+	tests := []struct {
+		name string
+		zl   lifxZones
+		want []Point
+	}{
+		{name: "bulb", zl: lifxZones{count: 1}},
+		{name: "strip", zl: lifxZones{kind: lifxZonesExtended, count: 4}, want: []Point{{0.125, 0.5}, {0.375, 0.5}, {0.625, 0.5}, {0.875, 0.5}}},
+		{name: "legacy strip", zl: lifxZones{kind: lifxZonesLegacy, count: 2}, want: []Point{{0.25, 0.5}, {0.75, 0.5}}},
+		{
+			name: "two tiles",
+			zl:   lifxZones{kind: lifxZonesMatrix, count: 6, tiles: []lifxTileSize{{width: 2, height: 2}, {width: 1, height: 2}}},
+			want: []Point{
+				{1.0 / 6, 0.25}, {3.0 / 6, 0.25}, {1.0 / 6, 0.75}, {3.0 / 6, 0.75},
+				{5.0 / 6, 0.25}, {5.0 / 6, 0.75},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.zl.points()
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d points, got %v", len(tt.want), got)
+			}
+			for i := range got {
+				if math.Abs(got[i].X-tt.want[i].X) > 1e-9 || math.Abs(got[i].Y-tt.want[i].Y) > 1e-9 {
+					t.Fatalf("point %d: expected %v, got %v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResampleZones(t *testing.T) {
+	in := []Color{{H: 0}, {H: 120}, {H: 240}}
+	out := resampleZones(in, 6)
+	want := []float64{0, 0, 120, 120, 240, 240}
+	for i, h := range want {
+		if out[i].H != h {
+			t.Fatalf("zone %d: expected hue %.0f, got %.0f", i, h, out[i].H)
+		}
+	}
+	if got := resampleZones(in, 2); got[0].H != 0 || got[1].H != 120 {
+		t.Fatalf("unexpected downsample: %+v", got)
+	}
+}
@@ -2,30 +2,129 @@ package lights
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Manager struct {
 	mu          sync.RWMutex
 	controllers map[Brand]Controller
 	devices     map[string]Device
+	groups      map[string]Group
+	// addresses are the manual addresses discovery probes directly.
+	addresses []ManualAddress
+	// eventHandlers receive controller push events (see EventSource).
+	eventHandlers []func(DeviceEvent)
+
+	// states is the last known state of each device, kept current by
+	// commands, reads and push events; subscribers hear about changes.
+	stateMu     sync.Mutex
+	states      map[string]cachedState
+	subscribers map[uint64]func(DeviceStateChange)
+	subSeq      uint64
+	pollGrace   time.Duration
+
+	// health tracks reachability from the background health checks.
+	healthMu     sync.Mutex
+	health       map[string]*deviceHealth
+	healthTiming healthTiming
+	stopHealth   context.CancelFunc
+
+	// fades tracks in-flight software fades so a newer command for the
+	// same device can cancel them.
+	fadeMu  sync.Mutex
+	fades   map[string]fadeEntry
+	fadeSeq uint64
+
+	// queues hold the newest pending command per device, budgets the shared
+	// rate limits declared by RateBudgeter controllers, and batches the
+	// groups waiting for each BatchSetter.
+	queueMu sync.Mutex
+	queues  map[string]*deviceQueue
+	budgets map[string]*rateBudget
+	batches map[Controller]*batchQueue
+
+	// identifyPeriod is how long each half of a software identify flash
+	// lasts.
+	identifyPeriod time.Duration
 }
 
 func NewManager() *Manager {
 	return &Manager{
-		controllers: make(map[Brand]Controller),
-		devices:     make(map[string]Device),
+		controllers:  make(map[Brand]Controller),
+		devices:      make(map[string]Device),
+		groups:       make(map[string]Group),
+		states:       make(map[string]cachedState),
+		subscribers:  make(map[uint64]func(DeviceStateChange)),
+		pollGrace:    statePollGrace,
+		health:       make(map[string]*deviceHealth),
+		healthTiming: defaultHealthTiming,
+		fades:        make(map[string]fadeEntry),
+		queues:       make(map[string]*deviceQueue),
+		budgets:      make(map[string]*rateBudget),
+		batches:      make(map[Controller]*batchQueue),
+
+		identifyPeriod: identifyFlashPeriod,
 	}
 }
 
 func (m *Manager) RegisterController(c Controller) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	m.controllers[c.Brand()] = c
+	m.mu.Unlock()
+	if es, ok := c.(EventSource); ok {
+		es.SetEventHandler(m.handleEvent)
+	}
+}
+
+// OnDeviceEvent registers fn to be called for every event pushed by a
+// controller, after the device list and state cache have been updated.
+// State events also reach Subscribe handlers as SourceExternal changes,
+// except those arriving within the grace period after a command, which are
+// usually its echo.
+func (m *Manager) OnDeviceEvent(fn func(DeviceEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.eventHandlers = append(m.eventHandlers, fn)
+}
+
+func (m *Manager) handleEvent(ev DeviceEvent) {
+	if ev.Type == DeviceEventState && ev.State != nil {
+		m.markSeen(ev.DeviceID)
+		m.recordRead(ev.DeviceID, *ev.State)
+	}
+
+	m.mu.Lock()
+	switch ev.Type {
+	case DeviceEventAdded:
+		if ev.Device != nil {
+			d := *ev.Device
+			if existing, ok := m.devices[d.ID]; ok {
+				d.keepSettings(existing)
+			}
+			m.devices[d.ID] = d
+		}
+	case DeviceEventRemoved:
+		delete(m.devices, ev.DeviceID)
+		m.forgetState(ev.DeviceID)
+		m.forgetHealth(ev.DeviceID)
+	}
+	m.mu.Unlock()
+	m.notifyEvent(ev)
+}
+
+func (m *Manager) notifyEvent(ev DeviceEvent) {
+	m.mu.RLock()
+	handlers := m.eventHandlers
+	m.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(ev)
+	}
 }
 
 func (m *Manager) GetController(brand Brand) (Controller, bool) {
@@ -35,6 +134,20 @@ func (m *Manager) GetController(brand Brand) (Controller, bool) {
 	return c, ok
 }
 
+// Controllers returns the registered controllers ordered by brand.
+func (m *Manager) Controllers() []Controller {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	controllers := make([]Controller, 0, len(m.controllers))
+	for _, c := range m.controllers {
+		controllers = append(controllers, c)
+	}
+	sort.Slice(controllers, func(i, j int) bool {
+		return controllers[i].Brand() < controllers[j].Brand()
+	})
+	return controllers
+}
+
 // DiscoverAllWithProgress runs discovery across all controllers concurrently.
 // onDevices is called (under an internal lock, so serially) each time a
 // controller finishes, with only the devices that controller returned.
@@ -58,6 +171,9 @@ func (m *Manager) DiscoverAllWithProgress(ctx context.Context, onDevices func([]
 		go func(ctrl Controller) {
 			defer wg.Done()
 			devices, err := ctrl.Discover(ctx)
+			if err == nil {
+				devices = append(devices, m.probeAddresses(ctx, ctrl, devices)...)
+			}
 			mu.Lock()
 			defer mu.Unlock()
 			if err != nil {
@@ -72,21 +188,30 @@ func (m *Manager) DiscoverAllWithProgress(ctx context.Context, onDevices func([]
 	}
 
 	wg.Wait()
+	m.addDevices(allDevices)
+
+	if len(errs) > 0 && len(allDevices) == 0 {
+		return nil, fmt.Errorf("discovery failed: %v", errs)
+	}
+
+	return allDevices, nil
+}
 
+// addDevices records discovered devices, keeping the user's settings for
+// ones already known and moving them over from legacy IDs.
+func (m *Manager) addDevices(devices []Device) {
 	m.mu.Lock()
-	for _, d := range allDevices {
+	renamed := m.migrateLegacyIDs(devices)
+	for _, d := range devices {
 		if existing, ok := m.devices[d.ID]; ok {
-			d.Room = existing.Room
+			d.keepSettings(existing)
 		}
 		m.devices[d.ID] = d
 	}
 	m.mu.Unlock()
-
-	if len(errs) > 0 && len(allDevices) == 0 {
-		return nil, fmt.Errorf("discovery failed: %v", errs)
+	for oldID, newID := range renamed {
+		m.notifyEvent(DeviceEvent{Type: DeviceEventRenamed, DeviceID: newID, PreviousID: oldID})
 	}
-
-	return allDevices, nil
 }
 
 func (m *Manager) controllerFor(deviceID string) (Controller, error) {
@@ -98,20 +223,225 @@ func (m *Manager) controllerFor(deviceID string) (Controller, error) {
 	return ctrl, nil
 }
 
+// SetDeviceState applies state to a device. When state requests a transition
+// and the controller can't fade natively, the fade is emulated in software
+// and continues in the background after SetDeviceState returns. State is
+// translated to what the device can show (see Capabilities.Translate) and
+// the device's calibration is applied to what is sent. Commands wait in the
+// device's outbound queue for its rate budget; one replaced there by a newer
+// command returns nil without being sent. On success the state cache
+// records the requested target, attributed to ctx's source.
 func (m *Manager) SetDeviceState(ctx context.Context, deviceID string, state DeviceState) error {
 	ctrl, err := m.controllerFor(deviceID)
 	if err != nil {
 		return err
 	}
-	return ctrl.SetState(ctx, deviceID, state)
+	if err := m.skipOffline(ctx, deviceID); err != nil {
+		return err
+	}
+	caps := ctrl.Capabilities(deviceID)
+	state = caps.Clamp(caps.Translate(state))
+	sent := caps.Clamp(m.calibrate(deviceID, state))
+	m.cancelFade(deviceID)
+	if d := state.Transition(0); d > 0 && !caps.NativeTransitions {
+		err = m.startFade(ctx, ctrl, deviceID, sent, d)
+	} else {
+		err = m.setState(ctx, ctrl, deviceID, sent)
+	}
+	if errors.Is(err, errSuperseded) {
+		return nil
+	}
+	if err != nil {
+		m.checkSoon(deviceID)
+		return err
+	}
+	m.recordState(deviceID, state, sourceFrom(ctx))
+	return nil
+}
+
+// SetDeviceStates applies many device states at once. Devices are grouped by
+// brand; controllers implementing BatchSetter receive their whole group in
+// one call, one batch per controller at a time, others are updated
+// concurrently per device. Devices with a rate
+// budget go through their outbound queues one by one instead. Devices that
+// need a software fade go through SetDeviceState. Scene and screen sync
+// commands skip devices known to be offline. The returned error joins every
+// per-device failure.
+func (m *Manager) SetDeviceStates(ctx context.Context, states map[string]DeviceState) error {
+	var errs []error
+	for id, err := range m.applyStates(ctx, states) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
+// applyStates does the work of SetDeviceStates and returns every device's
+// result, nil for those that succeeded.
+func (m *Manager) applyStates(ctx context.Context, states map[string]DeviceState) map[string]error {
+	var (
+		mu      sync.Mutex
+		results = make(map[string]error, len(states))
+		wg      sync.WaitGroup
+	)
+	result := func(id string, err error) {
+		mu.Lock()
+		results[id] = err
+		mu.Unlock()
+	}
+
+	byCtrl := make(map[Controller]map[string]DeviceState)
+	requested := make(map[string]DeviceState, len(states))
+	for id, state := range states {
+		ctrl, err := m.controllerFor(id)
+		if err != nil {
+			result(id, err)
+			continue
+		}
+		if err := m.skipOffline(ctx, id); err != nil {
+			result(id, err)
+			continue
+		}
+		caps := ctrl.Capabilities(id)
+		state = caps.Clamp(caps.Translate(state))
+		if d := state.Transition(0); d > 0 && !caps.NativeTransitions {
+			wg.Add(1)
+			go func(id string, state DeviceState) {
+				defer wg.Done()
+				result(id, m.SetDeviceState(ctx, id, state))
+			}(id, state)
+			continue
+		}
+		m.cancelFade(id)
+		if byCtrl[ctrl] == nil {
+			byCtrl[ctrl] = make(map[string]DeviceState)
+		}
+		requested[id] = state
+		byCtrl[ctrl][id] = caps.Clamp(m.calibrate(id, state))
+	}
+
+	source := sourceFrom(ctx)
+	for ctrl, group := range byCtrl {
+		bs, isBatch := ctrl.(BatchSetter)
+		batch := make(map[string]DeviceState)
+		for id, state := range group {
+			if isBatch && m.budgetFor(ctrl, id) == nil {
+				batch[id] = state
+				continue
+			}
+			wg.Add(1)
+			go func(ctrl Controller, id string, state DeviceState) {
+				defer wg.Done()
+				err := m.send(ctx, ctrl, id, func(ctx context.Context) error {
+					if isBatch {
+						return bs.SetStates(ctx, map[string]DeviceState{id: state})
+					}
+					return ctrl.SetState(ctx, id, state)
+				})
+				if errors.Is(err, errSuperseded) {
+					result(id, nil)
+					return
+				}
+				if err != nil {
+					result(id, err)
+					m.checkSoon(id)
+					return
+				}
+				m.recordState(id, requested[id], source)
+				result(id, nil)
+			}(ctrl, id, state)
+		}
+		if len(batch) > 0 {
+			wg.Add(1)
+			go func(ctrl Controller, bs BatchSetter, group map[string]DeviceState) {
+				defer wg.Done()
+				for id, err := range m.sendBatch(ctx, ctrl, bs, group) {
+					if errors.Is(err, errSuperseded) {
+						result(id, nil)
+						continue
+					}
+					result(id, err)
+					if err != nil {
+						m.checkSoon(id)
+					} else {
+						m.recordState(id, requested[id], source)
+					}
+				}
+			}(ctrl, bs, batch)
+		}
+	}
+
+	wg.Wait()
+	return results
+}
+
+// Capabilities returns the capability descriptor for a device.
+func (m *Manager) Capabilities(deviceID string) (Capabilities, error) {
+	ctrl, err := m.controllerFor(deviceID)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	return ctrl.Capabilities(deviceID), nil
+}
+
+// Layout returns the physical segment positions of a device, or nil when its
+// controller doesn't provide a layout.
+func (m *Manager) Layout(deviceID string) []Point {
+	ctrl, err := m.controllerFor(deviceID)
+	if err != nil {
+		return nil
+	}
+	lp, ok := ctrl.(LayoutProvider)
+	if !ok {
+		return nil
+	}
+	return lp.Layout(deviceID)
+}
+
+// CommandHistory returns the commands recorded for deviceID by controllers
+// that keep a history, oldest first. An empty deviceID merges the history of
+// every such controller.
+func (m *Manager) CommandHistory(deviceID string) []CommandRecord {
+	if deviceID != "" {
+		ctrl, err := m.controllerFor(deviceID)
+		if err != nil {
+			return nil
+		}
+		if rec, ok := ctrl.(CommandRecorder); ok {
+			return rec.History(deviceID)
+		}
+		return nil
+	}
+
+	m.mu.RLock()
+	var records []CommandRecord
+	for _, ctrl := range m.controllers {
+		if rec, ok := ctrl.(CommandRecorder); ok {
+			records = append(records, rec.History("")...)
+		}
+	}
+	m.mu.RUnlock()
+	sort.SliceStable(records, func(i, j int) bool { return records[i].At.Before(records[j].At) })
+	return records
+}
+
+// GetDeviceState reads a device's state from the device. The result updates
+// the state cache; a difference from the cached state is reported to
+// subscribers as an external change.
 func (m *Manager) GetDeviceState(ctx context.Context, deviceID string) (DeviceState, error) {
 	ctrl, err := m.controllerFor(deviceID)
 	if err != nil {
 		return DeviceState{}, err
 	}
-	return ctrl.GetState(ctx, deviceID)
+	state, err := ctrl.GetState(ctx, deviceID)
+	if err != nil {
+		m.checkSoon(deviceID)
+		return DeviceState{}, err
+	}
+	m.markSeen(deviceID)
+	m.recordRead(deviceID, state)
+	return state, nil
 }
 
 func (m *Manager) TurnOn(ctx context.Context, deviceID string) error {
@@ -120,7 +450,19 @@ func (m *Manager) TurnOn(ctx context.Context, deviceID string) error {
 		return err
 	}
 	log.Printf("[manager] TurnOn %s", deviceID)
-	return ctrl.TurnOn(ctx, deviceID)
+	m.cancelFade(deviceID)
+	err = m.send(ctx, ctrl, deviceID, func(ctx context.Context) error {
+		return ctrl.TurnOn(ctx, deviceID)
+	})
+	if errors.Is(err, errSuperseded) {
+		return nil
+	}
+	if err != nil {
+		m.checkSoon(deviceID)
+		return err
+	}
+	m.recordPower(deviceID, true, sourceFrom(ctx))
+	return nil
 }
 
 func (m *Manager) TurnOff(ctx context.Context, deviceID string) error {
@@ -129,7 +471,19 @@ func (m *Manager) TurnOff(ctx context.Context, deviceID string) error {
 		return err
 	}
 	log.Printf("[manager] TurnOff %s", deviceID)
-	return ctrl.TurnOff(ctx, deviceID)
+	m.cancelFade(deviceID)
+	err = m.send(ctx, ctrl, deviceID, func(ctx context.Context) error {
+		return ctrl.TurnOff(ctx, deviceID)
+	})
+	if errors.Is(err, errSuperseded) {
+		return nil
+	}
+	if err != nil {
+		m.checkSoon(deviceID)
+		return err
+	}
+	m.recordPower(deviceID, false, sourceFrom(ctx))
+	return nil
 }
 
 func (m *Manager) GetDevices() []Device {
@@ -137,6 +491,7 @@ func (m *Manager) GetDevices() []Device {
 	defer m.mu.RUnlock()
 	devices := make([]Device, 0, len(m.devices))
 	for _, d := range m.devices {
+		m.applyHealth(&d)
 		devices = append(devices, d)
 	}
 	sort.Slice(devices, func(i, j int) bool {
@@ -152,14 +507,22 @@ func (m *Manager) SetDevices(devices []Device) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	for _, d := range devices {
+		d.Offline = false // health checks decide afresh
 		m.devices[d.ID] = d
 	}
 }
 
+// RemoveDevice forgets a device, along with any manual address it was added
+// by.
 func (m *Manager) RemoveDevice(deviceID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if d, ok := m.devices[deviceID]; ok {
+		m.forgetAddressesLocked(d)
+	}
 	delete(m.devices, deviceID)
+	m.forgetState(deviceID)
+	m.forgetHealth(deviceID)
 }
 
 func (m *Manager) SetDeviceRoom(deviceID, room string) {
@@ -172,6 +535,19 @@ func (m *Manager) SetDeviceRoom(deviceID, room string) {
 }
 
 func (m *Manager) Close() error {
+	m.healthMu.Lock()
+	if m.stopHealth != nil {
+		m.stopHealth()
+	}
+	m.healthMu.Unlock()
+
+	m.fadeMu.Lock()
+	for id, f := range m.fades {
+		f.cancel()
+		delete(m.fades, id)
+	}
+	m.fadeMu.Unlock()
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	for _, c := range m.controllers {
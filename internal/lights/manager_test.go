@@ -0,0 +1,271 @@
+package lights
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchVirtual is a virtual controller that implements BatchSetter and
+// records the devices of every SetStates call. Each call, batched or not,
+// takes delay and counts how many overlapped, per controller and per device.
+type batchVirtual struct {
+	*VirtualController
+	rate  float64
+	delay time.Duration
+
+	mu             sync.Mutex
+	batches        []string
+	inFlight       int
+	maxInFlight    int
+	devInFlight    map[string]int
+	maxDevInFlight int
+}
+
+func (c *batchVirtual) RateBudget(string) (string, float64) {
+	return "bridge", c.rate
+}
+
+// enter marks ids in flight and returns the func that clears them.
+func (c *batchVirtual) enter(ids []string) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.devInFlight == nil {
+		c.devInFlight = make(map[string]int)
+	}
+	for _, id := range ids {
+		c.devInFlight[id]++
+		c.maxDevInFlight = max(c.maxDevInFlight, c.devInFlight[id])
+	}
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, id := range ids {
+			c.devInFlight[id]--
+		}
+	}
+}
+
+func (c *batchVirtual) SetState(ctx context.Context, id string, state DeviceState) error {
+	defer c.enter([]string{id})()
+	time.Sleep(c.delay)
+	return c.VirtualController.SetState(ctx, id, state)
+}
+
+func (c *batchVirtual) SetStates(ctx context.Context, states map[string]DeviceState) error {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	defer c.enter(ids)()
+	c.mu.Lock()
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+	time.Sleep(c.delay)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for id, state := range states {
+		if err := c.VirtualController.SetState(ctx, id, state); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	c.batches = append(c.batches, strings.Join(ids, ","))
+	c.mu.Unlock()
+	return nil
+}
+
+func TestSetDeviceStates_Routing(t *testing.T) {
+	instant := DeviceState{On: true, Brightness: 1, Color: &Color{H: 120, S: 1, B: 1}}.WithTransition(0)
+	fading := instant.WithTransition(500)
+
+	tests := []struct {
+		name        string
+		rate        float64
+		states      map[string]DeviceState
+		wantBatches []string
+	}{
+		{
+			name:        "one batch without a budget",
+			states:      map[string]DeviceState{"virtual:a": instant, "virtual:b": instant, "virtual:c": instant},
+			wantBatches: []string{"virtual:a,virtual:b,virtual:c"},
+		},
+		{
+			name:        "per device through the queue with a budget",
+			rate:        100,
+			states:      map[string]DeviceState{"virtual:a": instant, "virtual:b": instant},
+			wantBatches: []string{"virtual:a", "virtual:b"},
+		},
+		{
+			name:        "software fades leave the batch",
+			states:      map[string]DeviceState{"virtual:a": instant, "virtual:b": fading},
+			wantBatches: []string{"virtual:a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &batchVirtual{VirtualController: NewVirtualController(), rate: tt.rate}
+			c.SetDevices([]VirtualDevice{{ID: "a"}, {ID: "b"}, {ID: "c"}})
+			m := NewManager()
+			m.RegisterController(c)
+			defer m.Close()
+
+			if err := m.SetDeviceStates(context.Background(), tt.states); err != nil {
+				t.Fatalf("SetDeviceStates: %v", err)
+			}
+			c.mu.Lock()
+			got := append([]string(nil), c.batches...)
+			c.mu.Unlock()
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(tt.wantBatches, " ") {
+				t.Fatalf("expected batches %v, got %v", tt.wantBatches, got)
+			}
+			// A software fade without a cached state sends its first step in
+			// the background.
+			for id := range tt.states {
+				if len(waitForHistory(t, m, id, 1)) == 0 {
+					t.Fatalf("expected %s to receive its state", id)
+				}
+			}
+		})
+	}
+}
+
+func TestSetDeviceStates_OneBatchInFlight(t *testing.T) {
+	c := &batchVirtual{VirtualController: NewVirtualController(), delay: 100 * time.Millisecond}
+	c.SetDevices([]VirtualDevice{{ID: "a"}, {ID: "b"}})
+	m := NewManager()
+	m.RegisterController(c)
+	defer m.Close()
+	ctx := context.Background()
+	frame := func(b float64) DeviceState {
+		return DeviceState{On: true, Brightness: b, Color: &Color{H: 120, S: 1, B: 1}}.WithTransition(0)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = m.SetDeviceStates(ctx, map[string]DeviceState{"virtual:a": frame(0.1), "virtual:b": frame(0.1)})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// Frames arriving while the first batch is in flight wait and are
+	// merged into one.
+	for i := 2; i <= 5; i++ {
+		wg.Add(1)
+		go func(b float64) {
+			defer wg.Done()
+			_ = m.SetDeviceStates(ctx, map[string]DeviceState{"virtual:a": frame(b)})
+		}(float64(i) / 10)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := m.SetDeviceStates(ctx, map[string]DeviceState{"virtual:a": frame(0.9), "virtual:b": frame(0.9)}); err != nil {
+		t.Fatalf("SetDeviceStates: %v", err)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxInFlight != 1 {
+		t.Fatalf("expected one batch in flight at a time, saw %d", c.maxInFlight)
+	}
+	if len(c.batches) != 2 {
+		t.Fatalf("expected the waiting frames to merge into one batch, got %v", c.batches)
+	}
+	for _, id := range []string{"virtual:a", "virtual:b"} {
+		if st, ok := m.CachedState(id); !ok || st.Brightness != 0.9 {
+			t.Fatalf("expected %s to end on the newest frame, got %+v", id, st)
+		}
+	}
+}
+
+func TestSetDeviceStates_BatchWaitsForDeviceCommand(t *testing.T) {
+	c := &batchVirtual{VirtualController: NewVirtualController(), delay: 100 * time.Millisecond}
+	c.SetDevices([]VirtualDevice{{ID: "a"}, {ID: "b"}})
+	m := NewManager()
+	m.RegisterController(c)
+	defer m.Close()
+	ctx := context.Background()
+	frame := func(b float64) DeviceState {
+		return DeviceState{On: true, Brightness: b, Color: &Color{H: 120, S: 1, B: 1}}.WithTransition(0)
+	}
+
+	// A per-device command, such as a fade step, is in flight when the
+	// batch arrives.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = m.setState(ctx, c, "virtual:a", frame(0.1))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := m.SetDeviceStates(ctx, map[string]DeviceState{"virtual:a": frame(0.9), "virtual:b": frame(0.9)}); err != nil {
+		t.Fatalf("SetDeviceStates: %v", err)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	maxDev := c.maxDevInFlight
+	c.mu.Unlock()
+	if maxDev != 1 {
+		t.Fatalf("expected one command in flight per device, saw %d", maxDev)
+	}
+	if st, err := c.GetState(ctx, "virtual:a"); err != nil || st.Brightness != 0.9 {
+		t.Fatalf("expected the batch to follow the device command, got %+v, %v", st, err)
+	}
+}
+
+func TestSetDeviceStates_BatchOutlivesOneCaller(t *testing.T) {
+	c := &batchVirtual{VirtualController: NewVirtualController(), delay: 100 * time.Millisecond}
+	c.SetDevices([]VirtualDevice{{ID: "a"}, {ID: "b"}})
+	m := NewManager()
+	m.RegisterController(c)
+	defer m.Close()
+	frame := func(b float64) DeviceState {
+		return DeviceState{On: true, Brightness: b, Color: &Color{H: 120, S: 1, B: 1}}.WithTransition(0)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = m.SetDeviceStates(context.Background(), map[string]DeviceState{"virtual:a": frame(0.1)})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Both callers wait for the same second batch; the one that gives up
+	// while it is in flight must not abort the other's state.
+	errc := make(chan error, 1)
+	go func() {
+		errc <- m.SetDeviceStates(context.Background(), map[string]DeviceState{"virtual:b": frame(0.5)})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = m.SetDeviceStates(ctx, map[string]DeviceState{"virtual:a": frame(0.5)})
+	}()
+	time.Sleep(120 * time.Millisecond)
+	cancel()
+
+	if err := <-errc; err != nil {
+		t.Fatalf("expected the remaining caller's batch to succeed, got %v", err)
+	}
+	wg.Wait()
+	if st, err := c.GetState(context.Background(), "virtual:b"); err != nil || st.Brightness != 0.5 {
+		t.Fatalf("expected virtual:b to receive its state, got %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,570 @@
+package lights
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// NanoleafAPIPort is the port of the Nanoleaf OpenAPI.
+	NanoleafAPIPort = 16021
+	// nanoleafStreamPort is where panels listen for extControl v2 frames.
+	nanoleafStreamPort = 60222
+)
+
+// Shape types that carry no LEDs (controllers, connectors, Rhythm module)
+// and are left out of the addressable layout.
+var nanoleafUnlitShapes = map[int]bool{
+	1:  true, // Rhythm
+	12: true, // Shapes controller
+	16: true, // Lines connector
+	19: true, // Controller cap
+	20: true, // Power connector
+}
+
+type NanoleafController struct {
+	mu      sync.RWMutex
+	devices map[string]*nanoleafDevice
+	client  *http.Client
+}
+
+type nanoleafDevice struct {
+	// apiURL is the authenticated base, http://<ip>:16021/api/v1/<token>.
+	apiURL  string
+	udpAddr string
+	// panelIDs and points list the lit panels in Zones order.
+	panelIDs []int
+	points   []Point
+	// minKelvin and maxKelvin are the colour-temperature range the device
+	// reported; zero until it has been queried.
+	minKelvin, maxKelvin int
+}
+
+// nanoleafInfo is the subset of GET /api/v1/<token>/ we use.
+type nanoleafInfo struct {
+	Name            string `json:"name"`
+	SerialNo        string `json:"serialNo"`
+	Model           string `json:"model"`
+	FirmwareVersion string `json:"firmwareVersion"`
+	State           struct {
+		CT struct {
+			Min int `json:"min"`
+			Max int `json:"max"`
+		} `json:"ct"`
+	} `json:"state"`
+	PanelLayout struct {
+		Layout            nanoleafLayout `json:"layout"`
+		GlobalOrientation struct {
+			Value float64 `json:"value"`
+		} `json:"globalOrientation"`
+	} `json:"panelLayout"`
+}
+
+type nanoleafLayout struct {
+	NumPanels    int `json:"numPanels"`
+	PositionData []struct {
+		PanelID   int     `json:"panelId"`
+		X         float64 `json:"x"`
+		Y         float64 `json:"y"`
+		O         float64 `json:"o"`
+		ShapeType int     `json:"shapeType"`
+	} `json:"positionData"`
+}
+
+type nanoleafValue struct {
+	Value int `json:"value"`
+}
+
+type nanoleafStateResponse struct {
+	On struct {
+		Value bool `json:"value"`
+	} `json:"on"`
+	Brightness nanoleafValue `json:"brightness"`
+	Hue        nanoleafValue `json:"hue"`
+	Sat        nanoleafValue `json:"sat"`
+	CT         nanoleafValue `json:"ct"`
+	ColorMode  string        `json:"colorMode"`
+}
+
+func NewNanoleafController() *NanoleafController {
+	return &NanoleafController{
+		devices: make(map[string]*nanoleafDevice),
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (c *NanoleafController) Brand() Brand {
+	return BrandNanoleaf
+}
+
+// nanoleafDefaultCapabilities covers every current panel family. The
+// OpenAPI only fades brightness, so transitions are left to the manager.
+var nanoleafDefaultCapabilities = Capabilities{
+	Color:      true,
+	Kelvin:     true,
+	MinKelvin:  1200,
+	MaxKelvin:  6500,
+	KelvinStep: 1,
+	Segments:   1,
+	ReadBack:   true,
+}
+
+// Capabilities reports the number of lit panels as Segments and the
+// colour-temperature range the device reported.
+func (c *NanoleafController) Capabilities(deviceID string) Capabilities {
+	caps := nanoleafDefaultCapabilities
+	c.mu.RLock()
+	if dev, ok := c.devices[deviceID]; ok {
+		if len(dev.panelIDs) > 0 {
+			caps.Segments = len(dev.panelIDs)
+		}
+		if dev.maxKelvin > 0 {
+			caps.MinKelvin, caps.MaxKelvin = dev.minKelvin, dev.maxKelvin
+		}
+	}
+	c.mu.RUnlock()
+	return caps
+}
+
+// Layout returns each lit panel's position, scaled to the unit square with
+// the layout's global orientation applied and Y pointing down.
+func (c *NanoleafController) Layout(deviceID string) []Point {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if dev, ok := c.devices[deviceID]; ok {
+		return append([]Point(nil), dev.points...)
+	}
+	return nil
+}
+
+// AddDevice registers a paired device. Discover then reads its panel layout.
+func (c *NanoleafController) AddDevice(ip, token string) {
+	deviceID := fmt.Sprintf("nanoleaf:%s", ip)
+	log.Printf("[nanoleaf] Adding device %s", deviceID)
+	host := net.JoinHostPort(ip, strconv.Itoa(NanoleafAPIPort))
+	c.addDevice(deviceID, fmt.Sprintf("http://%s/api/v1/%s", host, token),
+		net.JoinHostPort(ip, strconv.Itoa(nanoleafStreamPort)))
+}
+
+// DiscoveryHints implements DiscoveryProvider: controllers announce
+// themselves over mDNS.
+func (c *NanoleafController) DiscoveryHints() []DiscoveryHint {
+	return []DiscoveryHint{{Method: DiscoverMDNS, Targets: []string{"_nanoleafapi._tcp"}}}
+}
+
+// AddDiscovered implements DiscoveryProvider. A controller needs a token
+// from pairing before it can be reached, so one found by a scan is only
+// logged; the pairing flow lists them.
+func (c *NanoleafController) AddDiscovered(host, name string) {
+	log.Printf("[nanoleaf] Found %q at %s; pair it to add its panels", name, host)
+}
+
+func (c *NanoleafController) addDevice(deviceID, apiURL, udpAddr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dev := &nanoleafDevice{apiURL: apiURL, udpAddr: udpAddr}
+	if old, ok := c.devices[deviceID]; ok {
+		dev.panelIDs, dev.points = old.panelIDs, old.points
+		dev.minKelvin, dev.maxKelvin = old.minKelvin, old.maxKelvin
+	}
+	c.devices[deviceID] = dev
+}
+
+// RemoveDevice forgets a device, e.g. after its token was revoked.
+func (c *NanoleafController) RemoveDevice(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.devices, fmt.Sprintf("nanoleaf:%s", ip))
+}
+
+func (c *NanoleafController) Discover(ctx context.Context) ([]Device, error) {
+	c.mu.RLock()
+	known := make(map[string]*nanoleafDevice, len(c.devices))
+	for id, dev := range c.devices {
+		known[id] = dev
+	}
+	c.mu.RUnlock()
+
+	log.Printf("[nanoleaf] Discover: %d paired device(s) to query", len(known))
+
+	var result []Device
+	for id, dev := range known {
+		var info nanoleafInfo
+		if err := c.do(ctx, http.MethodGet, dev.apiURL+"/", nil, &info); err != nil {
+			log.Printf("[nanoleaf] Failed to get info for %s: %v", id, err)
+			continue
+		}
+
+		panelIDs, points := nanoleafLayoutPoints(info.PanelLayout.Layout, info.PanelLayout.GlobalOrientation.Value)
+		c.mu.Lock()
+		if cur, ok := c.devices[id]; ok {
+			cur.panelIDs, cur.points = panelIDs, points
+			if info.State.CT.Max > 0 {
+				cur.minKelvin, cur.maxKelvin = info.State.CT.Min, info.State.CT.Max
+			}
+		}
+		c.mu.Unlock()
+
+		log.Printf("[nanoleaf] Discovered: %s (%d panels) at %s", info.Name, len(panelIDs), ipFromDeviceID(id))
+		d := Device{
+			ID:              id,
+			Brand:           BrandNanoleaf,
+			Name:            info.Name,
+			Model:           nanoleafModelName(info.Model),
+			LastIP:          ipFromDeviceID(id),
+			LastSeen:        time.Now(),
+			FirmwareVersion: info.FirmwareVersion,
+		}
+		d.applyCapabilities(c.Capabilities(id))
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// nanoleafLayoutPoints returns the lit panels of layout and their positions
+// in the unit square. The layout is rotated by the orientation set in the
+// Nanoleaf app, and flipped because Nanoleaf's Y axis points up.
+func nanoleafLayoutPoints(layout nanoleafLayout, orientation float64) ([]int, []Point) {
+	sin, cos := math.Sincos(orientation * math.Pi / 180)
+	var ids []int
+	var raw []Point
+	for _, p := range layout.PositionData {
+		if nanoleafUnlitShapes[p.ShapeType] {
+			continue
+		}
+		ids = append(ids, p.PanelID)
+		raw = append(raw, Point{X: p.X*cos - p.Y*sin, Y: p.X*sin + p.Y*cos})
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	minX, maxX, minY, maxY := raw[0].X, raw[0].X, raw[0].Y, raw[0].Y
+	for _, p := range raw[1:] {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	scale := func(v, lo, hi float64) float64 {
+		if hi-lo < 1e-9 {
+			return 0.5
+		}
+		return (v - lo) / (hi - lo)
+	}
+	points := make([]Point, len(raw))
+	for i, p := range raw {
+		points[i] = Point{X: scale(p.X, minX, maxX), Y: 1 - scale(p.Y, minY, maxY)}
+	}
+	return ids, points
+}
+
+func nanoleafModelName(model string) string {
+	switch model {
+	case "NL22":
+		return "Light Panels"
+	case "NL29":
+		return "Canvas"
+	case "NL42", "NL47", "NL48":
+		return "Shapes"
+	case "NL52":
+		return "Elements"
+	case "NL59":
+		return "Lines"
+	}
+	return model
+}
+
+func (c *NanoleafController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"on": map[string]bool{"value": state.On}}
+	if !state.On {
+		return c.do(ctx, http.MethodPut, dev.apiURL+"/state", body, nil)
+	}
+
+	body["brightness"] = map[string]int{"value": max(1, int(math.Round(state.Brightness*100)))}
+	switch {
+	case len(state.Zones) > 0 && len(dev.panelIDs) > 1:
+		// Per-panel colours need a static effect; the state endpoint only
+		// addresses the whole layout.
+		if err := c.do(ctx, http.MethodPut, dev.apiURL+"/state", body, nil); err != nil {
+			return err
+		}
+		return c.do(ctx, http.MethodPut, dev.apiURL+"/effects",
+			nanoleafStaticEffect(dev.panelIDs, state.Zones, state.Transition(0)), nil)
+	case state.Color != nil:
+		body["hue"] = map[string]int{"value": int(math.Round(state.Color.H)) % 360}
+		body["sat"] = map[string]int{"value": int(math.Round(state.Color.S * 100))}
+	case state.Kelvin != nil:
+		body["ct"] = map[string]int{"value": *state.Kelvin}
+	}
+	return c.do(ctx, http.MethodPut, dev.apiURL+"/state", body, nil)
+}
+
+// nanoleafStaticEffect builds a one-frame static effect that paints each
+// panel with its zone. Frame times are in 100 ms units.
+func nanoleafStaticEffect(panelIDs []int, zones []Color, transition time.Duration) map[string]interface{} {
+	t := int(transition / (100 * time.Millisecond))
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d", len(panelIDs))
+	for i, z := range resampleZones(zones, len(panelIDs)) {
+		r, g, bl := HSBToRGB(z.H, z.S, z.B)
+		fmt.Fprintf(&b, " %d 1 %d %d %d 0 %d", panelIDs[i], r, g, bl, t)
+	}
+	return map[string]interface{}{
+		"write": map[string]interface{}{
+			"command":  "display",
+			"animType": "static",
+			"animData": b.String(),
+			"loop":     false,
+			"palette":  []interface{}{},
+		},
+	}
+}
+
+func (c *NanoleafController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return DeviceState{}, err
+	}
+
+	var resp nanoleafStateResponse
+	if err := c.do(ctx, http.MethodGet, dev.apiURL+"/state", nil, &resp); err != nil {
+		return DeviceState{}, err
+	}
+
+	state := DeviceState{
+		On:         resp.On.Value,
+		Brightness: float64(resp.Brightness.Value) / 100,
+	}
+	switch resp.ColorMode {
+	case "ct":
+		k := resp.CT.Value
+		state.Kelvin = &k
+	case "hs":
+		state.Color = &Color{H: float64(resp.Hue.Value), S: float64(resp.Sat.Value) / 100, B: 1}
+	}
+	return state, nil
+}
+
+func (c *NanoleafController) TurnOn(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, true)
+}
+
+func (c *NanoleafController) TurnOff(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, false)
+}
+
+func (c *NanoleafController) setPower(ctx context.Context, deviceID string, on bool) error {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"on": map[string]bool{"value": on}}
+	return c.do(ctx, http.MethodPut, dev.apiURL+"/state", body, nil)
+}
+
+// getDevice returns a snapshot of a paired device. Unlike WLED and Elgato,
+// unknown IDs can't be registered on the fly because they need a token.
+func (c *NanoleafController) getDevice(deviceID string) (nanoleafDevice, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	dev, ok := c.devices[deviceID]
+	if !ok {
+		return nanoleafDevice{}, fmt.Errorf("nanoleaf device %s is not paired", deviceID)
+	}
+	return *dev, nil
+}
+
+func (c *NanoleafController) do(ctx context.Context, method, url string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: HTTP %d", method, url, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *NanoleafController) Close() error {
+	return nil
+}
+
+// ErrNanoleafNotPairing is returned by PairNanoleaf while the device is not
+// in pairing mode (the power button hasn't been held yet).
+var ErrNanoleafNotPairing = errors.New("pairing mode not enabled")
+
+// PairNanoleaf requests an auth token from the device at addr (host:port).
+// It succeeds within 30 seconds of the power button being held for 5–7
+// seconds, and returns ErrNanoleafNotPairing otherwise.
+func PairNanoleaf(ctx context.Context, addr string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/api/v1/new", addr), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return "", ErrNanoleafNotPairing
+	default:
+		return "", fmt.Errorf("unexpected HTTP %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AuthToken string `json:"auth_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.AuthToken == "" {
+		return "", errors.New("empty auth token")
+	}
+	return body.AuthToken, nil
+}
+
+// StartStream switches each device into extControl v2 mode and opens a UDP
+// socket to it. Every frame then addresses all lit panels individually.
+func (c *NanoleafController) StartStream(ctx context.Context, deviceIDs []string) (Stream, error) {
+	s := &nanoleafStream{
+		conns:    make(map[string]net.Conn),
+		panelIDs: make(map[string][]int),
+	}
+
+	var errs []error
+	for _, id := range deviceIDs {
+		dev, err := c.getDevice(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(dev.panelIDs) == 0 {
+			errs = append(errs, fmt.Errorf("%s: panel layout unknown", id))
+			continue
+		}
+		body := map[string]interface{}{
+			"write": map[string]string{
+				"command":           "display",
+				"animType":          "extControl",
+				"extControlVersion": "v2",
+			},
+		}
+		if err := c.do(ctx, http.MethodPut, dev.apiURL+"/effects", body, nil); err != nil {
+			errs = append(errs, fmt.Errorf("%s: enable extControl: %w", id, err))
+			continue
+		}
+		conn, err := net.Dial("udp", dev.udpAddr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		s.conns[id] = conn
+		s.panelIDs[id] = dev.panelIDs
+		s.devices = append(s.devices, id)
+	}
+	if len(s.conns) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no Nanoleaf devices")
+		}
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
+
+// nanoleafStream writes one extControl v2 datagram per device and frame.
+// Panels hold their last colour, so no keepalive is needed.
+type nanoleafStream struct {
+	devices  []string
+	conns    map[string]net.Conn
+	panelIDs map[string][]int
+
+	mu        sync.Mutex
+	closeOnce sync.Once
+}
+
+func (s *nanoleafStream) Devices() []string {
+	return append([]string(nil), s.devices...)
+}
+
+func (s *nanoleafStream) Send(states map[string]DeviceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for id, st := range states {
+		conn, ok := s.conns[id]
+		if !ok {
+			continue
+		}
+		ids := s.panelIDs[id]
+		packet := encodeNanoleafFrame(ids, statePixels(st, len(ids)), st.Transition(0))
+		if _, err := conn.Write(packet); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *nanoleafStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+	})
+	return nil
+}
+
+// encodeNanoleafFrame builds an extControl v2 frame: a big-endian panel
+// count, then per panel its ID, R, G, B, W and a transition time in 100 ms
+// units.
+func encodeNanoleafFrame(panelIDs []int, pixels [][3]uint8, transition time.Duration) []byte {
+	t := uint16(transition / (100 * time.Millisecond))
+	p := make([]byte, 2, 2+8*len(panelIDs))
+	binary.BigEndian.PutUint16(p, uint16(len(panelIDs)))
+	for i, id := range panelIDs {
+		p = binary.BigEndian.AppendUint16(p, uint16(id))
+		p = append(p, pixels[i][0], pixels[i][1], pixels[i][2], 0)
+		p = binary.BigEndian.AppendUint16(p, t)
+	}
+	return p
+}
@@ -0,0 +1,195 @@
+package lights
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const fakeNanoleafToken = "tok"
+
+// fakeNanoleaf serves the OpenAPI for a three-panel Shapes layout with a
+// controller, and listens for extControl frames.
+type fakeNanoleaf struct {
+	mu   sync.Mutex
+	puts map[string][]map[string]interface{}
+
+	http    *httptest.Server
+	udp     net.PacketConn
+	packets chan []byte
+}
+
+func startFakeNanoleaf(t *testing.T) *fakeNanoleaf {
+	t.Helper()
+	f := &fakeNanoleaf{puts: make(map[string][]map[string]interface{}), packets: make(chan []byte, 16)}
+
+	base := "/api/v1/" + fakeNanoleafToken
+	mux := http.NewServeMux()
+	mux.HandleFunc(base+"/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			f.mu.Lock()
+			path := strings.TrimPrefix(r.URL.Path, base)
+			f.puts[path] = append(f.puts[path], body)
+			f.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		switch strings.TrimPrefix(r.URL.Path, base) {
+		case "/":
+			_, _ = w.Write([]byte(`{
+				"name": "Shapes 4A2B", "serialNo": "S123", "model": "NL42", "firmwareVersion": "9.2.4",
+				"state": {"ct": {"value": 4000, "min": 2700, "max": 6500}},
+				"panelLayout": {
+					"layout": {"numPanels": 4, "positionData": [
+						{"panelId": 10, "x": 0, "y": 0, "o": 0, "shapeType": 7},
+						{"panelId": 11, "x": 100, "y": 0, "o": 0, "shapeType": 7},
+						{"panelId": 12, "x": 100, "y": 50, "o": 0, "shapeType": 7},
+						{"panelId": 0, "x": 50, "y": 0, "o": 0, "shapeType": 12}
+					]},
+					"globalOrientation": {"value": 0}
+				}
+			}`))
+		case "/state":
+			_, _ = w.Write([]byte(`{"on":{"value":true},"brightness":{"value":40},"hue":{"value":200},"sat":{"value":50},"ct":{"value":4000},"colorMode":"hs"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	f.http = httptest.NewServer(mux)
+	t.Cleanup(f.http.Close)
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	f.udp = udp
+	t.Cleanup(func() { udp.Close() })
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, _, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			f.packets <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	return f
+}
+
+func (f *fakeNanoleaf) lastPut(path string) map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.puts[path]) == 0 {
+		return nil
+	}
+	return f.puts[path][len(f.puts[path])-1]
+}
+
+func newTestNanoleafController(t *testing.T, f *fakeNanoleaf) (*NanoleafController, string) {
+	t.Helper()
+	c := NewNanoleafController()
+	id := "nanoleaf:127.0.0.1"
+	c.addDevice(id, f.http.URL+"/api/v1/"+fakeNanoleafToken, f.udp.LocalAddr().String())
+	devices, err := c.Discover(context.Background())
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Discover: %v (%d devices)", err, len(devices))
+	}
+	return c, id
+}
+
+func TestNanoleaf_DiscoverLayout(t *testing.T) {
+	f := startFakeNanoleaf(t)
+	c, id := newTestNanoleafController(t, f)
+
+	if caps := c.Capabilities(id); caps.Segments != 3 || caps.MinKelvin != 2700 || caps.MaxKelvin != 6500 {
+		t.Fatalf("expected 3 lit panels and the reported 2700–6500K, got %+v", caps)
+	}
+	points := c.Layout(id)
+	want := []Point{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 0}}
+	if len(points) != len(want) {
+		t.Fatalf("expected %d points, got %+v", len(want), points)
+	}
+	for i, p := range want {
+		if points[i] != p {
+			t.Fatalf("panel %d: expected %+v, got %+v", i, p, points[i])
+		}
+	}
+}
+
+func TestNanoleaf_SetAndGetState(t *testing.T) {
+	f := startFakeNanoleaf(t)
+	c, id := newTestNanoleafController(t, f)
+	ctx := context.Background()
+
+	err := c.SetState(ctx, id, DeviceState{On: true, Brightness: 0.5, Color: &Color{H: 120, S: 0.8, B: 1}})
+	if err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	body := f.lastPut("/state")
+	if body["brightness"].(map[string]interface{})["value"] != float64(50) ||
+		body["hue"].(map[string]interface{})["value"] != float64(120) ||
+		body["sat"].(map[string]interface{})["value"] != float64(80) {
+		t.Fatalf("unexpected state body: %v", body)
+	}
+
+	err = c.SetState(ctx, id, DeviceState{On: true, Brightness: 1, Zones: []Color{{H: 0, S: 1, B: 1}}})
+	if err != nil {
+		t.Fatalf("SetState zones: %v", err)
+	}
+	write := f.lastPut("/effects")["write"].(map[string]interface{})
+	if write["animType"] != "static" || write["animData"] != "3 10 1 255 0 0 0 0 11 1 255 0 0 0 0 12 1 255 0 0 0 0" {
+		t.Fatalf("unexpected static effect: %v", write)
+	}
+
+	st, err := c.GetState(ctx, id)
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if !st.On || st.Brightness != 0.4 || st.Color == nil || st.Color.H != 200 || st.Color.S != 0.5 {
+		t.Fatalf("unexpected read-back: %+v", st)
+	}
+}
+
+func TestNanoleaf_ExtControlStream(t *testing.T) {
+	f := startFakeNanoleaf(t)
+	c, id := newTestNanoleafController(t, f)
+
+	stream, err := c.StartStream(context.Background(), []string{id})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	defer stream.Close()
+	if write := f.lastPut("/effects")["write"].(map[string]interface{}); write["extControlVersion"] != "v2" {
+		t.Fatalf("expected extControl v2, got %v", write)
+	}
+
+	_ = stream.Send(map[string]DeviceState{
+		id: {On: true, Brightness: 1, Zones: []Color{{H: 0, S: 1, B: 1}, {H: 120, S: 1, B: 1}, {H: 240, S: 1, B: 1}}},
+	})
+
+	var p []byte
+	select {
+	case p = <-f.packets:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no extControl frame received")
+	}
+	if len(p) != 2+3*8 || binary.BigEndian.Uint16(p) != 3 {
+		t.Fatalf("unexpected frame header/length: %v", p)
+	}
+	if binary.BigEndian.Uint16(p[2:]) != 10 || p[4] != 255 || binary.BigEndian.Uint16(p[10:]) != 11 || p[13] != 255 {
+		t.Fatalf("expected panel 10 red and panel 11 green, got %v", p)
+	}
+	if binary.BigEndian.Uint16(p[18:]) != 12 || p[22] != 255 {
+		t.Fatalf("expected panel 12 blue, got %v", p[18:])
+	}
+}
@@ -0,0 +1,350 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errSuperseded is returned for a command that a newer command for the same
+// device replaced before it was sent. The newer command carries the state
+// forward, so callers treat it as success.
+var errSuperseded = errors.New("superseded by a newer command")
+
+// CommandMetrics counts what happened to the commands queued for a device.
+type CommandMetrics struct {
+	// Sent commands reached the controller, whether or not they succeeded.
+	Sent uint64 `json:"sent"`
+	// Coalesced commands were replaced by a newer one while waiting.
+	Coalesced uint64 `json:"coalesced"`
+	// Dropped commands expired (their context ended) while waiting.
+	Dropped uint64 `json:"dropped"`
+}
+
+// deviceQueue holds at most one pending command for a device: a newer
+// command replaces it, so a slow device always receives the latest state
+// rather than a backlog.
+type deviceQueue struct {
+	pending  *outboundCommand
+	draining bool
+	metrics  CommandMetrics
+}
+
+type outboundCommand struct {
+	ctx   context.Context
+	apply func(context.Context) error
+	done  chan error
+
+	// batch is set for a state bound for a BatchSetter, which goes out in
+	// the controller's next batch instead of through apply.
+	batch    *batchQueue
+	deviceID string
+	state    DeviceState
+}
+
+// rateBudget spaces commands drawn from one RateBudgeter key.
+type rateBudget struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// reserve claims the next free slot and returns how long to wait for it.
+func (b *rateBudget) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	wait := b.next.Sub(now)
+	b.next = b.next.Add(b.interval)
+	return wait
+}
+
+// budgetFor returns the rate budget deviceID draws from, or nil when its
+// controller declares none.
+func (m *Manager) budgetFor(ctrl Controller, deviceID string) *rateBudget {
+	rb, ok := ctrl.(RateBudgeter)
+	if !ok {
+		return nil
+	}
+	key, rate := rb.RateBudget(deviceID)
+	if rate <= 0 {
+		return nil
+	}
+	key = string(ctrl.Brand()) + "/" + key
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	b, ok := m.budgets[key]
+	if !ok {
+		b = &rateBudget{}
+		m.budgets[key] = b
+	}
+	b.mu.Lock()
+	b.interval = time.Duration(float64(time.Second) / rate)
+	b.mu.Unlock()
+	return b
+}
+
+// send queues apply as deviceID's next command and waits until it has been
+// sent, replaced by a newer command (errSuperseded) or ctx ends. Commands
+// for one device never overlap, and devices with a rate budget are spaced
+// to stay within it.
+func (m *Manager) send(ctx context.Context, ctrl Controller, deviceID string, apply func(context.Context) error) error {
+	budget := m.budgetFor(ctrl, deviceID)
+	cmd := &outboundCommand{ctx: ctx, apply: apply, done: make(chan error, 1)}
+
+	m.queueMu.Lock()
+	q := m.queue(deviceID)
+	if q.pending != nil {
+		q.pending.done <- errSuperseded
+		q.metrics.Coalesced++
+	}
+	q.pending = cmd
+	if !q.draining {
+		q.draining = true
+		go m.drain(q, budget)
+	}
+	m.queueMu.Unlock()
+
+	select {
+	case err := <-cmd.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain sends q's pending command until none is left, waiting for a slot
+// in budget before each one and taking whichever command is newest once
+// the slot arrives.
+func (m *Manager) drain(q *deviceQueue, budget *rateBudget) {
+	for {
+		m.queueMu.Lock()
+		if q.pending == nil {
+			q.draining = false
+			m.queueMu.Unlock()
+			return
+		}
+		m.queueMu.Unlock()
+
+		if budget != nil {
+			time.Sleep(budget.reserve())
+		}
+
+		m.queueMu.Lock()
+		cmd := q.pending
+		q.pending = nil
+		err := cmd.ctx.Err()
+		if err == nil && cmd.batch != nil {
+			// The device stays claimed until its batch releases it.
+			m.joinBatch(cmd)
+			m.queueMu.Unlock()
+			return
+		}
+		if err != nil {
+			q.metrics.Dropped++
+		} else {
+			q.metrics.Sent++
+		}
+		m.queueMu.Unlock()
+
+		if err == nil {
+			err = cmd.apply(cmd.ctx)
+		}
+		cmd.done <- err
+	}
+}
+
+// queue returns deviceID's queue, creating it. m.queueMu must be held.
+func (m *Manager) queue(deviceID string) *deviceQueue {
+	q, ok := m.queues[deviceID]
+	if !ok {
+		q = &deviceQueue{}
+		m.queues[deviceID] = q
+	}
+	return q
+}
+
+// batchQueue gathers the devices waiting for a BatchSetter while its
+// previous batch is in flight. They go out together in one call, so a
+// controller never has two batches in flight and a slow one receives the
+// latest frame rather than a backlog.
+//
+// A batched device stays claimed in its deviceQueue from the moment it
+// joins a batch until that batch returns, so it never has a batch and a
+// per-device command in flight at once. Commands queued for it meanwhile
+// wait in its deviceQueue, newest wins, and follow in order.
+type batchQueue struct {
+	bs       BatchSetter
+	pending  map[string]*outboundCommand
+	draining bool
+}
+
+// sendBatch queues states for bs and waits until they have been sent, and
+// returns every device's result: errSuperseded for a state a newer command
+// replaced before it was sent, or ctx's error once ctx ends.
+func (m *Manager) sendBatch(ctx context.Context, ctrl Controller, bs BatchSetter, states map[string]DeviceState) map[string]error {
+	cmds := make(map[string]*outboundCommand, len(states))
+
+	m.queueMu.Lock()
+	bq, ok := m.batches[ctrl]
+	if !ok {
+		bq = &batchQueue{bs: bs, pending: make(map[string]*outboundCommand)}
+		m.batches[ctrl] = bq
+	}
+	for id, state := range states {
+		cmd := &outboundCommand{ctx: ctx, done: make(chan error, 1), batch: bq, deviceID: id, state: state}
+		cmds[id] = cmd
+		q := m.queue(id)
+		if q.pending != nil {
+			q.pending.done <- errSuperseded
+			q.metrics.Coalesced++
+			q.pending = nil
+		}
+		if prev, ok := bq.pending[id]; ok {
+			// Waiting for the next batch: take its place.
+			prev.done <- errSuperseded
+			q.metrics.Coalesced++
+			bq.pending[id] = cmd
+			continue
+		}
+		if q.draining {
+			// Busy with a command of its own or an earlier batch.
+			q.pending = cmd
+			continue
+		}
+		q.draining = true
+		m.joinBatch(cmd)
+	}
+	m.queueMu.Unlock()
+
+	results := make(map[string]error, len(cmds))
+	for id, cmd := range cmds {
+		select {
+		case err := <-cmd.done:
+			results[id] = err
+		case <-ctx.Done():
+			results[id] = ctx.Err()
+		}
+	}
+	return results
+}
+
+// joinBatch adds cmd, whose device is already claimed, to its controller's
+// next batch. m.queueMu must be held.
+func (m *Manager) joinBatch(cmd *outboundCommand) {
+	bq := cmd.batch
+	bq.pending[cmd.deviceID] = cmd
+	if !bq.draining {
+		bq.draining = true
+		go m.drainBatches(bq)
+	}
+}
+
+// release frees deviceID once its batch has returned, handing it its next
+// queued command. m.queueMu must be held.
+func (m *Manager) release(deviceID string) {
+	q := m.queue(deviceID)
+	switch {
+	case q.pending == nil:
+		q.draining = false
+	case q.pending.batch != nil:
+		cmd := q.pending
+		q.pending = nil
+		m.joinBatch(cmd)
+	default:
+		// Batched devices declare no rate budget.
+		go m.drain(q, nil)
+	}
+}
+
+// drainBatches sends bq's waiting devices until none is left. The batch
+// runs until every caller whose state is in it has given up.
+func (m *Manager) drainBatches(bq *batchQueue) {
+	for {
+		m.queueMu.Lock()
+		if len(bq.pending) == 0 {
+			bq.draining = false
+			m.queueMu.Unlock()
+			return
+		}
+		batch := bq.pending
+		bq.pending = make(map[string]*outboundCommand)
+
+		states := make(map[string]DeviceState, len(batch))
+		var ctxs []context.Context
+		for id, cmd := range batch {
+			if err := cmd.ctx.Err(); err != nil {
+				cmd.done <- err
+				m.queue(id).metrics.Dropped++
+				delete(batch, id)
+				m.release(id)
+				continue
+			}
+			m.queue(id).metrics.Sent++
+			states[id] = cmd.state
+			ctxs = append(ctxs, cmd.ctx)
+		}
+		m.queueMu.Unlock()
+
+		if len(states) == 0 {
+			continue
+		}
+		ctx, cancel := jointContext(ctxs)
+		err := bq.bs.SetStates(ctx, states)
+		cancel()
+
+		m.queueMu.Lock()
+		for id, cmd := range batch {
+			cmd.done <- err
+			m.release(id)
+		}
+		m.queueMu.Unlock()
+	}
+}
+
+// jointContext returns a context that carries the values of ctxs[0] and
+// ends only once every one of ctxs has ended.
+func jointContext(ctxs []context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctxs[0]))
+	var mu sync.Mutex
+	left := len(ctxs)
+	stops := make([]func() bool, len(ctxs))
+	for i, c := range ctxs {
+		stops[i] = context.AfterFunc(c, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if left--; left == 0 {
+				cancel()
+			}
+		})
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
+
+// setState sends state to deviceID through its queue.
+func (m *Manager) setState(ctx context.Context, ctrl Controller, deviceID string, state DeviceState) error {
+	return m.send(ctx, ctrl, deviceID, func(ctx context.Context) error {
+		return ctrl.SetState(ctx, deviceID, state)
+	})
+}
+
+// CommandMetrics returns the command counters of every device that has been
+// sent a command.
+func (m *Manager) CommandMetrics() map[string]CommandMetrics {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	out := make(map[string]CommandMetrics, len(m.queues))
+	for id, q := range m.queues {
+		out[id] = q.metrics
+	}
+	return out
+}
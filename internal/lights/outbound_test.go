@@ -0,0 +1,104 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// budgetedVirtual is a virtual controller whose devices share one rate
+// budget, like lights on a Hue bridge.
+type budgetedVirtual struct {
+	*VirtualController
+	rate float64
+}
+
+func (c *budgetedVirtual) RateBudget(string) (string, float64) {
+	return "bridge", c.rate
+}
+
+func newBudgetedManager(rate float64, ids ...string) *Manager {
+	c := &budgetedVirtual{VirtualController: NewVirtualController(), rate: rate}
+	devices := make([]VirtualDevice, len(ids))
+	for i, id := range ids {
+		devices[i] = VirtualDevice{ID: id, Capabilities: Capabilities{Color: true, Segments: 1}}
+	}
+	c.SetDevices(devices)
+	m := NewManager()
+	m.RegisterController(c)
+	return m
+}
+
+func colorState(h float64) DeviceState {
+	return DeviceState{On: true, Brightness: 1, Color: &Color{H: h, S: 1, B: 1}}.WithTransition(0)
+}
+
+func TestOutbound_LatestWins(t *testing.T) {
+	m := newBudgetedManager(10, "a")
+	ctx := context.Background()
+
+	if err := m.SetDeviceState(ctx, "virtual:a", colorState(10)); err != nil {
+		t.Fatalf("first command: %v", err)
+	}
+	// The next three wait for the budget; only the last is sent.
+	var wg sync.WaitGroup
+	for i := 2; i <= 4; i++ {
+		wg.Add(1)
+		go func(h float64) {
+			defer wg.Done()
+			if err := m.SetDeviceState(ctx, "virtual:a", colorState(h)); err != nil {
+				t.Errorf("superseded commands must succeed: %v", err)
+			}
+		}(float64(i * 10))
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	hist := m.CommandHistory("virtual:a")
+	if len(hist) != 2 || hist[1].State.Color.H != 40 {
+		t.Fatalf("expected the first and the newest command, got %+v", hist)
+	}
+	if gap := hist[1].At.Sub(hist[0].At); gap < 90*time.Millisecond {
+		t.Fatalf("expected commands spaced by the budget, got %v", gap)
+	}
+	if st, _ := m.CachedState("virtual:a"); st.Color.H != 40 {
+		t.Fatalf("expected the cache to hold the newest state, got %+v", st.Color)
+	}
+	if got := m.CommandMetrics()["virtual:a"]; got != (CommandMetrics{Sent: 2, Coalesced: 2}) {
+		t.Fatalf("unexpected metrics %+v", got)
+	}
+}
+
+func TestOutbound_SharedBudgetAndExpiry(t *testing.T) {
+	m := newBudgetedManager(20, "a", "b")
+	ctx := context.Background()
+
+	if err := m.SetDeviceStates(ctx, map[string]DeviceState{"virtual:a": colorState(0), "virtual:b": colorState(0)}); err != nil {
+		t.Fatalf("SetDeviceStates: %v", err)
+	}
+	hist := m.CommandHistory("")
+	if len(hist) != 2 {
+		t.Fatalf("expected both devices updated, got %+v", hist)
+	}
+	if gap := hist[1].At.Sub(hist[0].At); gap < 40*time.Millisecond {
+		t.Fatalf("expected devices on one budget to be spaced, got %v", gap)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := m.SetDeviceState(short, "virtual:a", colorState(90)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to time out, got %v", err)
+	}
+	// The expired command is dropped when its slot comes up.
+	if err := m.SetDeviceState(ctx, "virtual:b", colorState(90)); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	if got := m.CommandMetrics()["virtual:a"]; got.Dropped != 1 || got.Sent != 1 {
+		t.Fatalf("unexpected metrics %+v", got)
+	}
+	if hist := m.CommandHistory("virtual:a"); len(hist) != 1 {
+		t.Fatalf("expired command was sent: %+v", hist)
+	}
+}
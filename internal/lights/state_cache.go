@@ -0,0 +1,158 @@
+package lights
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// statePollGrace is how long after a command reads are not trusted to
+// update the cache: slow devices can still report the previous state.
+const statePollGrace = 2 * time.Second
+
+// StateSource says what caused a device state change.
+type StateSource string
+
+const (
+	SourceUser       StateSource = "user"
+	SourceScene      StateSource = "scene"
+	SourceScreenSync StateSource = "screensync"
+	// SourceExternal covers changes made outside LightSync, seen through
+	// controller push events or when a read differs from the cached state.
+	SourceExternal StateSource = "external"
+)
+
+// cachedState is a device's last known state and when LightSync last sent
+// it a command.
+type cachedState struct {
+	state     DeviceState
+	commanded time.Time
+}
+
+type sourceKey struct{}
+
+// WithSource marks commands sent with ctx as coming from source. Commands
+// without a source are attributed to the user.
+func WithSource(ctx context.Context, source StateSource) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+func sourceFrom(ctx context.Context) StateSource {
+	if s, ok := ctx.Value(sourceKey{}).(StateSource); ok {
+		return s
+	}
+	return SourceUser
+}
+
+// DeviceStateChange is delivered to Subscribe handlers when a device's
+// last known state changes.
+type DeviceStateChange struct {
+	DeviceID string       `json:"deviceId"`
+	State    DeviceState  `json:"state"`
+	Previous *DeviceState `json:"previous,omitempty"` // nil when nothing was known
+	Source   StateSource  `json:"source"`
+}
+
+// Subscribe registers fn to be called for every state change, on the
+// goroutine that caused it; handlers must not block. Screen sync reports a
+// change per device per frame. The returned function removes fn.
+func (m *Manager) Subscribe(fn func(DeviceStateChange)) (unsubscribe func()) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.subSeq++
+	id := m.subSeq
+	m.subscribers[id] = fn
+	return func() {
+		m.stateMu.Lock()
+		defer m.stateMu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// CachedState returns a device's last known state without asking the
+// device.
+func (m *Manager) CachedState(deviceID string) (DeviceState, bool) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	cs, ok := m.states[deviceID]
+	return cs.state.clone(), ok
+}
+
+// recordState updates the cache and tells subscribers when the state differs
+// from what was known. Anything other than SourceExternal is a command.
+func (m *Manager) recordState(deviceID string, state DeviceState, source StateSource) {
+	state = state.clone()
+	state.TransitionMs = nil
+
+	m.stateMu.Lock()
+	cs, known := m.states[deviceID]
+	prev := cs.state
+	if source != SourceExternal {
+		cs.commanded = time.Now()
+	}
+	cs.state = state
+	m.states[deviceID] = cs
+	if known && sameState(prev, state) {
+		m.stateMu.Unlock()
+		return
+	}
+	subs := make([]func(DeviceStateChange), 0, len(m.subscribers))
+	for _, fn := range m.subscribers {
+		subs = append(subs, fn)
+	}
+	m.stateMu.Unlock()
+
+	if len(subs) == 0 {
+		return
+	}
+	change := DeviceStateChange{DeviceID: deviceID, State: state, Source: source}
+	if known {
+		change.Previous = &prev
+	}
+	for _, fn := range subs {
+		fn(change)
+	}
+}
+
+// recordRead records a state read from or pushed by the device, unless a
+// command was sent too recently for it to be anything but that command's
+// echo or the state before it.
+func (m *Manager) recordRead(deviceID string, state DeviceState) {
+	m.stateMu.Lock()
+	cs, ok := m.states[deviceID]
+	m.stateMu.Unlock()
+	if ok && time.Since(cs.commanded) < m.pollGrace {
+		return
+	}
+	m.recordState(deviceID, state, SourceExternal)
+}
+
+// recordPower applies a TurnOn or TurnOff to the cached state. Nothing is
+// recorded for devices without one, as the rest of the state is unknown.
+func (m *Manager) recordPower(deviceID string, on bool, source StateSource) {
+	state, ok := m.CachedState(deviceID)
+	if !ok {
+		return
+	}
+	state.On = on
+	m.recordState(deviceID, state, source)
+}
+
+func (m *Manager) forgetState(deviceID string) {
+	m.stateMu.Lock()
+	delete(m.states, deviceID)
+	m.stateMu.Unlock()
+}
+
+func sameState(a, b DeviceState) bool {
+	return a.On == b.On && a.Brightness == b.Brightness &&
+		equalPtr(a.Color, b.Color) && equalPtr(a.Kelvin, b.Kelvin) &&
+		slices.Equal(a.Zones, b.Zones)
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,135 @@
+package lights
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// changeLog collects the changes delivered to a subscriber.
+type changeLog struct {
+	mu      sync.Mutex
+	changes []DeviceStateChange
+}
+
+func (l *changeLog) add(ch DeviceStateChange) {
+	l.mu.Lock()
+	l.changes = append(l.changes, ch)
+	l.mu.Unlock()
+}
+
+func (l *changeLog) take() []DeviceStateChange {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := l.changes
+	l.changes = nil
+	return out
+}
+
+func TestStateCache_CommandsAndSources(t *testing.T) {
+	m, _ := newTestVirtualManager(VirtualDevice{ID: "a"}, VirtualDevice{ID: "b"})
+	var log changeLog
+	unsubscribe := m.Subscribe(log.add)
+	ctx := context.Background()
+
+	scene := DeviceState{On: true, Brightness: 0.4, Color: &Color{H: 30, S: 1, B: 1}}.WithTransition(0)
+	if err := m.SetDeviceStates(WithSource(ctx, SourceScene), map[string]DeviceState{"virtual:a": scene, "virtual:b": scene}); err != nil {
+		t.Fatalf("SetDeviceStates: %v", err)
+	}
+	changes := log.take()
+	if len(changes) != 2 || changes[0].Source != SourceScene || changes[0].Previous != nil {
+		t.Fatalf("expected two scene changes, got %+v", changes)
+	}
+	st, ok := m.CachedState("virtual:a")
+	if !ok || st.Brightness != 0.4 || st.Color.H != 30 || st.TransitionMs != nil {
+		t.Fatalf("unexpected cached state %+v", st)
+	}
+
+	// Repeating a state is not a change.
+	if err := m.SetDeviceState(ctx, "virtual:a", scene); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	if changes := log.take(); len(changes) != 0 {
+		t.Fatalf("expected no change, got %+v", changes)
+	}
+
+	if err := m.TurnOff(ctx, "virtual:a"); err != nil {
+		t.Fatalf("TurnOff: %v", err)
+	}
+	changes = log.take()
+	if len(changes) != 1 || changes[0].Source != SourceUser || changes[0].State.On || !changes[0].Previous.On || changes[0].State.Color == nil {
+		t.Fatalf("expected a user power-off change, got %+v", changes)
+	}
+
+	unsubscribe()
+	if err := m.TurnOn(ctx, "virtual:a"); err != nil {
+		t.Fatalf("TurnOn: %v", err)
+	}
+	if changes := log.take(); len(changes) != 0 {
+		t.Fatalf("expected no delivery after unsubscribing, got %+v", changes)
+	}
+}
+
+func TestStateCache_ReadsReportExternalChanges(t *testing.T) {
+	m, c := newTestVirtualManager(VirtualDevice{ID: "a"})
+	m.pollGrace = 50 * time.Millisecond
+	var log changeLog
+	m.Subscribe(log.add)
+	ctx := context.Background()
+
+	if err := m.SetDeviceState(ctx, "virtual:a", DeviceState{On: true, Brightness: 1}); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	log.take()
+
+	// Someone else dims the light. A read straight after our command isn't
+	// trusted; a later one is.
+	if err := c.SetState(ctx, "virtual:a", DeviceState{On: true, Brightness: 0.2}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	if _, err := m.GetDeviceState(ctx, "virtual:a"); err != nil {
+		t.Fatalf("GetDeviceState: %v", err)
+	}
+	if st, _ := m.CachedState("virtual:a"); st.Brightness != 1 {
+		t.Fatalf("a read inside the grace period must not update the cache, got %+v", st)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := m.GetDeviceState(ctx, "virtual:a"); err != nil {
+		t.Fatalf("GetDeviceState: %v", err)
+	}
+	changes := log.take()
+	if len(changes) != 1 || changes[0].Source != SourceExternal || changes[0].State.Brightness != 0.2 {
+		t.Fatalf("expected an external change, got %+v", changes)
+	}
+
+	// Push events long enough after a command are trusted.
+	m.handleEvent(DeviceEvent{Type: DeviceEventState, DeviceID: "virtual:a", State: &DeviceState{On: false, Brightness: 0.2}})
+	if changes := log.take(); len(changes) != 1 || changes[0].Source != SourceExternal || changes[0].State.On {
+		t.Fatalf("expected the pushed change, got %+v", changes)
+	}
+}
+
+func TestStateCache_PushedEchoIsNotExternal(t *testing.T) {
+	m, _ := newTestVirtualManager(VirtualDevice{ID: "a"})
+	var log changeLog
+	m.Subscribe(log.add)
+	ctx := context.Background()
+
+	sent := DeviceState{On: true, Brightness: 0.5, Color: &Color{H: 30, S: 1, B: 1}}
+	if err := m.SetDeviceState(ctx, "virtual:a", sent.WithTransition(0)); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	log.take()
+
+	// The bridge reports the command back after an xy round trip, which
+	// never reproduces the floats exactly.
+	echo := DeviceState{On: true, Brightness: 0.498, Color: &Color{H: 30.2, S: 0.99, B: 1}}
+	m.handleEvent(DeviceEvent{Type: DeviceEventState, DeviceID: "virtual:a", State: &echo})
+	if changes := log.take(); len(changes) != 0 {
+		t.Fatalf("expected the echo to be ignored, got %+v", changes)
+	}
+	if st, _ := m.CachedState("virtual:a"); st.Brightness != 0.5 || st.Color.H != 30 {
+		t.Fatalf("expected the commanded state to stay cached, got %+v", st)
+	}
+}
@@ -0,0 +1,115 @@
+package lights
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// StreamSet is the collection of streams opened for one screen sync session.
+// A nil *StreamSet covers no devices.
+type StreamSet struct {
+	m       *Manager
+	mu      sync.Mutex
+	streams []Stream
+	// byDevice indexes streams. Streams aren't used as map keys: one
+	// backed by a slice, like a Hue stream spanning bridges, isn't hashable.
+	byDevice map[string]int
+}
+
+// StartStreams asks every controller implementing Streamer to open a stream
+// for its share of deviceIDs. Controllers that fail are logged and skipped;
+// their devices stay on the regular SetDeviceState path.
+func (m *Manager) StartStreams(ctx context.Context, deviceIDs []string) *StreamSet {
+	byBrand := make(map[Brand][]string)
+	for _, id := range deviceIDs {
+		b := brandFromDeviceID(id)
+		byBrand[b] = append(byBrand[b], id)
+	}
+
+	set := &StreamSet{m: m, byDevice: make(map[string]int)}
+	for brand, ids := range byBrand {
+		ctrl, ok := m.GetController(brand)
+		if !ok {
+			continue
+		}
+		streamer, ok := ctrl.(Streamer)
+		if !ok {
+			continue
+		}
+		s, err := streamer.StartStream(ctx, ids)
+		if err != nil {
+			log.Printf("[manager] %s stream unavailable, using regular commands: %v", brand, err)
+			continue
+		}
+		for _, id := range s.Devices() {
+			set.byDevice[id] = len(set.streams)
+		}
+		set.streams = append(set.streams, s)
+		log.Printf("[manager] %s stream open for %d device(s)", brand, len(s.Devices()))
+	}
+	return set
+}
+
+// Covers reports whether deviceID is driven by one of the streams.
+func (s *StreamSet) Covers(deviceID string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.byDevice[deviceID]
+	return ok
+}
+
+// Send routes each state to the stream covering its device, with the
+// device's calibration applied. States for uncovered devices are ignored.
+// Delivered frames are recorded in the Manager's state cache as screen sync
+// changes.
+func (s *StreamSet) Send(states map[string]DeviceState) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	perStream := make(map[int]map[string]DeviceState)
+	for id, st := range states {
+		i, ok := s.byDevice[id]
+		if !ok {
+			continue
+		}
+		if perStream[i] == nil {
+			perStream[i] = make(map[string]DeviceState)
+		}
+		perStream[i][id] = s.m.calibrate(id, st)
+	}
+	streams := s.streams
+	s.mu.Unlock()
+
+	for i, batch := range perStream {
+		if err := streams[i].Send(batch); err != nil {
+			log.Printf("[manager] Stream send failed: %v", err)
+			continue
+		}
+		for id := range batch {
+			s.m.recordState(id, states[id], SourceScreenSync)
+		}
+	}
+}
+
+// Close ends every stream in the set.
+func (s *StreamSet) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	streams := s.streams
+	s.streams = nil
+	s.byDevice = map[string]int{}
+	s.mu.Unlock()
+
+	for _, stream := range streams {
+		if err := stream.Close(); err != nil {
+			log.Printf("[manager] Stream close failed: %v", err)
+		}
+	}
+}
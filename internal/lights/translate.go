@@ -0,0 +1,109 @@
+package lights
+
+import "math"
+
+// Translate returns state expressed in what the device can show. On
+// white-only devices a colour becomes its nearest colour temperature, and
+// brightness is scaled by the colour's perceived lightness so a deep blue
+// frame doesn't light a Key Light at full power. On colour-only devices a
+// colour temperature becomes its black-body colour. Devices with neither
+// keep brightness alone. Manager applies it before Clamp.
+func (c Capabilities) Translate(state DeviceState) DeviceState {
+	if !c.Color && (state.Color != nil || len(state.Zones) > 0) {
+		col := averageColor(state.Zones)
+		if state.Color != nil {
+			col = *state.Color
+		}
+		state.Brightness *= colorLightness(col)
+		state.Color = nil
+		state.Zones = nil
+		if c.Kelvin {
+			k := colorToKelvin(col)
+			state.Kelvin = &k
+		}
+	}
+	if state.Kelvin != nil && !c.Kelvin {
+		if c.Color && state.Color == nil && len(state.Zones) == 0 {
+			r, g, b := KelvinToRGB(*state.Kelvin)
+			h, s, _ := RGBToHSB(r, g, b)
+			state.Color = &Color{H: h, S: s, B: 1}
+		}
+		state.Kelvin = nil
+	}
+	return state
+}
+
+// colorToKelvin returns the temperature on the black-body curve nearest to
+// col in CIE 1960 uv, the usual definition of correlated colour
+// temperature. Colours far from white land at the nearest end: reds warm,
+// blues cool.
+func colorToKelvin(col Color) int {
+	r, g, b := hsbToRGBFloat(col.H, col.S, 1)
+	uv, ok := rgbToUV(r, g, b)
+	if !ok {
+		return DefaultKelvin
+	}
+	best, bestDist := DefaultKelvin, math.Inf(1)
+	for _, p := range planckianLocus {
+		if d := math.Hypot(p.uv[0]-uv[0], p.uv[1]-uv[1]); d < bestDist {
+			best, bestDist = p.kelvin, d
+		}
+	}
+	return best
+}
+
+type locusPoint struct {
+	kelvin int
+	uv     [2]float64
+}
+
+// planckianLocus samples KelvinToRGB every 100K from 1000K to 20000K.
+var planckianLocus = func() []locusPoint {
+	var pts []locusPoint
+	for k := 1000; k <= 20000; k += 100 {
+		r, g, b := KelvinToRGB(k)
+		if uv, ok := rgbToUV(float64(r)/255, float64(g)/255, float64(b)/255); ok {
+			pts = append(pts, locusPoint{kelvin: k, uv: uv})
+		}
+	}
+	return pts
+}()
+
+// rgbToUV converts sRGB (0–1) to CIE 1960 uv chromaticity.
+func rgbToUV(r, g, b float64) ([2]float64, bool) {
+	r, g, b = gammaCorrect(r), gammaCorrect(g), gammaCorrect(b)
+	x := 0.4124*r + 0.3576*g + 0.1805*b
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := 0.0193*r + 0.1192*g + 0.9505*b
+	d := x + 15*y + 3*z
+	if d == 0 {
+		return [2]float64{}, false
+	}
+	return [2]float64{4 * x / d, 6 * y / d}, true
+}
+
+// colorLightness is col's perceived lightness (0–1) relative to white at
+// full brightness.
+func colorLightness(col Color) float64 {
+	if col.S <= 0 {
+		return col.B
+	}
+	r, g, b := hsbToRGBFloat(col.H, col.S, 1)
+	y := 0.2126*gammaCorrect(r) + 0.7152*gammaCorrect(g) + 0.0722*gammaCorrect(b)
+	return col.B * gammaEncode(y)
+}
+
+// averageColor mixes zones in RGB, for devices that can show only one.
+func averageColor(zones []Color) Color {
+	if len(zones) == 0 {
+		return Color{H: 0, S: 0, B: 1}
+	}
+	var r, g, b float64
+	for _, z := range zones {
+		zr, zg, zb := hsbToRGBFloat(z.H, z.S, z.B)
+		r, g, b = r+zr, g+zg, b+zb
+	}
+	n := float64(len(zones))
+	h, s, v := rgbFloatToHSB(r/n, g/n, b/n)
+	return Color{H: h, S: s, B: v}
+}
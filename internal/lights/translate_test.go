@@ -0,0 +1,67 @@
+package lights
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestTranslate_ColorOnWhiteOnly(t *testing.T) {
+	caps := elgatoCapabilities
+
+	white := caps.Translate(DeviceState{On: true, Brightness: 0.8, Color: &Color{H: 0, S: 0, B: 1}})
+	if white.Color != nil || white.Kelvin == nil || math.Abs(float64(*white.Kelvin)-6500) > 200 || white.Brightness != 0.8 {
+		t.Fatalf("expected white to become ~6500K at full brightness, got %+v (kelvin %v)", white, white.Kelvin)
+	}
+	orange := caps.Translate(DeviceState{On: true, Brightness: 1, Color: &Color{H: 30, S: 1, B: 1}})
+	if orange.Kelvin == nil || *orange.Kelvin > 3500 {
+		t.Fatalf("expected orange to become a warm white, got %v", orange.Kelvin)
+	}
+	blue := caps.Translate(DeviceState{On: true, Brightness: 1, Color: &Color{H: 240, S: 1, B: 1}})
+	if blue.Kelvin == nil || *blue.Kelvin < 9000 || blue.Brightness > 0.4 {
+		t.Fatalf("expected blue to become a dim cool white, got %+v (kelvin %v)", blue, blue.Kelvin)
+	}
+	if got := caps.Clamp(blue); *got.Kelvin != 7000 {
+		t.Fatalf("expected Clamp to bring it into the device range, got %d", *got.Kelvin)
+	}
+
+	zones := caps.Translate(DeviceState{On: true, Brightness: 1, Zones: []Color{{H: 0, S: 1, B: 1}, {H: 0, S: 0, B: 1}}})
+	if zones.Zones != nil || zones.Kelvin == nil || *zones.Kelvin > 6000 {
+		t.Fatalf("expected zones to collapse to a warm white, got %+v", zones)
+	}
+
+	dimmable := Capabilities{Segments: 1}.Translate(DeviceState{On: true, Brightness: 1, Color: &Color{H: 120, S: 1, B: 0.5}})
+	if dimmable.Color != nil || dimmable.Kelvin != nil || dimmable.Brightness <= 0 || dimmable.Brightness >= 0.5 {
+		t.Fatalf("expected brightness alone on a dimmable light, got %+v", dimmable)
+	}
+}
+
+func TestTranslate_KelvinOnColorOnly(t *testing.T) {
+	caps := Capabilities{Color: true, Segments: 1}
+	k := 2700
+	st := caps.Translate(DeviceState{On: true, Brightness: 0.6, Kelvin: &k})
+	if st.Kelvin != nil || st.Color == nil || st.Color.H < 20 || st.Color.H > 40 || st.Brightness != 0.6 {
+		t.Fatalf("expected 2700K to become a warm orange, got %+v (color %+v)", st, st.Color)
+	}
+	full := Capabilities{Color: true, Kelvin: true}
+	if st := full.Translate(DeviceState{On: true, Kelvin: &k}); st.Kelvin == nil || st.Color != nil {
+		t.Fatalf("devices with both must be left alone, got %+v", st)
+	}
+}
+
+func TestTranslate_ManagerSendsWhiteToWhiteOnly(t *testing.T) {
+	c := NewVirtualController()
+	c.SetDevices([]VirtualDevice{{ID: "key", Capabilities: Capabilities{Kelvin: true, MinKelvin: 2900, MaxKelvin: 7000, Segments: 1}}})
+	m := NewManager()
+	m.RegisterController(c)
+	ctx := context.Background()
+
+	red := DeviceState{On: true, Brightness: 1, Color: &Color{H: 0, S: 1, B: 1}}.WithTransition(0)
+	if err := m.SetDeviceStates(WithSource(ctx, SourceScreenSync), map[string]DeviceState{"virtual:key": red}); err != nil {
+		t.Fatalf("SetDeviceStates: %v", err)
+	}
+	hist := m.CommandHistory("virtual:key")
+	if len(hist) != 1 || hist[0].State.Color != nil || hist[0].State.Kelvin == nil || *hist[0].State.Kelvin != 2900 {
+		t.Fatalf("expected red to arrive as the warmest white, got %+v", hist)
+	}
+}
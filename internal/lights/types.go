@@ -8,10 +8,15 @@ import (
 type Brand string
 
 const (
-	BrandLIFX   Brand = "lifx"
-	BrandHue    Brand = "hue"
-	BrandElgato Brand = "elgato"
-	BrandGovee  Brand = "govee"
+	BrandLIFX     Brand = "lifx"
+	BrandHue      Brand = "hue"
+	BrandElgato   Brand = "elgato"
+	BrandGovee    Brand = "govee"
+	BrandWLED     Brand = "wled"
+	BrandNanoleaf Brand = "nanoleaf"
+	BrandYeelight Brand = "yeelight"
+	BrandDMX      Brand = "dmx"
+	BrandVirtual  Brand = "virtual"
 )
 
 const DefaultKelvin = 4000
@@ -34,6 +39,21 @@ type Device struct {
 	FirmwareVersion string `json:"firmwareVersion,omitempty"`
 	// Room is the user-assigned room label for grouping (e.g. "Bedroom", "Office").
 	Room string `json:"room,omitempty"`
+	// Capabilities is the full descriptor reported by the controller at
+	// discovery. The Supports*/Kelvin fields above mirror it.
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// Offline is set while health checks can't reach the device.
+	Offline bool `json:"offline,omitempty"`
+	// Calibration corrects the device's colour rendering; nil when the
+	// device hasn't been calibrated.
+	Calibration *Calibration `json:"calibration,omitempty"`
+}
+
+// keepSettings copies the user's settings for a device from existing, its
+// previous record, when discovery reports it again.
+func (d *Device) keepSettings(existing Device) {
+	d.Room = existing.Room
+	d.Calibration = existing.Calibration
 }
 
 type DeviceState struct {
@@ -41,6 +61,52 @@ type DeviceState struct {
 	Brightness float64 `json:"brightness"`
 	Color      *Color  `json:"color,omitempty"`
 	Kelvin     *int    `json:"kelvin,omitempty"`
+	// TransitionMs is the fade duration for this change in milliseconds.
+	// nil means "use the controller default"; 0 requests an instant change.
+	TransitionMs *int `json:"transitionMs,omitempty"`
+	// Zones optionally gives one colour per segment in device order (see
+	// Capabilities.Segments); each zone's B is scaled by Brightness. Devices
+	// with a single segment use Color instead, and zone lists of a different
+	// length are resampled to fit.
+	Zones []Color `json:"zones,omitempty"`
+}
+
+// Transition returns the requested fade duration, or def when the state
+// leaves it to the controller.
+func (s DeviceState) Transition(def time.Duration) time.Duration {
+	if s.TransitionMs == nil {
+		return def
+	}
+	if *s.TransitionMs <= 0 {
+		return 0
+	}
+	return time.Duration(*s.TransitionMs) * time.Millisecond
+}
+
+// WithTransition returns a copy of s with TransitionMs set to ms.
+func (s DeviceState) WithTransition(ms int) DeviceState {
+	s.TransitionMs = &ms
+	return s
+}
+
+// clone returns a copy of s that shares no pointers or slices with it.
+func (s DeviceState) clone() DeviceState {
+	if s.Color != nil {
+		c := *s.Color
+		s.Color = &c
+	}
+	if s.Kelvin != nil {
+		k := *s.Kelvin
+		s.Kelvin = &k
+	}
+	if s.TransitionMs != nil {
+		ms := *s.TransitionMs
+		s.TransitionMs = &ms
+	}
+	if s.Zones != nil {
+		s.Zones = append([]Color(nil), s.Zones...)
+	}
+	return s
 }
 
 type Color struct {
@@ -49,10 +115,22 @@ type Color struct {
 	B float64 `json:"b"`
 }
 
+// Point is a normalised position on a surface, with (0,0) at the top-left
+// corner and (1,1) at the bottom-right.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
 func HSBToRGB(h, s, b float64) (r, g, bl uint8) {
+	rf, gf, bf := hsbToRGBFloat(h, s, b)
+	return uint8(rf * 255), uint8(gf * 255), uint8(bf * 255)
+}
+
+// hsbToRGBFloat is HSBToRGB with channels in 0–1, without rounding.
+func hsbToRGBFloat(h, s, b float64) (r, g, bl float64) {
 	if s == 0 {
-		v := uint8(b * 255)
-		return v, v, v
+		return b, b, b
 	}
 
 	h = math.Mod(h, 360)
@@ -66,21 +144,114 @@ func HSBToRGB(h, s, b float64) (r, g, bl uint8) {
 	q := b * (1.0 - s*ff)
 	t := b * (1.0 - s*(1.0-ff))
 
-	var rr, gg, bb float64
 	switch i {
 	case 0:
-		rr, gg, bb = b, t, p
+		return b, t, p
 	case 1:
-		rr, gg, bb = q, b, p
+		return q, b, p
 	case 2:
-		rr, gg, bb = p, b, t
+		return p, b, t
 	case 3:
-		rr, gg, bb = p, q, b
+		return p, q, b
 	case 4:
-		rr, gg, bb = t, p, b
+		return t, p, b
 	default:
-		rr, gg, bb = b, p, q
+		return b, p, q
 	}
+}
 
-	return uint8(rr * 255), uint8(gg * 255), uint8(bb * 255)
+// RGBToHSB is the inverse of HSBToRGB.
+func RGBToHSB(r, g, b uint8) (h, s, v float64) {
+	return rgbFloatToHSB(float64(r)/255, float64(g)/255, float64(b)/255)
+}
+
+// rgbFloatToHSB is RGBToHSB with channels in 0–1.
+func rgbFloatToHSB(rf, gf, bf float64) (h, s, v float64) {
+	max := math.Max(rf, math.Max(gf, bf))
+	min := math.Min(rf, math.Min(gf, bf))
+	d := max - min
+	v = max
+	if max > 0 {
+		s = d / max
+	}
+	if d == 0 {
+		return 0, s, v
+	}
+	switch max {
+	case rf:
+		h = math.Mod((gf-bf)/d, 6)
+	case gf:
+		h = (bf-rf)/d + 2
+	default:
+		h = (rf-gf)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// KelvinToRGB approximates the colour of a black-body radiator at k Kelvin
+// (Tanner Helland's fit), for RGB-only devices asked for white light.
+func KelvinToRGB(k int) (r, g, b uint8) {
+	t := float64(k) / 100
+	clamp := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(255, v)))
+	}
+
+	var rf, gf, bf float64
+	if t <= 66 {
+		rf = 255
+		gf = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		rf = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		gf = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		bf = 255
+	case t <= 19:
+		bf = 0
+	default:
+		bf = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	return clamp(rf), clamp(gf), clamp(bf)
+}
+
+// statePixels renders state to n RGB pixels with brightness applied, for
+// controllers that drive individual LEDs or panels.
+func statePixels(st DeviceState, n int) [][3]uint8 {
+	pixels := make([][3]uint8, n)
+	if !st.On {
+		return pixels
+	}
+	if len(st.Zones) > 0 {
+		for i, z := range resampleZones(st.Zones, n) {
+			r, g, b := HSBToRGB(z.H, z.S, z.B*st.Brightness)
+			pixels[i] = [3]uint8{r, g, b}
+		}
+		return pixels
+	}
+
+	var px [3]uint8
+	switch {
+	case st.Color != nil:
+		r, g, b := HSBToRGB(st.Color.H, st.Color.S, st.Color.B*st.Brightness)
+		px = [3]uint8{r, g, b}
+	case st.Kelvin != nil:
+		r, g, b := KelvinToRGB(*st.Kelvin)
+		px = [3]uint8{
+			uint8(float64(r) * st.Brightness),
+			uint8(float64(g) * st.Brightness),
+			uint8(float64(b) * st.Brightness),
+		}
+	default:
+		v := uint8(255 * st.Brightness)
+		px = [3]uint8{v, v, v}
+	}
+	for i := range pixels {
+		pixels[i] = px
+	}
+	return pixels
 }
@@ -0,0 +1,270 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// VirtualDevice is a user-defined simulated light. It keeps its state in
+// memory and can be made to behave like a slow or lossy real device. It
+// shows up as a light with ID "virtual:<ID>".
+type VirtualDevice struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Capabilities Capabilities `json:"capabilities"`
+	// LatencyMs delays every command and state read.
+	LatencyMs int `json:"latencyMs,omitempty"`
+	// PacketLoss is the fraction (0–1) of commands silently dropped, as a
+	// UDP device would.
+	PacketLoss float64 `json:"packetLoss,omitempty"`
+	// Layout optionally places each segment for spatial screen sync.
+	Layout []Point `json:"layout,omitempty"`
+}
+
+// ErrVirtualRateLimited is returned when a command arrives sooner than the
+// device's Capabilities.MaxCommandRate allows.
+var ErrVirtualRateLimited = errors.New("rate limited")
+
+// virtualHistorySize is the number of commands kept across all virtual
+// devices; older ones are overwritten.
+const virtualHistorySize = 1024
+
+type VirtualController struct {
+	mu      sync.Mutex
+	devices map[string]*virtualDevice
+	// rng is seeded with a constant so packet loss is reproducible from run
+	// to run.
+	rng *rand.Rand
+
+	history []CommandRecord
+	next    int
+}
+
+type virtualDevice struct {
+	cfg          VirtualDevice
+	state        DeviceState
+	lastAccepted time.Time
+	// seq orders commands so a slow one finishing late can't overwrite a
+	// newer state.
+	seq, applied uint64
+}
+
+func NewVirtualController() *VirtualController {
+	return &VirtualController{
+		devices: make(map[string]*virtualDevice),
+		rng:     rand.New(rand.NewSource(1)),
+	}
+}
+
+func (c *VirtualController) Brand() Brand {
+	return BrandVirtual
+}
+
+// SetDevices replaces the simulated devices. Devices that already exist keep
+// their current state.
+func (c *VirtualController) SetDevices(devices []VirtualDevice) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next := make(map[string]*virtualDevice, len(devices))
+	for _, cfg := range devices {
+		id := virtualDeviceID(cfg.ID)
+		d, ok := c.devices[id]
+		if !ok {
+			d = &virtualDevice{state: DeviceState{Brightness: 1}}
+		}
+		d.cfg = cfg
+		next[id] = d
+	}
+	c.devices = next
+}
+
+func virtualDeviceID(id string) string {
+	return fmt.Sprintf("virtual:%s", id)
+}
+
+// virtualCapabilities fills in what a zero Capabilities leaves out so a
+// device defined with only a name behaves like a colour bulb.
+func virtualCapabilities(caps Capabilities) Capabilities {
+	if caps == (Capabilities{}) {
+		caps = Capabilities{Color: true, Kelvin: true, MinKelvin: 2500, MaxKelvin: 9000, KelvinStep: 1}
+	}
+	if caps.Segments < 1 {
+		caps.Segments = 1
+	}
+	caps.ReadBack = true
+	return caps
+}
+
+func (c *VirtualController) Capabilities(deviceID string) Capabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.devices[deviceID]; ok {
+		return virtualCapabilities(d.cfg.Capabilities)
+	}
+	return virtualCapabilities(Capabilities{})
+}
+
+func (c *VirtualController) Layout(deviceID string) []Point {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.devices[deviceID]; ok && len(d.cfg.Layout) > 0 {
+		return append([]Point(nil), d.cfg.Layout...)
+	}
+	return nil
+}
+
+func (c *VirtualController) Discover(ctx context.Context) ([]Device, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]Device, 0, len(c.devices))
+	for id, d := range c.devices {
+		dev := Device{
+			ID:       id,
+			Brand:    BrandVirtual,
+			Name:     d.cfg.Name,
+			Model:    "Virtual light",
+			LastSeen: time.Now(),
+		}
+		dev.applyCapabilities(virtualCapabilities(d.cfg.Capabilities))
+		result = append(result, dev)
+	}
+	return result, nil
+}
+
+func (c *VirtualController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+	c.mu.Lock()
+	d, ok := c.devices[deviceID]
+	if !ok {
+		c.mu.Unlock()
+		return fmt.Errorf("unknown virtual device %s", deviceID)
+	}
+	rec := CommandRecord{DeviceID: deviceID, State: state.clone(), At: time.Now()}
+	var err error
+	if rate := d.cfg.Capabilities.MaxCommandRate; rate > 0 && rec.At.Sub(d.lastAccepted) < time.Duration(float64(time.Second)/rate) {
+		err = ErrVirtualRateLimited
+		rec.Dropped = true
+		rec.Error = err.Error()
+	} else if d.cfg.PacketLoss > 0 && c.rng.Float64() < d.cfg.PacketLoss {
+		rec.Dropped = true
+	} else {
+		d.lastAccepted = rec.At
+	}
+	c.recordLocked(rec)
+	d.seq++
+	seq := d.seq
+	latency := time.Duration(d.cfg.LatencyMs) * time.Millisecond
+	c.mu.Unlock()
+
+	if err != nil || rec.Dropped {
+		return err
+	}
+	if err := virtualDelay(ctx, latency); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq > d.applied {
+		d.applied = seq
+		d.state = state.clone()
+		d.state.TransitionMs = nil
+	}
+	return nil
+}
+
+func virtualDelay(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+func (c *VirtualController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
+	c.mu.Lock()
+	d, ok := c.devices[deviceID]
+	if !ok {
+		c.mu.Unlock()
+		return DeviceState{}, fmt.Errorf("unknown virtual device %s", deviceID)
+	}
+	latency := time.Duration(d.cfg.LatencyMs) * time.Millisecond
+	c.mu.Unlock()
+
+	if err := virtualDelay(ctx, latency); err != nil {
+		return DeviceState{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return d.state.clone(), nil
+}
+
+func (c *VirtualController) TurnOn(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, true)
+}
+
+func (c *VirtualController) TurnOff(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, false)
+}
+
+func (c *VirtualController) setPower(ctx context.Context, deviceID string, on bool) error {
+	c.mu.Lock()
+	d, ok := c.devices[deviceID]
+	var st DeviceState
+	if ok {
+		st = d.state.clone()
+	}
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown virtual device %s", deviceID)
+	}
+	st.On = on
+	return c.SetState(ctx, deviceID, st)
+}
+
+// recordLocked appends rec to the ring buffer. Callers hold c.mu.
+func (c *VirtualController) recordLocked(rec CommandRecord) {
+	if len(c.history) < virtualHistorySize {
+		c.history = append(c.history, rec)
+		return
+	}
+	c.history[c.next] = rec
+	c.next = (c.next + 1) % virtualHistorySize
+}
+
+// History returns the recorded commands for deviceID, oldest first. An
+// empty deviceID returns every virtual device's commands.
+func (c *VirtualController) History(deviceID string) []CommandRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ordered := append(append([]CommandRecord(nil), c.history[c.next:]...), c.history[:c.next]...)
+	if deviceID == "" {
+		return ordered
+	}
+	out := make([]CommandRecord, 0, len(ordered))
+	for _, rec := range ordered {
+		if rec.DeviceID == deviceID {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// ClearHistory empties the command history.
+func (c *VirtualController) ClearHistory() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = nil
+	c.next = 0
+}
+
+func (c *VirtualController) Close() error {
+	return nil
+}
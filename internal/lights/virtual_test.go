@@ -0,0 +1,91 @@
+package lights
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestVirtualManager(devices ...VirtualDevice) (*Manager, *VirtualController) {
+	c := NewVirtualController()
+	c.SetDevices(devices)
+	m := NewManager()
+	m.RegisterController(c)
+	return m, c
+}
+
+func TestVirtual_StateAndHistory(t *testing.T) {
+	m, _ := newTestVirtualManager(VirtualDevice{ID: "a", Name: "Desk", LatencyMs: 20})
+	ctx := context.Background()
+
+	start := time.Now()
+	want := DeviceState{On: true, Brightness: 0.5, Color: &Color{H: 200, S: 1, B: 1}}.WithTransition(0)
+	if err := m.SetDeviceState(ctx, "virtual:a", want); err != nil {
+		t.Fatalf("SetDeviceState: %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("expected simulated latency")
+	}
+	want.Color.H = 0 // the recorded and stored states must be copies
+
+	st, err := m.GetDeviceState(ctx, "virtual:a")
+	if err != nil {
+		t.Fatalf("GetDeviceState: %v", err)
+	}
+	if !st.On || st.Brightness != 0.5 || st.Color == nil || st.Color.H != 200 {
+		t.Fatalf("unexpected state %+v", st)
+	}
+
+	history := m.CommandHistory("virtual:a")
+	if len(history) != 1 || history[0].Dropped || history[0].State.Color.H != 200 {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	if all := m.CommandHistory(""); len(all) != 1 {
+		t.Fatalf("expected merged history of 1, got %d", len(all))
+	}
+}
+
+func TestVirtual_LossAndRateLimit(t *testing.T) {
+	m, _ := newTestVirtualManager(
+		VirtualDevice{ID: "lossy", PacketLoss: 1},
+		VirtualDevice{ID: "slow", Capabilities: Capabilities{Color: true, MaxCommandRate: 1}},
+	)
+	ctx := context.Background()
+	on := DeviceState{On: true, Brightness: 1, Color: &Color{H: 10, S: 1, B: 1}}.WithTransition(0)
+
+	if err := m.SetDeviceState(ctx, "virtual:lossy", on); err != nil {
+		t.Fatalf("lost commands should not fail: %v", err)
+	}
+	if st, _ := m.GetDeviceState(ctx, "virtual:lossy"); st.On {
+		t.Fatalf("lost command was applied")
+	}
+
+	if err := m.SetDeviceState(ctx, "virtual:slow", on); err != nil {
+		t.Fatalf("first command: %v", err)
+	}
+	if err := m.SetDeviceState(ctx, "virtual:slow", on); !errors.Is(err, ErrVirtualRateLimited) {
+		t.Fatalf("expected rate limit, got %v", err)
+	}
+
+	history := m.CommandHistory("")
+	if len(history) != 3 || !history[0].Dropped || history[1].Dropped || history[2].Error == "" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
+
+func TestVirtual_HistoryRing(t *testing.T) {
+	_, c := newTestVirtualManager(VirtualDevice{ID: "a"})
+	ctx := context.Background()
+	for i := 0; i < virtualHistorySize+10; i++ {
+		_ = c.SetState(ctx, "virtual:a", DeviceState{On: true, Brightness: float64(i)})
+	}
+	history := c.History("virtual:a")
+	if len(history) != virtualHistorySize {
+		t.Fatalf("expected %d records, got %d", virtualHistorySize, len(history))
+	}
+	if history[0].State.Brightness != 10 || history[len(history)-1].State.Brightness != float64(virtualHistorySize+9) {
+		t.Fatalf("expected oldest-first order after wrapping, got %v … %v",
+			history[0].State.Brightness, history[len(history)-1].State.Brightness)
+	}
+}
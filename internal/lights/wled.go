@@ -0,0 +1,475 @@
+package lights
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// wledRealtimePort is WLED's default UDP realtime (notifier) port, used
+	// until /json/info reports the configured one.
+	wledRealtimePort = 21324
+	wledProtoDRGB    = 2
+	wledProtoDNRGB   = 4
+	wledDRGBMaxLEDs  = 490
+	wledDNRGBMaxLEDs = 489
+	// wledRealtimeTimeout is how many seconds WLED stays in realtime mode
+	// after the last packet before falling back to its own state.
+	wledRealtimeTimeout = 2
+	// wledKeepalive re-sends the last frame when screen sync has nothing new,
+	// so static scenes don't time out.
+	wledKeepalive = time.Second
+)
+
+type WLEDController struct {
+	mu      sync.RWMutex
+	devices map[string]*wledDevice
+	client  *http.Client
+}
+
+type wledDevice struct {
+	baseURL string
+	udpAddr string
+	leds    int
+}
+
+// wledInfo is the subset of /json/info we use.
+type wledInfo struct {
+	Name    string `json:"name"`
+	Ver     string `json:"ver"`
+	MAC     string `json:"mac"`
+	Arch    string `json:"arch"`
+	Product string `json:"product"`
+	UDPPort int    `json:"udpport"`
+	Leds    struct {
+		Count int `json:"count"`
+	} `json:"leds"`
+}
+
+// wledStateResponse is the subset of /json/state we read back.
+type wledStateResponse struct {
+	On  bool `json:"on"`
+	Bri int  `json:"bri"`
+	Seg []struct {
+		Col [][]int `json:"col"`
+	} `json:"seg"`
+}
+
+func NewWLEDController() *WLEDController {
+	return &WLEDController{
+		devices: make(map[string]*wledDevice),
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+func (c *WLEDController) Brand() Brand {
+	return BrandWLED
+}
+
+// wledDefaultCapabilities covers an RGB strip. White is rendered by RGB
+// approximation, and the JSON API fades natively in 100 ms steps.
+var wledDefaultCapabilities = Capabilities{
+	Color:             true,
+	Kelvin:            true,
+	MinKelvin:         2000,
+	MaxKelvin:         6500,
+	KelvinStep:        1,
+	NativeTransitions: true,
+	Segments:          1,
+	ReadBack:          true,
+}
+
+// Capabilities reports the LED count seen at the last probe as Segments.
+func (c *WLEDController) Capabilities(deviceID string) Capabilities {
+	caps := wledDefaultCapabilities
+	c.mu.RLock()
+	if dev, ok := c.devices[deviceID]; ok && dev.leds > 0 {
+		caps.Segments = dev.leds
+	}
+	c.mu.RUnlock()
+	return caps
+}
+
+// Layout implements LayoutProvider. WLED only knows the LED count, not
+// where the strip is mounted, so the LEDs are laid out left to right in one
+// row and screen sync samples one full-height column per LED.
+func (c *WLEDController) Layout(deviceID string) []Point {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	dev, ok := c.devices[deviceID]
+	if !ok || dev.leds < 2 {
+		return nil
+	}
+	points := make([]Point, dev.leds)
+	for i := range points {
+		points[i] = Point{X: (float64(i) + 0.5) / float64(dev.leds), Y: 0.5}
+	}
+	return points
+}
+
+// AddDevice registers a WLED node found by mDNS. Discover then probes it.
+func (c *WLEDController) AddDevice(addr string) {
+	deviceID := fmt.Sprintf("wled:%s", addr)
+	log.Printf("[wled] Adding device %s", deviceID)
+	c.addDevice(deviceID, "http://"+addr, net.JoinHostPort(addr, strconv.Itoa(wledRealtimePort)))
+}
+
+// DiscoveryHints implements DiscoveryProvider: WLED nodes announce
+// themselves over mDNS.
+func (c *WLEDController) DiscoveryHints() []DiscoveryHint {
+	return []DiscoveryHint{{Method: DiscoverMDNS, Targets: []string{"_wled._tcp"}}}
+}
+
+// AddDiscovered implements DiscoveryProvider by registering host for the
+// next Discover.
+func (c *WLEDController) AddDiscovered(host, _ string) {
+	c.AddDevice(host)
+}
+
+func (c *WLEDController) addDevice(deviceID, baseURL, udpAddr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dev := &wledDevice{baseURL: baseURL, udpAddr: udpAddr}
+	if old, ok := c.devices[deviceID]; ok {
+		dev.leds = old.leds
+	}
+	c.devices[deviceID] = dev
+}
+
+func (c *WLEDController) Discover(ctx context.Context) ([]Device, error) {
+	c.mu.RLock()
+	known := make(map[string]*wledDevice, len(c.devices))
+	for id, dev := range c.devices {
+		known[id] = dev
+	}
+	c.mu.RUnlock()
+
+	log.Printf("[wled] Discover: %d known address(es) to probe", len(known))
+
+	var result []Device
+	for id, dev := range known {
+		var info wledInfo
+		if err := c.do(ctx, http.MethodGet, dev.baseURL+"/json/info", nil, &info); err != nil {
+			log.Printf("[wled] Failed to get info for %s: %v", id, err)
+			continue
+		}
+
+		c.mu.Lock()
+		if cur, ok := c.devices[id]; ok {
+			cur.leds = info.Leds.Count
+			// The realtime port is configurable; 21324 is only the default.
+			if host, _, err := net.SplitHostPort(cur.udpAddr); err == nil && info.UDPPort > 0 {
+				cur.udpAddr = net.JoinHostPort(host, strconv.Itoa(info.UDPPort))
+			}
+		}
+		c.mu.Unlock()
+
+		log.Printf("[wled] Discovered: %s (%d LEDs) at %s", info.Name, info.Leds.Count, dev.baseURL)
+		d := Device{
+			ID:              id,
+			Brand:           BrandWLED,
+			Name:            info.Name,
+			Model:           fmt.Sprintf("WLED %s", info.Arch),
+			LastIP:          ipFromDeviceID(id),
+			LastSeen:        time.Now(),
+			FirmwareVersion: info.Ver,
+		}
+		d.applyCapabilities(c.Capabilities(id))
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func (c *WLEDController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"on": state.On}
+	if state.TransitionMs != nil {
+		body["transition"] = int(math.Round(float64(state.Transition(0)) / float64(100*time.Millisecond)))
+	}
+	if !state.On {
+		return c.do(ctx, http.MethodPost, dev.baseURL+"/json/state", body, nil)
+	}
+
+	body["bri"] = max(1, int(math.Round(state.Brightness*255)))
+	leds := c.Capabilities(deviceID).Segments
+	switch {
+	case len(state.Zones) > 0 && leds > 1:
+		// Per-LED control on the main segment; fx 0 is "Solid" so the
+		// pattern isn't overwritten by a running effect.
+		pixels := make([]string, 0, leds)
+		for _, z := range resampleZones(state.Zones, leds) {
+			r, g, b := HSBToRGB(z.H, z.S, z.B)
+			pixels = append(pixels, fmt.Sprintf("%02X%02X%02X", r, g, b))
+		}
+		body["seg"] = map[string]interface{}{"id": 0, "fx": 0, "i": pixels}
+	case state.Color != nil:
+		r, g, b := HSBToRGB(state.Color.H, state.Color.S, state.Color.B)
+		body["seg"] = map[string]interface{}{"fx": 0, "col": [][]int{{int(r), int(g), int(b)}}}
+	case state.Kelvin != nil:
+		r, g, b := KelvinToRGB(*state.Kelvin)
+		body["seg"] = map[string]interface{}{"fx": 0, "col": [][]int{{int(r), int(g), int(b)}}}
+	}
+
+	return c.do(ctx, http.MethodPost, dev.baseURL+"/json/state", body, nil)
+}
+
+func (c *WLEDController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return DeviceState{}, err
+	}
+
+	var resp wledStateResponse
+	if err := c.do(ctx, http.MethodGet, dev.baseURL+"/json/state", nil, &resp); err != nil {
+		return DeviceState{}, err
+	}
+
+	state := DeviceState{
+		On:         resp.On,
+		Brightness: float64(resp.Bri) / 255,
+	}
+	if len(resp.Seg) > 0 && len(resp.Seg[0].Col) > 0 && len(resp.Seg[0].Col[0]) >= 3 {
+		col := resp.Seg[0].Col[0]
+		h, s, v := RGBToHSB(uint8(col[0]), uint8(col[1]), uint8(col[2]))
+		state.Color = &Color{H: h, S: s, B: v}
+	}
+	return state, nil
+}
+
+func (c *WLEDController) TurnOn(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, true)
+}
+
+func (c *WLEDController) TurnOff(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, false)
+}
+
+func (c *WLEDController) setPower(ctx context.Context, deviceID string, on bool) error {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, dev.baseURL+"/json/state", map[string]bool{"on": on}, nil)
+}
+
+// getDevice returns a known device, or registers one from the IP embedded
+// in the device ID (devices restored from the store before any scan).
+func (c *WLEDController) getDevice(deviceID string) (*wledDevice, error) {
+	c.mu.RLock()
+	dev, ok := c.devices[deviceID]
+	c.mu.RUnlock()
+	if ok {
+		return dev, nil
+	}
+
+	ip := ipFromDeviceID(deviceID)
+	if ip == "" {
+		return nil, fmt.Errorf("cannot extract IP from device ID %q", deviceID)
+	}
+	c.AddDevice(ip)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.devices[deviceID], nil
+}
+
+func (c *WLEDController) do(ctx context.Context, method, url string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: HTTP %d", method, url, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *WLEDController) Close() error {
+	return nil
+}
+
+// StartStream opens a UDP realtime session to each device. WLED needs no
+// handshake; it enters realtime mode on the first packet.
+func (c *WLEDController) StartStream(ctx context.Context, deviceIDs []string) (Stream, error) {
+	s := &wledStream{
+		ctrl:    c,
+		conns:   make(map[string]net.Conn),
+		leds:    make(map[string]int),
+		last:    make(map[string][][]byte),
+		sentAt:  make(map[string]time.Time),
+		baseURL: make(map[string]string),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	var errs []error
+	for _, id := range deviceIDs {
+		dev, err := c.getDevice(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conn, err := net.Dial("udp", dev.udpAddr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		s.conns[id] = conn
+		s.leds[id] = c.Capabilities(id).Segments
+		s.baseURL[id] = dev.baseURL
+		s.devices = append(s.devices, id)
+	}
+	if len(s.conns) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no WLED devices")
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	go s.loop()
+	return s, nil
+}
+
+// wledStream pushes per-LED frames over DRGB (or DNRGB for strips longer
+// than one DRGB packet) and keeps the session alive between frames.
+type wledStream struct {
+	ctrl    *WLEDController
+	devices []string
+	conns   map[string]net.Conn
+	leds    map[string]int
+	baseURL map[string]string
+
+	mu     sync.Mutex
+	last   map[string][][]byte
+	sentAt map[string]time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *wledStream) Devices() []string {
+	return append([]string(nil), s.devices...)
+}
+
+func (s *wledStream) Send(states map[string]DeviceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for id, st := range states {
+		conn, ok := s.conns[id]
+		if !ok {
+			continue
+		}
+		packets := encodeWLEDRealtime(statePixels(st, s.leds[id]))
+		s.last[id] = packets
+		s.sentAt[id] = time.Now()
+		for _, p := range packets {
+			if _, err := conn.Write(p); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", id, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *wledStream) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(wledKeepalive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		for id, packets := range s.last {
+			if time.Since(s.sentAt[id]) < wledKeepalive {
+				continue
+			}
+			for _, p := range packets {
+				_, _ = s.conns[id].Write(p)
+			}
+			s.sentAt[id] = time.Now()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Close stops the keepalive and asks each device to leave realtime mode
+// right away instead of waiting for the timeout.
+func (s *wledStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		for id, conn := range s.conns {
+			conn.Close()
+			if err := s.ctrl.do(ctx, http.MethodPost, s.baseURL[id]+"/json/state", map[string]bool{"live": false}, nil); err != nil {
+				log.Printf("[wled] Failed to leave realtime mode on %s: %v", id, err)
+			}
+		}
+	})
+	return nil
+}
+
+// encodeWLEDRealtime builds DRGB packets for strips that fit in one packet
+// and DNRGB packets (with a 16-bit start index) otherwise.
+func encodeWLEDRealtime(pixels [][3]uint8) [][]byte {
+	if len(pixels) <= wledDRGBMaxLEDs {
+		p := make([]byte, 0, 2+3*len(pixels))
+		p = append(p, wledProtoDRGB, wledRealtimeTimeout)
+		for _, px := range pixels {
+			p = append(p, px[0], px[1], px[2])
+		}
+		return [][]byte{p}
+	}
+
+	var packets [][]byte
+	for start := 0; start < len(pixels); start += wledDNRGBMaxLEDs {
+		end := min(start+wledDNRGBMaxLEDs, len(pixels))
+		p := make([]byte, 0, 4+3*(end-start))
+		p = append(p, wledProtoDNRGB, wledRealtimeTimeout, byte(start>>8), byte(start))
+		for _, px := range pixels[start:end] {
+			p = append(p, px[0], px[1], px[2])
+		}
+		packets = append(packets, p)
+	}
+	return packets
+}
@@ -0,0 +1,198 @@
+package lights
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWLED serves /json/info and /json/state and listens for realtime UDP.
+type fakeWLED struct {
+	leds int
+
+	mu    sync.Mutex
+	posts []map[string]interface{}
+
+	http    *httptest.Server
+	udp     net.PacketConn
+	packets chan []byte
+}
+
+func startFakeWLED(t *testing.T, leds int) *fakeWLED {
+	t.Helper()
+	f := &fakeWLED{leds: leds, packets: make(chan []byte, 64)}
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	f.udp = udp
+	t.Cleanup(func() { udp.Close() })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/info", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":    "Desk Strip",
+			"ver":     "0.14.4",
+			"arch":    "esp32",
+			"udpport": udp.LocalAddr().(*net.UDPAddr).Port,
+			"leds":    map[string]int{"count": f.leds},
+		})
+	})
+	mux.HandleFunc("/json/state", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			f.mu.Lock()
+			f.posts = append(f.posts, body)
+			f.mu.Unlock()
+			_, _ = w.Write([]byte(`{"success":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"on":true,"bri":128,"seg":[{"col":[[0,0,255],[0,0,0],[0,0,0]]}]}`))
+	})
+	f.http = httptest.NewServer(mux)
+	t.Cleanup(f.http.Close)
+
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, _, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			f.packets <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	return f
+}
+
+func (f *fakeWLED) lastPost() map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.posts) == 0 {
+		return nil
+	}
+	return f.posts[len(f.posts)-1]
+}
+
+func (f *fakeWLED) nextPacket(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case p := <-f.packets:
+		return p
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no realtime packet received")
+		return nil
+	}
+}
+
+func newTestWLEDController(t *testing.T, f *fakeWLED) (*WLEDController, string) {
+	t.Helper()
+	c := NewWLEDController()
+	id := "wled:127.0.0.1"
+	// Registered on the default realtime port; Discover picks up the one
+	// the fake reports.
+	c.addDevice(id, f.http.URL, net.JoinHostPort("127.0.0.1", strconv.Itoa(wledRealtimePort)))
+	devices, err := c.Discover(context.Background())
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Discover: %v (%d devices)", err, len(devices))
+	}
+	return c, id
+}
+
+func TestWLED_DiscoverAndJSONState(t *testing.T) {
+	f := startFakeWLED(t, 30)
+	c, id := newTestWLEDController(t, f)
+
+	if caps := c.Capabilities(id); caps.Segments != 30 {
+		t.Fatalf("expected 30 segments, got %d", caps.Segments)
+	}
+
+	err := c.SetState(context.Background(), id, DeviceState{
+		On:         true,
+		Brightness: 0.5,
+		Color:      &Color{H: 0, S: 1, B: 1},
+	}.WithTransition(700))
+	if err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	body := f.lastPost()
+	if body["on"] != true || body["bri"] != float64(128) || body["transition"] != float64(7) {
+		t.Fatalf("unexpected state body: %v", body)
+	}
+	seg, _ := body["seg"].(map[string]interface{})
+	col, _ := seg["col"].([]interface{})
+	if len(col) != 1 || col[0].([]interface{})[0] != float64(255) {
+		t.Fatalf("expected red primary colour, got %v", seg)
+	}
+
+	st, err := c.GetState(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if !st.On || st.Color == nil || st.Color.H != 240 {
+		t.Fatalf("expected blue read-back, got %+v", st)
+	}
+}
+
+func TestWLED_RealtimeDRGB(t *testing.T) {
+	f := startFakeWLED(t, 10)
+	c, id := newTestWLEDController(t, f)
+
+	points := c.Layout(id)
+	if len(points) != 10 || points[0].X >= points[9].X || points[0].Y != points[9].Y {
+		t.Fatalf("expected a left-to-right layout of 10 LEDs, got %v", points)
+	}
+
+	stream, err := c.StartStream(context.Background(), []string{id})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	_ = stream.Send(map[string]DeviceState{
+		id: {On: true, Brightness: 1, Zones: []Color{{H: 0, S: 1, B: 1}, {H: 120, S: 1, B: 1}}},
+	})
+
+	p := f.nextPacket(t)
+	if len(p) != 2+3*10 || p[0] != wledProtoDRGB || p[1] != wledRealtimeTimeout {
+		t.Fatalf("unexpected DRGB packet header/length: %v", p[:2])
+	}
+	if p[2] != 255 || p[3] != 0 || p[2+3*9+1] != 255 {
+		t.Fatalf("expected red→green gradient, got %v", p[2:])
+	}
+
+	// With no new frames the stream keeps the session alive.
+	if again := f.nextPacket(t); len(again) != len(p) {
+		t.Fatalf("expected keepalive resend, got %d bytes", len(again))
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if body := f.lastPost(); body["live"] != false {
+		t.Fatalf("expected live:false on close, got %v", body)
+	}
+}
+
+func TestEncodeWLEDRealtime_DNRGBChunks(t *testing.T) {
+	pixels := make([][3]uint8, 600)
+	packets := encodeWLEDRealtime(pixels)
+	if len(packets) != 2 {
+		t.Fatalf("expected 2 DNRGB packets, got %d", len(packets))
+	}
+	if packets[0][0] != wledProtoDNRGB || len(packets[0]) != 4+3*wledDNRGBMaxLEDs {
+		t.Fatalf("unexpected first packet: proto=%d len=%d", packets[0][0], len(packets[0]))
+	}
+	if start := int(packets[1][2])<<8 | int(packets[1][3]); start != wledDNRGBMaxLEDs {
+		t.Fatalf("expected second packet to start at %d, got %d", wledDNRGBMaxLEDs, start)
+	}
+	if len(packets[1]) != 4+3*(600-wledDNRGBMaxLEDs) {
+		t.Fatalf("unexpected second packet length %d", len(packets[1]))
+	}
+}
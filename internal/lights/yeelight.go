@@ -0,0 +1,646 @@
+package lights
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	yeelightPort          = 55443
+	yeelightDiscoveryAddr = "239.255.255.250:1982"
+	// yeelightMinDuration is the shortest "smooth" effect the firmware accepts.
+	yeelightMinDuration = 30 * time.Millisecond
+	// yeelightMinFlowDuration is the shortest step a colour flow accepts.
+	yeelightMinFlowDuration = 50 * time.Millisecond
+	// yeelightCommandGap spaces commands to one bulb: outside music mode the
+	// firmware allows 60 a minute.
+	yeelightCommandGap = time.Second
+	// yeelightDefaultTransition is used when a state leaves the fade to us.
+	yeelightDefaultTransition = 300 * time.Millisecond
+	// yeelightMusicConnectTimeout bounds how long StartStream waits for bulbs
+	// to connect back after set_music.
+	yeelightMusicConnectTimeout = 3 * time.Second
+)
+
+type YeelightController struct {
+	mu      sync.RWMutex
+	devices map[string]*yeelightDevice
+	// discoveryAddr is the multicast group searched by Discover; tests point
+	// it at a fake bulb.
+	discoveryAddr string
+	// commandGap is the spacing between commands to one bulb.
+	commandGap time.Duration
+}
+
+// yeelightDevice is one bulb and its command connection. Yeelight allows
+// only a few concurrent connections per bulb, so one is kept open and reused.
+type yeelightDevice struct {
+	addr string
+	// caps is guarded by YeelightController.mu.
+	caps Capabilities
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int
+	// gap is the spacing call keeps between commands, and next the earliest
+	// time the next one may go out.
+	gap  time.Duration
+	next time.Time
+}
+
+type yeelightCommand struct {
+	ID     int           `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// yeelightResponse is a command result, or a "props" notification when
+// Method is set.
+type yeelightResponse struct {
+	ID     int           `json:"id"`
+	Method string        `json:"method,omitempty"`
+	Result []interface{} `json:"result,omitempty"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func NewYeelightController() *YeelightController {
+	return &YeelightController{
+		devices:       make(map[string]*yeelightDevice),
+		discoveryAddr: yeelightDiscoveryAddr,
+		commandGap:    yeelightCommandGap,
+	}
+}
+
+func (c *YeelightController) Brand() Brand {
+	return BrandYeelight
+}
+
+// yeelightDefaultCapabilities covers colour bulbs and strips. Bulbs accept
+// about one command per second outside music mode.
+var yeelightDefaultCapabilities = Capabilities{
+	Color:             true,
+	Kelvin:            true,
+	MinKelvin:         1700,
+	MaxKelvin:         6500,
+	KelvinStep:        1,
+	MinBrightness:     0.01,
+	NativeTransitions: true,
+	Segments:          1,
+	MaxCommandRate:    1,
+	ReadBack:          true,
+}
+
+func (c *YeelightController) Capabilities(deviceID string) Capabilities {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if dev, ok := c.devices[deviceID]; ok {
+		return dev.caps
+	}
+	return yeelightDefaultCapabilities
+}
+
+// RateBudget implements RateBudgeter: outside music mode each bulb enforces
+// its own command quota.
+func (c *YeelightController) RateBudget(deviceID string) (string, float64) {
+	return deviceID, c.Capabilities(deviceID).MaxCommandRate
+}
+
+// yeelightCapabilities derives capabilities from the "support" method list a
+// bulb advertises: mono bulbs lack set_hsv, and colour-only ones set_ct_abx.
+func yeelightCapabilities(support []string) Capabilities {
+	caps := yeelightDefaultCapabilities
+	if len(support) == 0 {
+		return caps
+	}
+	has := make(map[string]bool, len(support))
+	for _, m := range support {
+		has[m] = true
+	}
+	caps.Color = has["set_hsv"] || has["set_rgb"]
+	caps.Kelvin = has["set_ct_abx"]
+	if !caps.Kelvin {
+		caps.MinKelvin, caps.MaxKelvin, caps.KelvinStep = 0, 0, 0
+	}
+	return caps
+}
+
+// Discover multicasts an M-SEARCH for "wifi_bulb" and collects the unicast
+// replies for two seconds.
+func (c *YeelightController) Discover(ctx context.Context) ([]Device, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("open discovery socket: %w", err)
+	}
+	defer conn.Close()
+
+	target, err := net.ResolveUDPAddr("udp4", c.discoveryAddr)
+	if err != nil {
+		return nil, err
+	}
+	msg := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + yeelightDiscoveryAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"ST: wifi_bulb\r\n"
+	if _, err := conn.WriteTo([]byte(msg), target); err != nil {
+		return nil, fmt.Errorf("send M-SEARCH: %w", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	seen := make(map[string]bool)
+	var result []Device
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		headers := parseYeelightHeaders(string(buf[:n]))
+		loc, err := url.Parse(headers["location"])
+		if err != nil || loc.Scheme != "yeelight" || loc.Hostname() == "" {
+			continue
+		}
+		ip := loc.Hostname()
+		deviceID := fmt.Sprintf("yeelight:%s", ip)
+		if seen[deviceID] {
+			continue
+		}
+		seen[deviceID] = true
+
+		caps := yeelightCapabilities(strings.Fields(headers["support"]))
+		c.addDevice(deviceID, loc.Host, caps)
+
+		name := headers["name"]
+		if name == "" {
+			name = fmt.Sprintf("Yeelight %s (%s)", headers["model"], ip)
+		}
+		log.Printf("[yeelight] Discovered: %s (%s) at %s", name, headers["model"], loc.Host)
+		d := Device{
+			ID:              deviceID,
+			Brand:           BrandYeelight,
+			Name:            name,
+			Model:           headers["model"],
+			LastIP:          ip,
+			LastSeen:        time.Now(),
+			FirmwareVersion: headers["fw_ver"],
+		}
+		d.applyCapabilities(caps)
+		result = append(result, d)
+	}
+	log.Printf("[yeelight] Discover: found %d bulb(s)", len(result))
+	return result, nil
+}
+
+// parseYeelightHeaders reads the HTTP-style header lines of a discovery
+// reply into a map with lower-cased keys.
+func parseYeelightHeaders(resp string) map[string]string {
+	headers := make(map[string]string)
+	for _, line := range strings.Split(resp, "\r\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		headers[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return headers
+}
+
+// addDevice registers a bulb, keeping an existing command connection when
+// the address is unchanged.
+func (c *YeelightController) addDevice(deviceID, addr string, caps Capabilities) {
+	c.mu.Lock()
+	old, ok := c.devices[deviceID]
+	if ok && old.addr == addr {
+		old.caps = caps
+		c.mu.Unlock()
+		return
+	}
+	c.devices[deviceID] = &yeelightDevice{addr: addr, caps: caps, gap: c.commandGap}
+	c.mu.Unlock()
+	if ok {
+		old.close()
+	}
+}
+
+// getDevice returns a known bulb, or registers one on the default port from
+// the IP embedded in the device ID (bulbs restored from the store).
+func (c *YeelightController) getDevice(deviceID string) (*yeelightDevice, error) {
+	c.mu.RLock()
+	dev, ok := c.devices[deviceID]
+	c.mu.RUnlock()
+	if ok {
+		return dev, nil
+	}
+
+	ip := ipFromDeviceID(deviceID)
+	if ip == "" {
+		return nil, fmt.Errorf("cannot extract IP from device ID %q", deviceID)
+	}
+	c.addDevice(deviceID, net.JoinHostPort(ip, strconv.Itoa(yeelightPort)), yeelightDefaultCapabilities)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.devices[deviceID], nil
+}
+
+// call sends one command and waits for its result, skipping notifications.
+// A reused connection that turns out to be dead is redialled once. Commands
+// are spaced by the bulb's quota, so a state that takes two of them still
+// stays within it.
+func (d *yeelightDevice) call(ctx context.Context, method string, params ...interface{}) ([]interface{}, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if wait := time.Until(d.next); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	d.next = time.Now().Add(d.gap)
+
+	reused := d.conn != nil
+	result, err := d.callLocked(ctx, method, params)
+	var cmdErr *yeelightError
+	var netErr net.Error
+	if err != nil && reused && !errors.As(err, &cmdErr) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		result, err = d.callLocked(ctx, method, params)
+	}
+	return result, err
+}
+
+type yeelightError struct {
+	code    int
+	message string
+}
+
+func (e *yeelightError) Error() string {
+	return fmt.Sprintf("yeelight error %d: %s", e.code, e.message)
+}
+
+func (d *yeelightDevice) callLocked(ctx context.Context, method string, params []interface{}) ([]interface{}, error) {
+	if d.conn == nil {
+		dialer := net.Dialer{Timeout: 2 * time.Second}
+		conn, err := dialer.DialContext(ctx, "tcp", d.addr)
+		if err != nil {
+			return nil, err
+		}
+		d.conn = conn
+		d.reader = bufio.NewReader(conn)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	_ = d.conn.SetDeadline(deadline)
+
+	d.nextID++
+	id := d.nextID
+	if params == nil {
+		params = []interface{}{}
+	}
+	data, err := json.Marshal(yeelightCommand{ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.conn.Write(append(data, '\r', '\n')); err != nil {
+		d.closeLocked()
+		return nil, err
+	}
+
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if err != nil {
+			d.closeLocked()
+			return nil, err
+		}
+		var resp yeelightResponse
+		if err := json.Unmarshal(line, &resp); err != nil || resp.Method != "" || resp.ID != id {
+			continue
+		}
+		if resp.Error != nil {
+			return nil, &yeelightError{code: resp.Error.Code, message: resp.Error.Message}
+		}
+		return resp.Result, nil
+	}
+}
+
+func (d *yeelightDevice) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closeLocked()
+}
+
+func (d *yeelightDevice) closeLocked() {
+	if d.conn != nil {
+		d.conn.Close()
+		d.conn, d.reader = nil, nil
+	}
+}
+
+// yeelightEffect returns the effect and duration parameters for a fade.
+func yeelightEffect(transition time.Duration) (string, int) {
+	if transition < yeelightMinDuration {
+		return "sudden", 0
+	}
+	return "smooth", int(transition / time.Millisecond)
+}
+
+func yeelightBright(b float64) int {
+	return min(100, max(1, int(math.Round(b*100))))
+}
+
+// SetState sends one command per state where it can: set_scene powers the
+// bulb on and sets colour or temperature with brightness together, as a
+// one-step colour flow when it fades. Brightness alone needs set_power and
+// set_bright, which call spaces to the bulb's quota.
+func (c *YeelightController) SetState(ctx context.Context, deviceID string, state DeviceState) error {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
+	}
+	caps := c.Capabilities(deviceID)
+	transition := state.Transition(yeelightDefaultTransition)
+	effect, duration := yeelightEffect(transition)
+
+	if !state.On {
+		_, err := dev.call(ctx, "set_power", "off", effect, duration)
+		return err
+	}
+	if scene := yeelightScene(state, caps, transition); scene != nil {
+		_, err := dev.call(ctx, "set_scene", scene...)
+		return err
+	}
+
+	// set_bright is rejected while the bulb is off.
+	if _, err := dev.call(ctx, "set_power", "on", effect, duration); err != nil {
+		return err
+	}
+	_, err = dev.call(ctx, "set_bright", yeelightBright(state.Brightness), effect, duration)
+	return err
+}
+
+// yeelightScene returns the set_scene parameters for an on state with a
+// colour or temperature the bulb supports, or nil for brightness alone. A
+// colour's B scales Brightness, as it does for every brand. Both SetState
+// and music mode build their scenes here.
+func yeelightScene(state DeviceState, caps Capabilities, transition time.Duration) []interface{} {
+	// A flow step is "duration,mode,value,brightness"; mode 1 is RGB and 2
+	// colour temperature. Count 1 with action 1 stays on the last step.
+	flow := func(mode, value, bright int) []interface{} {
+		return []interface{}{"cf", 1, 1, fmt.Sprintf("%d,%d,%d,%d", transition.Milliseconds(), mode, value, bright)}
+	}
+	fade := transition >= yeelightMinFlowDuration
+
+	switch {
+	case state.Color != nil && caps.Color:
+		bright := yeelightBright(state.Brightness * state.Color.B)
+		if fade {
+			r, g, b := HSBToRGB(state.Color.H, state.Color.S, 1)
+			return flow(1, int(r)<<16|int(g)<<8|int(b), bright)
+		}
+		return []interface{}{"hsv", int(math.Round(state.Color.H)) % 360, int(math.Round(state.Color.S * 100)), bright}
+	case state.Kelvin != nil && caps.Kelvin:
+		bright := yeelightBright(state.Brightness)
+		if fade {
+			return flow(2, *state.Kelvin, bright)
+		}
+		return []interface{}{"ct", *state.Kelvin, bright}
+	}
+	return nil
+}
+
+func (c *YeelightController) GetState(ctx context.Context, deviceID string) (DeviceState, error) {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return DeviceState{}, err
+	}
+	result, err := dev.call(ctx, "get_prop", "power", "bright", "color_mode", "ct", "hue", "sat", "rgb")
+	if err != nil {
+		return DeviceState{}, err
+	}
+	if len(result) < 7 {
+		return DeviceState{}, fmt.Errorf("short get_prop result: %v", result)
+	}
+	prop := func(i int) int {
+		n, _ := strconv.Atoi(fmt.Sprint(result[i]))
+		return n
+	}
+
+	state := DeviceState{
+		On:         fmt.Sprint(result[0]) == "on",
+		Brightness: float64(prop(1)) / 100,
+	}
+	// color_mode: 1 = RGB, 2 = colour temperature, 3 = HSV.
+	switch prop(2) {
+	case 1:
+		rgb := prop(6)
+		h, s, _ := RGBToHSB(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb))
+		state.Color = &Color{H: h, S: s, B: 1}
+	case 2:
+		k := prop(3)
+		state.Kelvin = &k
+	case 3:
+		state.Color = &Color{H: float64(prop(4)), S: float64(prop(5)) / 100, B: 1}
+	}
+	return state, nil
+}
+
+func (c *YeelightController) TurnOn(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, "on")
+}
+
+func (c *YeelightController) TurnOff(ctx context.Context, deviceID string) error {
+	return c.setPower(ctx, deviceID, "off")
+}
+
+func (c *YeelightController) setPower(ctx context.Context, deviceID, power string) error {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
+	}
+	effect, duration := yeelightEffect(yeelightDefaultTransition)
+	_, err = dev.call(ctx, "set_power", power, effect, duration)
+	return err
+}
+
+func (c *YeelightController) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, dev := range c.devices {
+		dev.close()
+	}
+	return nil
+}
+
+// StartStream puts each bulb in music mode: LightSync listens on a local TCP
+// port, asks the bulb to connect to it with set_music, and then writes
+// commands over that connection without the normal rate limit.
+func (c *YeelightController) StartStream(ctx context.Context, deviceIDs []string) (Stream, error) {
+	ln, err := net.Listen("tcp4", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("music mode listener: %w", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	var errs []error
+	pending := make(map[string]string) // bulb IP → device ID
+	for _, id := range deviceIDs {
+		dev, err := c.getDevice(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		host, err := dev.localIP(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		if _, err := dev.call(ctx, "set_music", 1, host, port); err != nil {
+			errs = append(errs, fmt.Errorf("%s: set_music: %w", id, err))
+			continue
+		}
+		bulbHost, _, _ := net.SplitHostPort(dev.addr)
+		pending[bulbHost] = id
+	}
+
+	s := &yeelightStream{ln: ln, conns: make(map[string]net.Conn), caps: make(map[string]Capabilities)}
+	_ = ln.(*net.TCPListener).SetDeadline(time.Now().Add(yeelightMusicConnectTimeout))
+	for len(pending) > 0 {
+		conn, err := ln.Accept()
+		if err != nil {
+			break
+		}
+		ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		id, ok := pending[ip]
+		if !ok {
+			conn.Close()
+			continue
+		}
+		delete(pending, ip)
+		s.conns[id] = conn
+		s.caps[id] = c.Capabilities(id)
+		s.devices = append(s.devices, id)
+	}
+	for _, id := range pending {
+		errs = append(errs, fmt.Errorf("%s: bulb did not connect for music mode", id))
+	}
+
+	if len(s.conns) == 0 {
+		ln.Close()
+		if len(errs) == 0 {
+			return nil, errors.New("no Yeelight devices")
+		}
+		return nil, errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		log.Printf("[yeelight] Music mode unavailable for some bulbs: %v", errors.Join(errs...))
+	}
+	return s, nil
+}
+
+// localIP returns the address of this machine as seen by the bulb, which is
+// where the bulb must connect back to for music mode.
+func (d *yeelightDevice) localIP(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	conn := d.conn
+	d.mu.Unlock()
+	if conn == nil {
+		if _, err := d.call(ctx, "get_prop", "power"); err != nil {
+			return "", err
+		}
+		d.mu.Lock()
+		conn = d.conn
+		d.mu.Unlock()
+		if conn == nil {
+			return "", errors.New("no connection to bulb")
+		}
+	}
+	host, _, err := net.SplitHostPort(conn.LocalAddr().String())
+	return host, err
+}
+
+// yeelightStream writes one set_scene command per bulb and frame over the
+// music mode connections. Bulbs don't reply in music mode.
+type yeelightStream struct {
+	ln      net.Listener
+	devices []string
+	conns   map[string]net.Conn
+	caps    map[string]Capabilities
+
+	mu        sync.Mutex
+	nextID    int
+	closeOnce sync.Once
+}
+
+func (s *yeelightStream) Devices() []string {
+	return append([]string(nil), s.devices...)
+}
+
+func (s *yeelightStream) Send(states map[string]DeviceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for id, st := range states {
+		conn, ok := s.conns[id]
+		if !ok {
+			continue
+		}
+		s.nextID++
+		cmd := yeelightCommand{ID: s.nextID, Method: "set_power", Params: []interface{}{"off", "sudden", 0}}
+		if st.On {
+			if scene := yeelightScene(st, s.caps[id], 0); scene != nil {
+				cmd.Method, cmd.Params = "set_scene", scene
+			} else {
+				cmd.Method, cmd.Params = "set_bright", []interface{}{yeelightBright(st.Brightness), "sudden", 0}
+			}
+		}
+		data, err := json.Marshal(cmd)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+		if _, err := conn.Write(append(data, '\r', '\n')); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close drops the music mode connections; bulbs return to normal mode when
+// the connection closes.
+func (s *yeelightStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+		s.ln.Close()
+	})
+	return nil
+}
@@ -0,0 +1,283 @@
+package lights
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeYeelight answers discovery on UDP and the JSON command protocol on
+// TCP, and connects back when asked for music mode.
+type fakeYeelight struct {
+	udp net.PacketConn
+	tcp net.Listener
+
+	mu       sync.Mutex
+	commands []yeelightCommand
+	music    chan yeelightCommand
+}
+
+func startFakeYeelight(t *testing.T) *fakeYeelight {
+	t.Helper()
+	f := &fakeYeelight{music: make(chan yeelightCommand, 16)}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	f.tcp = tcp
+	t.Cleanup(func() { tcp.Close() })
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	udp, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	f.udp = udp
+	t.Cleanup(func() { udp.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if !strings.Contains(string(buf[:n]), "wifi_bulb") {
+				continue
+			}
+			reply := "HTTP/1.1 200 OK\r\n" +
+				"Location: yeelight://" + tcp.Addr().String() + "\r\n" +
+				"id: 0x000000000015243f\r\n" +
+				"model: color\r\n" +
+				"fw_ver: 18\r\n" +
+				"support: get_prop set_power set_hsv set_ct_abx set_bright set_music set_scene\r\n" +
+				"name: Desk Bulb\r\n"
+			_, _ = udp.WriteTo([]byte(reply), addr)
+		}
+	}()
+	return f
+}
+
+func (f *fakeYeelight) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var cmd yeelightCommand
+		if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+			continue
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, cmd)
+		f.mu.Unlock()
+
+		// Unsolicited notifications interleave with results.
+		fmt.Fprintf(conn, "{\"method\":\"props\",\"params\":{\"power\":\"on\"}}\r\n")
+
+		result := `["ok"]`
+		switch cmd.Method {
+		case "get_prop":
+			result = `["on","40","3","4000","200","50","0"]`
+		case "set_music":
+			addr := net.JoinHostPort(fmt.Sprint(cmd.Params[1]), fmt.Sprint(cmd.Params[2]))
+			go f.connectMusic(addr)
+		}
+		fmt.Fprintf(conn, "{\"id\":%d,\"result\":%s}\r\n", cmd.ID, result)
+	}
+}
+
+func (f *fakeYeelight) connectMusic(addr string) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var cmd yeelightCommand
+		if json.Unmarshal(scanner.Bytes(), &cmd) == nil {
+			f.music <- cmd
+		}
+	}
+}
+
+func (f *fakeYeelight) methods() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, c := range f.commands {
+		out = append(out, c.Method)
+	}
+	return out
+}
+
+func newTestYeelightController(t *testing.T, f *fakeYeelight) (*YeelightController, string) {
+	t.Helper()
+	c := NewYeelightController()
+	c.discoveryAddr = f.udp.LocalAddr().String()
+	c.commandGap = 0
+	t.Cleanup(func() { c.Close() })
+	devices, err := c.Discover(context.Background())
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Discover: %v (%d devices)", err, len(devices))
+	}
+	if devices[0].ID != "yeelight:127.0.0.1" || devices[0].Name != "Desk Bulb" || !devices[0].SupportsColor {
+		t.Fatalf("unexpected device: %+v", devices[0])
+	}
+	return c, devices[0].ID
+}
+
+func TestYeelight_DiscoverAndCommands(t *testing.T) {
+	f := startFakeYeelight(t)
+	c, id := newTestYeelightController(t, f)
+	ctx := context.Background()
+
+	err := c.SetState(ctx, id, DeviceState{On: true, Brightness: 0.5, Color: &Color{H: 120, S: 0.8, B: 1}}.WithTransition(500))
+	if err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	if got := strings.Join(f.methods(), ","); got != "set_scene" {
+		t.Fatalf("expected a single set_scene, got %q", got)
+	}
+	f.mu.Lock()
+	scene := f.commands[0]
+	f.mu.Unlock()
+	// 120° at 80% saturation is RGB (50, 255, 50).
+	if fmt.Sprint(scene.Params) != "[cf 1 1 500,1,3342130,50]" {
+		t.Fatalf("unexpected set_scene params: %v", scene.Params)
+	}
+
+	st, err := c.GetState(ctx, id)
+	if err != nil {
+		t.Fatalf("GetState: %v", err)
+	}
+	if !st.On || st.Brightness != 0.4 || st.Color == nil || st.Color.H != 200 || st.Color.S != 0.5 {
+		t.Fatalf("unexpected read-back: %+v", st)
+	}
+}
+
+func TestYeelight_SetStateCommands(t *testing.T) {
+	k := 4000
+	tests := []struct {
+		name    string
+		state   DeviceState
+		methods string
+		params  string
+	}{
+		{"instant colour", DeviceState{On: true, Brightness: 1, Color: &Color{H: 30, S: 0.5, B: 1}}.WithTransition(0), "set_scene", "[hsv 30 50 100]"},
+		{"instant temperature", DeviceState{On: true, Brightness: 0.2, Kelvin: &k}.WithTransition(0), "set_scene", "[ct 4000 20]"},
+		{"temperature fade", DeviceState{On: true, Brightness: 0.2, Kelvin: &k}.WithTransition(1000), "set_scene", "[cf 1 1 1000,2,4000,20]"},
+		{"off", DeviceState{On: false}.WithTransition(0), "set_power", "[off sudden 0]"},
+		{"brightness only", DeviceState{On: true, Brightness: 0.6}.WithTransition(0), "set_power,set_bright", "[60 sudden 0]"},
+	}
+	f := startFakeYeelight(t)
+	c, id := newTestYeelightController(t, f)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := len(f.methods())
+			if err := c.SetState(context.Background(), id, tt.state); err != nil {
+				t.Fatalf("SetState: %v", err)
+			}
+			if got := strings.Join(f.methods()[sent:], ","); got != tt.methods {
+				t.Fatalf("expected %s, got %s", tt.methods, got)
+			}
+			f.mu.Lock()
+			last := f.commands[len(f.commands)-1]
+			f.mu.Unlock()
+			if got := fmt.Sprint(last.Params); got != tt.params {
+				t.Fatalf("expected params %s, got %s", tt.params, got)
+			}
+		})
+	}
+}
+
+func TestYeelightScene(t *testing.T) {
+	k := 4000
+	colour := Capabilities{Color: true, Kelvin: true}
+	tests := []struct {
+		name       string
+		state      DeviceState
+		caps       Capabilities
+		transition time.Duration
+		want       string
+	}{
+		{"colour", DeviceState{On: true, Brightness: 1, Color: &Color{H: 30, S: 0.5, B: 1}}, colour, 0, "[hsv 30 50 100]"},
+		{"colour B scales brightness", DeviceState{On: true, Brightness: 0.8, Color: &Color{H: 30, S: 0.5, B: 0.5}}, colour, 0, "[hsv 30 50 40]"},
+		{"colour fade", DeviceState{On: true, Brightness: 0.8, Color: &Color{H: 0, S: 1, B: 0.5}}, colour, time.Second, "[cf 1 1 1000,1,16711680,40]"},
+		{"temperature", DeviceState{On: true, Brightness: 0.2, Kelvin: &k}, colour, 0, "[ct 4000 20]"},
+		{"brightness floor", DeviceState{On: true, Brightness: 0.001, Kelvin: &k}, colour, 0, "[ct 4000 1]"},
+		{"colour on a white bulb", DeviceState{On: true, Brightness: 1, Color: &Color{H: 30, S: 1, B: 1}}, Capabilities{Kelvin: true}, 0, "[]"},
+		{"brightness only", DeviceState{On: true, Brightness: 0.6}, colour, 0, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(yeelightScene(tt.state, tt.caps, tt.transition)); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestYeelight_CommandsSpacedToQuota(t *testing.T) {
+	f := startFakeYeelight(t)
+	c := NewYeelightController()
+	c.discoveryAddr = f.udp.LocalAddr().String()
+	c.commandGap = 200 * time.Millisecond
+	t.Cleanup(func() { c.Close() })
+	if _, err := c.Discover(context.Background()); err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	start := time.Now()
+	if err := c.SetState(context.Background(), "yeelight:127.0.0.1", DeviceState{On: true, Brightness: 0.6}); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < c.commandGap {
+		t.Fatalf("expected set_bright to wait %v after set_power, took %v", c.commandGap, elapsed)
+	}
+}
+
+func TestYeelight_MusicMode(t *testing.T) {
+	f := startFakeYeelight(t)
+	c, id := newTestYeelightController(t, f)
+
+	stream, err := c.StartStream(context.Background(), []string{id})
+	if err != nil {
+		t.Fatalf("StartStream: %v", err)
+	}
+	defer stream.Close()
+	if devs := stream.Devices(); len(devs) != 1 || devs[0] != id {
+		t.Fatalf("unexpected stream devices %v", devs)
+	}
+
+	// Brightness is 0.5 × the colour's 0.5, as SetState sends it.
+	_ = stream.Send(map[string]DeviceState{id: {On: true, Brightness: 0.5, Color: &Color{H: 240, S: 1, B: 0.5}}})
+	select {
+	case cmd := <-f.music:
+		if cmd.Method != "set_scene" || cmd.Params[0] != "hsv" || cmd.Params[1] != float64(240) || cmd.Params[3] != float64(25) {
+			t.Fatalf("unexpected music command: %+v", cmd)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no command received over the music connection")
+	}
+}
+
+func TestYeelightCapabilities_Mono(t *testing.T) {
+	caps := yeelightCapabilities([]string{"get_prop", "set_power", "set_bright"})
+	if caps.Color || caps.Kelvin || caps.MaxKelvin != 0 {
+		t.Fatalf("expected brightness-only capabilities, got %+v", caps)
+	}
+}
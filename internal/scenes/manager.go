@@ -11,6 +11,10 @@ import (
 	"lightsync/internal/store"
 )
 
+// sceneTransitionMs is the crossfade applied to scene device states that
+// don't carry their own TransitionMs.
+const sceneTransitionMs = 500
+
 type Manager struct {
 	mu           sync.RWMutex
 	store        *store.Store
@@ -26,6 +30,8 @@ func NewManager(s *store.Store, lm *lights.Manager) *Manager {
 	}
 }
 
+// OnChange registers fn to be called when a scene becomes active, with its
+// group references resolved to the groups' current members.
 func (m *Manager) OnChange(fn func(scene store.Scene)) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -106,6 +112,7 @@ func (m *Manager) ActivateScene(ctx context.Context, id string) error {
 	if err != nil {
 		return err
 	}
+	scene = m.resolve(scene)
 
 	// Persist last activated scene so it can be restored on next app launch.
 	_ = m.store.SetLastSceneID(id)
@@ -122,11 +129,16 @@ func (m *Manager) ActivateScene(ctx context.Context, id string) error {
 		fn(scene)
 	}
 
+	states := make(map[string]lights.DeviceState, len(scene.Devices))
 	for deviceID, state := range scene.Devices {
-		if err := m.lightManager.SetDeviceState(ctx, deviceID, state); err != nil {
-			continue
+		if state.TransitionMs == nil {
+			state = state.WithTransition(sceneTransitionMs)
 		}
+		states[deviceID] = state
 	}
+	// Per-device failures are not fatal: the scene is already active and
+	// unreachable lights shouldn't block the rest.
+	_ = m.lightManager.SetDeviceStates(lights.WithSource(ctx, lights.SourceScene), states)
 
 	return nil
 }
@@ -139,6 +151,7 @@ func (m *Manager) MarkActive(id string) error {
 	if err != nil {
 		return err
 	}
+	scene = m.resolve(scene)
 
 	// Persist last activated scene so it can be restored on next app launch.
 	_ = m.store.SetLastSceneID(id)
@@ -154,6 +167,20 @@ func (m *Manager) MarkActive(id string) error {
 	return nil
 }
 
+// resolve expands the scene's group references ("group:<id>" keys and
+// screen sync device IDs) into the groups' current members, so a light added
+// to a group takes part without editing the scene. States set for a device
+// itself win over its groups'.
+func (m *Manager) resolve(scene store.Scene) store.Scene {
+	scene.Devices = m.lightManager.ExpandGroupStates(scene.Devices)
+	if scene.ScreenSync != nil {
+		cfg := *scene.ScreenSync
+		cfg.DeviceIDs = m.lightManager.ResolveDeviceIDs(cfg.DeviceIDs)
+		scene.ScreenSync = &cfg
+	}
+	return scene
+}
+
 // ClearActive clears the in-memory active scene without emitting any event.
 func (m *Manager) ClearActive() {
 	m.mu.Lock()
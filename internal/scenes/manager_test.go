@@ -0,0 +1,133 @@
+package scenes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lightsync/internal/lights"
+	"lightsync/internal/store"
+)
+
+// newTestManager wires a scene manager to virtual lights and a store in a
+// temporary config directory.
+func newTestManager(t *testing.T) (*Manager, *lights.Manager) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("APPDATA", dir)
+	s, err := store.New()
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+
+	virtual := lights.NewVirtualController()
+	virtual.SetDevices([]lights.VirtualDevice{
+		{ID: "desk", Name: "Desk"},
+		{ID: "shelf", Name: "Shelf", Capabilities: lights.Capabilities{Kelvin: true, MinKelvin: 2700, MaxKelvin: 6500, NativeTransitions: true}},
+	})
+	lm := lights.NewManager()
+	lm.RegisterController(virtual)
+	return NewManager(s, lm), lm
+}
+
+func waitForState(t *testing.T, lm *lights.Manager, id string, ok func(lights.DeviceState) bool) lights.DeviceState {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		st, err := lm.GetDeviceState(context.Background(), id)
+		if err == nil && ok(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never reached the expected state, last %+v (err %v)", id, st, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestActivateScene_AppliesStatesWithCrossfade(t *testing.T) {
+	m, lm := newTestManager(t)
+	kelvin := 3000
+	scene, err := m.CreateScene("Evening", "manual", map[string]lights.DeviceState{
+		"virtual:desk":  {On: true, Brightness: 0.8, Color: &lights.Color{H: 30, S: 0.9, B: 1}},
+		"virtual:shelf": {On: true, Brightness: 0.4, Kelvin: &kelvin},
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateScene: %v", err)
+	}
+
+	var activated string
+	m.OnChange(func(s store.Scene) { activated = s.ID })
+	if err := m.ActivateScene(context.Background(), scene.ID); err != nil {
+		t.Fatalf("ActivateScene: %v", err)
+	}
+	if activated != scene.ID || m.GetActiveScene() != scene.ID {
+		t.Fatalf("scene not marked active")
+	}
+
+	waitForState(t, lm, "virtual:desk", func(st lights.DeviceState) bool {
+		return st.On && st.Brightness == 0.8 && st.Color != nil && st.Color.H == 30
+	})
+	waitForState(t, lm, "virtual:shelf", func(st lights.DeviceState) bool {
+		return st.On && st.Kelvin != nil && *st.Kelvin == 3000
+	})
+
+	// The desk can't fade natively, so the crossfade is emulated in steps;
+	// the shelf gets one command carrying the transition.
+	if n := len(lm.CommandHistory("virtual:desk")); n < 3 {
+		t.Fatalf("expected a stepped software fade on the desk, got %d command(s)", n)
+	}
+	shelf := lm.CommandHistory("virtual:shelf")
+	if len(shelf) != 1 || shelf[0].State.Transition(0) != 500*time.Millisecond {
+		t.Fatalf("expected one native 500ms transition on the shelf, got %+v", shelf)
+	}
+}
+
+func TestOnCameraStateChange_ActivatesTriggeredScene(t *testing.T) {
+	m, lm := newTestManager(t)
+	if _, err := m.CreateScene("Call", "camera_on", map[string]lights.DeviceState{
+		"virtual:shelf": {On: true, Brightness: 1},
+	}, nil, nil, nil); err != nil {
+		t.Fatalf("CreateScene: %v", err)
+	}
+	if _, err := m.CreateScene("Off", "camera_off", map[string]lights.DeviceState{
+		"virtual:shelf": {On: false},
+	}, nil, nil, nil); err != nil {
+		t.Fatalf("CreateScene: %v", err)
+	}
+
+	m.OnCameraStateChange(context.Background(), true)
+	waitForState(t, lm, "virtual:shelf", func(st lights.DeviceState) bool { return st.On })
+	m.OnCameraStateChange(context.Background(), false)
+	waitForState(t, lm, "virtual:shelf", func(st lights.DeviceState) bool { return !st.On })
+}
+
+func TestActivateScene_ResolvesGroups(t *testing.T) {
+	m, lm := newTestManager(t)
+	if _, err := lm.DiscoverAllWithProgress(context.Background(), nil); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	lm.SetGroups([]lights.Group{{ID: "office", Name: "Office", Room: "Office"}})
+	scene, err := m.CreateScene("Focus", "manual", map[string]lights.DeviceState{
+		lights.GroupRef("office"): {On: true, Brightness: 0.6},
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateScene: %v", err)
+	}
+
+	// Both lights are placed in the office after the scene was saved.
+	lm.SetDeviceRoom("virtual:desk", "Office")
+	lm.SetDeviceRoom("virtual:shelf", "Office")
+	var activated store.Scene
+	m.OnChange(func(s store.Scene) { activated = s })
+	if err := m.ActivateScene(context.Background(), scene.ID); err != nil {
+		t.Fatalf("ActivateScene: %v", err)
+	}
+	if len(activated.Devices) != 2 {
+		t.Fatalf("expected the scene reported with the group's members, got %+v", activated.Devices)
+	}
+	for _, id := range []string{"virtual:desk", "virtual:shelf"} {
+		waitForState(t, lm, id, func(st lights.DeviceState) bool { return st.On && st.Brightness == 0.6 })
+	}
+}
@@ -33,6 +33,9 @@ type Engine struct {
 	config   store.ScreenSyncConfig
 	lightMgr *lights.Manager
 
+	// newCapturer opens the screen source; tests substitute a fixed image.
+	newCapturer func(store.ScreenSyncConfig) (capture.Capturer, error)
+
 	// Event callbacks (set once before Start, not changed concurrently).
 	onColors func([]lights.Color)
 	onStats  func(Stats)
@@ -49,19 +52,23 @@ type Engine struct {
 	done    chan struct{} // closed when run() exits
 	running bool
 
-	// lastSent tracks the most recently transmitted color per device so we can
-	// skip sends when the color hasn't changed visibly.
+	// lastSent tracks the most recently transmitted color per device (and
+	// lastZones the segments of zoned devices) so we can skip sends when the
+	// color hasn't changed visibly.
 	lastSentMu sync.Mutex
 	lastSent   map[string]lights.Color
+	lastZones  map[string][]lights.Color
 
-	// Per-brand send slots so Hue throttling doesn't block LIFX/Govee/Elgato.
-	// Each slot is a permit; acquiring it means we can send to that brand.
-	brandSlots         map[lights.Brand]chan struct{}
-	initBrandSlotsOnce sync.Once
+	// pendingSend holds the colours of devices whose previous send is
+	// still in flight (sendInFlight); each device has at most one.
+	sendMu       sync.Mutex
+	pendingSend  map[string]lights.DeviceState
+	pendingCtx   context.Context
+	sendInFlight map[string]bool
 
-	// Hue bridge rate limit: ~10 req/sec. We throttle Hue batches.
-	hueLastSend   time.Time
-	hueLastSendMu sync.Mutex
+	// supersededSeen is the light manager's coalesced + dropped command
+	// count for this session's devices at the last stats tick.
+	supersededSeen atomic.Uint64
 
 	// Preview frame: a JPEG snapshot of the captured image, updated at ~1 fps.
 	// Only produced when the popout has recently called GetPreviewFrame.
@@ -71,27 +78,19 @@ type Engine struct {
 	previewRequested int32 // atomic: >0 means someone wants previews
 }
 
-func (e *Engine) ensureBrandSlots() {
-	e.initBrandSlotsOnce.Do(func() {
-		brands := []lights.Brand{lights.BrandLIFX, lights.BrandHue, lights.BrandElgato, lights.BrandGovee}
-		e.brandSlots = make(map[lights.Brand]chan struct{}, len(brands))
-		for _, b := range brands {
-			ch := make(chan struct{}, 1)
-			ch <- struct{}{} // initial permit
-			e.brandSlots[b] = ch
-		}
-	})
-}
-
 // NewEngine creates an Engine that uses lm to apply light states.
 func NewEngine(lm *lights.Manager) *Engine {
 	return &Engine{
-		lightMgr:    lm,
-		sceneChange: process.NewSceneChangeDetector(),
-		smoother:    process.NewTemporalSmoother(),
-		handoff:     newColorHandoffBlender(),
-		stats:       newStatsCollector(),
-		lastSent:    make(map[string]lights.Color),
+		lightMgr:     lm,
+		newCapturer:  capture.NewCapturer,
+		sceneChange:  process.NewSceneChangeDetector(),
+		smoother:     process.NewTemporalSmoother(),
+		handoff:      newColorHandoffBlender(),
+		stats:        newStatsCollector(),
+		lastSent:     make(map[string]lights.Color),
+		lastZones:    make(map[string][]lights.Color),
+		pendingSend:  make(map[string]lights.DeviceState),
+		sendInFlight: make(map[string]bool),
 	}
 }
 
@@ -105,7 +104,8 @@ func (e *Engine) OnStats(fn func(Stats)) { e.onStats = fn }
 func (e *Engine) OnState(fn func(running bool)) { e.onState = fn }
 
 // Start begins a new capture loop using the given config.
-// If the engine is already running it is stopped first.
+// If the engine is already running it is stopped first. Group references in
+// cfg.DeviceIDs are resolved to the groups' members.
 func (e *Engine) Start(cfg store.ScreenSyncConfig) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -116,6 +116,7 @@ func (e *Engine) Start(cfg store.ScreenSyncConfig) error {
 	}
 
 	store.NormalizeScreenSyncConfig(&cfg)
+	cfg.DeviceIDs = e.lightMgr.ResolveDeviceIDs(cfg.DeviceIDs)
 	e.config = cfg
 	e.sceneChange.Reset()
 	e.smoother.Reset()
@@ -124,16 +125,17 @@ func (e *Engine) Start(cfg store.ScreenSyncConfig) error {
 	e.stats.reset()
 	e.lastSentMu.Lock()
 	e.lastSent = make(map[string]lights.Color)
+	e.lastZones = make(map[string][]lights.Color)
 	e.lastSentMu.Unlock()
-	e.hueLastSendMu.Lock()
-	e.hueLastSend = time.Time{} // reset so first Hue send isn't rate-limited
-	e.hueLastSendMu.Unlock()
+	e.supersededSeen.Store(e.superseded(cfg.DeviceIDs))
 
 	// Seed preview so the first few frames generate a thumbnail immediately
 	// (e.g. after a capture-mode switch restarts the engine).
 	atomic.StoreInt32(&e.previewRequested, 3)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// Every command the session sends is attributed to screen sync in the
+	// light manager's state cache.
+	ctx, cancel := context.WithCancel(lights.WithSource(context.Background(), lights.SourceScreenSync))
 	e.cancel = cancel
 	e.done = make(chan struct{})
 	e.running = true
@@ -256,6 +258,7 @@ func (e *Engine) maybeStorePreview(img image.Image) {
 // parameters are hot-reloaded.
 func (e *Engine) UpdateConfig(cfg store.ScreenSyncConfig) {
 	store.NormalizeScreenSyncConfig(&cfg)
+	cfg.DeviceIDs = e.lightMgr.ResolveDeviceIDs(cfg.DeviceIDs)
 	e.mu.Lock()
 	oldMode := e.config.CaptureMode
 	oldMonitor := e.config.MonitorIndex
@@ -303,12 +306,30 @@ func (e *Engine) run(ctx context.Context) {
 	}()
 
 	cfg := e.getConfig()
-	capturer, err := capture.NewCapturer(cfg)
+	capturer, err := e.newCapturer(cfg)
 	if err != nil {
 		return
 	}
 	defer capturer.Close()
 
+	// Devices whose brand supports a realtime stream (Hue Entertainment) are
+	// driven through it instead of per-device commands for this session.
+	streams := e.lightMgr.StartStreams(ctx, cfg.DeviceIDs)
+	defer streams.Close()
+
+	// Devices that know their physical layout (Nanoleaf panels, LIFX strips
+	// and tiles, WLED) get one colour per segment, sampled where that
+	// segment sits, whether streamed or sent as commands. Each has its own
+	// smoother since its colours don't go through the assigner.
+	layouts := make(map[string][]lights.Point)
+	zoneSmoothers := make(map[string]*process.TemporalSmoother)
+	for _, id := range cfg.DeviceIDs {
+		if points := e.lightMgr.Layout(id); len(points) > 1 {
+			layouts[id] = points
+			zoneSmoothers[id] = process.NewTemporalSmoother()
+		}
+	}
+
 	// DispatchExtractor reads cfg dynamically each frame — no need to replace it.
 	extractor := extract.New(cfg)
 
@@ -330,6 +351,7 @@ func (e *Engine) run(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-statsTicker.C:
+				e.recordSuperseded(e.getConfig().DeviceIDs)
 				if e.onStats != nil {
 					e.onStats(e.stats.snapshot(e.getConfig().SpeedPreset))
 				}
@@ -378,6 +400,17 @@ func (e *Engine) run(ctx context.Context) {
 		// ── 4. Temporal smoothing (adaptive EMA, resets on scene cut). ───────
 		colors = e.smoother.Smooth(colors, isCut, cfg.ColorSmoothing, cfg.BrightnessSmoothing, cfg.BrightnessMaxDeviation, cfg.BrightnessFloor, cfg.BrightnessCeiling)
 
+		// ── 4b. Per-segment colours for devices with a layout (spatial only).
+		var deviceZones map[string][]lights.Color
+		if len(layouts) > 0 && cfg.ColorMode == store.ColorModeMulti && cfg.MultiColorApproach != store.MultiColorScenePalette {
+			deviceZones = make(map[string][]lights.Color, len(layouts))
+			for id, points := range layouts {
+				zones := extract.LayoutColors(img, points, cfg)
+				zones = process.ApplyAdjustments(zones, cfg)
+				deviceZones[id] = zoneSmoothers[id].Smooth(zones, isCut, cfg.ColorSmoothing, cfg.BrightnessSmoothing, cfg.BrightnessMaxDeviation, cfg.BrightnessFloor, cfg.BrightnessCeiling)
+			}
+		}
+
 		// ── 5. Assign colors to devices. ────────────────────────────────────
 		currentOutput := prevOutput
 		if currentOutput == nil {
@@ -423,13 +456,22 @@ func (e *Engine) run(ctx context.Context) {
 				faded[id] = lights.Color{H: c.H, S: c.S, B: c.B * fade}
 			}
 			deviceColors = faded
+			for id, zones := range deviceZones {
+				fadedZones := make([]lights.Color, len(zones))
+				for i, c := range zones {
+					fadedZones[i] = lights.Color{H: c.H, S: c.S, B: c.B * fade}
+				}
+				deviceZones[id] = fadedZones
+			}
 		}
 
-		// ── 7. Send to lights (per-brand pipelines). Hue throttling doesn't block LIFX/etc.
+		// ── 7. Send to lights. The light manager paces rate-limited devices
+		// and keeps only their newest frame, so a throttled Hue bridge
+		// doesn't hold up LIFX/etc.
 		procEnd := time.Now()
 		captureMs := captureEnd.Sub(frameStart)
 		processMs := procEnd.Sub(captureEnd)
-		e.sendDeviceColorsByBrand(ctx, deviceColors, fade > 0, procEnd.Sub(frameStart))
+		e.sendDeviceColors(ctx, streams, deviceColors, deviceZones, fade > 0, procEnd.Sub(frameStart))
 		e.stats.recordFrame(procEnd.Sub(frameStart), captureMs, processMs, 0)
 
 		// Emit the output colors (first N values for the UI preview).
@@ -457,119 +499,174 @@ func (e *Engine) run(ctx context.Context) {
 	}
 }
 
-// sendDeviceColorsByBrand partitions toSend by brand and sends each brand in its own
-// goroutine. Each brand has a permit slot; if a brand is still sending the previous
-// frame, we skip that brand this frame (recordDrop) so Hue throttling doesn't block LIFX.
-func (e *Engine) sendDeviceColorsByBrand(ctx context.Context, deviceColors map[string]lights.Color, doSend bool, latency time.Duration) {
+// sendDeviceColors sends the frame's changed colours in the background. It
+// doesn't wait for the previous frame, but each device has at most one send
+// in flight: a state produced meanwhile waits, newest per device, and goes
+// out once that send returns, so a slow device neither holds up the rest nor
+// piles up goroutines. The light manager in turn queues each device's newest
+// state within its rate budget and reports the frames it superseded (see
+// recordSuperseded). Devices covered by streams bypass the manager's
+// queues entirely: streams buffer the latest frame and never block. Devices
+// with an entry in deviceZones get per-segment colours; streamed ones are
+// sent every frame, the rest when a segment changed.
+func (e *Engine) sendDeviceColors(ctx context.Context, streams *lights.StreamSet, deviceColors map[string]lights.Color, deviceZones map[string][]lights.Color, doSend bool, latency time.Duration) {
 	if len(deviceColors) == 0 || !doSend {
 		return
 	}
 
 	e.lastSentMu.Lock()
-	toSend := make(map[string]lights.Color, len(deviceColors))
+	toSend := make(map[string]lights.DeviceState, len(deviceColors))
 	for id, c := range deviceColors {
+		if zones, ok := deviceZones[id]; ok {
+			if streams.Covers(id) || zonesChangedEnoughToSend(e.lastZones[id], zones) {
+				toSend[id] = zonesFrameState(zones)
+				e.lastZones[id] = zones
+				e.lastSent[id] = c
+			}
+			continue
+		}
 		prev, seen := e.lastSent[id]
 		if !seen || colorChangedEnoughToSend(prev, c) {
-			toSend[id] = c
+			toSend[id] = frameState(c)
+			e.lastSent[id] = c
 		}
 	}
 	e.lastSentMu.Unlock()
 
+	streamed := make(map[string]lights.DeviceState)
+	for id, st := range toSend {
+		if streams.Covers(id) {
+			streamed[id] = st
+			delete(toSend, id)
+		}
+	}
+	if len(streamed) > 0 {
+		streams.Send(streamed)
+		e.stats.recordSend(len(streamed))
+	}
 	if len(toSend) == 0 {
 		return
 	}
 
-	byBrand := make(map[lights.Brand]map[string]lights.Color)
-	for id, c := range toSend {
-		brand := lights.BrandFromDeviceID(id)
-		if brand == "" {
-			continue
-		}
-		if byBrand[brand] == nil {
-			byBrand[brand] = make(map[string]lights.Color)
+	e.sendMu.Lock()
+	replaced := 0
+	for id, st := range toSend {
+		if _, ok := e.pendingSend[id]; ok {
+			replaced++
 		}
-		byBrand[brand][id] = c
+		e.pendingSend[id] = st
 	}
+	e.pendingCtx = ctx
+	batch, batchCtx := e.takeReadySends()
+	e.sendMu.Unlock()
+	e.stats.recordDrops(replaced)
+	if len(batch) > 0 {
+		go e.flushSends(batchCtx, batch)
+	}
+}
 
-	e.ensureBrandSlots()
-	for brand, batch := range byBrand {
-		if len(batch) == 0 {
+// takeReadySends moves the pending states of devices with no send in
+// flight into a new batch and marks them in flight. e.sendMu must be held.
+func (e *Engine) takeReadySends() (map[string]lights.DeviceState, context.Context) {
+	batch := make(map[string]lights.DeviceState)
+	for id, st := range e.pendingSend {
+		if e.sendInFlight[id] {
 			continue
 		}
-		slot, ok := e.brandSlots[brand]
-		if !ok {
-			continue
-		}
-		// Hue bridge: ~10 req/sec. Space batches so we don't hit 429.
-		// Skip (don't record drop — this is intentional throttle, not blocking).
-		if brand == lights.BrandHue {
-			e.hueLastSendMu.Lock()
-			minInterval := time.Duration(len(batch)*10) * time.Millisecond // 100ms per req
-			if minInterval < 100*time.Millisecond {
-				minInterval = 100 * time.Millisecond
-			}
-			elapsed := time.Since(e.hueLastSend)
-			e.hueLastSendMu.Unlock()
-			if elapsed < minInterval {
-				continue
-			}
-		}
-		select {
-		case <-slot:
-			// Acquired permit — update lastSent and send in background.
-			e.lastSentMu.Lock()
-			for id, c := range batch {
-				e.lastSent[id] = c
-			}
-			e.lastSentMu.Unlock()
-			brandCopy := brand
-			go func(slotCh chan struct{}, b lights.Brand, colors map[string]lights.Color) {
-				defer func() { slotCh <- struct{}{} }()
-				sendStart := time.Now()
-				count := e.sendBatch(ctx, colors)
-				e.stats.recordSend(count)
-				e.stats.recordSendDuration(time.Since(sendStart))
-				if b == lights.BrandHue {
-					e.hueLastSendMu.Lock()
-					e.hueLastSend = time.Now()
-					e.hueLastSendMu.Unlock()
-				}
-			}(slot, brandCopy, batch)
-		default:
-			// Brand still busy — skip. Don't record Hue drops (expected to be slow).
-			if brand != lights.BrandHue {
-				e.stats.recordDrop()
-			}
+		batch[id] = st
+		e.sendInFlight[id] = true
+		delete(e.pendingSend, id)
+	}
+	return batch, e.pendingCtx
+}
+
+// flushSends hands batch to the light manager, then the states that
+// arrived for its devices meanwhile, until none is left.
+func (e *Engine) flushSends(ctx context.Context, batch map[string]lights.DeviceState) {
+	for len(batch) > 0 {
+		sendStart := time.Now()
+		count := e.sendBatch(ctx, batch)
+		e.stats.recordSend(count)
+		e.stats.recordSendDuration(time.Since(sendStart))
+
+		e.sendMu.Lock()
+		for id := range batch {
+			delete(e.sendInFlight, id)
 		}
+		batch, ctx = e.takeReadySends()
+		e.sendMu.Unlock()
+	}
+}
+
+// superseded sums the commands the light manager coalesced or dropped for
+// deviceIDs.
+func (e *Engine) superseded(deviceIDs []string) uint64 {
+	metrics := e.lightMgr.CommandMetrics()
+	var n uint64
+	for _, id := range deviceIDs {
+		n += metrics[id].Coalesced + metrics[id].Dropped
+	}
+	return n
+}
+
+// recordSuperseded counts the frames the light manager superseded since the
+// last call as drops.
+func (e *Engine) recordSuperseded(deviceIDs []string) {
+	n := e.superseded(deviceIDs)
+	if prev := e.supersededSeen.Swap(n); n > prev {
+		e.stats.recordDrops(int(n - prev))
 	}
 }
 
-// sendBatch sends a device→color map to the light manager. Devices are updated
-// concurrently. Returns the number of SetState calls performed.
-func (e *Engine) sendBatch(ctx context.Context, batch map[string]lights.Color) int {
+// sendBatch sends a device→state map to the light manager in one
+// SetDeviceStates call, so controllers with a batch path (one LIFX socket,
+// one Hue HTTP/2 burst) can use it. Returns the number of devices updated.
+func (e *Engine) sendBatch(ctx context.Context, batch map[string]lights.DeviceState) int {
 	if len(batch) == 0 {
 		return 0
 	}
 	sendCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	var wg sync.WaitGroup
-	for devID, c := range batch {
-		wg.Add(1)
-		go func(id string, col lights.Color) {
-			defer wg.Done()
-			state := lights.DeviceState{
-				On:         true,
-				Brightness: col.B,
-				Color:      &lights.Color{H: col.H, S: col.S, B: 1.0},
-			}
-			_ = e.lightMgr.SetDeviceState(sendCtx, id, state)
-		}(devID, c)
-	}
-	wg.Wait()
+	_ = e.lightMgr.SetDeviceStates(sendCtx, batch)
 	return len(batch)
 }
 
+// frameState converts a screen sync colour to a device state. Real-time
+// frames must land immediately; any device-side fade would smear consecutive
+// frames together.
+func frameState(col lights.Color) lights.DeviceState {
+	return lights.DeviceState{
+		On:         true,
+		Brightness: col.B,
+		Color:      &lights.Color{H: col.H, S: col.S, B: 1.0},
+	}.WithTransition(0)
+}
+
+// zonesFrameState is frameState for a device driven one segment at a time.
+// Each zone carries its own brightness.
+func zonesFrameState(zones []lights.Color) lights.DeviceState {
+	return lights.DeviceState{
+		On:         true,
+		Brightness: 1.0,
+		Zones:      zones,
+	}.WithTransition(0)
+}
+
+// zonesChangedEnoughToSend reports whether any segment of curr changed
+// enough from prev to send (see colorChangedEnoughToSend).
+func zonesChangedEnoughToSend(prev, curr []lights.Color) bool {
+	if len(prev) != len(curr) {
+		return true
+	}
+	for i := range curr {
+		if colorChangedEnoughToSend(prev[i], curr[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 // colorChangedEnoughToSend returns true when the RGB difference between two
 // HSB colours exceeds the threshold on any channel. Lower = smoother transitions
 // (more commands); higher = fewer commands, can cause visible stepping.
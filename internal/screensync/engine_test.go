@@ -0,0 +1,146 @@
+package screensync
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+
+	"lightsync/internal/lights"
+	"lightsync/internal/screensync/capture"
+	"lightsync/internal/store"
+)
+
+// solidCapturer returns the same single-colour frame every time.
+type solidCapturer struct{ img image.Image }
+
+func (c solidCapturer) Capture() (image.Image, error) { return c.img, nil }
+func (c solidCapturer) Close()                        {}
+
+func TestEngine_DrivesVirtualLights(t *testing.T) {
+	virtual := lights.NewVirtualController()
+	virtual.SetDevices([]lights.VirtualDevice{{ID: "tv", Name: "TV backlight"}})
+	lm := lights.NewManager()
+	lm.RegisterController(virtual)
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 20, G: 40, B: 230, A: 255}}, image.Point{}, draw.Src)
+
+	e := NewEngine(lm)
+	e.newCapturer = func(store.ScreenSyncConfig) (capture.Capturer, error) {
+		return solidCapturer{img: img}, nil
+	}
+	cfg := store.DefaultScreenSyncConfig()
+	cfg.DeviceIDs = []string{"virtual:tv"}
+	if err := e.Start(cfg); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer e.Stop()
+
+	// Nothing is sent during the 2s calibration, then the fade-in begins.
+	deadline := time.Now().Add(6 * time.Second)
+	for time.Now().Before(deadline) {
+		history := lm.CommandHistory("virtual:tv")
+		if len(history) > 0 {
+			st := history[len(history)-1].State
+			if !st.On || st.Color == nil || math.Abs(st.Color.H-235) > 10 || st.Transition(-1) != 0 {
+				t.Fatalf("expected an instant blue frame, got %+v", st)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("engine never drove the virtual light")
+}
+
+func TestEngine_ZonesWithoutStream(t *testing.T) {
+	virtual := lights.NewVirtualController()
+	virtual.SetDevices([]lights.VirtualDevice{{
+		ID:           "strip",
+		Capabilities: lights.Capabilities{Color: true, Segments: 2},
+		Layout:       []lights.Point{{X: 0.25, Y: 0.5}, {X: 0.75, Y: 0.5}},
+	}})
+	lm := lights.NewManager()
+	lm.RegisterController(virtual)
+
+	// Red on the left half, blue on the right.
+	img := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	draw.Draw(img, image.Rect(0, 0, 32, 36), &image.Uniform{C: color.RGBA{R: 230, A: 255}}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(32, 0, 64, 36), &image.Uniform{C: color.RGBA{B: 230, A: 255}}, image.Point{}, draw.Src)
+
+	e := NewEngine(lm)
+	e.newCapturer = func(store.ScreenSyncConfig) (capture.Capturer, error) {
+		return solidCapturer{img: img}, nil
+	}
+	cfg := store.DefaultScreenSyncConfig()
+	cfg.ColorMode = store.ColorModeMulti
+	cfg.MultiColorApproach = store.MultiColorSpatialGrid
+	cfg.DeviceIDs = []string{"virtual:strip"}
+	if err := e.Start(cfg); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer e.Stop()
+
+	deadline := time.Now().Add(6 * time.Second)
+	for time.Now().Before(deadline) {
+		history := lm.CommandHistory("virtual:strip")
+		if len(history) > 0 {
+			zones := history[len(history)-1].State.Zones
+			if len(zones) != 2 || math.Abs(zones[0].H) > 10 || math.Abs(zones[1].H-240) > 10 {
+				t.Fatalf("expected a red and a blue zone, got %+v", zones)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("engine never drove the strip")
+}
+
+// slowVirtual is a virtual controller whose batch path takes delay.
+type slowVirtual struct {
+	*lights.VirtualController
+	delay time.Duration
+}
+
+func (c slowVirtual) SetStates(ctx context.Context, states map[string]lights.DeviceState) error {
+	time.Sleep(c.delay)
+	for id, st := range states {
+		if err := c.SetState(ctx, id, st); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestEngine_MergesFramesWhileSending(t *testing.T) {
+	virtual := slowVirtual{VirtualController: lights.NewVirtualController(), delay: 200 * time.Millisecond}
+	virtual.SetDevices([]lights.VirtualDevice{{ID: "tv"}})
+	lm := lights.NewManager()
+	lm.RegisterController(virtual)
+	e := NewEngine(lm)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		col := lights.Color{H: float64(i * 5), S: 1, B: 1}
+		e.sendDeviceColors(context.Background(), nil, map[string]lights.Color{"virtual:tv": col}, nil, true, 0)
+	}
+	if n := runtime.NumGoroutine() - before; n > 5 {
+		t.Fatalf("expected frames to wait for the send in flight, %d goroutines started", n)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		history := lm.CommandHistory("virtual:tv")
+		if len(history) > 0 {
+			if st := history[len(history)-1].State; st.Color != nil && st.Color.H == 245 {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected the newest frame to be sent, got %+v", lm.CommandHistory("virtual:tv"))
+}
@@ -64,6 +64,34 @@ func spatialColors(img image.Image, n int, method store.ExtractionMethod, whiteB
 	return colors
 }
 
+// LayoutColors extracts one color per point of a device's physical layout
+// (e.g. Nanoleaf panels), each from a cell centred on the point's position in
+// the frame. Cells are sized like a spatial grid of the same count, so
+// neighbouring segments sample neighbouring areas; layouts that already form
+// a grid (a strip is one row) use their own columns and rows.
+func LayoutColors(img image.Image, points []lights.Point, cfg store.ScreenSyncConfig) []lights.Color {
+	bounds := img.Bounds()
+	w := bounds.Dx()
+	h := bounds.Dy()
+
+	cols, rows := layoutDims(points)
+	cellW := max(1, w/cols)
+	cellH := max(1, h/rows)
+
+	colors := make([]lights.Color, 0, len(points))
+	for _, p := range points {
+		cx := bounds.Min.X + int(p.X*float64(w))
+		cy := bounds.Min.Y + int(p.Y*float64(h))
+		x0 := min(max(cx-cellW/2, bounds.Min.X), bounds.Max.X-cellW)
+		y0 := min(max(cy-cellH/2, bounds.Min.Y), bounds.Max.Y-cellH)
+		sub := image.Rect(x0, y0, x0+cellW, y0+cellH).Intersect(bounds)
+		colors = append(colors, extractCell(img, sub, cfg.SubMethod, cfg.WhiteBias))
+	}
+
+	replaceBlackCells(colors, 0.05)
+	return colors
+}
+
 func extractCell(img image.Image, bounds image.Rectangle, method store.ExtractionMethod, whiteBias float64) lights.Color {
 	switch method {
 	case store.ExtractionMethodBrightest:
@@ -132,6 +160,23 @@ func mostSaturatedInCell(img image.Image, bounds image.Rectangle, whiteBias floa
 	return lights.Color{H: h, S: s, B: bv}
 }
 
+// layoutDims returns the columns and rows of points when every combination
+// of their distinct X and Y positions is used, so a strip gets full-height
+// cells and a panel of tiles its own lattice. Other layouts fall back to
+// gridDims.
+func layoutDims(points []lights.Point) (cols, rows int) {
+	xs := make(map[float64]bool)
+	ys := make(map[float64]bool)
+	for _, p := range points {
+		xs[p.X] = true
+		ys[p.Y] = true
+	}
+	if len(xs)*len(ys) == len(points) {
+		return len(xs), len(ys)
+	}
+	return gridDims(len(points))
+}
+
 // gridDims returns (cols, rows) for a grid that holds at least n cells,
 // arranged as squarely as possible.
 func gridDims(n int) (cols, rows int) {
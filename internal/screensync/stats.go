@@ -17,7 +17,7 @@ type Stats struct {
 	ProcessMs           float64 `json:"processMs"` // avg time spent in extract+process
 	SendMs              float64 `json:"sendMs"`    // avg time spent sending to lights
 	UpdateRate          float64 `json:"updateRate"`   // device updates (SetState calls) per second
-	FramesDropped       int     `json:"framesDropped"` // frames skipped (previous send in flight)
+	FramesDropped       int     `json:"framesDropped"` // device updates superseded by a newer frame before they were sent
 	FramesDroppedPct    float64 `json:"framesDroppedPct"`
 	SceneChange         bool    `json:"sceneChange"`
 	CutReasonBrightness bool    `json:"cutReasonBrightness"` // brightness jump ≥ 40% triggered the cut
@@ -35,7 +35,7 @@ type statsCollector struct {
 	sendTotal           time.Duration
 	lastReset           time.Time
 	sendCount           int   // frames where we actually sent to lights
-	dropCount           int   // frames we wanted to send but skipped (prev send in flight)
+	dropCount           int   // device updates superseded before they were sent
 	deviceUpdatesTotal  int64 // total SetState calls across all sends
 	sceneChanged        bool
 	cutReasonBrightness bool
@@ -106,10 +106,11 @@ func (s *statsCollector) recordSendDuration(d time.Duration) {
 	s.mu.Unlock()
 }
 
-// recordDrop records a frame where we wanted to send but skipped (previous send in flight).
-func (s *statsCollector) recordDrop() {
+// recordDrops records device updates the light manager superseded with a
+// newer frame before sending them.
+func (s *statsCollector) recordDrops(n int) {
 	s.mu.Lock()
-	s.dropCount++
+	s.dropCount += n
 	s.mu.Unlock()
 }
 
@@ -107,7 +107,8 @@ type ScreenSyncConfig struct {
 	WindowHWND   uint64      `json:"windowHwnd,omitempty"`
 	WindowTitle  string      `json:"windowTitle,omitempty"`
 
-	// Devices assigned to this screen sync scene.
+	// Devices assigned to this screen sync scene. Group references
+	// ("group:<id>") stand for the group's members.
 	DeviceIDs []string `json:"deviceIds"`
 
 	// Color extraction
@@ -14,7 +14,7 @@ type Scene struct {
 	ID      string                        `json:"id"`
 	Name    string                        `json:"name"`
 	Trigger string                        `json:"trigger"`
-	Devices map[string]lights.DeviceState `json:"devices"`
+	Devices map[string]lights.DeviceState `json:"devices"` // keyed by device ID or group reference ("group:<id>")
 	// GlobalColor/GlobalKelvin persist the editor's global override so it can
 	// be restored when the scene is re-opened for editing.
 	GlobalColor  *lights.Color `json:"globalColor,omitempty"`
@@ -34,15 +34,28 @@ type Config struct {
 	Devices  []lights.Device `json:"devices"`
 	Scenes   []Scene         `json:"scenes"`
 	Settings Settings        `json:"settings"`
+	Groups   []lights.Group  `json:"groups,omitempty"`
 
-	HueBridges  []HueBridge `json:"hueBridges,omitempty"`
-	LastSceneID string      `json:"lastSceneId,omitempty"`
+	HueBridges      []HueBridge            `json:"hueBridges,omitempty"`
+	NanoleafDevices []NanoleafDevice       `json:"nanoleafDevices,omitempty"`
+	DMXFixtures     []lights.DMXFixture    `json:"dmxFixtures,omitempty"`
+	VirtualDevices  []lights.VirtualDevice `json:"virtualDevices,omitempty"`
+	ManualAddresses []lights.ManualAddress `json:"manualAddresses,omitempty"`
+	LastSceneID     string                 `json:"lastSceneId,omitempty"`
 }
 
 type HueBridge struct {
-	ID       string `json:"id"`
-	IP       string `json:"ip"`
-	Username string `json:"username"`
+	ID        string `json:"id"`
+	IP        string `json:"ip"`
+	Username  string `json:"username"`
+	ClientKey string `json:"clientKey,omitempty"`
+}
+
+// NanoleafDevice is a paired Nanoleaf controller and its OpenAPI token.
+type NanoleafDevice struct {
+	ID    string `json:"id"`
+	IP    string `json:"ip"`
+	Token string `json:"token"`
 }
 
 type Store struct {
@@ -125,6 +138,45 @@ func (s *Store) SetHueBridges(bridges []HueBridge) error {
 	return s.saveLocked()
 }
 
+func (s *Store) GetNanoleafDevices() []NanoleafDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]NanoleafDevice(nil), s.config.NanoleafDevices...)
+}
+
+func (s *Store) SetNanoleafDevices(devices []NanoleafDevice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.NanoleafDevices = devices
+	return s.saveLocked()
+}
+
+func (s *Store) GetDMXFixtures() []lights.DMXFixture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]lights.DMXFixture(nil), s.config.DMXFixtures...)
+}
+
+func (s *Store) SetDMXFixtures(fixtures []lights.DMXFixture) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.DMXFixtures = fixtures
+	return s.saveLocked()
+}
+
+func (s *Store) GetVirtualDevices() []lights.VirtualDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]lights.VirtualDevice(nil), s.config.VirtualDevices...)
+}
+
+func (s *Store) SetVirtualDevices(devices []lights.VirtualDevice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.VirtualDevices = devices
+	return s.saveLocked()
+}
+
 func (s *Store) GetLastSceneID() string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -163,6 +215,133 @@ func (s *Store) DeleteScene(id string) error {
 	return s.saveLocked()
 }
 
+func (s *Store) GetManualAddresses() []lights.ManualAddress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]lights.ManualAddress(nil), s.config.ManualAddresses...)
+}
+
+func (s *Store) SetManualAddresses(addrs []lights.ManualAddress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.ManualAddresses = addrs
+	return s.saveLocked()
+}
+
+func (s *Store) GetGroups() []lights.Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]lights.Group(nil), s.config.Groups...)
+}
+
+func (s *Store) UpsertGroup(group lights.Group) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, g := range s.config.Groups {
+		if g.ID == group.ID {
+			s.config.Groups[i] = group
+			return s.saveLocked()
+		}
+	}
+	s.config.Groups = append(s.config.Groups, group)
+	return s.saveLocked()
+}
+
+// DeleteGroup removes a group and every scene's reference to it.
+func (s *Store) DeleteGroup(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, g := range s.config.Groups {
+		if g.ID == id {
+			s.config.Groups = append(s.config.Groups[:i], s.config.Groups[i+1:]...)
+			break
+		}
+	}
+	ref := lights.GroupRef(id)
+	for i := range s.config.Scenes {
+		sc := &s.config.Scenes[i]
+		delete(sc.Devices, ref)
+		if sc.ScreenSync != nil {
+			ids := sc.ScreenSync.DeviceIDs[:0]
+			for _, deviceID := range sc.ScreenSync.DeviceIDs {
+				if deviceID != ref {
+					ids = append(ids, deviceID)
+				}
+			}
+			sc.ScreenSync.DeviceIDs = ids
+		}
+	}
+	return s.saveLocked()
+}
+
+// MigrateDeviceIDs rewrites device IDs in the saved device list and in
+// every scene and group that references them, including screen sync device
+// lists.
+// ids maps old IDs to new ones.
+func (s *Store) MigrateDeviceIDs(ids map[string]string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices := s.config.Devices[:0]
+	seen := make(map[string]int, len(s.config.Devices))
+	for _, d := range s.config.Devices {
+		if newID, ok := ids[d.ID]; ok {
+			d.ID = newID
+		}
+		if i, ok := seen[d.ID]; ok {
+			// Already listed under the new ID: keep a calibration only the
+			// duplicate has.
+			if devices[i].Calibration == nil {
+				devices[i].Calibration = d.Calibration
+			}
+			continue
+		}
+		seen[d.ID] = len(devices)
+		devices = append(devices, d)
+	}
+	s.config.Devices = devices
+
+	for i := range s.config.Scenes {
+		sc := &s.config.Scenes[i]
+		for oldID, newID := range ids {
+			st, ok := sc.Devices[oldID]
+			if !ok {
+				continue
+			}
+			delete(sc.Devices, oldID)
+			if _, exists := sc.Devices[newID]; !exists {
+				sc.Devices[newID] = st
+			}
+		}
+		if sc.ScreenSync != nil {
+			sc.ScreenSync.DeviceIDs = migrateIDList(sc.ScreenSync.DeviceIDs, ids)
+		}
+	}
+	for i := range s.config.Groups {
+		s.config.Groups[i].DeviceIDs = migrateIDList(s.config.Groups[i].DeviceIDs, ids)
+	}
+	return s.saveLocked()
+}
+
+// migrateIDList renames the IDs in list, dropping any that become duplicates.
+func migrateIDList(list []string, ids map[string]string) []string {
+	out := make([]string, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	for _, id := range list {
+		if newID, ok := ids[id]; ok {
+			id = newID
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
 func (s *Store) load() error {
 	data, err := os.ReadFile(s.filePath)
 	if err != nil {
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"lightsync/internal/lights"
+)
+
+// newTestStore returns a store holding cfg that saves to a temp directory.
+func newTestStore(t *testing.T, cfg Config) *Store {
+	t.Helper()
+	return &Store{config: cfg, filePath: filepath.Join(t.TempDir(), "config.json")}
+}
+
+// sceneDeviceIDs returns the sorted keys of a scene's device map.
+func sceneDeviceIDs(sc Scene) []string {
+	ids := make([]string, 0, len(sc.Devices))
+	for id := range sc.Devices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestMigrateIDList(t *testing.T) {
+	tests := []struct {
+		name string
+		list []string
+		ids  map[string]string
+		want []string
+	}{
+		{name: "empty", list: nil, ids: map[string]string{"a": "b"}, want: []string{}},
+		{name: "rename in place", list: []string{"x", "a", "y"}, ids: map[string]string{"a": "b"}, want: []string{"x", "b", "y"}},
+		{name: "unrelated IDs kept", list: []string{"x", "y"}, ids: map[string]string{"a": "b"}, want: []string{"x", "y"}},
+		{name: "new ID already listed later", list: []string{"a", "b"}, ids: map[string]string{"a": "b"}, want: []string{"b"}},
+		{name: "new ID already listed earlier", list: []string{"b", "x", "a"}, ids: map[string]string{"a": "b"}, want: []string{"b", "x"}},
+		{name: "two IDs merge into one", list: []string{"a", "c"}, ids: map[string]string{"a": "b", "c": "b"}, want: []string{"b"}},
+		{name: "renames are not chained", list: []string{"a", "b"}, ids: map[string]string{"a": "b", "b": "c"}, want: []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := migrateIDList(tt.list, tt.ids); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStore_MigrateDeviceIDs(t *testing.T) {
+	on := lights.DeviceState{On: true, Brightness: 1}
+	dim := lights.DeviceState{On: true, Brightness: 0.2}
+	cal := &lights.Calibration{RedGain: 0.9, WhitePoint: 5000}
+	manual := []lights.ManualAddress{{Brand: lights.BrandGovee, Address: "192.168.1.20"}}
+
+	tests := []struct {
+		name string
+		cfg  Config
+		ids  map[string]string
+		// What the store holds afterwards.
+		devices     []string
+		calibration map[string]*lights.Calibration
+		scenes      map[string][]string
+		sceneStates map[string]lights.DeviceState // keyed "scene/device"
+		screenSync  []string
+		groups      map[string][]string
+	}{
+		{
+			name: "rewrites every reference",
+			cfg: Config{
+				Devices: []lights.Device{{ID: "govee:192.168.1.20", Calibration: cal}, {ID: "lifx:1"}},
+				Scenes: []Scene{
+					{ID: "evening", Devices: map[string]lights.DeviceState{"govee:192.168.1.20": dim, "lifx:1": on}},
+					{ID: "sync", ScreenSync: &ScreenSyncConfig{DeviceIDs: []string{"lifx:1", "govee:192.168.1.20"}}},
+				},
+				Groups:          []lights.Group{{ID: "desk", DeviceIDs: []string{"govee:192.168.1.20"}}},
+				ManualAddresses: manual,
+			},
+			ids:         map[string]string{"govee:192.168.1.20": "govee:aabbcc"},
+			devices:     []string{"govee:aabbcc", "lifx:1"},
+			calibration: map[string]*lights.Calibration{"govee:aabbcc": cal},
+			scenes:      map[string][]string{"evening": {"govee:aabbcc", "lifx:1"}, "sync": {}},
+			sceneStates: map[string]lights.DeviceState{"evening/govee:aabbcc": dim},
+			screenSync:  []string{"lifx:1", "govee:aabbcc"},
+			groups:      map[string][]string{"desk": {"govee:aabbcc"}},
+		},
+		{
+			name: "new ID already present",
+			cfg: Config{
+				Devices: []lights.Device{{ID: "govee:aabbcc"}, {ID: "govee:192.168.1.20", Calibration: cal}},
+				Scenes: []Scene{
+					{ID: "evening", Devices: map[string]lights.DeviceState{"govee:aabbcc": on, "govee:192.168.1.20": dim}},
+					{ID: "sync", ScreenSync: &ScreenSyncConfig{DeviceIDs: []string{"govee:192.168.1.20", "govee:aabbcc"}}},
+				},
+				Groups:          []lights.Group{{ID: "desk", DeviceIDs: []string{"govee:aabbcc", "govee:192.168.1.20"}}},
+				ManualAddresses: manual,
+			},
+			ids:         map[string]string{"govee:192.168.1.20": "govee:aabbcc"},
+			devices:     []string{"govee:aabbcc"},
+			calibration: map[string]*lights.Calibration{"govee:aabbcc": cal},
+			scenes:      map[string][]string{"evening": {"govee:aabbcc"}, "sync": {}},
+			sceneStates: map[string]lights.DeviceState{"evening/govee:aabbcc": on},
+			screenSync:  []string{"govee:aabbcc"},
+			groups:      map[string][]string{"desk": {"govee:aabbcc"}},
+		},
+		{
+			name: "unknown IDs change nothing",
+			cfg: Config{
+				Devices:         []lights.Device{{ID: "lifx:1", Calibration: cal}},
+				Scenes:          []Scene{{ID: "evening", Devices: map[string]lights.DeviceState{"lifx:1": on}}},
+				Groups:          []lights.Group{{ID: "desk", DeviceIDs: []string{"lifx:1"}}},
+				ManualAddresses: manual,
+			},
+			ids:         map[string]string{"govee:192.168.1.20": "govee:aabbcc"},
+			devices:     []string{"lifx:1"},
+			calibration: map[string]*lights.Calibration{"lifx:1": cal},
+			scenes:      map[string][]string{"evening": {"lifx:1"}},
+			sceneStates: map[string]lights.DeviceState{"evening/lifx:1": on},
+			groups:      map[string][]string{"desk": {"lifx:1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, tt.cfg)
+			if err := s.MigrateDeviceIDs(tt.ids); err != nil {
+				t.Fatalf("MigrateDeviceIDs: %v", err)
+			}
+
+			var devices []string
+			for _, d := range s.GetDevices() {
+				devices = append(devices, d.ID)
+				if want := tt.calibration[d.ID]; !reflect.DeepEqual(d.Calibration, want) {
+					t.Fatalf("%s: expected calibration %+v, got %+v", d.ID, want, d.Calibration)
+				}
+			}
+			if !reflect.DeepEqual(devices, tt.devices) {
+				t.Fatalf("expected devices %v, got %v", tt.devices, devices)
+			}
+
+			for _, sc := range s.GetScenes() {
+				if got := sceneDeviceIDs(sc); !reflect.DeepEqual(got, tt.scenes[sc.ID]) {
+					t.Fatalf("scene %s: expected devices %v, got %v", sc.ID, tt.scenes[sc.ID], got)
+				}
+				for id, st := range sc.Devices {
+					if want, ok := tt.sceneStates[sc.ID+"/"+id]; ok && !reflect.DeepEqual(st, want) {
+						t.Fatalf("scene %s: expected %s to keep %+v, got %+v", sc.ID, id, want, st)
+					}
+				}
+				if sc.ScreenSync != nil && !reflect.DeepEqual(sc.ScreenSync.DeviceIDs, tt.screenSync) {
+					t.Fatalf("scene %s: expected screen sync devices %v, got %v", sc.ID, tt.screenSync, sc.ScreenSync.DeviceIDs)
+				}
+			}
+
+			for _, g := range s.GetGroups() {
+				if !reflect.DeepEqual(g.DeviceIDs, tt.groups[g.ID]) {
+					t.Fatalf("group %s: expected members %v, got %v", g.ID, tt.groups[g.ID], g.DeviceIDs)
+				}
+			}
+
+			// Manual addresses are keyed by brand and address, not device ID.
+			if got := s.GetManualAddresses(); !reflect.DeepEqual(got, manual) {
+				t.Fatalf("expected manual addresses %v, got %v", manual, got)
+			}
+
+			// The migration is saved.
+			reloaded := &Store{filePath: s.filePath}
+			if err := reloaded.load(); err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if !reflect.DeepEqual(reloaded.GetGroups(), s.GetGroups()) {
+				t.Fatalf("expected the saved groups %v, got %v", s.GetGroups(), reloaded.GetGroups())
+			}
+		})
+	}
+}
+
+func TestStore_DeleteGroup(t *testing.T) {
+	on := lights.DeviceState{On: true, Brightness: 1}
+	desk, shelf := lights.GroupRef("desk"), lights.GroupRef("shelf")
+
+	tests := []struct {
+		name       string
+		id         string
+		groups     []string
+		scenes     map[string][]string
+		screenSync []string
+	}{
+		{
+			name:       "removes the group and its references",
+			id:         "desk",
+			groups:     []string{"shelf"},
+			scenes:     map[string][]string{"evening": {shelf, "lifx:1"}, "sync": {}},
+			screenSync: []string{"lifx:1", shelf},
+		},
+		{
+			name:       "unknown group leaves scenes alone",
+			id:         "kitchen",
+			groups:     []string{"desk", "shelf"},
+			scenes:     map[string][]string{"evening": {desk, shelf, "lifx:1"}, "sync": {}},
+			screenSync: []string{desk, "lifx:1", shelf},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, Config{
+				Groups: []lights.Group{{ID: "desk"}, {ID: "shelf"}},
+				Scenes: []Scene{
+					{ID: "evening", Devices: map[string]lights.DeviceState{desk: on, shelf: on, "lifx:1": on}},
+					{ID: "sync", ScreenSync: &ScreenSyncConfig{DeviceIDs: []string{desk, "lifx:1", shelf}}},
+				},
+			})
+			if err := s.DeleteGroup(tt.id); err != nil {
+				t.Fatalf("DeleteGroup: %v", err)
+			}
+
+			var groups []string
+			for _, g := range s.GetGroups() {
+				groups = append(groups, g.ID)
+			}
+			if !reflect.DeepEqual(groups, tt.groups) {
+				t.Fatalf("expected groups %v, got %v", tt.groups, groups)
+			}
+			for _, sc := range s.GetScenes() {
+				if got := sceneDeviceIDs(sc); !reflect.DeepEqual(got, tt.scenes[sc.ID]) {
+					t.Fatalf("scene %s: expected devices %v, got %v", sc.ID, tt.scenes[sc.ID], got)
+				}
+				if sc.ScreenSync != nil && !reflect.DeepEqual(sc.ScreenSync.DeviceIDs, tt.screenSync) {
+					t.Fatalf("scene %s: expected screen sync devices %v, got %v", sc.ID, tt.screenSync, sc.ScreenSync.DeviceIDs)
+				}
+			}
+		})
+	}
+}